@@ -23,4 +23,39 @@ const (
 	NvidiaResourceName      = "nvidia.com/gpu"
 	NvidiaMigResourcePrefix = "nvidia.com/mig-"
 	MIG_UUID_PREFIX         = "MIG-"
+
+	// DuplicateLabelMode values select how attributes colliding with fixed labels are rendered
+	DuplicateLabelDrop   = "drop"
+	DuplicateLabelPrefix = "prefix"
+	DuplicateLabelError  = "error"
+
+	// LabelEscaping values select how quotes, backslashes and newlines in label values are rendered
+	LabelEscapingStrict = "strict"
+	LabelEscapingStrip  = "strip"
+
+	// LineEnding values select the line terminator of the rendered metrics
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+
+	// RenderMode values select how the metrics of the entity groups are rendered
+	RenderModeTemplate = "template"
+	RenderModeRegistry = "registry"
+
+	// CohortRule fields matched by the rule pattern
+	CohortMatchUUID  = "uuid"
+	CohortMatchModel = "model"
+
+	// TenantLabelMode values select how the GPU series are marked with the tenant owning them
+	TenantLabelModeNone   = "none"
+	TenantLabelModeLabel  = "label"
+	TenantLabelModePrefix = "prefix"
+
+	// HPCJobMappingEncoding values select how the lines of the HPC job mapping files are decoded
+	HPCJobMappingEncodingNone         = "none"
+	HPCJobMappingEncodingBase64Fields = "base64-fields"
+	HPCJobMappingEncodingBase64Line   = "base64-line"
+
+	// IncompleteMIGMode values select how the metrics of MIG instances whose instance doesn't resolve are mapped
+	IncompleteMIGModeParent = "parent"
+	IncompleteMIGModeMark   = "mark"
 )
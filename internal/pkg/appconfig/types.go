@@ -17,6 +17,9 @@
 package appconfig
 
 import (
+	"regexp"
+	"time"
+
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 )
 
@@ -36,6 +39,43 @@ type DumpConfig struct {
 	Compression bool   `yaml:"compression" json:"compression"` // Use gzip compression for dump files
 }
 
+// LegacyMetric describes a legacy-named series emitted in addition to a DCGM field
+type LegacyMetric struct {
+	Name       string // Legacy metric name, e.g. nvidia_gpu_duty_cycle
+	Multiplier int    // Multiplier applied to the DCGM value
+}
+
+// LinkDirection is the pair of fields of the two directions of link traffic, rendered under a single name
+type LinkDirection struct {
+	TXField string // DCGM field of the transmitted traffic
+	RXField string // DCGM field of the received traffic
+}
+
+// UtilizationBand is a coarse band of GPU utilization
+type UtilizationBand struct {
+	Label      string  // Value of the band label
+	UpperBound float64 // Highest utilization in the band, in percent
+}
+
+// Tenant is the ownership of GPUs by a tenant, whose GPU metrics are served on their own endpoint
+type Tenant struct {
+	GPUUUIDs []string // GPUs owned by the tenant
+	Owners   []string // Values of the tenant attribute, e.g. accounts, owned by the tenant
+}
+
+// CohortRule assigns the GPUs whose UUID or model name matches a glob pattern to a cohort
+type CohortRule struct {
+	Field   string // CohortMatchUUID or CohortMatchModel
+	Pattern string // Glob pattern, e.g. GPU-1234* or *A100*
+	Cohort  string
+}
+
+// ModelNameRule renders the GPU model names matching a regular expression as a canonical name
+type ModelNameRule struct {
+	Pattern *regexp.Regexp
+	Name    string
+}
+
 type Config struct {
 	CollectorsFile             string
 	Address                    string
@@ -65,8 +105,103 @@ type Config struct {
 	DCGMLogLevel               string
 	PodResourcesKubeletSocket  string
 	HPCJobMappingDir           string
+	HPCJobMappingSocket        string         // Unix socket answering GPU to job queries
+	HPCJobMappingSocketTTL     time.Duration  // How long socket answers are cached, at least HPCJobMappingSocketRefresh
+	HPCJobMappingSocketRefresh time.Duration  // Minimum interval between two socket queries, whatever the TTL
+	HPCJobMappingDB            string         // SQLite database with the GPU to job mapping
+	HPCJobMappingDBQuery       string         // Query returning gpu_uuid, jobid, userid rows
+	HPCJobMappingDBTTL         time.Duration  // How long query results are cached, at least HPCJobMappingDBRefresh
+	HPCJobMappingDBRefresh     time.Duration  // Minimum interval between two database queries, whatever the TTL
+	HPCJobMappingURL           string         // URL of a cluster-wide allocation service with the GPU to job mapping
+	HPCJobMappingURLTTL        time.Duration  // How long the mapping fetched from the URL is cached, at least HPCJobMappingURLRefresh
+	HPCJobMappingURLRefresh    time.Duration  // Minimum interval between two requests to the URL, whatever the TTL
+	HPCJobMappingConcurrency   int            // Concurrent queries of each mapper to its socket, database or URL backend
+	HPCMappingLingerDuration   time.Duration  // How long a removed job mapping keeps applying
+	HPCMappingStaleAfter       time.Duration  // Age of the newest mapping file past which job series are left out
+	HPCJobPlaceholder          string         // Job attribute of GPUs without a job, none when empty
+	HPCJobMappingNodeFile      string         // Mapping file with the jobs of GPUs without a file of their own
+	HPCJobMappingManifest      string         // Mapping file listing the files to read and their generation
+	HPCJobMappingGRESFile      string         // Mapping file with the jobs and the Slurm GRES strings of their GPUs
+	HPCMappingFilePattern      string         // Glob the names of the mapping files match, all files when empty
+	HPCJobMappingEncoding      string         // One of HPCJobMappingEncodingNone, HPCJobMappingEncodingBase64Fields, HPCJobMappingEncodingBase64Line
+	HPCIncompleteMIGMode       string         // One of IncompleteMIGModeParent, the default when empty, IncompleteMIGModeMark
+	HPCMPSPIDFile              string         // File with the job of each MPS client process
+	HPCMPSPmonFile             string         // File with the nvidia-smi pmon output listing the processes of each GPU
+	HPCMPSRefresh              time.Duration  // Minimum interval between two reads of the MPS files, read on every scrape when 0
+	HPCJobEnvVar               string         // Environment variable with the job of all the GPUs, in a per-job container
+	HPCUserEnvVar              string         // Environment variable with the user of the job, if any
+	HPCAccountEnvVar           string         // Environment variable with the account of the job, if any
+	HPCProcJobRegex            *regexp.Regexp // Extracts the job id of GPU processes from their cgroup, the processes are not scanned when nil
+	HPCProcRoot                string         // Mount point of the procfs scanned for GPU processes
+	HPCProcScanInterval        time.Duration  // Minimum interval between two scans of the processes
+	HPCIDRewriteRegex          *regexp.Regexp // Rewrites the mapped job ids, and other ids if configured, none when nil
+	HPCIDRewriteReplacement    string         // Replacement of the HPCIDRewriteRegex matches, with $1 style references
+	HPCIDRewriteAttributes     []string       // Attributes rewritten, among jobid, userid and account
+	HPCMappingFileAttribute    bool           // Record the mapping file of each mapped metric
+	HPCMappingFilesInfo        int            // Number of most recently modified mapping files listed by dcgm_hpc_mapping_file_info, none when 0
+	HPCSharingAttribute        bool           // Record whether the GPU of each mapped metric is exclusive to its job or shared
+	HPCCounterResetAttribute   bool           // Mark the counter samples lower than the previous scrape's
+	HPCEnergyCounter           bool           // Emit the GPU energy integrated from the power samples
+	HPCJobGPUSeconds           bool           // Emit the time each job had each GPU mapped to it
+	HPCMaxMappingFileBytes     int64          // Mapping files larger than this are skipped, no limit when 0
+	HPCMappingSettingsFile     string         // File overriding the mapping settings, re-read on SIGHUP
 	NvidiaResourceNames        []string
 	KubernetesVirtualGPUs      bool
+	KubernetesDeviceCheckpoint string     // Kubelet device plugin checkpoint with the devices of each pod
 	DumpConfig                 DumpConfig // Configuration for file-based dumps
 	KubernetesEnableDRA        bool
+	LegacyMetrics              map[string]LegacyMetric            // DCGM field name to legacy series
+	DuplicateLabelMode         string                             // One of DuplicateLabelDrop, DuplicateLabelPrefix, DuplicateLabelError
+	LabelEscaping              string                             // One of LabelEscapingStrict, LabelEscapingStrip
+	LineEnding                 string                             // One of LineEndingLF, LineEndingCRLF
+	RenderMode                 string                             // One of RenderModeTemplate, RenderModeRegistry
+	EnableOpenMetrics          bool                               // Serve /metrics as OpenMetrics, with the units of the fields, to the scrapers asking for it
+	StaticLabels               map[string]string                  // Labels added to every rendered series
+	HostnameOverrides          map[dcgm.Field_Entity_Group]string // Hostname label used instead of the metric's per group
+	EnableSelfMetrics          bool                               // Render metrics about the exporter itself
+	ScrapeHistoryCount         int                                // Number of rendered scrapes kept for /metrics/last
+	ScrapeHistoryMaxBytes      int                                // Total size bound of the kept scrapes
+	ScrapeFile                 string                             // File the output of each scrape is written to, for debugging
+	ScrapeFileMaxBytes         int                                // Size bound of the output written to ScrapeFile
+	EnableDeltaEndpoint        bool                               // Serve the series changed since the previous scrape on /metrics/delta
+	EnableChecksumTrailer      bool                               // End the scrapes of /metrics with a comment holding the checksum of the body
+	CollapseDuplicateSeries    bool                               // Drop the series duplicating a series of the same group, keeping the first
+	EnableFieldTimestamps      bool                               // Timestamp the samples with the time DCGM last updated their field
+	EnableEntityKindLabel      bool                               // Label GPU series with entity_kind="gpu" or "mig"
+	PromotedFields             []string                           // DCGM fields rendered as labels of the other series of their entity
+	FieldIDLabel               string                             // Label carrying the DCGM field id of a series, none when empty
+	FieldIDLabelTypes          []string                           // Prometheus types of the series getting FieldIDLabel, all when empty
+	FieldAliases               map[string][]string                // Series name to the DCGM fields rendered under it, in precedence order
+	LinkDirections             map[string]LinkDirection           // Series name to the TX and RX fields of link and switch series rendered under it
+	EnableNUMANodeLabel        bool                               // Label GPU series with the NUMA node of the GPU
+	EnableSerialLabel          bool                               // Label GPU series with the serial number of the GPU
+	EnableDeviceMinor          bool                               // Resolve the /dev/nvidia<minor> device minor number of the GPUs
+	MinorNumberLabel           string                             // Name of the minor number label of the alternate and job series, minor_number when empty
+	GPULabelOrder              []string                           // Order of the fixed labels of GPU series, the others follow
+	OmitEmptyGPULabels         bool                               // Leave out the pci_bus_id, device and modelName labels when empty
+	EnablePowerLimitLabel      bool                               // Label GPU series with the enforced power limit of the GPU
+	EnableThrottleReasonLabel  bool                               // Label GPU series with the decoded clock throttle reasons of the GPU
+	NodeGPUUtilBuckets         []float64                          // Upper bounds of the buckets of the node GPU utilization histogram, disabled when empty
+	PowerZones                 map[string]string                  // GPU UUID or hostname to the power zone of the GPU power sums, disabled when empty
+	UtilizationBands           []UtilizationBand                  // Bands of the util_band label of GPU series, in increasing order, disabled when empty
+	EnableHealthLabel          bool                               // Label GPU series with the worst health status of the GPU
+	EnableMIGInstanceCount     bool                               // Render the number of MIG instances of each GPU
+	EnableMIGNoInstances       bool                               // Render a sentinel series for the GPUs in MIG mode without instances
+	Tenants                    map[string]Tenant                  // Tenant name to the GPUs it owns
+	TenantAttribute            string                             // Attribute or label of GPU metrics naming their owner
+	TenantLabelMode            string                             // One of TenantLabelModeNone, TenantLabelModeLabel, TenantLabelModePrefix
+	CohortRules                []CohortRule                       // Rules assigning GPUs to cohorts, the first match wins
+	DefaultCohort              string                             // Cohort of the GPUs matching no rule, none when empty
+	ModelNameRules             []ModelNameRule                    // Rules normalizing the rendered GPU model names, the first match wins
+	EnabledEntityGroups        []dcgm.Field_Entity_Group          // The only entity groups rendered, all when empty
+	IntegerFields              []string                           // DCGM fields rendered as integers, besides the inferred ones
+	RoundedFields              []string                           // Fields whose values are rounded to the nearest integer
+	SummaryFields              []string                           // GPU fields rendered as summaries of their sampled values
+	SummaryWindow              time.Duration                      // Window of the sampled values of the summary quantiles, since the previous collection when 0
+	EnableGenerationLabel      bool                               // Label every series with the collection generation, for debugging
+	RenderDeadline             time.Duration                      // Time allowed to render the groups of a scrape, no limit when 0
+
+	// Sampling renders part of the metrics to cut the scrape size, for testing only
+	SampleRate   float64                              // Fraction of the entities rendered, all when 0 or 1
+	SampleFields map[dcgm.Field_Entity_Group][]string // The only fields rendered, per group
 }
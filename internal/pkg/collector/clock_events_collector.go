@@ -82,6 +82,23 @@ func (enm clockEventBitmask) String() string {
 	return clockEventToString[enm]
 }
 
+// ClockEventReasons returns the names of the reasons set in a DCGM_FI_DEV_CLOCKS_EVENT_REASONS
+// bitmask, in bit order. The bits without a name are returned in hexadecimal, e.g. 0x200.
+func ClockEventReasons(bitmask int64) []string {
+	var reasons []string
+	for bit := clockEventBitmask(1); bit > 0 && bit <= clockEventBitmask(bitmask); bit <<= 1 {
+		if clockEventBitmask(bitmask)&bit == 0 {
+			continue
+		}
+		if name, ok := clockEventToString[bit]; ok {
+			reasons = append(reasons, name)
+		} else {
+			reasons = append(reasons, fmt.Sprintf("%#x", int64(bit)))
+		}
+	}
+	return reasons
+}
+
 func (c *clockEventsCollector) GetMetrics() (MetricsByCounter, error) {
 	return c.expCollector.getMetrics()
 }
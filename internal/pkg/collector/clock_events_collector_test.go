@@ -796,3 +796,9 @@ func Test_clockEventsCollector_GetMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestClockEventReasons(t *testing.T) {
+	assert.Nil(t, ClockEventReasons(0))
+	assert.Equal(t, []string{"power_cap", "sw_thermal"}, ClockEventReasons(0x24))
+	assert.Equal(t, []string{"gpu_idle", "0x200"}, ClockEventReasons(0x201), "bits without a name are kept")
+}
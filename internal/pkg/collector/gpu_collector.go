@@ -17,11 +17,14 @@
 package collector
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 
@@ -42,6 +45,16 @@ type DCGMCollector struct {
 	deviceWatchList          devicewatchlistmanager.WatchList
 	hostname                 string
 	replaceBlanksInModelName bool
+
+	// summaryFields are the fields whose sampled values are attached to their metrics: those of
+	// the last sampleWindow, and the sum and the number of all the values fetched from samplesSince on
+	summaryFields map[dcgm.Short]bool
+	samplesSince  time.Time
+	sampleWindow  time.Duration
+	samples       map[fieldSampleKey]*fieldSamples
+
+	// gpuUUIDs are the UUIDs of the GPUs of the node by PCI address, the remote endpoints of links
+	gpuUUIDs map[pciAddress]string
 }
 
 func NewDCGMCollector(
@@ -59,6 +72,9 @@ func NewDCGMCollector(
 		deviceWatchList: deviceWatchList,
 		hostname:        hostname,
 	}
+	if deviceWatchList.DeviceInfo().InfoType() == dcgm.FE_LINK {
+		collector.gpuUUIDs = gpuUUIDsByPCIAddress()
+	}
 
 	if config == nil {
 		slog.Warn("Config is empty")
@@ -67,6 +83,12 @@ func NewDCGMCollector(
 
 	collector.useOldNamespace = config.UseOldNamespace
 	collector.replaceBlanksInModelName = config.ReplaceBlanksInModelName
+	if len(config.SummaryFields) > 0 && deviceWatchList.DeviceInfo().InfoType() == dcgm.FE_GPU {
+		collector.summaryFields = summaryFieldIDs(c, config.SummaryFields)
+		collector.samplesSince = time.Now()
+		collector.sampleWindow = config.SummaryWindow
+		collector.samples = map[fieldSampleKey]*fieldSamples{}
+	}
 
 	cleanups, err := deviceWatchList.Watch()
 	if err != nil {
@@ -89,6 +111,22 @@ func (c *DCGMCollector) GetMetrics() (MetricsByCounter, error) {
 
 	metrics := make(MetricsByCounter)
 
+	var fabricDomain string
+	var remoteUUIDs map[uint]string
+	switch c.deviceWatchList.DeviceInfo().InfoType() {
+	case dcgm.FE_SWITCH, dcgm.FE_LINK:
+		fabricDomain = nodeFabricDomain()
+	}
+	if c.deviceWatchList.DeviceInfo().InfoType() == dcgm.FE_LINK {
+		remoteUUIDs = c.linkRemoteUUIDs(monitoringInfo)
+	}
+
+	if len(c.summaryFields) > 0 {
+		if err := c.getSamples(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
 	for _, mi := range monitoringInfo {
 		var vals []dcgm.FieldValue_v1
 		var err error
@@ -113,7 +151,8 @@ func (c *DCGMCollector) GetMetrics() (MetricsByCounter, error) {
 		// InstanceInfo will be nil for GPUs
 		switch c.deviceWatchList.DeviceInfo().InfoType() {
 		case dcgm.FE_SWITCH, dcgm.FE_LINK:
-			toSwitchMetric(metrics, vals, c.counters, mi, c.useOldNamespace, c.hostname)
+			remoteUUID := remoteUUIDs[linkEntityID(mi.Entity.EntityId, mi.ParentId)]
+			toSwitchMetric(metrics, vals, c.counters, mi, c.useOldNamespace, c.hostname, fabricDomain, remoteUUID)
 		case dcgm.FE_CPU, dcgm.FE_CPU_CORE:
 			toCPUMetric(metrics, vals, c.counters, mi, c.useOldNamespace, c.hostname)
 		default:
@@ -125,12 +164,147 @@ func (c *DCGMCollector) GetMetrics() (MetricsByCounter, error) {
 				c.useOldNamespace,
 				c.hostname,
 				c.replaceBlanksInModelName)
+			if len(c.summaryFields) > 0 {
+				c.attachSamples(metrics, mi)
+			}
 		}
 	}
 
 	return metrics, nil
 }
 
+// fieldSampleKey identifies the values of a field of an entity
+type fieldSampleKey struct {
+	entity  dcgm.GroupEntityPair
+	fieldID dcgm.Short
+}
+
+// fieldSamples are the values sampled of a field of an entity: those of the sample window, for the
+// quantiles, and the sum and the number of all of them, which only grow
+type fieldSamples struct {
+	window []timedSample
+	sum    float64
+	count  uint64
+}
+
+// timedSample is a sampled value and the time it was sampled
+type timedSample struct {
+	at    time.Time
+	value float64
+}
+
+// summaryFieldIDs returns the ids of the named fields among the counters
+func summaryFieldIDs(c []counters.Counter, names []string) map[dcgm.Short]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	ids := map[dcgm.Short]bool{}
+	for _, counter := range c {
+		if slices.Contains(names, counter.FieldName) {
+			ids[counter.FieldID] = true
+		}
+	}
+	return ids
+}
+
+// getSamples fetches the values of the summary fields DCGM sampled since the previous collection
+// and adds them to the samples of their entity and field.
+func (c *DCGMCollector) getSamples(now time.Time) error {
+	c.startSampleWindow(now)
+	since := c.samplesSince
+	for _, group := range c.deviceWatchList.DeviceGroups() {
+		values, next, err := dcgmprovider.Client().GetValuesSince(group, c.deviceWatchList.DeviceFieldGroup(),
+			c.samplesSince)
+		if err != nil {
+			return err
+		}
+		if next.After(since) {
+			since = next
+		}
+		for _, val := range values {
+			if val.Status != 0 || !c.summaryFields[val.FieldID] {
+				continue
+			}
+			v, ok := sampleValue(val)
+			if !ok {
+				continue
+			}
+			key := fieldSampleKey{
+				entity:  dcgm.GroupEntityPair{EntityGroupId: val.EntityGroupId, EntityId: val.EntityID},
+				fieldID: val.FieldID,
+			}
+			c.addSample(key, time.UnixMicro(val.TS), v)
+		}
+	}
+	c.samplesSince = since
+	return nil
+}
+
+// startSampleWindow drops the samples older than the sample window from now, or all of them
+// without a window, so that the window holds the values of this collection only.
+func (c *DCGMCollector) startSampleWindow(now time.Time) {
+	cutoff := now.Add(-c.sampleWindow)
+	for _, samples := range c.samples {
+		samples.window = slices.DeleteFunc(samples.window, func(s timedSample) bool {
+			return c.sampleWindow <= 0 || s.at.Before(cutoff)
+		})
+	}
+}
+
+// addSample adds a value sampled at the time to the samples of the entity and field
+func (c *DCGMCollector) addSample(key fieldSampleKey, at time.Time, value float64) {
+	samples, ok := c.samples[key]
+	if !ok {
+		samples = &fieldSamples{}
+		c.samples[key] = samples
+	}
+	samples.window = append(samples.window, timedSample{at: at, value: value})
+	samples.sum += value
+	samples.count++
+}
+
+// sampleValue returns the value of a numeric sample, unless it is blank
+func sampleValue(val dcgm.FieldValue_v2) (float64, bool) {
+	if val.FieldType != dcgm.DCGM_FT_INT64 && val.FieldType != dcgm.DCGM_FT_DOUBLE {
+		return 0, false
+	}
+	v := toString(dcgm.FieldValue_v1{FieldID: val.FieldID, FieldType: val.FieldType, TS: val.TS, Value: val.Value})
+	if v == skipDCGMValue {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	return f, err == nil
+}
+
+// attachSamples sets the samples of the entity on its metrics of the summary fields. The metrics
+// of the entity are those of its GPU, and instance if it is one.
+func (c *DCGMCollector) attachSamples(metrics MetricsByCounter, mi devicemonitoring.Info) {
+	gpu := fmt.Sprintf("%d", mi.DeviceInfo.GPU)
+	instance := ""
+	if mi.InstanceInfo != nil {
+		instance = fmt.Sprintf("%d", mi.InstanceInfo.Info.NvmlInstanceId)
+	}
+	for counter, values := range metrics {
+		if !c.summaryFields[counter.FieldID] {
+			continue
+		}
+		samples, ok := c.samples[fieldSampleKey{entity: mi.Entity, fieldID: counter.FieldID}]
+		if !ok {
+			continue
+		}
+		window := make([]float64, len(samples.window))
+		for i, sample := range samples.window {
+			window[i] = sample.value
+		}
+		for i := range values {
+			if values[i].GPU == gpu && values[i].GPUInstanceID == instance {
+				values[i].Samples = window
+				values[i].SampleSum, values[i].SampleCount = samples.sum, samples.count
+			}
+		}
+	}
+}
+
 func findCounterField(c []counters.Counter, fieldID dcgm.Short) (counters.Counter, error) {
 	for i := 0; i < len(c); i++ {
 		if c[i].FieldID == fieldID {
@@ -141,11 +315,163 @@ func findCounterField(c []counters.Counter, fieldID dcgm.Short) (counters.Counte
 	return counters.Counter{}, fmt.Errorf("could not find counter corresponding to field ID '%d'", fieldID)
 }
 
+// fabricDomainFields are the GPU fields identifying the NVLink fabric domain, by preference
+var fabricDomainFields = []dcgm.Short{dcgm.DCGM_FI_DEV_FABRIC_CLUSTER_UUID, dcgm.DCGM_FI_DEV_FABRIC_CLIQUE_ID}
+
+// nodeFabricDomain returns the NVLink fabric domain of the GPUs of the node, empty when unknown.
+// DCGM reports the fabric cluster UUID and clique ID of GPUs only, and the switches and links of
+// the node belong to the domain of the GPUs they connect.
+func nodeFabricDomain() string {
+	gpus, err := dcgmprovider.Client().GetEntityGroupEntities(dcgm.FE_GPU)
+	if err != nil || len(gpus) == 0 {
+		return ""
+	}
+	entities := make([]dcgm.GroupEntityPair, len(gpus))
+	for i, gpu := range gpus {
+		entities[i] = dcgm.GroupEntityPair{EntityGroupId: dcgm.FE_GPU, EntityId: gpu}
+	}
+	values, err := dcgmprovider.Client().EntitiesGetLatestValues(entities, fabricDomainFields,
+		dcgm.DCGM_FV_FLAG_LIVE_DATA)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Could not read the fabric domain of the GPUs; err: %v", err))
+		return ""
+	}
+	return fabricDomainOf(values)
+}
+
+// fabricDomainOf returns the first fabric cluster UUID among the values, or the first clique ID
+// when there is none
+func fabricDomainOf(values []dcgm.FieldValue_v2) string {
+	for _, fieldID := range fabricDomainFields {
+		for _, val := range values {
+			if val.FieldID != fieldID {
+				continue
+			}
+			v := toString(dcgm.FieldValue_v1{FieldID: val.FieldID, FieldType: val.FieldType, TS: val.TS, Value: val.Value})
+			if v != skipDCGMValue && v != FailedToConvert && v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// linkRemotePCIFields are the fields of the PCI address of the remote endpoint of a link
+var linkRemotePCIFields = []dcgm.Short{
+	dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_DOMAIN,
+	dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_BUS,
+	dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_DEVICE,
+	dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_FUNCTION,
+}
+
+// pciAddress is the domain, bus, device and function of a PCI device
+type pciAddress [4]int64
+
+// parsePCIAddress parses a PCI bus id such as 00000000:07:00.0
+func parsePCIAddress(busID string) (pciAddress, bool) {
+	var a pciAddress
+	if _, err := fmt.Sscanf(busID, "%x:%x:%x.%x", &a[0], &a[1], &a[2], &a[3]); err != nil {
+		return pciAddress{}, false
+	}
+	return a, true
+}
+
+// gpuUUIDsByPCIAddress returns the UUIDs of the GPUs of the node by PCI address
+func gpuUUIDsByPCIAddress() map[pciAddress]string {
+	gpus, err := dcgmprovider.Client().GetEntityGroupEntities(dcgm.FE_GPU)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Could not list the GPUs the links connect to; err: %v", err))
+		return nil
+	}
+	uuids := make(map[pciAddress]string, len(gpus))
+	for _, gpu := range gpus {
+		device, err := dcgmprovider.Client().GetDeviceInfo(gpu)
+		if err != nil {
+			continue
+		}
+		if address, ok := parsePCIAddress(device.PCI.BusID); ok {
+			uuids[address] = device.UUID
+		}
+	}
+	return uuids
+}
+
+// linkEntityID returns the entity id of the link of the switch, packed as by dcgm.LinkGetLatestValues
+func linkEntityID(index, parentID uint) uint {
+	return uint(binary.LittleEndian.Uint32([]byte{uint8(dcgm.FE_SWITCH), uint8(index), uint8(parentID), 0}))
+}
+
+// linkRemoteUUIDs returns the UUIDs of the GPUs at the remote end of the links, by link entity id.
+// The links to another switch, or whose remote PCI address is unknown, are left out.
+func (c *DCGMCollector) linkRemoteUUIDs(monitoringInfo []devicemonitoring.Info) map[uint]string {
+	if len(c.gpuUUIDs) == 0 {
+		return nil
+	}
+	var entities []dcgm.GroupEntityPair
+	for _, mi := range monitoringInfo {
+		entities = append(entities, dcgm.GroupEntityPair{
+			EntityGroupId: dcgm.FE_LINK, EntityId: linkEntityID(mi.Entity.EntityId, mi.ParentId),
+		})
+	}
+	if len(entities) == 0 {
+		return nil
+	}
+	values, err := dcgmprovider.Client().EntitiesGetLatestValues(entities, linkRemotePCIFields,
+		dcgm.DCGM_FV_FLAG_LIVE_DATA)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Could not read the remote PCI addresses of the links; err: %v", err))
+		return nil
+	}
+	return remoteUUIDsOf(values, c.gpuUUIDs)
+}
+
+// remoteUUIDsOf returns the UUIDs of the GPUs whose PCI address is the remote address of the link
+// values, by link entity id
+func remoteUUIDsOf(values []dcgm.FieldValue_v2, gpuUUIDs map[pciAddress]string) map[uint]string {
+	type linkAddress struct {
+		address pciAddress
+		known   int
+	}
+	addresses := map[uint]*linkAddress{}
+	for _, val := range values {
+		part := slices.Index(linkRemotePCIFields, val.FieldID)
+		if part < 0 {
+			continue
+		}
+		v1 := dcgm.FieldValue_v1{FieldID: val.FieldID, FieldType: val.FieldType, TS: val.TS, Value: val.Value}
+		if v := toString(v1); v == skipDCGMValue || v == FailedToConvert {
+			continue
+		}
+		if addresses[val.EntityID] == nil {
+			addresses[val.EntityID] = &linkAddress{}
+		}
+		addresses[val.EntityID].address[part] = v1.Int64()
+		addresses[val.EntityID].known++
+	}
+
+	uuids := map[uint]string{}
+	for link, address := range addresses {
+		if address.known != len(linkRemotePCIFields) {
+			continue
+		}
+		if uuid, ok := gpuUUIDs[address.address]; ok {
+			uuids[link] = uuid
+		}
+	}
+	return uuids
+}
+
+// toSwitchMetric adds the metrics of a switch or link, labelled with the fabric domain of the node.
+// The metrics of a link are labelled with the UUIDs of its endpoints: its switch and, if known, the
+// GPU at its remote end.
 func toSwitchMetric(
 	metrics MetricsByCounter,
 	values []dcgm.FieldValue_v1, c []counters.Counter, mi devicemonitoring.Info, useOld bool, hostname string,
+	fabricDomain, remoteUUID string,
 ) {
 	labels := map[string]string{}
+	var switchUUID string
+	var entityMetrics []Metric
 
 	for _, val := range values {
 		v := toString(val)
@@ -158,6 +484,10 @@ func toSwitchMetric(
 
 		if counter.IsLabel() {
 			labels[counter.FieldName] = v
+			// The NVSwitch UUID, unlike its index, is stable across reboots
+			if counter.FieldID == dcgm.DCGM_FI_DEV_NVSWITCH_DEVICE_UUID && v != skipDCGMValue {
+				switchUUID = v
+			}
 			continue
 		}
 		uuid := "UUID"
@@ -180,9 +510,20 @@ func toSwitchMetric(
 				Hostname:     hostname,
 				Labels:       labels,
 				Attributes:   nil,
+				UpdatedAt:    updatedAt(val),
 			}
 		}
 
+		entityMetrics = append(entityMetrics, m)
+	}
+
+	for _, m := range entityMetrics {
+		m.SwitchUUID = switchUUID
+		m.FabricDomain = fabricDomain
+		if mi.Entity.EntityGroupId == dcgm.FE_LINK {
+			m.LocalUUID = switchUUID
+			m.RemoteUUID = remoteUUID
+		}
 		metrics[m.Counter] = append(metrics[m.Counter], m)
 	}
 }
@@ -226,6 +567,7 @@ func toCPUMetric(
 				Hostname:     hostname,
 				Labels:       labels,
 				Attributes:   nil,
+				UpdatedAt:    updatedAt(val),
 			}
 		}
 
@@ -293,6 +635,7 @@ func toMetric(
 
 			Labels:     labels,
 			Attributes: attrs,
+			UpdatedAt:  updatedAt(val),
 		}
 		if instanceInfo != nil {
 			m.MigProfile = instanceInfo.ProfileName
@@ -317,6 +660,14 @@ func getGPUModel(d dcgm.Device, replaceBlanksInModelName bool) string {
 	return gpuModel
 }
 
+// updatedAt returns the time DCGM updated the value, or the zero time when it is unknown
+func updatedAt(value dcgm.FieldValue_v1) time.Time {
+	if value.TS <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(value.TS)
+}
+
 func toString(value dcgm.FieldValue_v1) string {
 	switch value.FieldType {
 	case dcgm.DCGM_FT_INT64:
@@ -17,15 +17,19 @@
 package collector
 
 import (
+	"encoding/binary"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/devicemonitoring"
 )
 
 func TestToMetric(t *testing.T) {
@@ -166,3 +170,175 @@ func TestToMetricWhenDCGM_FI_DEV_XID_ERRORSField(t *testing.T) {
 		})
 	}
 }
+
+func TestToSwitchMetricNVSwitchUUID(t *testing.T) {
+	intValue := [4096]byte{}
+	intValue[0] = 42
+	uuidValue := [4096]byte{}
+	copy(uuidValue[:], "SWX-00000000-0000-0000-0000-000000000000")
+
+	c := []counters.Counter{
+		{
+			FieldID:   dcgm.DCGM_FI_DEV_NVSWITCH_TEMPERATURE_CURRENT,
+			FieldName: "DCGM_FI_DEV_NVSWITCH_TEMPERATURE_CURRENT",
+			PromType:  "gauge",
+		},
+		{
+			FieldID:   dcgm.DCGM_FI_DEV_NVSWITCH_DEVICE_UUID,
+			FieldName: "DCGM_FI_DEV_NVSWITCH_DEVICE_UUID",
+			PromType:  "label",
+		},
+	}
+
+	mi := devicemonitoring.Info{
+		Entity: dcgm.GroupEntityPair{EntityGroupId: dcgm.FE_SWITCH, EntityId: 1},
+	}
+
+	// The label field is reported after the metric it should be attached to
+	values := []dcgm.FieldValue_v1{
+		{FieldID: dcgm.DCGM_FI_DEV_NVSWITCH_TEMPERATURE_CURRENT, FieldType: dcgm.DCGM_FT_INT64, Value: intValue},
+		{FieldID: dcgm.DCGM_FI_DEV_NVSWITCH_DEVICE_UUID, FieldType: dcgm.DCGM_FT_STRING, Value: uuidValue},
+	}
+
+	metrics := make(MetricsByCounter)
+	toSwitchMetric(metrics, values, c, mi, false, "", "", "")
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "SWX-00000000-0000-0000-0000-000000000000", metrics[c[0]][0].SwitchUUID)
+
+	metrics = make(MetricsByCounter)
+	toSwitchMetric(metrics, values[:1], c, mi, false, "", "", "")
+	assert.Len(t, metrics, 1)
+	assert.Empty(t, metrics[c[0]][0].SwitchUUID)
+
+	link := devicemonitoring.Info{
+		Entity:   dcgm.GroupEntityPair{EntityGroupId: dcgm.FE_LINK, EntityId: 3},
+		ParentId: 1,
+	}
+	metrics = make(MetricsByCounter)
+	toSwitchMetric(metrics, values, c, link, false, "", "fabric-a", "GPU-1")
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "SWX-00000000-0000-0000-0000-000000000000", metrics[c[0]][0].SwitchUUID,
+		"the links are labelled with the UUID of their switch")
+	assert.Equal(t, "fabric-a", metrics[c[0]][0].FabricDomain)
+	assert.Equal(t, "SWX-00000000-0000-0000-0000-000000000000", metrics[c[0]][0].LocalUUID)
+	assert.Equal(t, "GPU-1", metrics[c[0]][0].RemoteUUID)
+}
+
+func TestRemoteUUIDsOf(t *testing.T) {
+	value := func(link uint, fieldID dcgm.Short, v int64) dcgm.FieldValue_v2 {
+		var bytes [4096]byte
+		binary.LittleEndian.PutUint64(bytes[:], uint64(v))
+		return dcgm.FieldValue_v2{EntityID: link, FieldID: fieldID, FieldType: dcgm.DCGM_FT_INT64, Value: bytes}
+	}
+	address := func(link uint, domain, bus, device, function int64) []dcgm.FieldValue_v2 {
+		return []dcgm.FieldValue_v2{
+			value(link, dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_DOMAIN, domain),
+			value(link, dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_BUS, bus),
+			value(link, dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_DEVICE, device),
+			value(link, dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_FUNCTION, function),
+		}
+	}
+	gpu, ok := parsePCIAddress("00000000:07:00.0")
+	require.True(t, ok)
+	gpuUUIDs := map[pciAddress]string{gpu: "GPU-0"}
+
+	values := address(1, 0, 7, 0, 0)
+	values = append(values, address(2, 0, 0x41, 0, 0)...)
+	values = append(values, address(3, 0, 7, 0, 0)[:3]...)
+	values = append(values, value(3, dcgm.DCGM_FI_DEV_NVSWITCH_LINK_REMOTE_PCIE_FUNCTION, dcgm.DCGM_FT_INT64_BLANK))
+
+	assert.Equal(t, map[uint]string{1: "GPU-0"}, remoteUUIDsOf(values, gpuUUIDs),
+		"the links to another device or of unknown remote address have no remote UUID")
+}
+
+func TestFabricDomainOf(t *testing.T) {
+	clusterUUID := [4096]byte{}
+	copy(clusterUUID[:], "8d1c3a6e-0000-0000-0000-000000000001")
+	blankUUID := [4096]byte{}
+	copy(blankUUID[:], dcgm.DCGM_FT_STR_BLANK)
+	clique := [4096]byte{}
+	clique[0] = 7
+
+	cluster := dcgm.FieldValue_v2{
+		FieldID: dcgm.DCGM_FI_DEV_FABRIC_CLUSTER_UUID, FieldType: dcgm.DCGM_FT_STRING, Value: clusterUUID,
+	}
+	blank := dcgm.FieldValue_v2{
+		FieldID: dcgm.DCGM_FI_DEV_FABRIC_CLUSTER_UUID, FieldType: dcgm.DCGM_FT_STRING, Value: blankUUID,
+	}
+	cliqueID := dcgm.FieldValue_v2{FieldID: dcgm.DCGM_FI_DEV_FABRIC_CLIQUE_ID, FieldType: dcgm.DCGM_FT_INT64, Value: clique}
+
+	assert.Equal(t, "8d1c3a6e-0000-0000-0000-000000000001",
+		fabricDomainOf([]dcgm.FieldValue_v2{cliqueID, blank, cluster}), "the cluster UUID is preferred to the clique ID")
+	assert.Equal(t, "7", fabricDomainOf([]dcgm.FieldValue_v2{blank, cliqueID}))
+	assert.Empty(t, fabricDomainOf([]dcgm.FieldValue_v2{blank}))
+}
+
+func TestAttachSamples(t *testing.T) {
+	util := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_GPU_UTIL, FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge"}
+	temp := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_GPU_TEMP, FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge"}
+	metrics := MetricsByCounter{
+		util: {
+			{Counter: util, GPU: "0", Value: "30"},
+			{Counter: util, GPU: "0", GPUInstanceID: "1", Value: "10"},
+		},
+		temp: {{Counter: temp, GPU: "0", Value: "40"}},
+	}
+	gpu := dcgm.GroupEntityPair{EntityGroupId: dcgm.FE_GPU, EntityId: 0}
+	instance := dcgm.GroupEntityPair{EntityGroupId: dcgm.FE_GPU_I, EntityId: 7}
+	c := &DCGMCollector{
+		summaryFields: summaryFieldIDs([]counters.Counter{util, temp}, []string{"DCGM_FI_DEV_GPU_UTIL"}),
+		samples:       map[fieldSampleKey]*fieldSamples{},
+	}
+	now := time.Now()
+	for _, v := range []float64{20, 30} {
+		c.addSample(fieldSampleKey{entity: gpu, fieldID: util.FieldID}, now, v)
+	}
+	for _, v := range []float64{5, 10} {
+		c.addSample(fieldSampleKey{entity: instance, fieldID: util.FieldID}, now, v)
+	}
+
+	c.attachSamples(metrics, devicemonitoring.Info{Entity: gpu, DeviceInfo: dcgm.Device{GPU: 0}})
+	c.attachSamples(metrics, devicemonitoring.Info{
+		Entity:       instance,
+		DeviceInfo:   dcgm.Device{GPU: 0},
+		InstanceInfo: &deviceinfo.GPUInstanceInfo{Info: dcgm.MigEntityInfo{NvmlInstanceId: 1}},
+	})
+
+	assert.Equal(t, []float64{20, 30}, metrics[util][0].Samples)
+	assert.Equal(t, []float64{5, 10}, metrics[util][1].Samples, "instances get their own samples")
+	assert.Equal(t, 15.0, metrics[util][1].SampleSum)
+	assert.Nil(t, metrics[temp][0].Samples, "only the summary fields get samples")
+}
+
+func TestSampleWindow(t *testing.T) {
+	key := fieldSampleKey{entity: dcgm.GroupEntityPair{EntityGroupId: dcgm.FE_GPU}, fieldID: dcgm.DCGM_FI_DEV_GPU_UTIL}
+	now := time.Now()
+
+	for _, tt := range []struct {
+		name       string
+		window     time.Duration
+		wantWindow []float64
+	}{
+		{name: "collect interval", window: 30 * time.Second, wantWindow: []float64{20, 30, 40}},
+		{name: "no window", window: 0, wantWindow: []float64{40}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &DCGMCollector{sampleWindow: tt.window, samples: map[fieldSampleKey]*fieldSamples{}}
+			c.startSampleWindow(now.Add(-time.Minute))
+			c.addSample(key, now.Add(-time.Minute), 10)
+			c.startSampleWindow(now.Add(-20 * time.Second))
+			c.addSample(key, now.Add(-20*time.Second), 20)
+			c.addSample(key, now.Add(-10*time.Second), 30)
+			c.startSampleWindow(now)
+			c.addSample(key, now, 40)
+
+			var window []float64
+			for _, sample := range c.samples[key].window {
+				window = append(window, sample.value)
+			}
+			assert.Equal(t, tt.wantWindow, window, "the quantiles are of the values of the window")
+			assert.Equal(t, 100.0, c.samples[key].sum, "the sum is of all the values")
+			assert.Equal(t, uint64(4), c.samples[key].count)
+		})
+	}
+}
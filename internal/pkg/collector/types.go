@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 
@@ -73,6 +74,27 @@ type Metric struct {
 	Hostname      string            `json:"hostname"`
 	Labels        map[string]string `json:"labels"`
 	Attributes    map[string]string `json:"attributes"`
+
+	// SwitchUUID is the UUID of the NVSwitch of a switch or link metric, if known
+	SwitchUUID string `json:"switch_uuid,omitempty"`
+	// FabricDomain is the NVLink fabric (cluster) a switch or link belongs to, if known
+	FabricDomain string `json:"fabric_domain,omitempty"`
+	// LocalUUID and RemoteUUID are the UUIDs of the endpoints of a link, its switch and the GPU it
+	// connects to, if known
+	LocalUUID  string `json:"local_uuid,omitempty"`
+	RemoteUUID string `json:"remote_uuid,omitempty"`
+	// DeviceMinor is the minor number of the /dev/nvidia<minor> device of a GPU, if resolved; it
+	// may differ from the DCGM index of the GPU
+	DeviceMinor string `json:"device_minor,omitempty"`
+	// UpdatedAt is the time DCGM last updated the field value, if known
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	// Samples are the values DCGM sampled over the summary window, oldest first, of the
+	// fields rendered as summaries
+	Samples []float64 `json:"samples,omitempty"`
+	// SampleSum and SampleCount are the sum and the number of all the values DCGM sampled since the
+	// exporter started, of the fields rendered as summaries
+	SampleSum   float64 `json:"sample_sum,omitempty"`
+	SampleCount uint64  `json:"sample_count,omitempty"`
 }
 
 func (m Metric) GetIDOfType(idType appconfig.KubernetesGPUIDType) (string, error) {
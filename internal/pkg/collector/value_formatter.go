@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package collector
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+// ValueFormatter formats the values of metrics as they are rendered, e.g. to round them.
+// The value is formatted as reported by DCGM, "%d" for integers and "%f" for floats.
+type ValueFormatter interface {
+	FormatValue(counter counters.Counter, value string) string
+}
+
+// DefaultValueFormatter renders values unchanged
+type DefaultValueFormatter struct{}
+
+func (DefaultValueFormatter) FormatValue(_ counters.Counter, value string) string {
+	return value
+}
+
+// RoundingValueFormatter rounds the values of some fields, e.g. power and energy fields, to the
+// nearest integer and renders the values of the other fields unchanged.
+type RoundingValueFormatter struct {
+	// fields are the names of the rounded fields, DCGM fields or legacy series
+	fields map[string]struct{}
+}
+
+// NewRoundingValueFormatter returns the formatter rounding the values of the named fields
+func NewRoundingValueFormatter(fields []string) RoundingValueFormatter {
+	f := RoundingValueFormatter{fields: make(map[string]struct{}, len(fields))}
+	for _, field := range fields {
+		f.fields[field] = struct{}{}
+	}
+	return f
+}
+
+func (f RoundingValueFormatter) FormatValue(counter counters.Counter, value string) string {
+	if _, ok := f.fields[counter.FieldName]; !ok {
+		return value
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+		return value
+	}
+	// adding 0 turns the -0 of small negative values into 0
+	return strconv.FormatFloat(math.Round(v)+0, 'f', -1, 64)
+}
@@ -97,6 +97,7 @@ func ExtractCounters(records [][]string, c *appconfig.Config) (*CounterSet, erro
 	for i, record := range records {
 		var alterField, alterHelp string
 		var multiplier int
+		var transform ValueTransform
 		var err error
 
 		if len(record) == 0 {
@@ -109,6 +110,7 @@ func ExtractCounters(records [][]string, c *appconfig.Config) (*CounterSet, erro
 
 		// Local PU addition - for fields with alternate metric name and possibly a multiplier
 		// expects alter_metric_name,alter_descriptoin,multiplier
+		// or alter_metric_name,alter_descriptoin,scale,offset for an affine transform
 		if len(record) == 6 {
 			alterField = record[3]
 			alterHelp = record[4]
@@ -116,6 +118,14 @@ func ExtractCounters(records [][]string, c *appconfig.Config) (*CounterSet, erro
 			if err != nil {
 				return nil, fmt.Errorf("Malformed CSV record, failed to parse line %d (`%v`), 6th field is not an integer", i, record)
 			}
+		} else if len(record) == 7 {
+			alterField = record[3]
+			alterHelp = record[4]
+			multiplier = 1
+			transform, err = parseValueTransform(record[5], record[6])
+			if err != nil {
+				return nil, fmt.Errorf("Malformed CSV record, failed to parse line %d (`%v`), %w", i, record, err)
+			}
 		} else if len(record) != 3 {
 			return nil, fmt.Errorf("malformed CSV record; err: failed to parse line %d (`%v`), "+
 				"expected 3 fields", i,
@@ -144,6 +154,9 @@ func ExtractCounters(records [][]string, c *appconfig.Config) (*CounterSet, erro
 						AlterFieldName: alterField,
 						AlterHelp:      alterHelp,
 						Multiplier:     multiplier,
+						Transform:      transform,
+						Unit:           FieldUnit(record[0]),
+						Integer:        isIntegerField(record[0], record[1], c.IntegerFields),
 					})
 				continue
 			}
@@ -160,12 +173,25 @@ func ExtractCounters(records [][]string, c *appconfig.Config) (*CounterSet, erro
 
 		res.DCGMCounters = append(res.DCGMCounters,
 			Counter{FieldID: fieldID, FieldName: record[0], PromType: record[1], Help: record[2],
-				AlterFieldName: alterField, AlterHelp: alterHelp, Multiplier: multiplier})
+				AlterFieldName: alterField, AlterHelp: alterHelp, Multiplier: multiplier, Transform: transform,
+				Unit: FieldUnit(record[0]), Integer: isIntegerField(record[0], record[1], c.IntegerFields)})
 	}
 
 	return &res, nil
 }
 
+func parseValueTransform(scale, offset string) (ValueTransform, error) {
+	s, err := strconv.ParseFloat(scale, 64)
+	if err != nil {
+		return ValueTransform{}, fmt.Errorf("6th field (scale) is not a number")
+	}
+	o, err := strconv.ParseFloat(offset, 64)
+	if err != nil {
+		return ValueTransform{}, fmt.Errorf("7th field (offset) is not a number")
+	}
+	return ValueTransform{Scale: s, Offset: o}, nil
+}
+
 func fieldIsSupported(fieldID uint, c *appconfig.Config) bool {
 	if fieldID < dcpFieldsStart || fieldID >= cpuFieldsStart {
 		return true
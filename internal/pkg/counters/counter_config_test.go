@@ -173,3 +173,46 @@ func extractCountersHelper(t *testing.T, input string, valid bool) {
 		assert.Nil(t, cc, "Expected no counters.")
 	}
 }
+
+func TestExtractCountersValueTransform(t *testing.T) {
+	c := &appconfig.Config{}
+
+	tests := []struct {
+		name           string
+		record         []string
+		wantMultiplier int
+		wantTransform  ValueTransform
+		wantErr        bool
+	}{
+		{
+			name:           "multiplier",
+			record:         []string{"DCGM_FI_DEV_FB_TOTAL", "gauge", "help", "nvidia_gpu_memory_total_bytes", "alter help", "1048576"},
+			wantMultiplier: 1048576,
+		},
+		{
+			name:           "scale and offset",
+			record:         []string{"DCGM_FI_DEV_GPU_TEMP", "gauge", "help", "nvidia_gpu_temperature_kelvin", "alter help", "1", "273.15"},
+			wantMultiplier: 1,
+			wantTransform:  ValueTransform{Scale: 1, Offset: 273.15},
+		},
+		{
+			name:    "invalid offset",
+			record:  []string{"DCGM_FI_DEV_GPU_TEMP", "gauge", "help", "nvidia_gpu_temperature_kelvin", "alter help", "1", "x"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := ExtractCounters([][]string{tt.record}, c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, cs.DCGMCounters, 1)
+			assert.Equal(t, tt.wantMultiplier, cs.DCGMCounters[0].Multiplier)
+			assert.Equal(t, tt.wantTransform, cs.DCGMCounters[0].Transform)
+		})
+	}
+}
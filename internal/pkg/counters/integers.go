@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package counters
+
+import (
+	"slices"
+	"strings"
+)
+
+// integerFieldPrefixes and integerFieldInfixes infer the DCGM fields counting events or pages,
+// whose values are always integral.
+var (
+	integerFieldPrefixes = []string{"DCGM_FI_DEV_ECC_", "DCGM_FI_DEV_RETIRED_", "DCGM_FI_DEV_XID_ERRORS"}
+	integerFieldInfixes  = []string{"_REMAPPED_ROWS", "_ROW_REMAP_", "_ERROR_COUNT", "_REPLAY_COUNTER"}
+)
+
+// isIntegerField reports whether the values of the field are integral, either inferred from its
+// name or listed in integerFields.
+func isIntegerField(fieldName, promType string, integerFields []string) bool {
+	if promType == "label" {
+		return false
+	}
+	if slices.Contains(integerFields, fieldName) {
+		return true
+	}
+	for _, prefix := range integerFieldPrefixes {
+		if strings.HasPrefix(fieldName, prefix) {
+			return true
+		}
+	}
+	for _, infix := range integerFieldInfixes {
+		if strings.Contains(fieldName, infix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package counters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+)
+
+func TestExtractCountersInteger(t *testing.T) {
+	records := [][]string{
+		{"DCGM_FI_DEV_ECC_DBE_VOL_TOTAL", "counter", "Total number of double-bit volatile ECC errors."},
+		{"DCGM_FI_DEV_RETIRED_PENDING", "counter", "Total number of pages pending retirement."},
+		{"DCGM_FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_TOTAL", "counter", "Total number of NVLink flow-control CRC errors."},
+		{"DCGM_FI_DEV_CORRECTABLE_REMAPPED_ROWS", "counter", "Number of remapped rows for correctable errors"},
+		{"DCGM_FI_DEV_POWER_USAGE", "gauge", "Power draw (in W)."},
+		{"DCGM_FI_DEV_SM_CLOCK", "gauge", "SM clock frequency (in MHz)."},
+		{"DCGM_FI_DEV_ECC_INFOROM_VER", "label", "ECC inforom version"},
+	}
+
+	cs, err := ExtractCounters(records, &appconfig.Config{IntegerFields: []string{"DCGM_FI_DEV_SM_CLOCK"}})
+	require.NoError(t, err)
+
+	integers := map[string]bool{}
+	for _, counter := range cs.DCGMCounters {
+		integers[counter.FieldName] = counter.Integer
+	}
+	assert.Equal(t, map[string]bool{
+		"DCGM_FI_DEV_ECC_DBE_VOL_TOTAL":                 true,
+		"DCGM_FI_DEV_RETIRED_PENDING":                   true,
+		"DCGM_FI_DEV_NVLINK_CRC_FLIT_ERROR_COUNT_TOTAL": true,
+		"DCGM_FI_DEV_CORRECTABLE_REMAPPED_ROWS":         true,
+		"DCGM_FI_DEV_POWER_USAGE":                       false,
+		"DCGM_FI_DEV_SM_CLOCK":                          true,
+		"DCGM_FI_DEV_ECC_INFOROM_VER":                   false,
+	}, integers)
+}
@@ -28,6 +28,23 @@ type Counter struct {
 	AlterFieldName string     `json:"alter_field_name"`
 	AlterHelp      string     `json:"alter_help"`
 	Multiplier     int        `json:"multiplier"`
+	// Transform, when set, replaces Multiplier when computing the alternate metric value
+	Transform ValueTransform `json:"transform"`
+	// Unit is the unit of the field values, e.g. celsius, or "" when it is unknown
+	Unit string `json:"unit,omitempty"`
+	// Integer tells the field values are integral, e.g. error counts, and rendered without decimals
+	Integer bool `json:"integer,omitempty"`
+}
+
+// ValueTransform is an affine transform, value*Scale + Offset, e.g. a unit conversion.
+// The zero value means no transform.
+type ValueTransform struct {
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+}
+
+func (t ValueTransform) IsSet() bool {
+	return t != ValueTransform{}
 }
 
 func (c Counter) IsLabel() bool {
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package counters
+
+// fieldUnits are the units of the values of the DCGM fields, in the OpenMetrics naming, i.e.
+// lower case and plural. They apply to the DCGM field values, not to the alternate values
+// computed with a multiplier or a transform. The OpenMetrics output of the registry render mode
+// renders them as # UNIT lines and unit suffixes.
+var fieldUnits = map[string]string{
+	"DCGM_FI_DEV_SM_CLOCK":                 "megahertz",
+	"DCGM_FI_DEV_MEM_CLOCK":                "megahertz",
+	"DCGM_FI_DEV_GPU_TEMP":                 "celsius",
+	"DCGM_FI_DEV_MEMORY_TEMP":              "celsius",
+	"DCGM_FI_DEV_POWER_USAGE":              "watts",
+	"DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION": "millijoules",
+	"DCGM_FI_DEV_FB_FREE":                  "mebibytes",
+	"DCGM_FI_DEV_FB_USED":                  "mebibytes",
+	"DCGM_FI_DEV_FB_RESERVED":              "mebibytes",
+	"DCGM_FI_DEV_FB_TOTAL":                 "mebibytes",
+	"DCGM_FI_DEV_GPU_UTIL":                 "percent",
+	"DCGM_FI_DEV_MEM_COPY_UTIL":            "percent",
+	"DCGM_FI_DEV_ENC_UTIL":                 "percent",
+	"DCGM_FI_DEV_DEC_UTIL":                 "percent",
+	"DCGM_FI_PROF_GR_ENGINE_ACTIVE":        "ratio",
+	"DCGM_FI_PROF_SM_ACTIVE":               "ratio",
+	"DCGM_FI_PROF_SM_OCCUPANCY":            "ratio",
+	"DCGM_FI_PROF_PIPE_TENSOR_ACTIVE":      "ratio",
+	"DCGM_FI_PROF_DRAM_ACTIVE":             "ratio",
+	"DCGM_FI_PROF_PIPE_FP64_ACTIVE":        "ratio",
+	"DCGM_FI_PROF_PIPE_FP32_ACTIVE":        "ratio",
+	"DCGM_FI_PROF_PIPE_FP16_ACTIVE":        "ratio",
+	"DCGM_FI_PROF_PCIE_TX_BYTES":           "bytes_per_second",
+	"DCGM_FI_PROF_PCIE_RX_BYTES":           "bytes_per_second",
+	"DCGM_FI_PROF_NVLINK_TX_BYTES":         "bytes_per_second",
+	"DCGM_FI_PROF_NVLINK_RX_BYTES":         "bytes_per_second",
+}
+
+// FieldUnit returns the unit of the values of the field, or "" when it has no known unit
+func FieldUnit(fieldName string) string {
+	return fieldUnits[fieldName]
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package counters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+)
+
+func TestExtractCountersUnit(t *testing.T) {
+	records := [][]string{
+		{"DCGM_FI_DEV_GPU_TEMP", "gauge", "GPU temperature (in C)."},
+		{"DCGM_FI_DEV_POWER_USAGE", "gauge", "Power draw (in W)."},
+		{"DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION", "counter", "Total energy consumption since boot (in mJ)."},
+		{"DCGM_FI_DEV_XID_ERRORS", "gauge", "Value of the last XID error encountered."},
+	}
+
+	cs, err := ExtractCounters(records, &appconfig.Config{})
+	require.NoError(t, err)
+	require.Len(t, cs.DCGMCounters, len(records))
+
+	units := map[string]string{}
+	for _, counter := range cs.DCGMCounters {
+		units[counter.FieldName] = counter.Unit
+	}
+	assert.Equal(t, map[string]string{
+		"DCGM_FI_DEV_GPU_TEMP":                 "celsius",
+		"DCGM_FI_DEV_POWER_USAGE":              "watts",
+		"DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION": "millijoules",
+		"DCGM_FI_DEV_XID_ERRORS":               "",
+	}, units)
+}
@@ -17,6 +17,8 @@
 package registry
 
 import (
+	"maps"
+	"slices"
 	"sync"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
@@ -58,6 +60,14 @@ func (r *Registry) Register(entityCollectorTuples collector.EntityCollectorTuple
 	r.collectorGroupsSeen[entityCollectorTuples] = struct{}{}
 }
 
+// Groups returns the entity groups with registered collectors, sorted.
+func (r *Registry) Groups() []dcgm.Field_Entity_Group {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	return slices.Sorted(maps.Keys(r.collectorGroups))
+}
+
 // Gather gathers metrics from all registered collectors.
 func (r *Registry) Gather() (MetricsByCounterGroup, error) {
 	r.mtx.Lock()
@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+)
+
+// checksumPrefix starts the checksum trailer, a comment so that Prometheus ignores it
+const checksumPrefix = "# CHECKSUM sha256 "
+
+// AppendChecksum appends to the rendered body a trailer comment line with the SHA-256 checksum
+// of the body, so that a consumer can verify that the body was not corrupted in transit: the
+// checksum is that of the bytes preceding the trailer line.
+func (r *Renderer) AppendChecksum(body []byte) []byte {
+	sum := sha256.Sum256(body)
+	body = append(body, checksumPrefix...)
+	body = append(body, hex.EncodeToString(sum[:])...)
+	if r.config.LineEnding == appconfig.LineEndingCRLF {
+		body = append(body, '\r')
+	}
+	return append(body, '\n')
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+)
+
+func TestAppendChecksum(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{})
+	var w bytes.Buffer
+	require.NoError(t, renderer.RenderGroup(&w, dcgm.FE_GPU, getMetricsByCounterWithTestMetric()))
+	body := bytes.Clone(w.Bytes())
+
+	output := renderer.AppendChecksum(w.Bytes())
+
+	require.True(t, bytes.HasPrefix(output, body), "the body is unchanged")
+	trailer := string(output[len(body):])
+	require.True(t, strings.HasPrefix(trailer, "# CHECKSUM sha256 "), trailer)
+	require.True(t, strings.HasSuffix(trailer, "\n"))
+	sum := sha256.Sum256(body)
+	assert.Equal(t, hex.EncodeToString(sum[:]), strings.TrimSuffix(strings.TrimPrefix(trailer, "# CHECKSUM sha256 "), "\n"))
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(output))
+	require.NoError(t, err, "the trailer is a comment Prometheus ignores")
+	require.Contains(t, families, "TEST_METRIC")
+	assert.Len(t, families["TEST_METRIC"].GetMetric(), 1)
+}
+
+func TestAppendChecksumCRLF(t *testing.T) {
+	body := []byte("TEST_METRIC 42\r\n")
+	output := NewRenderer(&appconfig.Config{LineEnding: appconfig.LineEndingCRLF}).AppendChecksum(bytes.Clone(body))
+
+	sum := sha256.Sum256(body)
+	assert.Equal(t, string(body)+"# CHECKSUM sha256 "+hex.EncodeToString(sum[:])+"\r\n", string(output))
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"maps"
+	"path/filepath"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+// cohortAttribute carries the cohort of the GPU of a metric
+const cohortAttribute = "cohort"
+
+// cohortOf returns the cohort of the first rule matching the GPU of the metric, or the default
+// cohort, empty for none, when no rule matches.
+func (r *Renderer) cohortOf(metric collector.Metric) string {
+	for _, rule := range r.config.CohortRules {
+		value := metric.GPUUUID
+		if rule.Field == appconfig.CohortMatchModel {
+			value = metric.GPUModelName
+		}
+		// the patterns are validated with the configuration
+		if ok, _ := filepath.Match(rule.Pattern, value); ok {
+			return rule.Cohort
+		}
+	}
+	return r.config.DefaultCohort
+}
+
+// withCohorts sets the cohort attribute of the GPU metrics according to the cohort rules. MIG
+// instances belong to the cohort of their GPU. Attributes maps are shared by the metrics of an
+// entity, so they are cloned rather than modified.
+func (r *Renderer) withCohorts(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	if group != dcgm.FE_GPU || (len(r.config.CohortRules) == 0 && r.config.DefaultCohort == "") {
+		return metrics
+	}
+	for _, values := range metrics {
+		for i, metric := range values {
+			cohort := r.cohortOf(metric)
+			if cohort == "" {
+				continue
+			}
+			attributes := make(map[string]string, len(metric.Attributes)+1)
+			maps.Copy(attributes, metric.Attributes)
+			attributes[cohortAttribute] = cohort
+			values[i].Attributes = attributes
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DeltaFilter leaves out of rendered scrapes the series whose value is the same as on the
+// previous scrape it filtered. The HELP and TYPE lines of a metric are kept when any of its series
+// is, and left out with the metric otherwise. This is not standard Prometheus: a consumer has to
+// carry the values of the series it doesn't receive over, and the filter is meant for a single
+// consumer as it is shared by all the scrapes.
+type DeltaFilter struct {
+	mu sync.Mutex
+	// last is the value of each series on the previous scrape, keyed by its name and labels
+	last map[string]string
+}
+
+func NewDeltaFilter() *DeltaFilter {
+	return &DeltaFilter{last: map[string]string{}}
+}
+
+// Filter writes the lines of the rendered scrape whose series changed to w. The series missing
+// from the scrape are forgotten, so they are written again when they come back.
+func (f *DeltaFilter) Filter(w io.Writer, rendered []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current := make(map[string]string, len(f.last))
+	// header holds the HELP and TYPE lines of the current metric until one of its series changed
+	var header []string
+	afterSeries := false
+	var out bytes.Buffer
+	for rawLine := range bytes.Lines(rendered) {
+		// the line ending, e.g. CRLF, is written as rendered
+		line := strings.TrimSpace(string(rawLine))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if afterSeries || strings.HasPrefix(line, "# HELP ") {
+				header = header[:0]
+			}
+			header = append(header, string(rawLine))
+			afterSeries = false
+			continue
+		}
+		afterSeries = true
+		series, value, ok := splitSample(line)
+		if !ok {
+			continue
+		}
+		current[series] = value
+		if last, seen := f.last[series]; seen && last == value {
+			continue
+		}
+		for _, h := range header {
+			out.WriteString(h)
+		}
+		header = header[:0]
+		out.Write(rawLine)
+	}
+	f.last = current
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// splitSample splits a sample line into its series, the name and labels, and its value, leaving
+// out the timestamp, if any.
+func splitSample(line string) (series, value string, ok bool) {
+	end := strings.IndexByte(line, ' ')
+	if strings.IndexByte(line, '{') >= 0 {
+		end = strings.LastIndexByte(line, '}') + 1
+	}
+	if end <= 0 {
+		return "", "", false
+	}
+	fields := strings.Fields(line[end:])
+	if len(fields) == 0 {
+		return "", "", false
+	}
+	return line[:end], fields[0], true
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestDeltaFilter(t *testing.T) {
+	temp := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Help: "GPU temperature (in C)."}
+	power := counters.Counter{FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W)."}
+	scrape := func(temps ...string) []byte {
+		t.Helper()
+		metrics := collector.MetricsByCounter{}
+		for gpu, value := range temps {
+			id := string(rune('0' + gpu))
+			metrics[temp] = append(metrics[temp], collector.Metric{GPU: id, UUID: "UUID", Hostname: "testhost", Counter: temp, Value: value})
+			metrics[power] = append(metrics[power], collector.Metric{GPU: id, UUID: "UUID", Hostname: "testhost", Counter: power, Value: "100"})
+		}
+		w := &bytes.Buffer{}
+		require.NoError(t, NewRenderer(&appconfig.Config{}).RenderGroup(w, dcgm.FE_GPU, metrics))
+		return w.Bytes()
+	}
+
+	filter := NewDeltaFilter()
+	w := &bytes.Buffer{}
+	require.NoError(t, filter.Filter(w, scrape("40", "50")))
+	assert.Equal(t, `# HELP DCGM_FI_DEV_GPU_TEMP GPU temperature (in C).
+# TYPE DCGM_FI_DEV_GPU_TEMP gauge
+DCGM_FI_DEV_GPU_TEMP{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 40
+DCGM_FI_DEV_GPU_TEMP{gpu="1",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 50
+# HELP DCGM_FI_DEV_POWER_USAGE Power draw (in W).
+# TYPE DCGM_FI_DEV_POWER_USAGE gauge
+DCGM_FI_DEV_POWER_USAGE{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 100
+DCGM_FI_DEV_POWER_USAGE{gpu="1",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 100
+`, w.String(), "every series is new on the first scrape, the metrics without series are left out")
+
+	w.Reset()
+	require.NoError(t, filter.Filter(w, scrape("40", "55")))
+	assert.Equal(t, `# HELP DCGM_FI_DEV_GPU_TEMP GPU temperature (in C).
+# TYPE DCGM_FI_DEV_GPU_TEMP gauge
+DCGM_FI_DEV_GPU_TEMP{gpu="1",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 55
+`, w.String())
+
+	w.Reset()
+	require.NoError(t, filter.Filter(w, scrape("40", "55")))
+	assert.Empty(t, w.String(), "nothing changed")
+
+	// a series missing from a scrape is written again when it comes back
+	w.Reset()
+	require.NoError(t, filter.Filter(w, scrape("40")))
+	assert.Empty(t, w.String())
+	w.Reset()
+	require.NoError(t, filter.Filter(w, scrape("40", "55")))
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_TEMP{gpu="1",`)
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_POWER_USAGE{gpu="1",`)
+	assert.NotContains(t, w.String(), `gpu="0"`)
+
+	// the timestamp of a sample is not part of its value
+	filter = NewDeltaFilter()
+	require.NoError(t, filter.Filter(io.Discard, []byte("DCGM_FI_DEV_GPU_TEMP{gpu=\"0\"} 40 1700000000123\n")))
+	w.Reset()
+	require.NoError(t, filter.Filter(w, []byte("DCGM_FI_DEV_GPU_TEMP{gpu=\"0\"} 40 1700000004567\n")))
+	assert.Empty(t, w.String())
+}
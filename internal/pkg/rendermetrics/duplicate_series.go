@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/common/model"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+const duplicateSeriesDroppedMetric = "dcgm_exporter_duplicate_series_dropped"
+
+// withoutDuplicateSeries drops the metrics of the group rendering a series identical to a previous
+// one but for its value, which Prometheus rejects the whole scrape for, as it happens when several
+// job mappers attribute a GPU to the same job. The series are compared across the counters of the
+// group by their rendered name and labels, and the first metric is kept.
+func (r *Renderer) withoutDuplicateSeries(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	if !r.config.CollapseDuplicateSeries {
+		return metrics
+	}
+	sortedCounters := slices.SortedFunc(maps.Keys(metrics), func(a, b counters.Counter) int {
+		return cmp.Or(cmp.Compare(a.FieldName, b.FieldName), cmp.Compare(a.FieldID, b.FieldID))
+	})
+	seen := make(map[string]bool)
+	for _, counter := range sortedCounters {
+		values := metrics[counter]
+		unique := values[:0:0]
+		for _, metric := range values {
+			keys := []string{seriesKey(counter.FieldName,
+				append(r.fixedLabelPairs(group, metric), metricLabelPairs(group, metric)...))}
+			if group == dcgm.FE_GPU && counter.AlterFieldName != "" && metric.AlterValue != "" {
+				keys = append(keys, seriesKey(counter.AlterFieldName,
+					append(r.alterLabelPairs(metric), metricLabelPairs(group, metric)...)))
+			}
+			if slices.ContainsFunc(keys, func(key string) bool { return seen[key] }) {
+				if _, warned := r.warnedKeys.LoadOrStore(duplicateSeriesDroppedMetric, struct{}{}); !warned {
+					slog.Warn(fmt.Sprintf("Dropping a duplicate %s series, see %s for the number of series dropped",
+						counter.FieldName, duplicateSeriesDroppedMetric))
+				}
+				r.duplicateSeriesDropped.Add(1)
+				continue
+			}
+			for _, key := range keys {
+				seen[key] = true
+			}
+			unique = append(unique, metric)
+		}
+		if len(unique) < len(values) {
+			metrics[counter] = unique
+		}
+	}
+	return metrics
+}
+
+// seriesKey identifies a rendered series by its name and its labels, sorted by name
+func seriesKey(name string, labels []labelPair) string {
+	slices.SortStableFunc(labels, func(a, b labelPair) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	var key strings.Builder
+	key.WriteString(name)
+	for _, label := range labels {
+		key.WriteByte(model.SeparatorByte)
+		key.WriteString(label.name)
+		key.WriteByte(model.SeparatorByte)
+		key.WriteString(label.value)
+	}
+	return key.String()
+}
+
+// RenderDuplicateSeriesDropped renders the number of duplicate series dropped, when they are
+// collapsed.
+func (r *Renderer) RenderDuplicateSeriesDropped(w io.Writer) error {
+	if !r.config.CollapseDuplicateSeries {
+		return nil
+	}
+	return r.renderCounter(w, duplicateSeriesDroppedMetric, duplicateSeriesDroppedHelp, r.duplicateSeriesDropped.Load())
+}
+
+// CollectDuplicateSeriesDropped is RenderDuplicateSeriesDropped in the registry render mode
+func (r *Renderer) CollectDuplicateSeriesDropped(set *MetricSet) error {
+	if !r.config.CollapseDuplicateSeries {
+		return nil
+	}
+	return r.collectCounter(set, duplicateSeriesDroppedMetric, duplicateSeriesDroppedHelp, r.duplicateSeriesDropped.Load())
+}
+
+const duplicateSeriesDroppedHelp = "Number of series dropped for duplicating a series of the same scrape"
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+func TestRenderGroupDuplicateSeries(t *testing.T) {
+	newMetrics := func() collector.MetricsByCounter {
+		metrics := getMetricsByCounterWithTestMetric()
+		counter := getTestMetric()
+		// two mappers attributing the GPU to the same job
+		metrics[counter][0].Attributes = map[string]string{"hpc_job": "1234"}
+		duplicate := metrics[counter][0]
+		duplicate.Attributes = map[string]string{"hpc_job": "1234"}
+		duplicate.Value = "43"
+		other := metrics[counter][0]
+		other.Attributes = map[string]string{"hpc_job": "5678"}
+		metrics[counter] = append(metrics[counter], duplicate, other)
+		return metrics
+	}
+
+	renderer := NewRenderer(&appconfig.Config{CollapseDuplicateSeries: true})
+	var w bytes.Buffer
+	require.NoError(t, renderer.RenderGroup(&w, dcgm.FE_GPU, newMetrics()))
+	require.NoError(t, renderer.RenderDuplicateSeriesDropped(&w))
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(w.Bytes()))
+	require.NoError(t, err, w.String())
+	require.Contains(t, families, "TEST_METRIC")
+	series := families["TEST_METRIC"].GetMetric()
+	require.Len(t, series, 2, "the duplicate series is dropped")
+	assert.Equal(t, 42.0, series[0].GetGauge().GetValue(), "the first series is kept")
+	require.Contains(t, families, duplicateSeriesDroppedMetric)
+	assert.Equal(t, 1.0, families[duplicateSeriesDroppedMetric].GetMetric()[0].GetCounter().GetValue())
+
+	w.Reset()
+	require.NoError(t, renderer.RenderGroup(&w, dcgm.FE_GPU, newMetrics()))
+	require.NoError(t, renderer.RenderDuplicateSeriesDropped(&w))
+	assert.Contains(t, w.String(), duplicateSeriesDroppedMetric+" 2\n", "the counter accumulates over the scrapes")
+
+	// without the collapse, the duplicate series are rendered as they are
+	w.Reset()
+	renderer = NewRenderer(&appconfig.Config{})
+	require.NoError(t, renderer.RenderGroup(&w, dcgm.FE_GPU, newMetrics()))
+	require.NoError(t, renderer.RenderDuplicateSeriesDropped(&w))
+	assert.NotContains(t, w.String(), duplicateSeriesDroppedMetric)
+	families, err = parser.TextToMetricFamilies(bytes.NewReader(w.Bytes()))
+	require.NoError(t, err, w.String())
+	assert.Len(t, families["TEST_METRIC"].GetMetric(), 3)
+}
+
+func TestRenderGroupDuplicateSeriesAcrossCounters(t *testing.T) {
+	// two counters rendered under the same name, e.g. a field and its deprecated id
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	metrics[counter][0].Attributes = map[string]string{"hpc_job": "1234"}
+	other := counter
+	other.FieldID++
+	duplicate := metrics[counter][0]
+	duplicate.Counter = other
+	duplicate.Value = "43"
+	metrics[other] = []collector.Metric{duplicate}
+
+	renderer := NewRenderer(&appconfig.Config{CollapseDuplicateSeries: true})
+	var w bytes.Buffer
+	require.NoError(t, renderer.RenderGroup(&w, dcgm.FE_GPU, metrics))
+	require.NoError(t, renderer.RenderDuplicateSeriesDropped(&w))
+	assert.Equal(t, 1, strings.Count(w.String(), "TEST_METRIC{"), w.String())
+	assert.Contains(t, w.String(), `hpc_job="1234"} 42`, "the series of the first counter is kept")
+	assert.Contains(t, w.String(), duplicateSeriesDroppedMetric+" 1\n")
+}
+
+func TestSeriesKey(t *testing.T) {
+	assert.Equal(t,
+		seriesKey("TEST_METRIC", []labelPair{{name: "gpu", value: "0"}, {name: "Hostname", value: "a"}}),
+		seriesKey("TEST_METRIC", []labelPair{{name: "Hostname", value: "a"}, {name: "gpu", value: "0"}}),
+		"the labels are compared regardless of their order")
+	assert.NotEqual(t,
+		seriesKey("TEST_METRIC", []labelPair{{name: "a", value: "b,c=d"}}),
+		seriesKey("TEST_METRIC", []labelPair{{name: "a", value: "b"}, {name: "c", value: "d"}}))
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+)
+
+var (
+	labelValueEscaper  = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labelValueStripper = strings.NewReplacer(`\`, "", `"`, "", "\n", "")
+)
+
+// escapeLabelValue escapes the backslashes, quotes and newlines of a label value, as required
+// by the Prometheus text format.
+func escapeLabelValue(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	return labelValueEscaper.Replace(value)
+}
+
+// stripLabelValue removes the backslashes, quotes and newlines of a label value, for consumers
+// not supporting escapes.
+func stripLabelValue(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	return labelValueStripper.Replace(value)
+}
+
+// labelValueFunc returns the function rendering label values with the escaping mode.
+func labelValueFunc(mode string) func(string) string {
+	if mode == appconfig.LabelEscapingStrip {
+		return stripLabelValue
+	}
+	return escapeLabelValue
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+// gpuFixedLabelNames are the fixed labels of the GPU series in their default order. The UUID
+// label is named uuid with the old namespace; both names refer to it in a label order.
+var gpuFixedLabelNames = []string{
+	"gpu", "UUID", "pci_bus_id", "device", "modelName", "GPU_I_PROFILE", "GPU_I_ID", "Hostname",
+}
+
+// ValidateGPULabelOrder checks that the order only names fixed labels of the GPU series, once.
+func ValidateGPULabelOrder(order []string) error {
+	seen := map[string]bool{}
+	for _, name := range order {
+		canonical := canonicalGPULabel(name)
+		if !slices.Contains(gpuFixedLabelNames, canonical) {
+			return fmt.Errorf("label %q is not a fixed GPU label, expected one of %s",
+				name, strings.Join(gpuFixedLabelNames, ", "))
+		}
+		if seen[canonical] {
+			return fmt.Errorf("label %q is ordered twice", name)
+		}
+		seen[canonical] = true
+	}
+	return nil
+}
+
+func canonicalGPULabel(name string) string {
+	if name == "uuid" {
+		return "UUID"
+	}
+	return name
+}
+
+// gpuLabelOrder returns the labels of the order followed by the other fixed labels in their
+// default order.
+func gpuLabelOrder(order []string) []string {
+	labels := make([]string, 0, len(gpuFixedLabelNames))
+	for _, name := range order {
+		labels = append(labels, canonicalGPULabel(name))
+	}
+	for _, name := range gpuFixedLabelNames {
+		if !slices.Contains(labels, name) {
+			labels = append(labels, name)
+		}
+	}
+	return labels
+}
+
+// labelPair is a label of a series, with its value as is, before any escaping
+type labelPair struct {
+	name  string
+	value string
+}
+
+// gpuFixedLabels returns the template function writing the fixed labels of a GPU series in the
+// order, with the values rendered by labelValue.
+func gpuFixedLabels(order []string, labelValue func(string) string, omitEmpty bool) func(collector.Metric) string {
+	pairs := gpuFixedLabelPairs(order, omitEmpty)
+	return func(metric collector.Metric) string {
+		var b strings.Builder
+		for _, pair := range pairs(metric) {
+			if b.Len() > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(pair.name + `="` + labelValue(pair.value) + `"`)
+		}
+		return b.String()
+	}
+}
+
+// gpuFixedLabelPairs returns the function listing the fixed labels of a GPU series in the order.
+// The MIG and hostname labels are only listed when set, and so are the pci_bus_id, device and
+// modelName labels with omitEmpty.
+func gpuFixedLabelPairs(order []string, omitEmpty bool) func(collector.Metric) []labelPair {
+	return func(metric collector.Metric) []labelPair {
+		pairs := make([]labelPair, 0, len(order))
+		for _, name := range order {
+			label, value := name, ""
+			switch name {
+			case "gpu":
+				value = metric.GPU
+			case "UUID":
+				label, value = metric.UUID, metric.AlterUUID
+			case "pci_bus_id":
+				value = metric.GPUPCIBusID
+			case "device":
+				value = metric.GPUDevice
+			case "modelName":
+				value = metric.GPUModelName
+			}
+			switch name {
+			case "pci_bus_id", "device", "modelName":
+				if omitEmpty && value == "" {
+					continue
+				}
+			case "GPU_I_PROFILE", "GPU_I_ID":
+				if metric.MigProfile == "" {
+					continue
+				}
+				value = metric.MigProfile
+				if name == "GPU_I_ID" {
+					value = metric.GPUInstanceID
+				}
+			case "Hostname":
+				if metric.Hostname == "" {
+					continue
+				}
+				value = metric.Hostname
+			}
+			pairs = append(pairs, labelPair{name: label, value: value})
+		}
+		return pairs
+	}
+}
+
+// defaultMinorNumberLabel names the minor number label of the alternate GPU series and of the job series
+const defaultMinorNumberLabel = "minor_number"
+
+// minorNumberLabel returns the template function writing the minor number label of a GPU series,
+// named name or minor_number when empty.
+func minorNumberLabel(name string, labelValue func(string) string) func(collector.Metric) string {
+	if name == "" {
+		name = defaultMinorNumberLabel
+	}
+	return func(metric collector.Metric) string {
+		return name + `="` + labelValue(minorNumber(metric)) + `"`
+	}
+}
+
+// minorNumber is the value of the minor number label of a GPU series: the resolved device minor
+// number of the GPU, or its DCGM index when the minor number is not resolved.
+func minorNumber(metric collector.Metric) string {
+	if metric.DeviceMinor != "" {
+		return metric.DeviceMinor
+	}
+	return metric.GPU
+}
@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+const jobMetricPrefix = "dcgm_job_"
+
+// jobAggregation is how the values of a field on the GPUs of a job are combined
+type jobAggregation int
+
+const (
+	jobSum jobAggregation = iota + 1
+	jobAverage
+)
+
+func (a jobAggregation) String() string {
+	if a == jobAverage {
+		return "averaged"
+	}
+	return "summed"
+}
+
+// jobAggregations are the aggregations of the gauge fields; counters are summed and
+// the other fields, e.g. states, bitmasks or error codes, are not rendered per job.
+var jobAggregations = map[string]jobAggregation{
+	"DCGM_FI_DEV_POWER_USAGE":         jobSum,
+	"DCGM_FI_DEV_FB_FREE":             jobSum,
+	"DCGM_FI_DEV_FB_USED":             jobSum,
+	"DCGM_FI_DEV_FB_RESERVED":         jobSum,
+	"DCGM_FI_PROF_PCIE_TX_BYTES":      jobSum,
+	"DCGM_FI_PROF_PCIE_RX_BYTES":      jobSum,
+	"DCGM_FI_PROF_NVLINK_TX_BYTES":    jobSum,
+	"DCGM_FI_PROF_NVLINK_RX_BYTES":    jobSum,
+	"DCGM_FI_DEV_GPU_TEMP":            jobAverage,
+	"DCGM_FI_DEV_MEMORY_TEMP":         jobAverage,
+	"DCGM_FI_DEV_SM_CLOCK":            jobAverage,
+	"DCGM_FI_DEV_MEM_CLOCK":           jobAverage,
+	"DCGM_FI_DEV_GPU_UTIL":            jobAverage,
+	"DCGM_FI_DEV_MEM_COPY_UTIL":       jobAverage,
+	"DCGM_FI_DEV_ENC_UTIL":            jobAverage,
+	"DCGM_FI_DEV_DEC_UTIL":            jobAverage,
+	"DCGM_FI_PROF_GR_ENGINE_ACTIVE":   jobAverage,
+	"DCGM_FI_PROF_SM_ACTIVE":          jobAverage,
+	"DCGM_FI_PROF_SM_OCCUPANCY":       jobAverage,
+	"DCGM_FI_PROF_PIPE_TENSOR_ACTIVE": jobAverage,
+	"DCGM_FI_PROF_DRAM_ACTIVE":        jobAverage,
+	"DCGM_FI_PROF_PIPE_FP64_ACTIVE":   jobAverage,
+	"DCGM_FI_PROF_PIPE_FP32_ACTIVE":   jobAverage,
+	"DCGM_FI_PROF_PIPE_FP16_ACTIVE":   jobAverage,
+}
+
+// jobAggregationOf returns the aggregation of the counter, and false when it has none
+func jobAggregationOf(counter counters.Counter) (jobAggregation, bool) {
+	if aggregation, ok := jobAggregations[counter.FieldName]; ok {
+		return aggregation, true
+	}
+	if counter.PromType == "counter" {
+		return jobSum, true
+	}
+	return 0, false
+}
+
+// jobMetricName returns the name of the job series of the DCGM field, e.g.
+// dcgm_job_dev_gpu_util for DCGM_FI_DEV_GPU_UTIL.
+func jobMetricName(fieldName string) string {
+	return jobMetricPrefix + strings.ToLower(strings.TrimPrefix(fieldName, "DCGM_FI_"))
+}
+
+// jobKey identifies the job of the job series
+type jobKey struct {
+	job      string
+	user     string
+	hostname string
+}
+
+// jobLabels returns the labels of the job, with the configured label escaping
+func (r *Renderer) jobLabels(k jobKey) string {
+	labels := fmt.Sprintf("jobid=\"%s\"", r.labelValue(k.job))
+	if k.user != "" {
+		labels += fmt.Sprintf(",userid=\"%s\"", r.labelValue(k.user))
+	}
+	if k.hostname != "" {
+		labels += fmt.Sprintf(",Hostname=\"%s\"", r.labelValue(k.hostname))
+	}
+	return labels
+}
+
+// jobValues are the values of a field on the GPUs of a job
+type jobValues struct {
+	sum  float64
+	gpus map[string]struct{}
+}
+
+// RenderJobs renders the GPU metrics mapped to jobs aggregated per job, as dcgm_job_* series
+// labeled by job instead of by GPU, along with the number of GPUs of each job and an info series
+// listing them. Each GPU or MIG instance contributes once to the series of each of its jobs;
+// metrics without a job, or with the job placeholder, are skipped.
+func (r *Renderer) RenderJobs(w io.Writer, metrics collector.MetricsByCounter) error {
+	w = r.lineWriter(w)
+	staticLabels := r.staticLabelPairs()
+	placeholder := *r.jobPlaceholder.Load()
+	jobGPUs := map[jobKey]map[string]struct{}{}
+
+	sortedCounters := slices.SortedFunc(maps.Keys(metrics), func(a, b counters.Counter) int {
+		return cmp.Compare(a.FieldName, b.FieldName)
+	})
+
+	var out strings.Builder
+	for _, counter := range sortedCounters {
+		aggregation, ok := jobAggregationOf(counter)
+		if !ok {
+			continue
+		}
+
+		values := map[jobKey]*jobValues{}
+		for _, metric := range metrics[counter] {
+			job := metric.Attributes[transformation.HpcJobAttribute]
+			// the placeholder of the unmapped GPUs is no job
+			if job == "" || job == placeholder {
+				continue
+			}
+			value, err := strconv.ParseFloat(metric.Value, 64)
+			if err != nil {
+				continue
+			}
+			key := jobKey{job: job, user: metric.Attributes[transformation.HpcUserAttribute], hostname: metric.Hostname}
+			gpu := metric.GPU
+			if metric.MigProfile != "" {
+				gpu += "." + metric.GPUInstanceID
+			}
+
+			if values[key] == nil {
+				values[key] = &jobValues{gpus: map[string]struct{}{}}
+			}
+			if _, seen := values[key].gpus[gpu]; seen {
+				continue
+			}
+			values[key].gpus[gpu] = struct{}{}
+			values[key].sum += value
+
+			if jobGPUs[key] == nil {
+				jobGPUs[key] = map[string]struct{}{}
+			}
+			jobGPUs[key][gpu] = struct{}{}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		name := jobMetricName(counter.FieldName)
+		fmt.Fprintf(&out, "# HELP %s %s (%s over the GPUs of the job)\n", name, counter.Help, aggregation)
+		fmt.Fprintf(&out, "# TYPE %s %s\n", name, counter.PromType)
+		for _, key := range sortedJobKeys(values) {
+			value := values[key].sum
+			if aggregation == jobAverage {
+				value /= float64(len(values[key].gpus))
+			}
+			fmt.Fprintf(&out, "%s{%s%s} %s\n", name, r.jobLabels(key), staticLabels,
+				strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+
+	if len(jobGPUs) > 0 {
+		name := jobMetricPrefix + "gpu_count"
+		fmt.Fprintf(&out, "# HELP %s Number of GPUs and MIG instances used by the job\n", name)
+		fmt.Fprintf(&out, "# TYPE %s gauge\n", name)
+		for _, key := range sortedJobKeys(jobGPUs) {
+			fmt.Fprintf(&out, "%s{%s%s} %d\n", name, r.jobLabels(key), staticLabels, len(jobGPUs[key]))
+		}
+
+		name = jobMetricPrefix + "gpus"
+		fmt.Fprintf(&out, "# HELP %s GPUs and MIG instances used by the job, as <gpu> or <gpu>.<instance> indices\n", name)
+		fmt.Fprintf(&out, "# TYPE %s gauge\n", name)
+		for _, key := range sortedJobKeys(jobGPUs) {
+			fmt.Fprintf(&out, "%s{%s,gpus=\"%s\"%s} 1\n", name, r.jobLabels(key),
+				r.labelValue(strings.Join(sortedJobGPUs(jobGPUs[key]), ",")), staticLabels)
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func sortedJobKeys[V any](m map[jobKey]V) []jobKey {
+	return slices.SortedFunc(maps.Keys(m), func(a, b jobKey) int {
+		return cmp.Or(cmp.Compare(a.job, b.job), cmp.Compare(a.user, b.user), cmp.Compare(a.hostname, b.hostname))
+	})
+}
+
+// sortedJobGPUs returns the GPUs of a job in the numeric order of their indices, the MIG
+// instances of a GPU following it, so that the list is stable across scrapes.
+func sortedJobGPUs(gpus map[string]struct{}) []string {
+	index := func(gpu string) (int, int) {
+		gpuIndex, instance, _ := strings.Cut(gpu, ".")
+		i, _ := strconv.Atoi(gpuIndex)
+		j, err := strconv.Atoi(instance)
+		if err != nil {
+			j = -1
+		}
+		return i, j
+	}
+	return slices.SortedFunc(maps.Keys(gpus), func(a, b string) int {
+		ai, aj := index(a)
+		bi, bj := index(b)
+		return cmp.Or(cmp.Compare(ai, bi), cmp.Compare(aj, bj), cmp.Compare(a, b))
+	})
+}
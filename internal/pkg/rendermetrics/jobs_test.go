@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestRenderJobs(t *testing.T) {
+	powerCounter := counters.Counter{FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W)."}
+	utilCounter := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge", Help: "GPU utilization (in %)."}
+	energyCounter := counters.Counter{
+		FieldName: "DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION", PromType: "counter", Help: "Total energy consumption (in mJ).",
+	}
+	// clock event reasons are a bitmask, which has no sensible aggregation
+	reasonsCounter := counters.Counter{FieldName: "DCGM_FI_DEV_CLOCK_EVENT_REASONS", PromType: "gauge"}
+
+	job := map[string]string{"jobid": "51234567", "userid": "1000"}
+	metrics := collector.MetricsByCounter{
+		powerCounter: {
+			{GPU: "0", Value: "100", Hostname: "node1", Counter: powerCounter, Attributes: job},
+			{GPU: "1", Value: "150", Hostname: "node1", Counter: powerCounter, Attributes: job},
+			{GPU: "2", Value: "30", Hostname: "node1", Counter: powerCounter, Attributes: map[string]string{}},
+		},
+		utilCounter: {
+			{GPU: "0", Value: "40", Hostname: "node1", Counter: utilCounter, Attributes: job},
+			{GPU: "1", Value: "90", Hostname: "node1", Counter: utilCounter, Attributes: job},
+		},
+		energyCounter: {
+			{GPU: "0", Value: "1000", Hostname: "node1", Counter: energyCounter, Attributes: job},
+			{GPU: "1", Value: "2500", Hostname: "node1", Counter: energyCounter, Attributes: job},
+		},
+		reasonsCounter: {
+			{GPU: "0", Value: "4", Hostname: "node1", Counter: reasonsCounter, Attributes: job},
+			{GPU: "1", Value: "1", Hostname: "node1", Counter: reasonsCounter, Attributes: job},
+		},
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderJobs(w, metrics))
+
+	assert.Equal(t, `# HELP dcgm_job_dev_gpu_util GPU utilization (in %). (averaged over the GPUs of the job)
+# TYPE dcgm_job_dev_gpu_util gauge
+dcgm_job_dev_gpu_util{jobid="51234567",userid="1000",Hostname="node1"} 65
+# HELP dcgm_job_dev_power_usage Power draw (in W). (summed over the GPUs of the job)
+# TYPE dcgm_job_dev_power_usage gauge
+dcgm_job_dev_power_usage{jobid="51234567",userid="1000",Hostname="node1"} 250
+# HELP dcgm_job_dev_total_energy_consumption Total energy consumption (in mJ). (summed over the GPUs of the job)
+# TYPE dcgm_job_dev_total_energy_consumption counter
+dcgm_job_dev_total_energy_consumption{jobid="51234567",userid="1000",Hostname="node1"} 3500
+# HELP dcgm_job_gpu_count Number of GPUs and MIG instances used by the job
+# TYPE dcgm_job_gpu_count gauge
+dcgm_job_gpu_count{jobid="51234567",userid="1000",Hostname="node1"} 2
+# HELP dcgm_job_gpus GPUs and MIG instances used by the job, as <gpu> or <gpu>.<instance> indices
+# TYPE dcgm_job_gpus gauge
+dcgm_job_gpus{jobid="51234567",userid="1000",Hostname="node1",gpus="0,1"} 1
+`, w.String())
+}
+
+func TestRenderJobsGPUInfo(t *testing.T) {
+	powerCounter := counters.Counter{FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W)."}
+	job := map[string]string{"jobid": "123"}
+	metrics := collector.MetricsByCounter{
+		powerCounter: {
+			{GPU: "4", Value: "100", Counter: powerCounter, Attributes: job},
+			{GPU: "0", Value: "100", Counter: powerCounter, Attributes: job},
+			{GPU: "1", Value: "100", Counter: powerCounter, Attributes: job},
+			{GPU: "2", Value: "30", Counter: powerCounter, Attributes: map[string]string{}},
+			{GPU: "3", MigProfile: "1g.10gb", GPUInstanceID: "7", Value: "20", Counter: powerCounter,
+				Attributes: map[string]string{"jobid": "456"}},
+		},
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderJobs(w, metrics))
+
+	var info []string
+	for _, line := range strings.Split(w.String(), "\n") {
+		if strings.HasPrefix(line, "dcgm_job_gpus{") {
+			info = append(info, line)
+		}
+	}
+	assert.Equal(t, []string{
+		`dcgm_job_gpus{jobid="123",gpus="0,1,4"} 1`,
+		`dcgm_job_gpus{jobid="456",gpus="3.7"} 1`,
+	}, info, "a single series per job, the GPUs without a job left out")
+}
+
+func TestRenderJobsLabelEscaping(t *testing.T) {
+	powerCounter := counters.Counter{FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W)."}
+	metrics := collector.MetricsByCounter{
+		powerCounter: {
+			{GPU: "0", Value: "100", Hostname: "node1", Counter: powerCounter,
+				Attributes: map[string]string{"jobid": `a "quoted" C:\path`, "userid": `u\1`}},
+		},
+	}
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: appconfig.LabelEscapingStrict, want: `jobid="a \"quoted\" C:\\path",userid="u\\1",Hostname="node1"`},
+		{mode: appconfig.LabelEscapingStrip, want: `jobid="a quoted C:path",userid="u1",Hostname="node1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			require.NoError(t, NewRenderer(&appconfig.Config{LabelEscaping: tt.mode}).RenderJobs(w, metrics))
+			assert.Contains(t, w.String(), `dcgm_job_dev_power_usage{`+tt.want+`} 100`)
+			assert.Contains(t, w.String(), `dcgm_job_gpus{`+tt.want+`,gpus="0"} 1`)
+		})
+	}
+}
+
+func TestRenderJobsPlaceholder(t *testing.T) {
+	powerCounter := counters.Counter{FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W)."}
+	metrics := collector.MetricsByCounter{
+		powerCounter: {
+			{GPU: "0", Value: "100", Counter: powerCounter, Attributes: map[string]string{"jobid": "123"}},
+			{GPU: "1", Value: "30", Counter: powerCounter, Attributes: map[string]string{"jobid": "none"}},
+			{GPU: "2", Value: "30", Counter: powerCounter, Attributes: map[string]string{"jobid": "none"}},
+		},
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{HPCJobPlaceholder: "none"}).RenderJobs(w, metrics))
+	assert.NotContains(t, w.String(), `jobid="none"`, "the idle GPUs are not aggregated into a job")
+	assert.Contains(t, w.String(), `dcgm_job_dev_power_usage{jobid="123"} 100`)
+}
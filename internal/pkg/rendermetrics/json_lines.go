@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"cmp"
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+// JSONLine is a single metric of the JSON Lines dump, tagged with its entity group.
+type JSONLine struct {
+	Group string `json:"group"`
+	collector.Metric
+}
+
+// RenderJSONLines writes every metric of the group as one JSON object per line.
+// Unlike RenderGroup, the output is meant for offline tooling rather than Prometheus.
+func RenderJSONLines(w io.Writer, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter) error {
+	enc := json.NewEncoder(w)
+
+	sortedCounters := slices.SortedFunc(maps.Keys(metrics), func(a, b counters.Counter) int {
+		return cmp.Compare(a.FieldName, b.FieldName)
+	})
+
+	for _, counter := range sortedCounters {
+		for _, metric := range metrics[counter] {
+			err := enc.Encode(JSONLine{Group: group.String(), Metric: metric})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
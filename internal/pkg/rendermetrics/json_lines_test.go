@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+func TestRenderJSONLines(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	metrics[counter] = append(metrics[counter], collector.Metric{
+		GPU:        "1",
+		GPUUUID:    "GPU-11111111-1111-1111-1111-111111111111",
+		Counter:    counter,
+		Value:      "7",
+		Labels:     map[string]string{"DCGM_FI_DRIVER_VERSION": "535.104.05"},
+		Attributes: map[string]string{"jobid": "51234567"},
+	})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderJSONLines(w, dcgm.FE_GPU, metrics))
+
+	var lines []JSONLine
+	scanner := bufio.NewScanner(w)
+	for scanner.Scan() {
+		var line JSONLine
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+
+		var metric collector.Metric
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &metric), "each line must round-trip to a Metric")
+		assert.Equal(t, line.Metric, metric)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, lines, 2)
+	for i, line := range lines {
+		assert.Equal(t, dcgm.FE_GPU.String(), line.Group)
+		assert.Equal(t, metrics[counter][i], line.Metric)
+	}
+}
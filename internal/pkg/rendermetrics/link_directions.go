@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"maps"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+const (
+	// directionLabel tells whether a link series rendered under a direction name is transmitted or received
+	directionLabel = "direction"
+	directionTX    = "tx"
+	directionRX    = "rx"
+)
+
+// withLinkDirections renders the TX and RX fields of the configured link directions under a
+// single name, with the direction label telling them apart, for the link and switch groups.
+// The series of the name carry the type and help of the TX field, or of the RX field when the
+// TX field is not reported.
+func (r *Renderer) withLinkDirections(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	if len(r.config.LinkDirections) == 0 || (group != dcgm.FE_LINK && group != dcgm.FE_SWITCH) {
+		return metrics
+	}
+	for name, fields := range r.config.LinkDirections {
+		tx, txFound := counterOf(metrics, fields.TXField)
+		rx, rxFound := counterOf(metrics, fields.RXField)
+		if !txFound && !rxFound {
+			continue
+		}
+		directed := tx
+		if !txFound {
+			directed = rx
+		}
+		directed.FieldName = name
+		directed.AlterFieldName = ""
+
+		var values []collector.Metric
+		for _, source := range []struct {
+			counter   counters.Counter
+			found     bool
+			direction string
+		}{{tx, txFound, directionTX}, {rx, rxFound, directionRX}} {
+			if !source.found {
+				continue
+			}
+			for _, metric := range metrics[source.counter] {
+				metric.Counter = directed
+				metric.Labels = maps.Clone(metric.Labels)
+				if metric.Labels == nil {
+					metric.Labels = map[string]string{}
+				}
+				metric.Labels[directionLabel] = source.direction
+				values = append(values, metric)
+			}
+			delete(metrics, source.counter)
+		}
+		metrics[directed] = append(metrics[directed], values...)
+	}
+	return metrics
+}
+
+// counterOf returns the counter of the metrics with the field name
+func counterOf(metrics collector.MetricsByCounter, fieldName string) (counters.Counter, bool) {
+	for counter := range metrics {
+		if counter.FieldName == fieldName {
+			return counter, true
+		}
+	}
+	return counters.Counter{}, false
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestRenderGroupLinkDirections(t *testing.T) {
+	tx := counters.Counter{FieldID: 780, FieldName: "DCGM_FI_DEV_NVSWITCH_LINK_THROUGHPUT_TX", PromType: "counter", Help: "NVLink TX bytes."}
+	rx := counters.Counter{FieldID: 781, FieldName: "DCGM_FI_DEV_NVSWITCH_LINK_THROUGHPUT_RX", PromType: "counter", Help: "NVLink RX bytes."}
+	metrics := func() collector.MetricsByCounter {
+		link := collector.Metric{GPU: "0", GPUDevice: "nvswitch0", Hostname: "testhost", Attributes: map[string]string{}}
+		txMetric, rxMetric := link, link
+		txMetric.Counter, txMetric.Value = tx, "100"
+		rxMetric.Counter, rxMetric.Value = rx, "200"
+		return collector.MetricsByCounter{tx: {txMetric}, rx: {rxMetric}}
+	}
+
+	renderer := NewRenderer(&appconfig.Config{
+		LinkDirections: map[string]appconfig.LinkDirection{
+			"dcgm_nvlink_throughput_bytes": {TXField: tx.FieldName, RXField: rx.FieldName},
+		},
+	})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_LINK, metrics()))
+	assert.Equal(t, `# HELP dcgm_nvlink_throughput_bytes NVLink TX bytes.
+# TYPE dcgm_nvlink_throughput_bytes counter
+dcgm_nvlink_throughput_bytes{nvlink="0",nvswitch="nvswitch0",Hostname="testhost",direction="tx"} 100
+dcgm_nvlink_throughput_bytes{nvlink="0",nvswitch="nvswitch0",Hostname="testhost",direction="rx"} 200
+`, w.String())
+
+	w.Reset()
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_SWITCH, metrics()))
+	assert.Contains(t, w.String(), `dcgm_nvlink_throughput_bytes{nvswitch="0",Hostname="testhost",direction="tx"} 100`)
+	assert.Contains(t, w.String(), `dcgm_nvlink_throughput_bytes{nvswitch="0",Hostname="testhost",direction="rx"} 200`)
+	assert.NotContains(t, w.String(), "DCGM_FI_DEV_NVSWITCH_LINK_THROUGHPUT")
+
+	w.Reset()
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics()))
+	assert.NotContains(t, w.String(), "direction=", "GPU series are not directed")
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+// modelNameOf returns the name of the first model name rule matching the model name, or the
+// model name itself when no rule matches.
+func (r *Renderer) modelNameOf(modelName string) string {
+	for _, rule := range r.config.ModelNameRules {
+		if rule.Pattern.MatchString(modelName) {
+			return rule.Name
+		}
+	}
+	return modelName
+}
+
+// withModelNames normalizes the model names of the GPU metrics according to the model name rules,
+// so that the names reported differently across driver versions are rendered alike.
+func (r *Renderer) withModelNames(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	if group != dcgm.FE_GPU || len(r.config.ModelNameRules) == 0 {
+		return metrics
+	}
+	// the GPUs of a node are of a few models, each is matched once
+	names := map[string]string{}
+	for _, values := range metrics {
+		for i, metric := range values {
+			if metric.GPUModelName == "" {
+				continue
+			}
+			name, ok := names[metric.GPUModelName]
+			if !ok {
+				name = r.modelNameOf(metric.GPUModelName)
+				names[metric.GPUModelName] = name
+			}
+			values[i].GPUModelName = name
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+const (
+	nodeGPUUtilMetric = "dcgm_node_gpu_util"
+	// nodeGPUUtilField is the field summarized by the node utilization histogram
+	nodeGPUUtilField = "DCGM_FI_DEV_GPU_UTIL"
+)
+
+// gpuUtilHistogram is the distribution of the utilization of the GPUs of a host
+type gpuUtilHistogram struct {
+	// buckets are the cumulative counts of the GPUs, by upper bound
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// RenderNodeGPUUtil renders the utilization of the GPUs of the node as the dcgm_node_gpu_util
+// histogram, with the configured buckets. Each GPU counts once, whatever the number of its jobs,
+// and MIG instances are skipped. Nothing is rendered without buckets or utilization metrics.
+func (r *Renderer) RenderNodeGPUUtil(w io.Writer, metrics collector.MetricsByCounter) error {
+	bounds := r.config.NodeGPUUtilBuckets
+	histograms := r.gpuUtilHistograms(metrics)
+	if len(histograms) == 0 {
+		return nil
+	}
+
+	w = r.lineWriter(w)
+	staticLabels := r.staticLabelPairs()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n", nodeGPUUtilMetric, nodeGPUUtilHelp)
+	fmt.Fprintf(&sb, "# TYPE %s histogram\n", nodeGPUUtilMetric)
+	for _, hostname := range slices.Sorted(maps.Keys(histograms)) {
+		h := histograms[hostname]
+		labels := fmt.Sprintf("Hostname=\"%s\"%s", r.labelValue(hostname), staticLabels)
+		for i, bound := range bounds {
+			fmt.Fprintf(&sb, "%s_bucket{%s,le=\"%s\"} %d\n", nodeGPUUtilMetric, labels,
+				strconv.FormatFloat(bound, 'f', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", nodeGPUUtilMetric, labels, h.count)
+		fmt.Fprintf(&sb, "%s_sum{%s} %s\n", nodeGPUUtilMetric, labels, strconv.FormatFloat(h.sum, 'f', -1, 64))
+		fmt.Fprintf(&sb, "%s_count{%s} %d\n", nodeGPUUtilMetric, labels, h.count)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CollectNodeGPUUtil is RenderNodeGPUUtil in the registry render mode
+func (r *Renderer) CollectNodeGPUUtil(set *MetricSet, metrics collector.MetricsByCounter) error {
+	histograms := r.gpuUtilHistograms(metrics)
+	for _, hostname := range slices.Sorted(maps.Keys(histograms)) {
+		h := histograms[hostname]
+		buckets := make(map[float64]uint64, len(h.buckets))
+		for i, bound := range r.config.NodeGPUUtilBuckets {
+			buckets[bound] = h.buckets[i]
+		}
+		names, values := r.labelNamesValues(r.withStaticLabels(labelPair{name: "Hostname", value: hostname}))
+		if err := set.add(prometheus.NewConstHistogram(prometheus.NewDesc(nodeGPUUtilMetric, nodeGPUUtilHelp, names, nil),
+			h.count, h.sum, buckets, values...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const nodeGPUUtilHelp = "Distribution of the utilization of the GPUs of the node (in %)"
+
+// gpuUtilHistograms returns the utilization histograms of the GPUs of the metrics, by hostname,
+// none without buckets
+func (r *Renderer) gpuUtilHistograms(metrics collector.MetricsByCounter) map[string]*gpuUtilHistogram {
+	bounds := r.config.NodeGPUUtilBuckets
+	if len(bounds) == 0 {
+		return nil
+	}
+
+	histograms := map[string]*gpuUtilHistogram{}
+	seen := map[string]struct{}{}
+	for counter, values := range metrics {
+		if counter.FieldName != nodeGPUUtilField {
+			continue
+		}
+		for _, metric := range values {
+			if metric.MigProfile != "" {
+				continue
+			}
+			if _, ok := seen[metric.GPUUUID+"/"+metric.GPU]; ok {
+				continue
+			}
+			value, err := strconv.ParseFloat(metric.Value, 64)
+			if err != nil {
+				continue
+			}
+			seen[metric.GPUUUID+"/"+metric.GPU] = struct{}{}
+
+			hostname := metric.Hostname
+			if override, ok := r.config.HostnameOverrides[dcgm.FE_GPU]; ok {
+				hostname = override
+			}
+			h := histograms[hostname]
+			if h == nil {
+				h = &gpuUtilHistogram{buckets: make([]uint64, len(bounds))}
+				histograms[hostname] = h
+			}
+			for i, bound := range bounds {
+				if value <= bound {
+					h.buckets[i]++
+				}
+			}
+			h.sum += value
+			h.count++
+		}
+	}
+	return histograms
+}
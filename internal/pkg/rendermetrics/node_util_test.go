@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestRenderNodeGPUUtil(t *testing.T) {
+	utilCounter := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge", Help: "GPU utilization (in %)."}
+	tempCounter := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Help: "GPU temperature (in C)."}
+	newMetric := func(counter counters.Counter, gpu, value string, attributes map[string]string) collector.Metric {
+		return collector.Metric{
+			Counter: counter, GPU: gpu, GPUUUID: "GPU-" + gpu, Value: value, Hostname: "node1", Attributes: attributes,
+		}
+	}
+	metrics := collector.MetricsByCounter{
+		utilCounter: {
+			newMetric(utilCounter, "0", "0", nil),
+			newMetric(utilCounter, "1", "25", nil),
+			// a GPU shared by two jobs counts once
+			newMetric(utilCounter, "2", "60", map[string]string{"jobid": "1"}),
+			newMetric(utilCounter, "2", "60", map[string]string{"jobid": "2"}),
+			newMetric(utilCounter, "3", "100", nil),
+			{Counter: utilCounter, GPU: "3", GPUInstanceID: "1", MigProfile: "1g.10gb", Value: "5", Hostname: "node1"},
+		},
+		tempCounter: {newMetric(tempCounter, "0", "40", nil)},
+	}
+
+	var w bytes.Buffer
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderNodeGPUUtil(&w, metrics))
+	assert.Empty(t, w.String(), "the histogram is only rendered when buckets are configured")
+
+	renderer := NewRenderer(&appconfig.Config{NodeGPUUtilBuckets: []float64{25, 50, 90}})
+	require.NoError(t, renderer.RenderNodeGPUUtil(&w, metrics))
+	assert.Equal(t, `# HELP dcgm_node_gpu_util Distribution of the utilization of the GPUs of the node (in %)
+# TYPE dcgm_node_gpu_util histogram
+dcgm_node_gpu_util_bucket{Hostname="node1",le="25"} 2
+dcgm_node_gpu_util_bucket{Hostname="node1",le="50"} 2
+dcgm_node_gpu_util_bucket{Hostname="node1",le="90"} 3
+dcgm_node_gpu_util_bucket{Hostname="node1",le="+Inf"} 4
+dcgm_node_gpu_util_sum{Hostname="node1"} 185
+dcgm_node_gpu_util_count{Hostname="node1"} 4
+`, w.String())
+
+	w.Reset()
+	require.NoError(t, renderer.RenderNodeGPUUtil(&w, collector.MetricsByCounter{tempCounter: metrics[tempCounter]}))
+	assert.Empty(t, w.String(), "nothing is rendered without utilization metrics")
+}
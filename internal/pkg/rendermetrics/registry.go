@@ -0,0 +1,402 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+// MetricSet is the series of a scrape in the registry render mode, collected as constant metrics
+// by the Collect methods of the renderer.
+type MetricSet struct {
+	metrics []prometheus.Metric
+}
+
+// add adds the series unless it could not be built, in which case the error is returned
+func (s *MetricSet) add(m prometheus.Metric, err error) error {
+	if err != nil {
+		return err
+	}
+	s.metrics = append(s.metrics, m)
+	return nil
+}
+
+// scrapeCollector collects a scrape on every gather. It is unchecked, describing no metric, so that
+// the series of a family may have different labels, as they do e.g. with and without MIG; the
+// registry still validates the names, the labels and the uniqueness of the series when gathered.
+type scrapeCollector func(set *MetricSet) error
+
+func (c scrapeCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	set := &MetricSet{}
+	if err := c(set); err != nil {
+		// the gather fails, rather than serving part of the scrape
+		ch <- prometheus.NewInvalidMetric(scrapeFailedDesc, fmt.Errorf("%w: %w", errScrapeFailed, err))
+		return
+	}
+	for _, m := range set.metrics {
+		ch <- m
+	}
+}
+
+var (
+	errScrapeFailed  = errors.New("scrape failed")
+	scrapeFailedDesc = prometheus.NewDesc("dcgm_exporter_scrape", "Scrape of the registry render mode", nil, nil)
+)
+
+const registrySeriesDroppedMetric = "dcgm_exporter_registry_series_dropped"
+
+// NewGatherer returns the gatherer of the registry render mode, for promhttp: a registry whose every
+// gather collects a scrape with collect. A scrape failing fails the gather. The series the registry
+// rejects, e.g. duplicate series, are dropped, logged and counted, and the others gathered. The
+// families of the fields of known unit carry it, for the OpenMetrics output.
+func (r *Renderer) NewGatherer(collect func(set *MetricSet) error) prometheus.Gatherer {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(scrapeCollector(collect))
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := registry.Gather()
+		// a single error is returned as is, rather than as a MultiError
+		var errs prometheus.MultiError
+		if !errors.As(err, &errs) && err != nil {
+			errs = prometheus.MultiError{err}
+		}
+		var rejected []error
+		for _, err := range errs {
+			if !errors.Is(err, errScrapeFailed) {
+				rejected = append(rejected, err)
+			}
+		}
+		if len(rejected) > 0 {
+			r.dropRegistrySeries("gathered", len(rejected), errors.Join(rejected...))
+		}
+		for _, family := range families {
+			if unit, ok := r.units.Load(family.GetName()); ok {
+				unit := unit.(string)
+				family.Unit = &unit
+			}
+		}
+		return families, err
+	})
+}
+
+// dropRegistrySeries counts and logs the series of the scope, e.g. an entity group, left out for
+// being rejected by the registry
+func (r *Renderer) dropRegistrySeries(scope string, dropped int, err error) {
+	r.registrySeriesDropped.Add(uint64(dropped))
+	// the same series are rejected on every scrape, they are warned about once
+	level := slog.LevelDebug
+	if _, warned := r.warnedKeys.LoadOrStore(registrySeriesDroppedMetric+":"+scope, struct{}{}); !warned {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level,
+		fmt.Sprintf("Dropping %d %s series rejected by the registry, see %s for the number of series dropped",
+			dropped, scope, registrySeriesDroppedMetric),
+		slog.String(logging.ErrorKey, err.Error()))
+}
+
+// CollectGroupContext is RenderGroupContext in the registry render mode, the series of the group
+// and the Slurm job series of GPUs being collected to the set. The series that can not be built,
+// e.g. with an invalid label name, are dropped, logged and counted.
+func (r *Renderer) CollectGroupContext(
+	ctx context.Context, set *MetricSet, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+	scrape Scrape,
+) error {
+	if err := r.contextErr(ctx); err != nil {
+		return err
+	}
+	if _, ok := r.templates[group]; !ok {
+		return fmt.Errorf("unexpected group: %s", group.String())
+	}
+	metrics, err := r.prepareMetrics(group, metrics)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	collected, invalid := r.constMetrics(group, metrics)
+	if len(invalid) > 0 {
+		r.dropRegistrySeries(group.String(), len(invalid), errors.Join(invalid...))
+	}
+	set.metrics = append(set.metrics, collected...)
+	scrape.observeRenderDuration(group.String(), time.Since(start))
+	r.notifyMetricCallback(group, metrics)
+	if group == dcgm.FE_GPU {
+		start = time.Now()
+		err = r.collectSlurm(set, metrics, scrape.JobSeriesStale)
+		scrape.observeRenderDuration(slurmRenderGroup, time.Since(start))
+	}
+	return err
+}
+
+// constMetrics returns the series of the prepared metrics of the group, with the labels the
+// templates render. The metrics whose value is not a number are skipped, and the invalid series
+// are left out, their errors returned.
+func (r *Renderer) constMetrics(group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter) ([]prometheus.Metric, []error) {
+	var invalid []error
+	sortedCounters := slices.SortedFunc(maps.Keys(metrics), func(a, b counters.Counter) int {
+		return cmp.Compare(a.FieldName, b.FieldName)
+	})
+
+	var collected []prometheus.Metric
+	for _, counter := range sortedCounters {
+		for _, metric := range metrics[counter] {
+			labels := append(r.fixedLabelPairs(group, metric), metricLabelPairs(group, metric)...)
+			m, err := r.constMetric(counter, counter.FieldName, counter.Help, metric, metric.Value, labels)
+			if err != nil {
+				invalid = append(invalid, err)
+			} else if m != nil {
+				collected = append(collected, m)
+			}
+
+			if group != dcgm.FE_GPU || counter.AlterFieldName == "" || metric.AlterValue == "" {
+				continue
+			}
+			labels = append(r.alterLabelPairs(metric), metricLabelPairs(group, metric)...)
+			m, err = r.constMetric(counter, counter.AlterFieldName, counter.AlterHelp, metric, metric.AlterValue, labels)
+			if err != nil {
+				invalid = append(invalid, err)
+			} else if m != nil {
+				collected = append(collected, m)
+			}
+		}
+	}
+	return collected, invalid
+}
+
+// collectSlurm is RenderSlurm in the registry render mode
+func (r *Renderer) collectSlurm(set *MetricSet, metrics collector.MetricsByCounter, stale bool) error {
+	if stale {
+		return nil
+	}
+	staticLabels := r.staticLabelList()
+	// the series of a device and job, which every counter carries, are collected once; a GPU
+	// shared by several jobs has a series per job
+	seen := make(map[string]bool)
+	for _, counter := range slices.SortedFunc(maps.Keys(metrics), func(a, b counters.Counter) int {
+		return cmp.Compare(a.FieldName, b.FieldName)
+	}) {
+		for _, metric := range metrics[counter] {
+			jobid := metric.Attributes[transformation.HpcJobAttribute]
+			if jobid == "" {
+				// only GPUs running jobs have job series
+				continue
+			}
+			labels := append(r.alterLabelPairs(metric), staticLabels...)
+			labels = append(labels, labelPair{name: "jobid", value: jobid})
+			userid := metric.Attributes[transformation.HpcUserAttribute]
+			if userid != "" {
+				labels = append(labels, labelPair{name: "userid", value: userid})
+			}
+			key := seriesKey("", labels)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if userid != "" {
+				userValue, _ := strconv.ParseFloat(slurmSampleValue(userid), 64)
+				if err := set.add(r.newConstMetric(slurmUserIDMetric, slurmUserIDHelp, prometheus.GaugeValue,
+					userValue, labels)); err != nil {
+					return err
+				}
+			}
+			jobValue, _ := strconv.ParseFloat(slurmSampleValue(jobid), 64)
+			if err := set.add(r.newConstMetric(slurmJobIDMetric, slurmJobIDHelp, prometheus.GaugeValue,
+				jobValue, labels)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RenderRegistrySeriesDropped renders the number of series dropped for being rejected by the
+// registry, in the registry render mode.
+func (r *Renderer) RenderRegistrySeriesDropped(w io.Writer) error {
+	if r.config.RenderMode != appconfig.RenderModeRegistry {
+		return nil
+	}
+	return r.renderCounter(w, registrySeriesDroppedMetric, registrySeriesDroppedHelp, r.registrySeriesDropped.Load())
+}
+
+// CollectRegistrySeriesDropped is RenderRegistrySeriesDropped in the registry render mode
+func (r *Renderer) CollectRegistrySeriesDropped(set *MetricSet) error {
+	return r.collectCounter(set, registrySeriesDroppedMetric, registrySeriesDroppedHelp, r.registrySeriesDropped.Load())
+}
+
+const registrySeriesDroppedHelp = "Number of series dropped for being rejected by the registry"
+
+// newConstMetric returns the series of the labels, with the configured label escaping
+func (r *Renderer) newConstMetric(
+	name, help string, valueType prometheus.ValueType, value float64, labels []labelPair,
+) (prometheus.Metric, error) {
+	names, values := r.labelNamesValues(labels)
+	return prometheus.NewConstMetric(prometheus.NewDesc(name, help, names, nil), valueType, value, values...)
+}
+
+// labelNamesValues returns the names and the values of the labels, with the configured label escaping
+func (r *Renderer) labelNamesValues(labels []labelPair) ([]string, []string) {
+	names := make([]string, len(labels))
+	values := make([]string, len(labels))
+	for i, label := range labels {
+		names[i] = label.name
+		values[i] = label.value
+		if r.config.LabelEscaping == appconfig.LabelEscapingStrip {
+			values[i] = stripLabelValue(label.value)
+		}
+	}
+	return names, values
+}
+
+// constMetric returns the series of the metric named name, nil when its value is not a number.
+// The summary fields are rendered as summaries, of cumulative sum and count like in the template.
+func (r *Renderer) constMetric(
+	counter counters.Counter, name, help string, metric collector.Metric, value string, labels []labelPair,
+) (prometheus.Metric, error) {
+	names, values := r.labelNamesValues(labels)
+	desc := prometheus.NewDesc(name, help, names, nil)
+	// the unit is that of the field values, not of the alternate values
+	if counter.Unit != "" && name == counter.FieldName {
+		r.units.Store(name, counter.Unit)
+	}
+
+	var m prometheus.Metric
+	var err error
+	if r.isSummary(counter) && name == counter.FieldName {
+		quantiles := make(map[float64]float64, len(summaryQuantiles))
+		for q, v := range summaryQuantileValues(metric) {
+			quantiles[q] = v
+		}
+		m, err = prometheus.NewConstSummary(desc, metric.SampleCount, metric.SampleSum, quantiles, values...)
+	} else {
+		v, parseErr := strconv.ParseFloat(value, 64)
+		if parseErr != nil {
+			if _, warned := r.warnedKeys.LoadOrStore("value:"+name, struct{}{}); !warned {
+				slog.Warn(fmt.Sprintf("Skipping the %s series whose value %q is not a number", name, value))
+			}
+			return nil, nil
+		}
+		m, err = prometheus.NewConstMetric(desc, valueType(counter.PromType), v, values...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s series: %w", name, err)
+	}
+	if r.config.EnableFieldTimestamps && !metric.UpdatedAt.IsZero() {
+		m = prometheus.NewMetricWithTimestamp(metric.UpdatedAt, m)
+	}
+	return m, nil
+}
+
+func valueType(promType string) prometheus.ValueType {
+	switch promType {
+	case "counter":
+		return prometheus.CounterValue
+	case "gauge":
+		return prometheus.GaugeValue
+	default:
+		return prometheus.UntypedValue
+	}
+}
+
+// fixedLabelPairs returns the fixed labels of a series of the group, as written by its template
+func (r *Renderer) fixedLabelPairs(group dcgm.Field_Entity_Group, metric collector.Metric) []labelPair {
+	var pairs []labelPair
+	optional := func(name, value string) {
+		if value != "" {
+			pairs = append(pairs, labelPair{name: name, value: value})
+		}
+	}
+	switch group {
+	case dcgm.FE_GPU:
+		return r.gpuLabelPairs(metric)
+	case dcgm.FE_SWITCH:
+		pairs = append(pairs, labelPair{name: "nvswitch", value: metric.GPU})
+		optional("nvswitch_uuid", metric.SwitchUUID)
+		optional("fabric_domain", metric.FabricDomain)
+	case dcgm.FE_LINK:
+		pairs = append(pairs,
+			labelPair{name: "nvlink", value: metric.GPU}, labelPair{name: "nvswitch", value: metric.GPUDevice})
+		optional("nvswitch_uuid", metric.SwitchUUID)
+		optional("fabric_domain", metric.FabricDomain)
+		optional("local_uuid", metric.LocalUUID)
+		optional("remote_uuid", metric.RemoteUUID)
+	case dcgm.FE_CPU:
+		pairs = append(pairs, labelPair{name: "cpu", value: metric.GPU})
+	case dcgm.FE_CPU_CORE:
+		pairs = append(pairs, labelPair{name: "cpucore", value: metric.GPU}, labelPair{name: "cpu", value: metric.GPUDevice})
+	}
+	optional("Hostname", metric.Hostname)
+	return pairs
+}
+
+// alterLabelPairs returns the fixed labels of the alternate series of a GPU metric, as written by
+// the GPU template
+func (r *Renderer) alterLabelPairs(metric collector.Metric) []labelPair {
+	minorNumberName := r.config.MinorNumberLabel
+	if minorNumberName == "" {
+		minorNumberName = defaultMinorNumberLabel
+	}
+	pairs := []labelPair{{name: minorNumberName, value: minorNumber(metric)}, {name: "uuid", value: metric.AlterUUID}}
+	if metric.GPUDevice != "" || !r.config.OmitEmptyGPULabels {
+		pairs = append(pairs, labelPair{name: "device", value: metric.GPUDevice})
+	}
+	if metric.GPUModelName != "" || !r.config.OmitEmptyGPULabels {
+		pairs = append(pairs, labelPair{name: "modelName", value: metric.GPUModelName})
+	}
+	if metric.MigProfile != "" {
+		pairs = append(pairs,
+			labelPair{name: "GPU_I_PROFILE", value: metric.MigProfile},
+			labelPair{name: "GPU_I_ID", value: metric.GPUInstanceID})
+	}
+	if metric.Hostname != "" {
+		pairs = append(pairs, labelPair{name: "Hostname", value: metric.Hostname})
+	}
+	return pairs
+}
+
+// metricLabelPairs returns the labels of the metric, followed by its attributes on GPU series
+func metricLabelPairs(group dcgm.Field_Entity_Group, metric collector.Metric) []labelPair {
+	var pairs []labelPair
+	for _, name := range slices.Sorted(maps.Keys(metric.Labels)) {
+		pairs = append(pairs, labelPair{name: name, value: metric.Labels[name]})
+	}
+	if group != dcgm.FE_GPU {
+		return pairs
+	}
+	for _, name := range slices.Sorted(maps.Keys(metric.Attributes)) {
+		pairs = append(pairs, labelPair{name: name, value: metric.Attributes[name]})
+	}
+	return pairs
+}
@@ -0,0 +1,300 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+// parsedSeries returns the series of the text output as their labels, by family, ignoring the
+// order of the series and of their labels
+func parsedSeries(t *testing.T, output []byte) map[string][]map[string]string {
+	t.Helper()
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(output))
+	require.NoError(t, err, string(output))
+	parsed := map[string][]map[string]string{}
+	for name, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{"__type__": family.GetType().String()}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			labels["__value__"] = metricValue(metric)
+			parsed[name] = append(parsed[name], labels)
+		}
+	}
+	return parsed
+}
+
+func metricValue(metric *dto.Metric) string {
+	switch {
+	case metric.Gauge != nil:
+		return formatFloat(metric.GetGauge().GetValue())
+	case metric.Counter != nil:
+		return formatFloat(metric.GetCounter().GetValue())
+	case metric.Histogram != nil:
+		h := metric.GetHistogram()
+		value := fmt.Sprintf("count=%d sum=%s", h.GetSampleCount(), formatFloat(h.GetSampleSum()))
+		for _, bucket := range h.GetBucket() {
+			value += fmt.Sprintf(" %s=%d", formatFloat(bucket.GetUpperBound()), bucket.GetCumulativeCount())
+		}
+		return value
+	default:
+		return formatFloat(metric.GetUntyped().GetValue())
+	}
+}
+
+// gatheredText returns the text output of the series collected by collect in the registry render mode
+func gatheredText(t *testing.T, renderer *Renderer, collect func(set *MetricSet) error) []byte {
+	t.Helper()
+	families, err := renderer.NewGatherer(collect).Gather()
+	require.NoError(t, err)
+	return encodedText(t, families)
+}
+
+// encodedText returns the families in the text format
+func encodedText(t *testing.T, families []*dto.MetricFamily) []byte {
+	t.Helper()
+	var w bytes.Buffer
+	encoder := expfmt.NewEncoder(&w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		require.NoError(t, encoder.Encode(family))
+	}
+	return w.Bytes()
+}
+
+// collectedGroup returns the text output of the series of the group in the registry render mode
+func collectedGroup(
+	t *testing.T, renderer *Renderer, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) []byte {
+	t.Helper()
+	return gatheredText(t, renderer, func(set *MetricSet) error {
+		return renderer.CollectGroupContext(context.Background(), set, group, metrics, Scrape{})
+	})
+}
+
+func TestCollectGroupRegistryMode(t *testing.T) {
+	power := counters.Counter{
+		FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W).",
+		AlterFieldName: "nvidia_gpu_power_usage_watts", AlterHelp: "Power draw.",
+	}
+	energy := counters.Counter{FieldName: "DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION", PromType: "counter", Help: "Energy (in mJ)."}
+	newMetrics := func() collector.MetricsByCounter {
+		return collector.MetricsByCounter{
+			power: {
+				{
+					GPU: "0", UUID: "UUID", AlterUUID: "GPU-0", GPUDevice: "nvidia0", GPUModelName: "NVIDIA A100",
+					Hostname: "testhost", Counter: power, Value: "215", AlterValue: "215",
+					Attributes: map[string]string{
+						"jobid": `say "hi"\` + "\n", transformation.HpcUserAttribute: "1000",
+					},
+				},
+				{
+					GPU: "1", UUID: "UUID", AlterUUID: "GPU-1", GPUDevice: "nvidia1", GPUModelName: "NVIDIA A100",
+					MigProfile: "1g.10gb", GPUInstanceID: "7", Hostname: "testhost", Counter: power, Value: "51",
+					Attributes: map[string]string{transformation.HpcJobAttribute: "42"},
+				},
+			},
+			energy: {
+				{GPU: "0", UUID: "UUID", AlterUUID: "GPU-0", Hostname: "testhost", Counter: energy, Value: "1000",
+					Labels: map[string]string{"cohort": "a"}, Attributes: map[string]string{}},
+			},
+		}
+	}
+	config := &appconfig.Config{StaticLabels: map[string]string{"site": "a"}}
+
+	var text bytes.Buffer
+	require.NoError(t, NewRenderer(config).RenderGroup(&text, dcgm.FE_GPU, newMetrics()))
+	registry := collectedGroup(t, NewRenderer(config), dcgm.FE_GPU, newMetrics())
+
+	assert.Equal(t, parsedSeries(t, text.Bytes()), parsedSeries(t, registry),
+		"the registry collects the series of the template, Slurm job series included, escaping the label values")
+	assert.Contains(t, string(registry), `jobid="say \"hi\"\\\n"`)
+	assert.Contains(t, string(registry), "# TYPE nvidia_gpu_jobId gauge\n")
+}
+
+func TestNewGathererUnits(t *testing.T) {
+	power := counters.Counter{
+		FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Unit: counters.FieldUnit("DCGM_FI_DEV_POWER_USAGE"),
+		AlterFieldName: "nvidia_gpu_power_usage_milliwatts",
+	}
+	metrics := getMetricsByCounterWithTestMetric()
+	metrics[power] = []collector.Metric{{
+		GPU: "0", UUID: "UUID", AlterUUID: "GPU-0", Hostname: "testhost", Counter: power,
+		Value: "215", AlterValue: "215000", Attributes: map[string]string{},
+	}}
+
+	renderer := NewRenderer(&appconfig.Config{RenderMode: appconfig.RenderModeRegistry})
+	families, err := renderer.NewGatherer(func(set *MetricSet) error {
+		return renderer.CollectGroupContext(context.Background(), set, dcgm.FE_GPU, metrics, Scrape{})
+	}).Gather()
+	require.NoError(t, err)
+	units := map[string]string{}
+	for _, family := range families {
+		units[family.GetName()] = family.GetUnit()
+	}
+	assert.Equal(t, map[string]string{
+		"DCGM_FI_DEV_POWER_USAGE":           "watts",
+		"nvidia_gpu_power_usage_milliwatts": "",
+		"TEST_METRIC":                       "",
+	}, units, "the alternate values and the fields without a known unit have no unit")
+
+	var w bytes.Buffer
+	encoder := expfmt.NewEncoder(&w, expfmt.NewFormat(expfmt.TypeOpenMetrics), expfmt.WithUnit())
+	for _, family := range families {
+		require.NoError(t, encoder.Encode(family))
+	}
+	assert.Contains(t, w.String(), "# UNIT DCGM_FI_DEV_POWER_USAGE_watts watts\n")
+	assert.Contains(t, w.String(), "\nDCGM_FI_DEV_POWER_USAGE_watts{")
+	assert.NotContains(t, w.String(), "# UNIT TEST_METRIC")
+}
+
+func TestCollectSlurmSharedGPU(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	metrics[counter][0].Attributes = map[string]string{
+		transformation.HpcJobAttribute: "42", transformation.HpcUserAttribute: "1000",
+	}
+	shared := metrics[counter][0]
+	shared.Attributes = map[string]string{
+		transformation.HpcJobAttribute: "43", transformation.HpcUserAttribute: "1001",
+	}
+	metrics[counter] = append(metrics[counter], shared)
+
+	series := parsedSeries(t, collectedGroup(t, NewRenderer(&appconfig.Config{}), dcgm.FE_GPU, metrics))
+	require.Len(t, series["nvidia_gpu_jobId"], 2, "a GPU shared by two jobs has a series per job")
+	require.Len(t, series["nvidia_gpu_jobUid"], 2)
+	jobs := []string{series["nvidia_gpu_jobId"][0]["jobid"], series["nvidia_gpu_jobId"][1]["jobid"]}
+	assert.ElementsMatch(t, []string{"42", "43"}, jobs)
+}
+
+func TestCollectGroupRegistryModeValidation(t *testing.T) {
+	counter := getTestMetric()
+	metrics := getMetricsByCounterWithTestMetric()
+	metrics[counter] = append(metrics[counter], metrics[counter][0])
+
+	other := metrics[counter][0]
+	other.GPU = "1"
+	metrics[counter] = append(metrics[counter], other)
+
+	renderer := NewRenderer(&appconfig.Config{RenderMode: appconfig.RenderModeRegistry})
+	families, err := renderer.NewGatherer(func(set *MetricSet) error {
+		return renderer.CollectGroupContext(context.Background(), set, dcgm.FE_GPU, metrics, Scrape{})
+	}).Gather()
+	require.ErrorContains(t, err, "was collected before with the same name and label values")
+	output := string(encodedText(t, families))
+	assert.Equal(t, 1, strings.Count(output, `TEST_METRIC{Hostname="testhost",UUID="GPU-00000000-0000-0000-0000-000000000000",device="nvidia0",gpu="0"`),
+		"the duplicate series is dropped")
+	assert.Contains(t, output, `gpu="1"`, "the valid series are gathered")
+	assert.Equal(t, uint64(1), renderer.registrySeriesDropped.Load())
+
+	metrics = getMetricsByCounterWithTestMetric()
+	metrics[counter][0].Attributes = map[string]string{"bad\xff": "1"}
+	output = string(collectedGroup(t, renderer, dcgm.FE_GPU, metrics))
+	assert.NotContains(t, output, "TEST_METRIC{", "the series of the invalid label name is dropped")
+	assert.Equal(t, uint64(2), renderer.registrySeriesDropped.Load())
+
+	output = string(gatheredText(t, renderer, renderer.CollectRegistrySeriesDropped))
+	assert.Contains(t, output, "dcgm_exporter_registry_series_dropped 2\n")
+}
+
+func TestNewGathererScrapeFailure(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{RenderMode: appconfig.RenderModeRegistry})
+	gatherer := renderer.NewGatherer(func(set *MetricSet) error {
+		if err := renderer.CollectRegistrySeriesDropped(set); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+
+	families, err := gatherer.Gather()
+	require.ErrorContains(t, err, "boom")
+	assert.Empty(t, families, "a failing scrape serves no part of it")
+	assert.Zero(t, renderer.registrySeriesDropped.Load(), "a failing scrape is not a dropped series")
+}
+
+func TestCollectExporterFamilies(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{
+		EnableSelfMetrics:       true,
+		NodeGPUUtilBuckets:      []float64{25, 50},
+		PowerZones:              map[string]string{"GPU-0": "rack-a"},
+		RenderDeadline:          time.Second,
+		CollapseDuplicateSeries: true,
+		StaticLabels:            map[string]string{"site": "a"},
+	})
+	util := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge", Help: "GPU utilization (in %)."}
+	power := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W)."}
+	metrics := collector.MetricsByCounter{
+		util:  {{Counter: util, GPU: "0", GPUUUID: "GPU-0", Value: "30", Hostname: "node1"}},
+		power: {{Counter: power, GPU: "0", GPUUUID: "GPU-0", Value: "250.5", Hostname: "node1"}},
+	}
+	renderDurations := map[string]time.Duration{"GPU": time.Second}
+	phases := map[string]time.Duration{ScrapePhaseCollect: 2 * time.Second}
+	coverage := transformation.MappingCoverage{Hostname: `node"1`, Active: 2, Mapped: 1}
+	updatedAt := 90 * time.Second
+
+	var text bytes.Buffer
+	require.NoError(t, renderer.RenderNodeGPUUtil(&text, metrics))
+	require.NoError(t, renderer.RenderZonePower(&text, metrics))
+	require.NoError(t, renderer.RenderGroupsUp(&text, []dcgm.Field_Entity_Group{dcgm.FE_GPU, dcgm.FE_SWITCH}))
+	require.NoError(t, renderer.RenderMappingConflicts(&text, 3))
+	require.NoError(t, renderer.RenderMappingFiles(&text, 2, []string{"1", `job"2`}))
+	require.NoError(t, renderer.RenderMappingOversize(&text, 1))
+	require.NoError(t, renderer.RenderMappingCoverage(&text, coverage))
+	require.NoError(t, renderer.RenderMappingAge(&text, updatedAt))
+	require.NoError(t, renderer.RenderDeadlineExceeded(&text))
+	require.NoError(t, renderer.RenderDuplicateSeriesDropped(&text))
+	require.NoError(t, renderer.RenderSelfMetrics(&text, renderDurations, phases))
+
+	registry := gatheredText(t, renderer, func(set *MetricSet) error {
+		return errors.Join(
+			renderer.CollectNodeGPUUtil(set, metrics),
+			renderer.CollectZonePower(set, metrics),
+			renderer.CollectGroupsUp(set, []dcgm.Field_Entity_Group{dcgm.FE_GPU, dcgm.FE_SWITCH}),
+			renderer.CollectMappingConflicts(set, 3),
+			renderer.CollectMappingFiles(set, 2, []string{"1", `job"2`}),
+			renderer.CollectMappingOversize(set, 1),
+			renderer.CollectMappingCoverage(set, coverage),
+			renderer.CollectMappingAge(set, updatedAt),
+			renderer.CollectDeadlineExceeded(set),
+			renderer.CollectDuplicateSeriesDropped(set),
+			renderer.CollectSelfMetrics(set, renderDurations, phases),
+		)
+	})
+
+	textSeries := parsedSeries(t, text.Bytes())
+	assert.Len(t, textSeries, 13, text.String())
+	assert.Equal(t, textSeries, parsedSeries(t, registry), "the registry collects the series of the text")
+}
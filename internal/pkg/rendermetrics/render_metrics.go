@@ -17,15 +17,28 @@
 package rendermetrics
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
 )
 
@@ -44,33 +57,40 @@ var (
 	gpuMetricsFormat = `
 {{- range $counter, $metrics := . -}}
 # HELP {{ $counter.FieldName }} {{ $counter.Help }}
-# TYPE {{ $counter.FieldName }} {{ $counter.PromType }}
+# TYPE {{ $counter.FieldName }} {{ promType $counter }}
 {{- range $metric := $metrics }}
-{{ $counter.FieldName }}{gpu="{{ $metric.GPU }}",{{ $metric.UUID }}="{{ $metric.AlterUUID }}",pci_bus_id="{{ $metric.GPUPCIBusID }}",device="{{ $metric.GPUDevice }}",modelName="{{ $metric.GPUModelName }}"{{if $metric.MigProfile}},GPU_I_PROFILE="{{ $metric.MigProfile }}",GPU_I_ID="{{ $metric.GPUInstanceID }}"{{end}}{{if $metric.Hostname }},Hostname="{{ $metric.Hostname }}"{{end}}
+{{- range $sample := samples $counter $metric }}
+{{ $counter.FieldName }}{{ $sample.Suffix }}{ {{- gpuFixedLabels $metric }}
 
 {{- range $k, $v := $metric.Labels -}}
-	,{{ $k }}="{{ $v }}"
+	,{{ $k }}="{{ labelValue $v }}"
 {{- end -}}
 {{- range $k, $v := $metric.Attributes -}}
-	,{{ $k }}="{{ $v }}"
+	,{{ $k }}="{{ labelValue $v }}"
+{{- end -}}
+{{- if $sample.Quantile -}}
+	,quantile="{{ $sample.Quantile }}"
 {{- end -}}
 
-} {{ $metric.Value -}}
+} {{ $sample.Value }}{{ timestamp $metric -}}
+{{- end }}
 {{- end }}
 {{- if $counter.AlterFieldName }}
 # HELP {{ $counter.AlterFieldName }} {{ $counter.AlterHelp }}
 # TYPE {{ $counter.AlterFieldName }} {{ $counter.PromType }}
 {{- range $metric := $metrics }}
-{{ $counter.AlterFieldName }}{minor_number="{{ $metric.GPU }}",uuid="{{ $metric.AlterUUID }}",device="{{ $metric.GPUDevice }}",modelName="{{ $metric.GPUModelName }}"{{if $metric.MigProfile}},GPU_I_PROFILE="{{ $metric.MigProfile }}",GPU_I_ID="{{ $metric.GPUInstanceID }}"{{end}}{{if $metric.Hostname }},Hostname="{{ $metric.Hostname }}"{{end}}
+{{- if $metric.AlterValue }}
+{{ $counter.AlterFieldName }}{ {{- minorNumberLabel $metric }},uuid="{{ labelValue $metric.AlterUUID }}"{{if or $metric.GPUDevice (not omitEmptyLabels)}},device="{{ labelValue $metric.GPUDevice }}"{{end}}{{if or $metric.GPUModelName (not omitEmptyLabels)}},modelName="{{ labelValue $metric.GPUModelName }}"{{end}}{{if $metric.MigProfile}},GPU_I_PROFILE="{{ labelValue $metric.MigProfile }}",GPU_I_ID="{{ labelValue $metric.GPUInstanceID }}"{{end}}{{if $metric.Hostname }},Hostname="{{ labelValue $metric.Hostname }}"{{end}}
 
 {{- range $k, $v := $metric.Labels -}}
-        ,{{ $k }}="{{ $v }}"
+        ,{{ $k }}="{{ labelValue $v }}"
 {{- end -}}
 {{- range $k, $v := $metric.Attributes -}}
-        ,{{ $k }}="{{ $v }}"
+        ,{{ $k }}="{{ labelValue $v }}"
 {{- end -}}
 
-} {{ $metric.AlterValue -}}
+} {{ $metric.AlterValue }}{{ timestamp $metric -}}
+{{- end }}
 {{- end }}
 {{- end }}
 {{ end }}`
@@ -80,12 +100,12 @@ var (
 # HELP {{ $counter.FieldName }} {{ $counter.Help }}
 # TYPE {{ $counter.FieldName }} {{ $counter.PromType }}
 {{- range $metric := $metrics }}
-{{ $counter.FieldName }}{nvswitch="{{ $metric.GPU }}"{{if $metric.Hostname }},Hostname="{{ $metric.Hostname }}"{{end}}
+{{ $counter.FieldName }}{nvswitch="{{ labelValue $metric.GPU }}"{{if $metric.SwitchUUID }},nvswitch_uuid="{{ labelValue $metric.SwitchUUID }}"{{end}}{{if $metric.FabricDomain }},fabric_domain="{{ labelValue $metric.FabricDomain }}"{{end}}{{if $metric.Hostname }},Hostname="{{ labelValue $metric.Hostname }}"{{end}}
 
 {{- range $k, $v := $metric.Labels -}}
-	,{{ $k }}="{{ $v }}"
+	,{{ $k }}="{{ labelValue $v }}"
 {{- end -}}
-} {{ $metric.Value -}}
+} {{ $metric.Value }}{{ timestamp $metric -}}
 {{- end }}
 {{ end }}`
 
@@ -94,12 +114,12 @@ var (
 # HELP {{ $counter.FieldName }} {{ $counter.Help }}
 # TYPE {{ $counter.FieldName }} {{ $counter.PromType }}
 {{- range $metric := $metrics }}
-{{ $counter.FieldName }}{nvlink="{{ $metric.GPU }}",nvswitch="{{ $metric.GPUDevice }}"{{if $metric.Hostname }},Hostname="{{ $metric.Hostname }}"{{end}}
+{{ $counter.FieldName }}{nvlink="{{ labelValue $metric.GPU }}",nvswitch="{{ labelValue $metric.GPUDevice }}"{{if $metric.SwitchUUID }},nvswitch_uuid="{{ labelValue $metric.SwitchUUID }}"{{end}}{{if $metric.FabricDomain }},fabric_domain="{{ labelValue $metric.FabricDomain }}"{{end}}{{if $metric.LocalUUID }},local_uuid="{{ labelValue $metric.LocalUUID }}"{{end}}{{if $metric.RemoteUUID }},remote_uuid="{{ labelValue $metric.RemoteUUID }}"{{end}}{{if $metric.Hostname }},Hostname="{{ labelValue $metric.Hostname }}"{{end}}
 
 {{- range $k, $v := $metric.Labels -}}
-	,{{ $k }}="{{ $v }}"
+	,{{ $k }}="{{ labelValue $v }}"
 {{- end -}}
-} {{ $metric.Value -}}
+} {{ $metric.Value }}{{ timestamp $metric -}}
 {{- end }}
 {{ end }}`
 
@@ -108,12 +128,12 @@ var (
 # HELP {{ $counter.FieldName }} {{ $counter.Help }}
 # TYPE {{ $counter.FieldName }} {{ $counter.PromType }}
 {{- range $metric := $metrics }}
-{{ $counter.FieldName }}{cpu="{{ $metric.GPU }}"{{if $metric.Hostname }},Hostname="{{ $metric.Hostname }}"{{end}}
+{{ $counter.FieldName }}{cpu="{{ labelValue $metric.GPU }}"{{if $metric.Hostname }},Hostname="{{ labelValue $metric.Hostname }}"{{end}}
 
 {{- range $k, $v := $metric.Labels -}}
-	,{{ $k }}="{{ $v }}"
+	,{{ $k }}="{{ labelValue $v }}"
 {{- end -}}
-} {{ $metric.Value -}}
+} {{ $metric.Value }}{{ timestamp $metric -}}
 {{- end }}
 {{ end }}`
 
@@ -122,92 +142,722 @@ var (
 # HELP {{ $counter.FieldName }} {{ $counter.Help }}
 # TYPE {{ $counter.FieldName }} {{ $counter.PromType }}
 {{- range $metric := $metrics }}
-{{ $counter.FieldName }}{cpucore="{{ $metric.GPU }}",cpu="{{ $metric.GPUDevice }}"{{if $metric.Hostname }},Hostname="{{ $metric.Hostname }}"{{end}}
+{{ $counter.FieldName }}{cpucore="{{ labelValue $metric.GPU }}",cpu="{{ labelValue $metric.GPUDevice }}"{{if $metric.Hostname }},Hostname="{{ labelValue $metric.Hostname }}"{{end}}
 
 {{- range $k, $v := $metric.Labels -}}
-	,{{ $k }}="{{ $v }}"
+	,{{ $k }}="{{ labelValue $v }}"
 {{- end -}}
-} {{ $metric.Value -}}
+} {{ $metric.Value }}{{ timestamp $metric -}}
 {{- end }}
 {{ end }}`
 )
 
+// labelValueFuncs are the template functions of the default, strict, label escaping, without
+// timestamps
+var labelValueFuncs = template.FuncMap{"labelValue": escapeLabelValue, "timestamp": noTimestamp}
+
+// noTimestamp is the timestamp template function rendering no timestamp
+func noTimestamp(collector.Metric) string {
+	return ""
+}
+
+// fieldTimestamp is the timestamp template function rendering, after the value, the time DCGM
+// last updated the field in milliseconds. Nothing is rendered when the time is unknown, so that
+// the sample gets the scrape time.
+func fieldTimestamp(metric collector.Metric) string {
+	if metric.UpdatedAt.IsZero() {
+		return ""
+	}
+	return " " + strconv.FormatInt(metric.UpdatedAt.UnixMilli(), 10)
+}
+
 var getGPUMetricsTemplate = sync.OnceValue(func() *template.Template {
-	return template.Must(template.New("gpuMetricsFormat").Parse(gpuMetricsFormat))
+	return template.Must(template.New("gpuMetricsFormat").
+		Funcs(labelValueFuncs).
+		Funcs(template.FuncMap{
+			"gpuFixedLabels":   gpuFixedLabels(gpuFixedLabelNames, escapeLabelValue, false),
+			"minorNumberLabel": minorNumberLabel("", escapeLabelValue),
+			"omitEmptyLabels":  func() bool { return false },
+			"promType":         func(counter counters.Counter) string { return counter.PromType },
+			"samples":          valueSamples,
+		}).
+		Parse(gpuMetricsFormat))
 })
 
 var getSwitchMetricsTemplate = sync.OnceValue(func() *template.Template {
-	return template.Must(template.New("switchMetricsFormat").Parse(switchMetricsFormat))
+	return template.Must(template.New("switchMetricsFormat").Funcs(labelValueFuncs).Parse(switchMetricsFormat))
 })
 
 var getLinkMetricsTemplate = sync.OnceValue(func() *template.Template {
-	return template.Must(template.New("linkMetricsFormat").Parse(linkMetricsFormat))
+	return template.Must(template.New("linkMetricsFormat").Funcs(labelValueFuncs).Parse(linkMetricsFormat))
 })
 
 var getCPUMetricsTemplate = sync.OnceValue(func() *template.Template {
-	return template.Must(template.New("cpuMetricsFormat").Parse(cpuMetricsFormat))
+	return template.Must(template.New("cpuMetricsFormat").Funcs(labelValueFuncs).Parse(cpuMetricsFormat))
 })
 
 var getCPUCoreMetricsTemplate = sync.OnceValue(func() *template.Template {
-	return template.Must(template.New("cpuMetricsFormat").Parse(cpuCoreMetricsFormat))
+	return template.Must(template.New("cpuMetricsFormat").Funcs(labelValueFuncs).Parse(cpuCoreMetricsFormat))
 })
 
+// fixedLabels are the label names each entity group template always emits
+var fixedLabels = map[dcgm.Field_Entity_Group][]string{
+	dcgm.FE_GPU: {
+		"gpu", "UUID", "uuid", "pci_bus_id", "device", "modelName", "GPU_I_PROFILE", "GPU_I_ID", "Hostname",
+		"minor_number", entityKindLabel, quantileLabel,
+	},
+	dcgm.FE_SWITCH:   {"nvswitch", "nvswitch_uuid", "fabric_domain", "Hostname"},
+	dcgm.FE_LINK:     {"nvlink", "nvswitch", "nvswitch_uuid", "fabric_domain", "local_uuid", "remote_uuid", "Hostname"},
+	dcgm.FE_CPU:      {"cpu", "Hostname"},
+	dcgm.FE_CPU_CORE: {"cpucore", "cpu", "Hostname"},
+}
+
+// slurmLabels are the label names RenderSlurm always emits
+var slurmLabels = []string{
+	"minor_number", "uuid", "device", "modelName", "GPU_I_PROFILE", "GPU_I_ID", "Hostname",
+	transformation.HpcJobAttribute, transformation.HpcUserAttribute,
+}
+
+var (
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+)
+
+// ValidateMetricName checks that name is a legal metric name.
+func ValidateMetricName(name string) error {
+	if !metricNameRE.MatchString(name) {
+		return fmt.Errorf("metric name %q is invalid", name)
+	}
+	return nil
+}
+
+// ValidateStaticLabels checks that the static labels are legal label names
+// and that none of them collides with a label the exporter already emits.
+func ValidateStaticLabels(labels map[string]string) error {
+	for name := range labels {
+		if err := ValidateLabelName(name); err != nil {
+			return fmt.Errorf("static %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateLabelName checks that name is a legal label name that does not collide with
+// a label the exporter already emits.
+func ValidateLabelName(name string) error {
+	if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+		return fmt.Errorf("label name %q is invalid", name)
+	}
+	if slices.Contains(slurmLabels, name) {
+		return fmt.Errorf("label %q collides with a fixed label", name)
+	}
+	for group, names := range fixedLabels {
+		if slices.Contains(names, name) {
+			return fmt.Errorf("label %q collides with a fixed %s label", name, group.String())
+		}
+	}
+	return nil
+}
+
+// entityKindLabel distinguishes the series of physical GPUs from those of MIG instances
+const (
+	entityKindLabel = "entity_kind"
+	entityKindGPU   = "gpu"
+	entityKindMIG   = "mig"
+)
+
+// generationLabel carries the collection generation, when enabled
+const generationLabel = "collection_generation"
+
+// duplicateLabelPrefix follows the Prometheus convention for labels clashing with target labels
+const duplicateLabelPrefix = "exported_"
+
+// Renderer renders metric groups in the Prometheus text format according to the exporter configuration.
+type Renderer struct {
+	config     *appconfig.Config
+	warnedKeys sync.Map
+	// units are the units of the series names collected in the registry render mode, if known
+	units sync.Map
+
+	metricCallback MetricCallback
+	valueFormatter collector.ValueFormatter
+
+	// templates render the groups with the configured label escaping and GPU label order
+	templates map[dcgm.Field_Entity_Group]*template.Template
+	// labelValue renders a label value with the configured escaping
+	labelValue func(string) string
+	// minorNumberLabel renders the minor number label of the alternate GPU series and of the job series
+	minorNumberLabel func(collector.Metric) string
+	// gpuLabelPairs lists the fixed labels of the GPU series in the configured order
+	gpuLabelPairs func(collector.Metric) []labelPair
+
+	// generation is the collection generation of the series rendered next
+	generation atomic.Uint64
+
+	// enabledGroups are the only groups rendered by RenderGroups, all when nil
+	enabledGroups map[dcgm.Field_Entity_Group]bool
+
+	// tenants mark the GPU series with the tenant owning them, in the tenant label mode
+	tenants []namedTenant
+
+	// deadlineExceeded is the number of renderings aborted for exceeding their deadline
+	deadlineExceeded atomic.Uint64
+	// duplicateSeriesDropped is the number of duplicate series dropped by withoutDuplicateSeries
+	duplicateSeriesDropped atomic.Uint64
+	// registrySeriesDropped is the number of series rejected by the registry, in the registry render mode
+	registrySeriesDropped atomic.Uint64
+
+	// jobPlaceholder is the job of the unmapped GPUs, which are left out of the per-job series
+	jobPlaceholder atomic.Pointer[string]
+}
+
+// MetricCallback receives every rendered metric of a group along with its counter.
+// The metric is a copy, so changes made by the callback are not rendered.
+type MetricCallback func(group dcgm.Field_Entity_Group, counter counters.Counter, metric collector.Metric) error
+
+// SetJobPlaceholder sets the job of the unmapped GPUs, e.g. after the mapping settings file was
+// re-read on SIGHUP.
+func (r *Renderer) SetJobPlaceholder(placeholder string) {
+	r.jobPlaceholder.Store(&placeholder)
+}
+
+// SetMetricCallback registers the callback invoked for each metric rendered by RenderGroup.
+// It must be set before the renderer is used.
+func (r *Renderer) SetMetricCallback(callback MetricCallback) {
+	r.metricCallback = callback
+}
+
+// SetValueFormatter replaces the formatter of the rendered values.
+// It must be set before the renderer is used.
+func (r *Renderer) SetValueFormatter(formatter collector.ValueFormatter) {
+	r.valueFormatter = formatter
+}
+
+func NewRenderer(c *appconfig.Config) *Renderer {
+	r := &Renderer{
+		config:         c,
+		valueFormatter: collector.DefaultValueFormatter{},
+	}
+	r.labelValue = labelValueFunc(c.LabelEscaping)
+	r.SetJobPlaceholder(c.HPCJobPlaceholder)
+	r.minorNumberLabel = minorNumberLabel(c.MinorNumberLabel, r.labelValue)
+	r.gpuLabelPairs = gpuFixedLabelPairs(gpuLabelOrder(c.GPULabelOrder), c.OmitEmptyGPULabels)
+	if c.TenantLabelMode == appconfig.TenantLabelModeLabel || c.TenantLabelMode == appconfig.TenantLabelModePrefix {
+		r.tenants = namedTenants(c)
+	}
+	r.templates = map[dcgm.Field_Entity_Group]*template.Template{
+		dcgm.FE_GPU: template.Must(getGPUMetricsTemplate().Clone()).Funcs(template.FuncMap{
+			"gpuFixedLabels":   gpuFixedLabels(gpuLabelOrder(c.GPULabelOrder), r.labelValue, c.OmitEmptyGPULabels),
+			"minorNumberLabel": r.minorNumberLabel,
+			"omitEmptyLabels":  func() bool { return c.OmitEmptyGPULabels },
+			"promType":         r.promType,
+			"samples":          r.samples,
+		}),
+		dcgm.FE_SWITCH:   template.Must(getSwitchMetricsTemplate().Clone()),
+		dcgm.FE_LINK:     template.Must(getLinkMetricsTemplate().Clone()),
+		dcgm.FE_CPU:      template.Must(getCPUMetricsTemplate().Clone()),
+		dcgm.FE_CPU_CORE: template.Must(getCPUCoreMetricsTemplate().Clone()),
+	}
+	timestamp := noTimestamp
+	if c.EnableFieldTimestamps {
+		timestamp = fieldTimestamp
+	}
+	for _, tmpl := range r.templates {
+		tmpl.Funcs(template.FuncMap{"labelValue": r.labelValue, "timestamp": timestamp})
+	}
+	if len(c.EnabledEntityGroups) > 0 {
+		r.enabledGroups = map[dcgm.Field_Entity_Group]bool{}
+		for _, group := range c.EnabledEntityGroups {
+			r.enabledGroups[group] = true
+		}
+	}
+	if r.samplingEnabled() {
+		slog.Warn("Metric sampling is enabled, only a sample of the metrics is rendered",
+			slog.Float64("rate", c.SampleRate), slog.Int("groups_with_sampled_fields", len(c.SampleFields)))
+	}
+	return r
+}
+
+var defaultRenderer = NewRenderer(&appconfig.Config{})
+
+// RenderGroup renders the metrics of the group with the default configuration.
 func RenderGroup(w io.Writer, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter) error {
-	var tmpl *template.Template
-
-	switch group {
-	case dcgm.FE_GPU:
-		tmpl = getGPUMetricsTemplate()
-	case dcgm.FE_SWITCH:
-		tmpl = getSwitchMetricsTemplate()
-	case dcgm.FE_LINK:
-		tmpl = getLinkMetricsTemplate()
-	case dcgm.FE_CPU:
-		tmpl = getCPUMetricsTemplate()
-	case dcgm.FE_CPU_CORE:
-		tmpl = getCPUCoreMetricsTemplate()
-	default:
+	return defaultRenderer.RenderGroup(w, group, metrics)
+}
+
+// GenerationLabelEnabled reports whether the series get the collection generation label.
+func (r *Renderer) GenerationLabelEnabled() bool {
+	return r.config.EnableGenerationLabel
+}
+
+// SetGeneration sets the collection generation of the series rendered next, labeled with it
+// when enabled. Renderings of different generations must not run concurrently.
+func (r *Renderer) SetGeneration(generation uint64) {
+	r.generation.Store(generation)
+}
+
+// GroupEnabled reports whether the group is among the configured EnabledEntityGroups.
+func (r *Renderer) GroupEnabled(group dcgm.Field_Entity_Group) bool {
+	return r.enabledGroups == nil || r.enabledGroups[group]
+}
+
+// RenderGroups renders the enabled groups in the order of their entity group ids.
+// The disabled groups are left out, they are not an error.
+func (r *Renderer) RenderGroups(w io.Writer, groups map[dcgm.Field_Entity_Group]collector.MetricsByCounter) error {
+	return r.RenderGroupsContext(context.Background(), w, groups)
+}
+
+// RenderGroupsContext is RenderGroups aborting at the first group not started before the context
+// is done, in which case the groups already rendered are kept and the context error is returned.
+func (r *Renderer) RenderGroupsContext(
+	ctx context.Context, w io.Writer, groups map[dcgm.Field_Entity_Group]collector.MetricsByCounter,
+) error {
+	for _, group := range slices.Sorted(maps.Keys(groups)) {
+		if !r.GroupEnabled(group) {
+			continue
+		}
+		if err := r.RenderGroupContext(ctx, w, group, groups[group], Scrape{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scrape is the state of the scrape the groups are rendered for. It is passed to every call, since
+// concurrent scrapes may differ; the zero value renders all the series.
+type Scrape struct {
+	// JobSeriesStale leaves the samples of the Slurm job series out, the job mapping being stale
+	JobSeriesStale bool
+	// RenderDurations, when not nil, is filled in with the time spent rendering each group, as
+	// passed to RenderSelfMetrics
+	RenderDurations map[string]time.Duration
+}
+
+// RenderGroupContext is RenderGroup for the scrape, unless the context is done, in which case
+// nothing is rendered and the context error is returned. A group is rendered whole once started,
+// so that the output of aborted renderings ends at a group boundary.
+func (r *Renderer) RenderGroupContext(
+	ctx context.Context, w io.Writer, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+	scrape Scrape,
+) error {
+	if err := r.contextErr(ctx); err != nil {
+		return err
+	}
+	return r.renderGroup(w, group, metrics, scrape)
+}
+
+// contextErr returns the error of the context when done, counting the renderings aborted for
+// exceeding their deadline
+func (r *Renderer) contextErr(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		r.deadlineExceeded.Add(1)
+	}
+	return err
+}
+
+// flusher is a buffered writer, e.g. a *bufio.Writer
+type flusher interface {
+	Flush() error
+}
+
+// RenderGroup renders the metrics of the group, followed by the Slurm job series for GPUs.
+// A buffered writer implementing Flush() error is flushed once the group is written, so the
+// output of a group is never left in the buffer.
+func (r *Renderer) RenderGroup(w io.Writer, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter) error {
+	return r.renderGroup(w, group, metrics, Scrape{})
+}
+
+func (r *Renderer) renderGroup(
+	w io.Writer, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter, scrape Scrape,
+) error {
+	tmpl, ok := r.templates[group]
+	if !ok {
 		return fmt.Errorf("unexpected group: %s", group.String())
 	}
-	err := tmpl.Execute(w, metrics)
+	w = r.lineWriter(w)
+	metrics, err := r.prepareMetrics(group, metrics)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = tmpl.Execute(w, metrics)
+	scrape.observeRenderDuration(group.String(), time.Since(start))
+	if err == nil {
+		r.notifyMetricCallback(group, metrics)
+	}
 	if group == dcgm.FE_GPU && err == nil {
-		return RenderSlurm(w, metrics)
+		start = time.Now()
+		err = r.RenderSlurm(w, metrics, scrape.JobSeriesStale)
+		scrape.observeRenderDuration(slurmRenderGroup, time.Since(start))
+	}
+	if f, ok := w.(flusher); ok && err == nil {
+		err = f.Flush()
 	}
 	return err
 }
 
+// prepareMetrics returns the metrics of the group as they are rendered
+func (r *Renderer) prepareMetrics(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) (collector.MetricsByCounter, error) {
+	metrics = withoutEmptyValues(metrics)
+	metrics = r.withModelNames(group, metrics)
+	metrics = r.withLinkDirections(group, metrics)
+	metrics = r.withSampling(group, metrics)
+	metrics, err := r.resolveDuplicateLabels(group, metrics)
+	if err != nil {
+		return nil, err
+	}
+	metrics = r.withHostnameOverride(group, metrics)
+	metrics = r.withCohorts(group, metrics)
+	metrics = r.withTenants(group, metrics)
+	metrics = r.withExtraLabels(group, metrics)
+	metrics = r.withoutDuplicateSeries(group, metrics)
+	metrics = r.withFormattedValues(metrics)
+	return withIntegerValues(metrics), nil
+}
+
+// notifyMetricCallback invokes the metric callback for each rendered metric. Callback errors
+// are logged and do not fail the rendering.
+func (r *Renderer) notifyMetricCallback(group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter) {
+	if r.metricCallback == nil {
+		return
+	}
+	for counter, values := range metrics {
+		for _, metric := range values {
+			metric.Labels = maps.Clone(metric.Labels)
+			metric.Attributes = maps.Clone(metric.Attributes)
+			if err := r.metricCallback(group, counter, metric); err != nil {
+				slog.Warn("Metric callback failed",
+					slog.String(logging.ErrorKey, err.Error()),
+					slog.String(logging.FieldEntityGroupKey, group.String()),
+					slog.String("counter", counter.FieldName))
+			}
+		}
+	}
+}
+
+// withHostnameOverride replaces the hostname of the metrics when the group has a configured override.
+func (r *Renderer) withHostnameOverride(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	hostname, ok := r.config.HostnameOverrides[group]
+	if !ok {
+		return metrics
+	}
+	for _, values := range metrics {
+		for i := range values {
+			values[i].Hostname = hostname
+		}
+	}
+	return metrics
+}
+
+// withExtraLabels adds the configured static labels, the entity kind label of GPU metrics,
+// the field id label and the generation label when enabled, to the labels of every metric.
+// Labels maps are shared by the metrics of an entity, so they are cloned rather than modified.
+func (r *Renderer) withExtraLabels(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	entityKind := r.config.EnableEntityKindLabel && group == dcgm.FE_GPU
+	generation := ""
+	if r.config.EnableGenerationLabel {
+		generation = strconv.FormatUint(r.generation.Load(), 10)
+	}
+	if len(r.config.StaticLabels) == 0 && !entityKind && r.config.FieldIDLabel == "" && generation == "" {
+		return metrics
+	}
+	for counter, values := range metrics {
+		fieldID := r.hasFieldIDLabel(counter)
+		for i, metric := range values {
+			labels := make(map[string]string, len(r.config.StaticLabels)+len(metric.Labels)+2)
+			maps.Copy(labels, r.config.StaticLabels)
+			maps.Copy(labels, metric.Labels)
+			if entityKind {
+				labels[entityKindLabel] = entityKindGPU
+				if metric.MigProfile != "" {
+					labels[entityKindLabel] = entityKindMIG
+				}
+			}
+			if fieldID {
+				labels[r.config.FieldIDLabel] = strconv.Itoa(int(counter.FieldID))
+			}
+			if generation != "" {
+				labels[generationLabel] = generation
+			}
+			values[i].Labels = labels
+		}
+	}
+	return metrics
+}
+
+// hasFieldIDLabel tells whether the series of the counter get the field id label, which can be
+// limited to some Prometheus types, e.g. to counters, to keep the cardinality of gauges down.
+func (r *Renderer) hasFieldIDLabel(counter counters.Counter) bool {
+	if r.config.FieldIDLabel == "" {
+		return false
+	}
+	return len(r.config.FieldIDLabelTypes) == 0 || slices.Contains(r.config.FieldIDLabelTypes, counter.PromType)
+}
+
+// withFormattedValues formats the values of the metrics with the value formatter. The alternate
+// values of counters without a multiplier or a transform are copies of the values and are set to
+// the formatted values, so that both series agree; the others are formatted by the
+// transformations computing them.
+func (r *Renderer) withFormattedValues(metrics collector.MetricsByCounter) collector.MetricsByCounter {
+	if _, ok := r.valueFormatter.(collector.DefaultValueFormatter); ok {
+		return metrics
+	}
+	for counter, values := range metrics {
+		copiesValue := counter.Multiplier == 1 && !counter.Transform.IsSet()
+		for i := range values {
+			values[i].Value = r.valueFormatter.FormatValue(counter, values[i].Value)
+			if copiesValue && values[i].AlterValue != "" {
+				values[i].AlterValue = values[i].Value
+			}
+		}
+	}
+	return metrics
+}
+
+// withIntegerValues renders the integral values of integer fields without decimals, e.g. 5 for
+// 5.000000. Fractional values, and values that are not numbers, are left untouched.
+func withIntegerValues(metrics collector.MetricsByCounter) collector.MetricsByCounter {
+	for counter, values := range metrics {
+		if !counter.Integer {
+			continue
+		}
+		for i := range values {
+			values[i].Value = integerValue(values[i].Value)
+			values[i].AlterValue = integerValue(values[i].AlterValue)
+		}
+	}
+	return metrics
+}
+
+// integerValue returns the value without decimals when it is integral
+func integerValue(value string) string {
+	if !strings.ContainsAny(value, ".eE") {
+		return value
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(v, 0) || v != math.Trunc(v) {
+		return value
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// withoutEmptyValues drops metrics with an empty value, as DCGM reports for fields
+// unsupported on a GPU, which would otherwise render as lines Prometheus rejects.
+func withoutEmptyValues(metrics collector.MetricsByCounter) collector.MetricsByCounter {
+	filtered := make(collector.MetricsByCounter, len(metrics))
+	for counter, values := range metrics {
+		var nonEmpty []collector.Metric
+		for _, metric := range values {
+			if metric.Value == "" {
+				continue
+			}
+			nonEmpty = append(nonEmpty, metric)
+		}
+		if len(nonEmpty) > 0 {
+			filtered[counter] = nonEmpty
+		}
+	}
+	return filtered
+}
+
+// resolveDuplicateLabels handles labels and attributes whose key collides with a fixed label of
+// the group, which would otherwise render a series with the same label twice.
+func (r *Renderer) resolveDuplicateLabels(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) (collector.MetricsByCounter, error) {
+	reserved := r.reservedLabels(group)
+	for _, values := range metrics {
+		for i, metric := range values {
+			labels, err := r.withoutReservedLabels(group, metric.Labels, reserved)
+			if err != nil {
+				return nil, err
+			}
+			attributes, err := r.withoutReservedLabels(group, metric.Attributes, reserved)
+			if err != nil {
+				return nil, err
+			}
+			values[i].Labels, values[i].Attributes = labels, attributes
+		}
+	}
+	return metrics, nil
+}
+
+// withoutReservedLabels returns the labels with the reserved ones resolved according to the
+// duplicate label mode. The labels are cloned rather than modified, as they are shared by the
+// metrics of an entity.
+func (r *Renderer) withoutReservedLabels(
+	group dcgm.Field_Entity_Group, labels map[string]string, reserved []string,
+) (map[string]string, error) {
+	var resolved map[string]string
+	for _, label := range reserved {
+		value, exists := labels[label]
+		if !exists {
+			continue
+		}
+		if resolved == nil {
+			resolved = maps.Clone(labels)
+		}
+		delete(resolved, label)
+
+		switch r.config.DuplicateLabelMode {
+		case appconfig.DuplicateLabelError:
+			return nil, fmt.Errorf("attribute %q collides with a fixed %s label", label, group.String())
+		case appconfig.DuplicateLabelPrefix:
+			resolved[duplicateLabelPrefix+label] = value
+		default:
+			if _, warned := r.warnedKeys.LoadOrStore(label, struct{}{}); !warned {
+				slog.Warn(fmt.Sprintf("Dropping attribute %q that collides with a fixed %s label",
+					label, group.String()))
+			}
+		}
+	}
+	if resolved == nil {
+		return labels, nil
+	}
+	return resolved, nil
+}
+
+// reservedLabels returns the fixed labels of the group along with the static labels and the
+// labels set by the renderer, e.g. the cohort
+func (r *Renderer) reservedLabels(group dcgm.Field_Entity_Group) []string {
+	reserved := slices.Clone(fixedLabels[group])
+	reserved = append(reserved, slices.Collect(maps.Keys(r.config.StaticLabels))...)
+	if r.config.FieldIDLabel != "" {
+		reserved = append(reserved, r.config.FieldIDLabel)
+	}
+	if r.config.EnableGenerationLabel {
+		reserved = append(reserved, generationLabel)
+	}
+	if group != dcgm.FE_GPU {
+		return reserved
+	}
+	if r.config.MinorNumberLabel != "" {
+		reserved = append(reserved, r.config.MinorNumberLabel)
+	}
+	if len(r.config.CohortRules) > 0 || r.config.DefaultCohort != "" {
+		reserved = append(reserved, cohortAttribute)
+	}
+	if len(r.tenants) > 0 && r.config.TenantLabelMode == appconfig.TenantLabelModeLabel {
+		reserved = append(reserved, tenantAttribute)
+	}
+	return reserved
+}
+
+// staticLabelPairs returns the static labels, and the generation label when enabled, formatted
+// to be appended to a label set
+func (r *Renderer) staticLabelPairs() string {
+	pairs := ""
+	for _, label := range r.staticLabelList() {
+		pairs += fmt.Sprintf(",%s=\"%s\"", label.name, r.labelValue(label.value))
+	}
+	return pairs
+}
+
+// staticLabelList returns the static labels, and the generation label when enabled
+func (r *Renderer) staticLabelList() []labelPair {
+	var pairs []labelPair
+	for _, name := range slices.Sorted(maps.Keys(r.config.StaticLabels)) {
+		pairs = append(pairs, labelPair{name: name, value: r.config.StaticLabels[name]})
+	}
+	if r.config.EnableGenerationLabel {
+		pairs = append(pairs, labelPair{name: generationLabel, value: strconv.FormatUint(r.generation.Load(), 10)})
+	}
+	return pairs
+}
+
+// The Slurm job series
+const (
+	slurmJobIDMetric  = "nvidia_gpu_jobId"
+	slurmJobIDHelp    = "JobId number of a job currently using this GPU as reported by Slurm"
+	slurmUserIDMetric = "nvidia_gpu_jobUid"
+	slurmUserIDHelp   = "Uid number of user running jobs on this GPU"
+)
+
+// RenderSlurm renders the Slurm job series with the default configuration.
 func RenderSlurm(w io.Writer, metrics collector.MetricsByCounter) error {
-	strJobId := `# HELP nvidia_gpu_jobId JobId number of a job currently using this GPU as reported by Slurm
- # TYPE nvidia_gpu_jobId gauge
-`
-	strUserId := `# HELP nvidia_gpu_jobUid Uid number of user running jobs on this GPU
-# TYPE nvidia_gpu_jobUid gauge
-`
+	return defaultRenderer.RenderSlurm(w, metrics, false)
+}
+
+// RenderSlurm renders the Slurm job series of the metrics. When the job mapping is stale, the
+// samples are left out, so that Prometheus marks the series stale rather than keeping them.
+func (r *Renderer) RenderSlurm(w io.Writer, metrics collector.MetricsByCounter, stale bool) error {
+	w = r.lineWriter(w)
+	staticLabels := r.staticLabelPairs()
+
+	// the families are only rendered when they have series
+	strJobId := ""
+	strUserId := ""
+	// the series of a device and job, which every counter carries, are rendered once; a GPU
+	// shared by several jobs has a series per job
+	seen := make(map[string]bool)
 	for _, deviceMetrics := range metrics {
 		for _, deviceMetric := range deviceMetrics {
+			jobid := deviceMetric.Attributes[transformation.HpcJobAttribute]
+			if jobid == "" || stale {
+				// only GPUs running jobs have job series
+				continue
+			}
 			hostname := ""
 			if deviceMetric.Hostname != "" {
-				hostname = ",Hostname=\"" + deviceMetric.Hostname + "\""
-			}
-			props := fmt.Sprintf("{minor_number=\"%s\",uuid=\"%s\",device=\"%s\",modelName=\"%s\",GPU_I_PROFILE=\"%s\",GPU_I_ID=\"%s\"%s", deviceMetric.GPU, deviceMetric.AlterUUID, deviceMetric.GPUDevice, deviceMetric.GPUModelName, deviceMetric.MigProfile, deviceMetric.GPUInstanceID, hostname)
-			if !strings.Contains(strJobId, props) {
-				jobid := ""
-				userid := ""
-				jobid, _ = deviceMetric.Attributes[transformation.HpcJobAttribute]
-				userid, _ = deviceMetric.Attributes[transformation.HpcUserAttribute]
-				if jobid != "" {
-					props += fmt.Sprintf(",jobid=\"%s\"", jobid)
-					if userid != "" {
-						props += fmt.Sprintf(",userid=\"%s\"} ", userid)
-						strUserId += "nvidia_gpu_jobUid" + props + userid + "\n"
-					} else {
-						props += "} "
-					}
-					strJobId += "nvidia_gpu_jobId" + props + jobid + "\n"
-				}
+				hostname = ",Hostname=\"" + r.labelValue(deviceMetric.Hostname) + "\""
 			}
+			// like the GPU template, only MIG instances get the MIG labels
+			migLabels := ""
+			if deviceMetric.MigProfile != "" {
+				migLabels = fmt.Sprintf(",GPU_I_PROFILE=\"%s\",GPU_I_ID=\"%s\"",
+					r.labelValue(deviceMetric.MigProfile), r.labelValue(deviceMetric.GPUInstanceID))
+			}
+			// like the GPU template, empty device and model labels may be omitted
+			deviceLabels := ""
+			if deviceMetric.GPUDevice != "" || !r.config.OmitEmptyGPULabels {
+				deviceLabels += ",device=\"" + r.labelValue(deviceMetric.GPUDevice) + "\""
+			}
+			if deviceMetric.GPUModelName != "" || !r.config.OmitEmptyGPULabels {
+				deviceLabels += ",modelName=\"" + r.labelValue(deviceMetric.GPUModelName) + "\""
+			}
+			props := fmt.Sprintf("{%s,uuid=\"%s\"%s%s%s",
+				r.minorNumberLabel(deviceMetric), r.labelValue(deviceMetric.AlterUUID), deviceLabels,
+				migLabels, hostname+staticLabels)
+			userid := deviceMetric.Attributes[transformation.HpcUserAttribute]
+			props += fmt.Sprintf(",jobid=\"%s\"", r.labelValue(jobid))
+			if userid != "" {
+				props += fmt.Sprintf(",userid=\"%s\"", r.labelValue(userid))
+			}
+			props += "} "
+			if seen[props] {
+				continue
+			}
+			seen[props] = true
+			if userid != "" {
+				strUserId += slurmUserIDMetric + props + slurmSampleValue(userid) + "\n"
+			}
+			strJobId += slurmJobIDMetric + props + slurmSampleValue(jobid) + "\n"
 		}
 	}
+	if strJobId != "" {
+		strJobId = fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", slurmJobIDMetric, slurmJobIDHelp, slurmJobIDMetric) +
+			strJobId
+	}
+	if strUserId != "" {
+		strUserId = fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", slurmUserIDMetric, slurmUserIDHelp, slurmUserIDMetric) +
+			strUserId
+	}
 	_, err := w.Write([]byte(strJobId + strUserId))
 	return err
 }
+
+// slurmSampleValue returns the id as sample value, or 0 when the id is not a number,
+// e.g. a job placeholder, which is still carried by the jobid label.
+func slurmSampleValue(id string) string {
+	if _, err := strconv.ParseFloat(id, 64); err != nil {
+		return "0"
+	}
+	return id
+}
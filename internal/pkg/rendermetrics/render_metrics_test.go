@@ -17,15 +17,28 @@
 package rendermetrics
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/common/expfmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
 )
 
 func getMetricsByCounterWithTestMetric() collector.MetricsByCounter {
@@ -39,6 +52,7 @@ func getMetricsByCounterWithTestMetric() collector.MetricsByCounter {
 		Hostname:     "testhost",
 		UUID:         "UUID",
 		GPUUUID:      "GPU-00000000-0000-0000-0000-000000000000",
+		AlterUUID:    "GPU-00000000-0000-0000-0000-000000000000",
 		Counter:      counter,
 		Value:        "42",
 		Attributes:   map[string]string{},
@@ -46,6 +60,23 @@ func getMetricsByCounterWithTestMetric() collector.MetricsByCounter {
 	return metrics
 }
 
+func getSwitchMetricsByCounter(switchUUID string) collector.MetricsByCounter {
+	metrics := collector.MetricsByCounter{}
+	counter := getTestMetric()
+
+	metrics[counter] = append(metrics[counter], collector.Metric{
+		GPU:        "0",
+		GPUDevice:  "nvswitch0",
+		Hostname:   "testhost",
+		UUID:       "UUID",
+		SwitchUUID: switchUUID,
+		Counter:    counter,
+		Value:      "42",
+		Attributes: map[string]string{},
+	})
+	return metrics
+}
+
 func getTestMetric() counters.Counter {
 	counter := counters.Counter{
 		FieldID:   2000,
@@ -130,3 +161,991 @@ TEST_METRIC{cpucore="0",cpu="nvidia0",Hostname="testhost"} 42
 		})
 	}
 }
+
+func TestRenderGroupSkipsEmptyValues(t *testing.T) {
+	counter := getTestMetric()
+	emptyCounter := counters.Counter{
+		FieldID:   2001,
+		FieldName: "EMPTY_METRIC",
+		PromType:  "gauge",
+	}
+
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{GPU: "0", Counter: counter, Value: "42", Hostname: "testhost"},
+			{GPU: "1", Counter: counter, Value: "", Hostname: "testhost"},
+		},
+		emptyCounter: {
+			{GPU: "0", Counter: emptyCounter, Value: "", Hostname: "testhost"},
+		},
+	}
+
+	for _, group := range []dcgm.Field_Entity_Group{dcgm.FE_SWITCH, dcgm.FE_LINK, dcgm.FE_CPU, dcgm.FE_CPU_CORE} {
+		t.Run(group.String(), func(t *testing.T) {
+			w := &bytes.Buffer{}
+			require.NoError(t, RenderGroup(w, group, metrics))
+			assert.Contains(t, w.String(), `Hostname="testhost"} 42`)
+			assert.Equal(t, 1, strings.Count(w.String(), "Hostname="))
+			assert.NotContains(t, w.String(), "EMPTY_METRIC")
+			assert.NotContains(t, w.String(), "} \n")
+		})
+	}
+
+	t.Run(dcgm.FE_GPU.String(), func(t *testing.T) {
+		w := &bytes.Buffer{}
+		require.NoError(t, RenderGroup(w, dcgm.FE_GPU, metrics))
+		assert.Contains(t, w.String(), `TEST_METRIC{gpu="0",`)
+		assert.NotContains(t, w.String(), `gpu="1"`)
+		assert.NotContains(t, w.String(), "EMPTY_METRIC")
+	})
+}
+
+func TestRenderGroupDuplicateLabels(t *testing.T) {
+	newMetrics := func() collector.MetricsByCounter {
+		metrics := getMetricsByCounterWithTestMetric()
+		metrics[getTestMetric()][0].Attributes = map[string]string{"gpu": "7", "jobid": "42"}
+		return metrics
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "drop by default",
+			mode: "",
+			want: `TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost",jobid="42"} 42`,
+		},
+		{
+			name: "drop",
+			mode: appconfig.DuplicateLabelDrop,
+			want: `TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost",jobid="42"} 42`,
+		},
+		{
+			name: "prefix",
+			mode: appconfig.DuplicateLabelPrefix,
+			want: `TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost",exported_gpu="7",jobid="42"} 42`,
+		},
+		{
+			name:    "error",
+			mode:    appconfig.DuplicateLabelError,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newMetrics()
+			w := &bytes.Buffer{}
+			err := NewRenderer(&appconfig.Config{DuplicateLabelMode: tt.mode}).RenderGroup(w, dcgm.FE_GPU, metrics)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, w.String(), tt.want)
+			assert.Equal(t, 1, strings.Count(w.String(), `{gpu="`), "a single gpu label is expected")
+			assert.NotContains(t, w.String(), `,gpu="`, "a single gpu label is expected")
+			assert.Equal(t, "7", metrics[getTestMetric()][0].Attributes["gpu"], "input metrics are not modified")
+		})
+	}
+}
+
+func TestRenderGroupNVSwitchUUID(t *testing.T) {
+	const switchUUID = "SWX-00000000-0000-0000-0000-000000000000"
+
+	tests := []struct {
+		name       string
+		group      dcgm.Field_Entity_Group
+		switchUUID string
+		want       string
+	}{
+		{
+			name:       "switch with UUID",
+			group:      dcgm.FE_SWITCH,
+			switchUUID: switchUUID,
+			want:       `TEST_METRIC{nvswitch="0",nvswitch_uuid="` + switchUUID + `",Hostname="testhost"} 42`,
+		},
+		{
+			name:  "switch without UUID",
+			group: dcgm.FE_SWITCH,
+			want:  `TEST_METRIC{nvswitch="0",Hostname="testhost"} 42`,
+		},
+		{
+			name:       "link with UUID",
+			group:      dcgm.FE_LINK,
+			switchUUID: switchUUID,
+			want:       `TEST_METRIC{nvlink="0",nvswitch="nvswitch0",nvswitch_uuid="` + switchUUID + `",Hostname="testhost"} 42`,
+		},
+		{
+			name:  "link without UUID",
+			group: dcgm.FE_LINK,
+			want:  `TEST_METRIC{nvlink="0",nvswitch="nvswitch0",Hostname="testhost"} 42`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := RenderGroup(w, tt.group, getSwitchMetricsByCounter(tt.switchUUID))
+			require.NoError(t, err)
+			assert.Contains(t, w.String(), tt.want)
+		})
+	}
+}
+
+func TestRenderGroupFabricDomain(t *testing.T) {
+	metrics := getSwitchMetricsByCounter("")
+	counter := getTestMetric()
+	metrics[counter][0].FabricDomain = "fabric-a"
+	other := metrics[counter][0]
+	other.GPU = "1"
+	other.FabricDomain = "fabric-b"
+	unknown := metrics[counter][0]
+	unknown.GPU = "2"
+	unknown.FabricDomain = ""
+	metrics[counter] = append(metrics[counter], other, unknown)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderGroup(w, dcgm.FE_SWITCH, metrics))
+	assert.Contains(t, w.String(), `TEST_METRIC{nvswitch="0",fabric_domain="fabric-a",Hostname="testhost"} 42`)
+	assert.Contains(t, w.String(), `TEST_METRIC{nvswitch="1",fabric_domain="fabric-b",Hostname="testhost"} 42`)
+	assert.Contains(t, w.String(), `TEST_METRIC{nvswitch="2",Hostname="testhost"} 42`,
+		"the fabric domain is omitted when unknown")
+
+	w.Reset()
+	require.NoError(t, RenderGroup(w, dcgm.FE_LINK, metrics))
+	assert.Contains(t, w.String(), `TEST_METRIC{nvlink="0",nvswitch="nvswitch0",fabric_domain="fabric-a",Hostname="testhost"} 42`)
+	assert.Contains(t, w.String(), `TEST_METRIC{nvlink="1",nvswitch="nvswitch0",fabric_domain="fabric-b",Hostname="testhost"} 42`)
+	assert.Contains(t, w.String(), `TEST_METRIC{nvlink="2",nvswitch="nvswitch0",Hostname="testhost"} 42`)
+}
+
+func TestRenderGroupLinkEndpoints(t *testing.T) {
+	metrics := getSwitchMetricsByCounter("")
+	counter := getTestMetric()
+	metrics[counter][0].LocalUUID = "SWX-0"
+	metrics[counter][0].RemoteUUID = "GPU-0"
+	local := metrics[counter][0]
+	local.GPU = "1"
+	local.RemoteUUID = ""
+	metrics[counter] = append(metrics[counter], local)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderGroup(w, dcgm.FE_LINK, metrics))
+	assert.Contains(t, w.String(),
+		`TEST_METRIC{nvlink="0",nvswitch="nvswitch0",local_uuid="SWX-0",remote_uuid="GPU-0",Hostname="testhost"} 42`)
+	assert.Contains(t, w.String(), `TEST_METRIC{nvlink="1",nvswitch="nvswitch0",local_uuid="SWX-0",Hostname="testhost"} 42`,
+		"the remote UUID is omitted when unknown")
+
+	registry := collectedGroup(t, NewRenderer(&appconfig.Config{}), dcgm.FE_LINK, metrics)
+	assert.ElementsMatch(t, parsedSeries(t, w.Bytes())["TEST_METRIC"], parsedSeries(t, registry)["TEST_METRIC"],
+		"the registry collects the same labels")
+}
+
+func TestRenderGroupsEnabledEntityGroups(t *testing.T) {
+	counter := getTestMetric()
+	cpuMetrics := collector.MetricsByCounter{
+		counter: {{GPU: "0", Counter: counter, Value: "7", Hostname: "testhost"}},
+	}
+	groups := map[dcgm.Field_Entity_Group]collector.MetricsByCounter{
+		dcgm.FE_GPU:    getMetricsByCounterWithTestMetric(),
+		dcgm.FE_SWITCH: getSwitchMetricsByCounter(""),
+		dcgm.FE_CPU:    cpuMetrics,
+	}
+
+	renderer := NewRenderer(&appconfig.Config{EnabledEntityGroups: []dcgm.Field_Entity_Group{dcgm.FE_GPU}})
+	assert.True(t, renderer.GroupEnabled(dcgm.FE_GPU))
+	assert.False(t, renderer.GroupEnabled(dcgm.FE_SWITCH))
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroups(w, groups))
+	assert.Contains(t, w.String(), `TEST_METRIC{gpu="0",`)
+	assert.NotContains(t, w.String(), `nvswitch=`)
+	assert.NotContains(t, w.String(), `cpu=`)
+
+	w.Reset()
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderGroups(w, groups))
+	assert.Contains(t, w.String(), `TEST_METRIC{gpu="0",`)
+	assert.Contains(t, w.String(), `TEST_METRIC{nvswitch="0",`)
+	assert.Contains(t, w.String(), `TEST_METRIC{cpu="0",`, "all the groups are rendered by default")
+}
+
+func TestRenderGroupStaticLabels(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{StaticLabels: map[string]string{"datacenter": "dc1"}})
+
+	metrics := getMetricsByCounterWithTestMetric()
+	metrics[getTestMetric()][0].Attributes = map[string]string{
+		transformation.HpcJobAttribute:  "42",
+		transformation.HpcUserAttribute: "1000",
+	}
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+	assert.Contains(t, w.String(), `Hostname="testhost",datacenter="dc1",jobid="42",userid="1000"} 42`)
+	assert.Contains(t, w.String(), `nvidia_gpu_jobId{minor_number="0",uuid="GPU-00000000-0000-0000-0000-000000000000",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost",datacenter="dc1",jobid="42",userid="1000"} 42`)
+	assert.Contains(t, w.String(), `nvidia_gpu_jobUid{minor_number="0",uuid="GPU-00000000-0000-0000-0000-000000000000",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost",datacenter="dc1",jobid="42",userid="1000"} 1000`)
+
+	w = &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_SWITCH, getSwitchMetricsByCounter("")))
+	assert.Contains(t, w.String(), `TEST_METRIC{nvswitch="0",Hostname="testhost",datacenter="dc1"} 42`)
+}
+
+func TestRenderGroupHostnameOverride(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{HostnameOverrides: map[dcgm.Field_Entity_Group]string{
+		dcgm.FE_GPU: "remote-node",
+		dcgm.FE_CPU: "aggregator",
+	}})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, getMetricsByCounterWithTestMetric()))
+	assert.Contains(t, w.String(), `modelName="NVIDIA T400 4GB",Hostname="remote-node"} 42`)
+
+	w = &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_CPU, getMetricsByCounterWithTestMetric()))
+	assert.Contains(t, w.String(), `TEST_METRIC{cpu="0",Hostname="aggregator"} 42`)
+
+	w = &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_SWITCH, getSwitchMetricsByCounter("")))
+	assert.Contains(t, w.String(), `TEST_METRIC{nvswitch="0",Hostname="testhost"} 42`, "groups without override keep the metric hostname")
+}
+
+func TestRenderSlurmJobPlaceholder(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	metrics[getTestMetric()][0].Attributes = map[string]string{transformation.HpcJobAttribute: "none"}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderSlurm(w, metrics))
+	assert.Contains(t, w.String(), `Hostname="testhost",jobid="none"} 0`)
+}
+
+func TestRenderSlurmStaleJobSeries(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	metrics[getTestMetric()][0].Attributes = map[string]string{transformation.HpcJobAttribute: "42"}
+	renderer := NewRenderer(&appconfig.Config{})
+
+	// the scrapes rendered at once keep their own staleness
+	var wg sync.WaitGroup
+	outputs := make([]bytes.Buffer, 8)
+	for i := range outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scrape := Scrape{JobSeriesStale: i%2 == 1}
+			assert.NoError(t, renderer.RenderGroupContext(context.Background(), &outputs[i], dcgm.FE_GPU, metrics, scrape))
+		}()
+	}
+	wg.Wait()
+
+	for i := range outputs {
+		if i%2 == 1 {
+			assert.NotContains(t, outputs[i].String(), "nvidia_gpu_jobId{", "the job series of a stale mapping are left out")
+			assert.Contains(t, outputs[i].String(), `TEST_METRIC{gpu="0",`, "the GPU series are rendered")
+		} else {
+			assert.Contains(t, outputs[i].String(), `jobid="42"} 42`)
+		}
+	}
+}
+
+func TestRenderSlurmSeriesSharingALabelPrefix(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	metrics[counter][0].Attributes = map[string]string{transformation.HpcJobAttribute: "42"}
+	// the labels of the second series are a prefix of the labels of the first one
+	withoutHostname := metrics[counter][0]
+	withoutHostname.Hostname = ""
+	metrics[counter] = append(metrics[counter], withoutHostname)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderSlurm(w, metrics))
+	assert.Equal(t, 2, strings.Count(w.String(), "nvidia_gpu_jobId{"), w.String())
+}
+
+func TestRenderSlurmSharedGPU(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	metrics[counter][0].Attributes = map[string]string{
+		transformation.HpcJobAttribute: "42", transformation.HpcUserAttribute: "1000",
+	}
+	// the mapper expands the metrics of a GPU held by two jobs into a copy per job
+	shared := metrics[counter][0]
+	shared.Attributes = map[string]string{
+		transformation.HpcJobAttribute: "43", transformation.HpcUserAttribute: "1001",
+	}
+	metrics[counter] = append(metrics[counter], shared)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderSlurm(w, metrics))
+	assert.Equal(t, 2, strings.Count(w.String(), "nvidia_gpu_jobId{"), w.String())
+	assert.Equal(t, 2, strings.Count(w.String(), "nvidia_gpu_jobUid{"), w.String())
+	assert.Contains(t, w.String(), `jobid="42",userid="1000"} 42`)
+	assert.Contains(t, w.String(), `jobid="43",userid="1001"} 43`)
+}
+
+func TestRenderSlurmMIGLabels(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	metrics[counter][0].AlterUUID = "GPU-00000000-0000-0000-0000-000000000000"
+	metrics[counter][0].Attributes = map[string]string{transformation.HpcJobAttribute: "42"}
+	mig := metrics[counter][0]
+	mig.GPU = "1"
+	mig.GPUDevice = "nvidia1"
+	mig.AlterUUID = "MIG-00000000-0000-0000-0000-000000000000"
+	mig.MigProfile = "1g.10gb"
+	mig.GPUInstanceID = "7"
+	mig.Attributes = map[string]string{transformation.HpcJobAttribute: "43"}
+	metrics[counter] = append(metrics[counter], mig)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderSlurm(w, metrics))
+
+	assert.Contains(t, w.String(),
+		`nvidia_gpu_jobId{minor_number="0",uuid="GPU-00000000-0000-0000-0000-000000000000",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost",jobid="42"} 42`,
+		"full GPUs have no MIG labels")
+	assert.Contains(t, w.String(),
+		`nvidia_gpu_jobId{minor_number="1",uuid="MIG-00000000-0000-0000-0000-000000000000",device="nvidia1",modelName="NVIDIA T400 4GB",GPU_I_PROFILE="1g.10gb",GPU_I_ID="7",Hostname="testhost",jobid="43"} 43`)
+	assert.Equal(t, 1, strings.Count(w.String(), "GPU_I_PROFILE="))
+}
+
+// slurmBenchmarkMetrics returns the metrics of a node with gpus GPUs and fields fields per GPU,
+// only the first busy GPUs of which run a job.
+func slurmBenchmarkMetrics(gpus, fields, busy int) collector.MetricsByCounter {
+	metrics := collector.MetricsByCounter{}
+	for f := 0; f < fields; f++ {
+		counter := counters.Counter{FieldID: dcgm.Short(f), FieldName: fmt.Sprintf("FIELD_%d", f), PromType: "gauge"}
+		for g := 0; g < gpus; g++ {
+			attributes := map[string]string{}
+			if g < busy {
+				attributes[transformation.HpcJobAttribute] = fmt.Sprintf("%d", 1000+g)
+				attributes[transformation.HpcUserAttribute] = "1000"
+			}
+			metrics[counter] = append(metrics[counter], collector.Metric{
+				Counter:      counter,
+				Value:        "1",
+				GPU:          fmt.Sprintf("%d", g),
+				GPUDevice:    fmt.Sprintf("nvidia%d", g),
+				AlterUUID:    fmt.Sprintf("GPU-%d", g),
+				GPUModelName: "NVIDIA A100",
+				Hostname:     "testhost",
+				Attributes:   attributes,
+			})
+		}
+	}
+	return metrics
+}
+
+func TestRenderSlurmSkipsIdleGPUs(t *testing.T) {
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderSlurm(w, slurmBenchmarkMetrics(8, 3, 2)))
+	assert.Equal(t, 2, strings.Count(w.String(), "nvidia_gpu_jobId{"), "one series per busy GPU")
+	assert.Equal(t, 2, strings.Count(w.String(), "nvidia_gpu_jobUid{"))
+	assert.True(t, strings.HasPrefix(w.String(), "# HELP nvidia_gpu_jobId JobId number of a job currently using this GPU as reported by Slurm\n# TYPE nvidia_gpu_jobId gauge\n"))
+	assert.Contains(t, w.String(), "\n# TYPE nvidia_gpu_jobUid gauge\n")
+
+	w.Reset()
+	require.NoError(t, RenderSlurm(w, slurmBenchmarkMetrics(8, 3, 0)))
+	assert.Empty(t, w.String(), "an idle node has no job series, nor their families")
+}
+
+func BenchmarkRenderSlurmMostlyIdle(b *testing.B) {
+	metrics := slurmBenchmarkMetrics(64, 40, 2)
+	for b.Loop() {
+		if err := RenderSlurm(io.Discard, metrics); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRenderGroupFlushesBufferedWriter(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	metrics[getTestMetric()][0].Attributes = map[string]string{transformation.HpcJobAttribute: "42"}
+
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	require.NoError(t, RenderGroup(w, dcgm.FE_GPU, metrics))
+
+	assert.Zero(t, w.Buffered(), "nothing is left in the buffer")
+	assert.Contains(t, out.String(), "TEST_METRIC{")
+	assert.Contains(t, out.String(), `jobid="42"} 42`, "the slurm block is flushed too")
+}
+
+func TestRenderGroupMetricCallback(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	second := metrics[counter][0]
+	second.GPU = "1"
+	second.Attributes = map[string]string{"jobid": "42"}
+	metrics[counter] = append(metrics[counter], second)
+
+	var rendered []collector.Metric
+	renderer := NewRenderer(&appconfig.Config{})
+	renderer.SetMetricCallback(func(group dcgm.Field_Entity_Group, c counters.Counter, metric collector.Metric) error {
+		assert.Equal(t, dcgm.FE_GPU, group)
+		assert.Equal(t, counter, c)
+		rendered = append(rendered, metric)
+		metric.Attributes["jobid"] = "changed"
+		return errors.New("callback errors are logged")
+	})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+	assert.Len(t, rendered, 2, "the callback fires once per rendered series")
+	assert.Equal(t, 2, strings.Count(w.String(), "TEST_METRIC{"))
+	assert.Equal(t, "42", metrics[counter][1].Attributes["jobid"], "the callback cannot mutate the metric")
+}
+
+func TestRenderGroupEntityKindLabel(t *testing.T) {
+	newMetrics := func() collector.MetricsByCounter {
+		metrics := getMetricsByCounterWithTestMetric()
+		counter := getTestMetric()
+		mig := metrics[counter][0]
+		mig.GPU = "1"
+		mig.GPUDevice = "nvidia1"
+		mig.MigProfile = "1g.10gb"
+		mig.GPUInstanceID = "7"
+		metrics[counter] = append(metrics[counter], mig)
+		return metrics
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{EnableEntityKindLabel: true}).RenderGroup(w, dcgm.FE_GPU, newMetrics()))
+	assert.Contains(t, w.String(), `device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost",entity_kind="gpu"} 42`)
+	assert.Contains(t, w.String(), `GPU_I_PROFILE="1g.10gb",GPU_I_ID="7",Hostname="testhost",entity_kind="mig"} 42`)
+
+	w = &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderGroup(w, dcgm.FE_GPU, newMetrics()))
+	assert.NotContains(t, w.String(), "entity_kind")
+}
+
+func TestRenderGroupFieldIDLabel(t *testing.T) {
+	newMetrics := func() collector.MetricsByCounter {
+		metrics := getMetricsByCounterWithTestMetric()
+		gauge := getTestMetric()
+		counter := counters.Counter{FieldID: 2001, FieldName: "TEST_COUNTER", PromType: "counter"}
+		metric := metrics[gauge][0]
+		metric.Counter = counter
+		metrics[counter] = []collector.Metric{metric}
+		return metrics
+	}
+
+	tests := []struct {
+		name          string
+		config        *appconfig.Config
+		wantOnGauge   bool
+		wantOnCounter bool
+	}{
+		{
+			name:   "disabled",
+			config: &appconfig.Config{},
+		},
+		{
+			name:          "all types",
+			config:        &appconfig.Config{FieldIDLabel: "field_id"},
+			wantOnGauge:   true,
+			wantOnCounter: true,
+		},
+		{
+			name:          "counters only",
+			config:        &appconfig.Config{FieldIDLabel: "field_id", FieldIDLabelTypes: []string{"counter"}},
+			wantOnCounter: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			require.NoError(t, NewRenderer(tt.config).RenderGroup(w, dcgm.FE_GPU, newMetrics()))
+
+			var gaugeLine, counterLine string
+			for _, line := range strings.Split(w.String(), "\n") {
+				switch {
+				case strings.HasPrefix(line, "TEST_METRIC{"):
+					gaugeLine = line
+				case strings.HasPrefix(line, "TEST_COUNTER{"):
+					counterLine = line
+				}
+			}
+			require.NotEmpty(t, gaugeLine)
+			require.NotEmpty(t, counterLine)
+			assert.Equal(t, tt.wantOnGauge, strings.Contains(gaugeLine, `field_id="2000"`), gaugeLine)
+			assert.Equal(t, tt.wantOnCounter, strings.Contains(counterLine, `field_id="2001"`), counterLine)
+		})
+	}
+}
+
+func TestRenderGroupGPULabelOrder(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	metrics[counter] = append(metrics[counter], collector.Metric{
+		GPU:           "1",
+		GPUDevice:     "nvidia1",
+		GPUModelName:  "NVIDIA A100",
+		GPUInstanceID: "7",
+		MigProfile:    "1g.10gb",
+		UUID:          "UUID",
+		AlterUUID:     "MIG-11111111-1111-1111-1111-111111111111",
+		Counter:       counter,
+		Value:         "7",
+		Attributes:    map[string]string{"jobid": "1234"},
+	})
+
+	w := &bytes.Buffer{}
+	renderer := NewRenderer(&appconfig.Config{GPULabelOrder: []string{"Hostname", "UUID", "GPU_I_ID", "gpu"}})
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+
+	assert.Contains(t, w.String(),
+		`TEST_METRIC{Hostname="testhost",UUID="GPU-00000000-0000-0000-0000-000000000000",gpu="0",pci_bus_id="",device="nvidia0",modelName="NVIDIA T400 4GB"} 42`)
+	assert.Contains(t, w.String(),
+		`TEST_METRIC{UUID="MIG-11111111-1111-1111-1111-111111111111",GPU_I_ID="7",gpu="1",pci_bus_id="",device="nvidia1",modelName="NVIDIA A100",GPU_I_PROFILE="1g.10gb",jobid="1234"} 7`,
+		"labels that are not set are skipped and the labels not named follow in their default order")
+
+	w.Reset()
+	require.NoError(t, RenderGroup(w, dcgm.FE_GPU, getMetricsByCounterWithTestMetric()))
+	assert.Contains(t, w.String(),
+		`TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost"} 42`,
+		"the default order is unchanged")
+}
+
+func TestRenderGroupOmitEmptyGPULabels(t *testing.T) {
+	newMetrics := func() collector.MetricsByCounter {
+		metrics := getMetricsByCounterWithTestMetric()
+		metrics[getTestMetric()][0].GPUModelName = ""
+		metrics[getTestMetric()][0].Attributes = map[string]string{transformation.HpcJobAttribute: "42"}
+		return metrics
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderGroup(w, dcgm.FE_GPU, newMetrics()))
+	assert.Contains(t, w.String(), `pci_bus_id="",device="nvidia0",modelName="",Hostname="testhost"`, "empty labels are kept by default")
+
+	w.Reset()
+	renderer := NewRenderer(&appconfig.Config{OmitEmptyGPULabels: true})
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, newMetrics()))
+	assert.Contains(t, w.String(),
+		`TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",device="nvidia0",Hostname="testhost",jobid="42"} 42`)
+	assert.Contains(t, w.String(),
+		`nvidia_gpu_jobId{minor_number="0",uuid="GPU-00000000-0000-0000-0000-000000000000",device="nvidia0",Hostname="testhost",jobid="42"} 42`)
+	assert.NotContains(t, w.String(), "modelName")
+	assert.NotContains(t, w.String(), "pci_bus_id")
+}
+
+func TestRenderGroupMinorNumber(t *testing.T) {
+	counter := counters.Counter{
+		FieldID:        155,
+		FieldName:      "DCGM_FI_DEV_POWER_USAGE",
+		PromType:       "gauge",
+		Help:           "Power draw (in W).",
+		AlterFieldName: "nvidia_gpu_power_usage_watts",
+		AlterHelp:      "Power draw.",
+		Multiplier:     1,
+	}
+	newMetrics := func(deviceMinor string) collector.MetricsByCounter {
+		return collector.MetricsByCounter{
+			counter: {{
+				Counter:     counter,
+				Value:       "215",
+				AlterValue:  "215",
+				GPU:         "0",
+				GPUDevice:   "nvidia0",
+				AlterUUID:   "GPU-0",
+				DeviceMinor: deviceMinor,
+				Hostname:    "testhost",
+				Attributes:  map[string]string{transformation.HpcJobAttribute: "42"},
+			}},
+		}
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderGroup(w, dcgm.FE_GPU, newMetrics("")))
+	assert.Contains(t, w.String(), `nvidia_gpu_power_usage_watts{minor_number="0",uuid="GPU-0",`, "the DCGM index when unresolved")
+
+	w.Reset()
+	require.NoError(t, RenderGroup(w, dcgm.FE_GPU, newMetrics("3")))
+	assert.Contains(t, w.String(), `nvidia_gpu_power_usage_watts{minor_number="3",uuid="GPU-0",`, "the resolved device minor number")
+	assert.Contains(t, w.String(), `nvidia_gpu_jobId{minor_number="3",uuid="GPU-0",`)
+
+	w.Reset()
+	renderer := NewRenderer(&appconfig.Config{MinorNumberLabel: "gpu_index"})
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, newMetrics("")))
+	assert.Contains(t, w.String(), `nvidia_gpu_power_usage_watts{gpu_index="0",uuid="GPU-0",`)
+	assert.Contains(t, w.String(), `nvidia_gpu_jobId{gpu_index="0",uuid="GPU-0",`)
+	assert.NotContains(t, w.String(), "minor_number")
+}
+
+func TestRenderGroupFieldTimestamps(t *testing.T) {
+	temp := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Help: "GPU temperature (in C)."}
+	power := counters.Counter{FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W)."}
+	clock := counters.Counter{FieldName: "DCGM_FI_DEV_SM_CLOCK", PromType: "gauge", Help: "SM clock frequency (in MHz)."}
+	metrics := collector.MetricsByCounter{
+		temp:  {{GPU: "0", UUID: "UUID", Hostname: "testhost", Counter: temp, Value: "40", UpdatedAt: time.UnixMilli(1700000000123)}},
+		power: {{GPU: "0", UUID: "UUID", Hostname: "testhost", Counter: power, Value: "100", UpdatedAt: time.UnixMilli(1700000004567)}},
+		clock: {{GPU: "0", UUID: "UUID", Hostname: "testhost", Counter: clock, Value: "1410"}},
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{EnableFieldTimestamps: true}).RenderGroup(w, dcgm.FE_GPU, metrics))
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_TEMP{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 40 1700000000123`+"\n")
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_POWER_USAGE{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 100 1700000004567`+"\n")
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_SM_CLOCK{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 1410`+"\n",
+		"no timestamp without update time")
+
+	w.Reset()
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderGroup(w, dcgm.FE_GPU, metrics))
+	assert.NotContains(t, w.String(), "1700000000123", "disabled by default")
+	assert.NotContains(t, w.String(), "1700000004567")
+}
+
+func TestValidateGPULabelOrder(t *testing.T) {
+	assert.NoError(t, ValidateGPULabelOrder([]string{"uuid", "gpu"}))
+	assert.Error(t, ValidateGPULabelOrder([]string{"jobid"}))
+	assert.Error(t, ValidateGPULabelOrder([]string{"UUID", "uuid"}))
+}
+
+// oneDecimalFormatter rounds values to one decimal
+type oneDecimalFormatter struct{}
+
+func (oneDecimalFormatter) FormatValue(_ counters.Counter, value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
+
+func TestRenderGroupValueFormatter(t *testing.T) {
+	newMetrics := func() collector.MetricsByCounter {
+		metrics := getMetricsByCounterWithTestMetric()
+		metrics[getTestMetric()][0].Value = "41.987654"
+		return metrics
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderGroup(w, dcgm.FE_GPU, newMetrics()))
+	assert.Contains(t, w.String(), `Hostname="testhost"} 41.987654`, "values are rendered unchanged by default")
+
+	renderer := NewRenderer(&appconfig.Config{})
+	renderer.SetValueFormatter(oneDecimalFormatter{})
+	w = &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, newMetrics()))
+	assert.Contains(t, w.String(), `Hostname="testhost"} 42.0`)
+	assert.NotContains(t, w.String(), "41.987654")
+}
+
+func TestRenderGroupValueFormatterAlterValue(t *testing.T) {
+	counter := counters.Counter{
+		FieldID:        155,
+		FieldName:      "DCGM_FI_DEV_POWER_USAGE",
+		PromType:       "gauge",
+		Help:           "Power draw (in W).",
+		AlterFieldName: "nvidia_gpu_power_usage_watts",
+		AlterHelp:      "Power draw.",
+		Multiplier:     1,
+	}
+	metrics := collector.MetricsByCounter{
+		counter: {{
+			Counter:    counter,
+			Value:      "215.123456",
+			AlterValue: "215.123456",
+			GPU:        "0",
+			GPUUUID:    "GPU-00000000-0000-0000-0000-000000000000",
+			AlterUUID:  "GPU-00000000-0000-0000-0000-000000000000",
+			Hostname:   "testhost",
+			Labels:     map[string]string{},
+			Attributes: map[string]string{},
+		}},
+	}
+
+	renderer := NewRenderer(&appconfig.Config{})
+	renderer.SetValueFormatter(oneDecimalFormatter{})
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+
+	var values []string
+	for _, line := range strings.Split(w.String(), "\n") {
+		if strings.HasPrefix(line, "DCGM_FI_DEV_POWER_USAGE{") || strings.HasPrefix(line, "nvidia_gpu_power_usage_watts{") {
+			values = append(values, line[strings.LastIndex(line, " ")+1:])
+		}
+	}
+	assert.Equal(t, []string{"215.1", "215.1"}, values)
+}
+
+func TestRenderGroupIntegerValues(t *testing.T) {
+	integer := counters.Counter{
+		FieldID: 313, FieldName: "DCGM_FI_DEV_ECC_DBE_VOL_TOTAL", PromType: "counter", Integer: true,
+	}
+	fractional := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		integer: {
+			{Counter: integer, Value: "5.000000", GPU: "0", Hostname: "testhost"},
+			{Counter: integer, Value: "2.500000", GPU: "1", Hostname: "testhost"},
+			{Counter: integer, Value: "7", GPU: "2", Hostname: "testhost"},
+		},
+		fractional: {
+			{Counter: fractional, Value: "5.000000", GPU: "0", Hostname: "testhost"},
+		},
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, RenderGroup(w, dcgm.FE_GPU, metrics))
+	assert.Regexp(t, `DCGM_FI_DEV_ECC_DBE_VOL_TOTAL\{gpu="0",.*\} 5\n`, w.String())
+	assert.Regexp(t, `DCGM_FI_DEV_ECC_DBE_VOL_TOTAL\{gpu="1",.*\} 2\.500000\n`, w.String(),
+		"fractional values are untouched")
+	assert.Regexp(t, `DCGM_FI_DEV_ECC_DBE_VOL_TOTAL\{gpu="2",.*\} 7\n`, w.String())
+	assert.Regexp(t, `DCGM_FI_DEV_POWER_USAGE\{gpu="0",.*\} 5\.000000\n`, w.String(),
+		"the values of fields that are not integer are untouched")
+}
+
+func TestRenderGroupLabelEscaping(t *testing.T) {
+	const value = `a "quoted" C:\path`
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: appconfig.LabelEscapingStrict, want: `a \"quoted\" C:\\path`},
+		{mode: appconfig.LabelEscapingStrip, want: `a quoted C:path`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			renderer := NewRenderer(&appconfig.Config{LabelEscaping: tt.mode})
+
+			metrics := getMetricsByCounterWithTestMetric()
+			metric := &metrics[getTestMetric()][0]
+			metric.GPUModelName = value
+			metric.Attributes = map[string]string{
+				transformation.HpcJobAttribute:  "42",
+				transformation.HpcUserAttribute: value,
+			}
+			w := &bytes.Buffer{}
+			require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+			assert.Contains(t, w.String(), `TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="`+tt.want+`",`)
+			assert.Contains(t, w.String(), `,userid="`+tt.want+`"} 42`)
+			assert.Contains(t, w.String(), `nvidia_gpu_jobUid{minor_number="0",uuid="GPU-00000000-0000-0000-0000-000000000000",device="nvidia0",modelName="`+tt.want+`",Hostname="testhost",jobid="42",userid="`+tt.want+`"} 0`,
+				"RenderSlurm escapes the label values too")
+
+			switchMetrics := getSwitchMetricsByCounter("")
+			switchMetrics[getTestMetric()][0].Labels = map[string]string{"label": value}
+			w.Reset()
+			require.NoError(t, renderer.RenderGroup(w, dcgm.FE_SWITCH, switchMetrics))
+			assert.Contains(t, w.String(), `TEST_METRIC{nvswitch="0",Hostname="testhost",label="`+tt.want+`"} 42`)
+		})
+	}
+}
+
+func TestRenderGroupLineEnding(t *testing.T) {
+	tests := []struct {
+		lineEnding string
+		want       string
+	}{
+		{lineEnding: "", want: "\n"},
+		{lineEnding: appconfig.LineEndingLF, want: "\n"},
+		{lineEnding: appconfig.LineEndingCRLF, want: "\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lineEnding, func(t *testing.T) {
+			renderer := NewRenderer(&appconfig.Config{LineEnding: tt.lineEnding})
+
+			metrics := getMetricsByCounterWithTestMetric()
+			metrics[getTestMetric()][0].Attributes = map[string]string{transformation.HpcJobAttribute: "42"}
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+
+			// the buffered output is flushed through the line translation
+			out := buf.String()
+			require.Contains(t, out, "nvidia_gpu_jobId", "RenderSlurm uses the line ending too")
+			lines := strings.SplitAfter(out, "\n")
+			require.Greater(t, len(lines), 2)
+			for _, line := range lines[:len(lines)-1] {
+				assert.True(t, strings.HasSuffix(line, tt.want), "line %q", line)
+				assert.NotContains(t, strings.TrimSuffix(line, tt.want), "\r")
+			}
+			assert.Empty(t, lines[len(lines)-1])
+		})
+	}
+}
+
+func TestRenderGroupCohorts(t *testing.T) {
+	metrics := func() collector.MetricsByCounter {
+		metrics := getMetricsByCounterWithTestMetric()
+		counter := getTestMetric()
+		gpu := metrics[counter][0]
+		for i, gpuModel := range []struct{ uuid, model string }{
+			{uuid: "GPU-12345678", model: "NVIDIA H100 80GB HBM3"},
+			{uuid: "GPU-abcdef00", model: "NVIDIA A100-SXM4-80GB"},
+			{uuid: "GPU-12ffffff", model: "NVIDIA A100-SXM4-80GB"},
+		} {
+			metric := gpu
+			metric.GPU = strconv.Itoa(i + 1)
+			metric.GPUUUID, metric.AlterUUID, metric.GPUModelName = gpuModel.uuid, gpuModel.uuid, gpuModel.model
+			metrics[counter] = append(metrics[counter], metric)
+		}
+		// MIG instances belong to the cohort of their GPU
+		mig := metrics[counter][2]
+		mig.MigProfile, mig.GPUInstanceID = "1g.10gb", "7"
+		metrics[counter] = append(metrics[counter], mig)
+		return metrics
+	}
+
+	rules := []appconfig.CohortRule{
+		{Field: appconfig.CohortMatchUUID, Pattern: "GPU-12*", Cohort: "canary"},
+		{Field: appconfig.CohortMatchModel, Pattern: "*A100*", Cohort: "a100"},
+	}
+
+	tests := []struct {
+		name          string
+		defaultCohort string
+		want          []string
+	}{
+		{name: "Without default cohort", want: []string{"", "canary", "a100", "canary", "a100"}},
+		{name: "With default cohort", defaultCohort: "other", want: []string{"other", "canary", "a100", "canary", "a100"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderer := NewRenderer(&appconfig.Config{CohortRules: rules, DefaultCohort: tt.defaultCohort})
+
+			var got []string
+			renderer.SetMetricCallback(func(_ dcgm.Field_Entity_Group, _ counters.Counter, metric collector.Metric) error {
+				got = append(got, metric.Attributes["cohort"])
+				return nil
+			})
+			w := &bytes.Buffer{}
+			require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics()))
+			assert.Equal(t, tt.want, got)
+			assert.Contains(t, w.String(), `modelName="NVIDIA A100-SXM4-80GB",Hostname="testhost",cohort="a100"} 42`)
+			assert.Contains(t, w.String(), `modelName="NVIDIA H100 80GB HBM3",Hostname="testhost",cohort="canary"} 42`)
+		})
+	}
+}
+
+func TestRenderGroupReservedRendererLabels(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	// a DCGM label and an attribute named as the labels set by the renderer
+	metrics[counter][0].Labels = map[string]string{"cohort": "label"}
+	metrics[counter][0].Attributes = map[string]string{"tenant": "attribute"}
+
+	renderer := NewRenderer(&appconfig.Config{
+		DefaultCohort:   "other",
+		Tenants:         map[string]appconfig.Tenant{"physics": {GPUUUIDs: []string{metrics[counter][0].GPUUUID}}},
+		TenantLabelMode: appconfig.TenantLabelModeLabel,
+	})
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(w.Bytes()))
+	require.NoError(t, err, w.String())
+	labels := map[string]string{}
+	for _, label := range families["TEST_METRIC"].GetMetric()[0].GetLabel() {
+		assert.NotContains(t, labels, label.GetName(), "the %s label is rendered once", label.GetName())
+		labels[label.GetName()] = label.GetValue()
+	}
+	assert.Equal(t, "other", labels["cohort"])
+	assert.Equal(t, "physics", labels["tenant"])
+}
+
+func TestRenderGroupModelNames(t *testing.T) {
+	metrics := getMetricsByCounterWithTestMetric()
+	counter := getTestMetric()
+	gpu := metrics[counter][0]
+	metrics[counter] = nil
+	for i, model := range []string{"NVIDIA A100-SXM4-80GB", "NVIDIA A100 80GB PCIe", "NVIDIA A100-SXM4-40GB", ""} {
+		metric := gpu
+		metric.GPU = strconv.Itoa(i)
+		metric.GPUModelName = model
+		metrics[counter] = append(metrics[counter], metric)
+	}
+
+	renderer := NewRenderer(&appconfig.Config{ModelNameRules: []appconfig.ModelNameRule{
+		{Pattern: regexp.MustCompile(`NVIDIA A100.*80GB`), Name: "A100-80GB"},
+		{Pattern: regexp.MustCompile(`A100`), Name: "A100"},
+	}})
+	var got []string
+	renderer.SetMetricCallback(func(_ dcgm.Field_Entity_Group, _ counters.Counter, metric collector.Metric) error {
+		got = append(got, metric.GPUModelName)
+		return nil
+	})
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+
+	assert.Equal(t, []string{"A100-80GB", "A100-80GB", "A100", ""}, got, "the first matching rule wins")
+	assert.Contains(t, w.String(), `TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="A100-80GB"`)
+	assert.Contains(t, w.String(), `TEST_METRIC{gpu="1",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="A100-80GB"`)
+	assert.NotContains(t, w.String(), "SXM4")
+
+	metrics[counter][0].GPUModelName = "Tesla V100-SXM2-32GB"
+	w.Reset()
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+	assert.Contains(t, w.String(), `modelName="Tesla V100-SXM2-32GB"`, "unmatched names are unchanged")
+}
+
+func TestRenderGroupsContextDeadline(t *testing.T) {
+	groups := map[dcgm.Field_Entity_Group]collector.MetricsByCounter{
+		dcgm.FE_GPU:    getMetricsByCounterWithTestMetric(),
+		dcgm.FE_SWITCH: getSwitchMetricsByCounter(""),
+	}
+
+	renderer := NewRenderer(&appconfig.Config{RenderDeadline: 20 * time.Millisecond})
+	// the GPU group, rendered first, takes longer than the deadline
+	renderer.SetMetricCallback(func(group dcgm.Field_Entity_Group, _ counters.Counter, _ collector.Metric) error {
+		if group == dcgm.FE_GPU {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), renderer.config.RenderDeadline)
+	defer cancel()
+	w := &bytes.Buffer{}
+	err := renderer.RenderGroupsContext(ctx, w, groups)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the output ends with the whole GPU group, without job series
+	assert.Contains(t, w.String(), `TEST_METRIC{gpu="0",`)
+	assert.True(t, strings.HasSuffix(w.String(), `Hostname="testhost"} 42`+"\n"), "the output ends at a group boundary")
+	assert.NotContains(t, w.String(), `nvswitch=`)
+
+	w.Reset()
+	require.ErrorIs(t, renderer.RenderGroupContext(ctx, w, dcgm.FE_SWITCH, groups[dcgm.FE_SWITCH], Scrape{}), context.DeadlineExceeded)
+	assert.Empty(t, w.String(), "nothing is rendered past the deadline")
+
+	w.Reset()
+	require.NoError(t, renderer.RenderDeadlineExceeded(w))
+	assert.Contains(t, w.String(), "# TYPE dcgm_exporter_render_deadline_exceeded counter\n")
+	assert.Contains(t, w.String(), "dcgm_exporter_render_deadline_exceeded 2\n")
+
+	w.Reset()
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderDeadlineExceeded(w))
+	assert.Empty(t, w.String(), "the counter is rendered with a deadline only")
+}
+
+func TestRenderGroupRoundedFields(t *testing.T) {
+	power := counters.Counter{
+		FieldID:        155,
+		FieldName:      "DCGM_FI_DEV_POWER_USAGE",
+		PromType:       "gauge",
+		AlterFieldName: "nvidia_gpu_power_usage_watts",
+		Multiplier:     1,
+	}
+	util := counters.Counter{FieldID: 203, FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		power: {{Counter: power, Value: "215.623456", AlterValue: "215.623456", GPU: "0", Hostname: "testhost"}},
+		util:  {{Counter: util, Value: "87.654321", GPU: "0", Hostname: "testhost"}},
+	}
+
+	renderer := NewRenderer(&appconfig.Config{})
+	renderer.SetValueFormatter(collector.NewRoundingValueFormatter([]string{"DCGM_FI_DEV_POWER_USAGE"}))
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+
+	values := map[string]string{}
+	for _, line := range strings.Split(w.String(), "\n") {
+		name, _, found := strings.Cut(line, "{")
+		if !found {
+			continue
+		}
+		values[name] = line[strings.LastIndex(line, " ")+1:]
+	}
+	assert.Equal(t, "216", values["DCGM_FI_DEV_POWER_USAGE"])
+	assert.Equal(t, "216", values["nvidia_gpu_power_usage_watts"], "the alternate series is rounded too")
+	assert.Equal(t, "87.654321", values["DCGM_FI_DEV_GPU_UTIL"], "the other fields keep their precision")
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rendermetrics
+
+import (
+	"hash/fnv"
+	"slices"
+	"strconv"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+// samplingEnabled tells whether the metrics are sampled, which renders only part of them
+// to cut the scrape size, e.g. of a smoke test on a large node, and is never meant as a filter.
+func (r *Renderer) samplingEnabled() bool {
+	return (r.config.SampleRate > 0 && r.config.SampleRate < 1) || len(r.config.SampleFields) > 0
+}
+
+// withSampling keeps the metrics of the sampled entities and, if the group has a list of sampled
+// fields, of those fields only.
+func (r *Renderer) withSampling(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	if !r.samplingEnabled() {
+		return metrics
+	}
+	fields := r.config.SampleFields[group]
+	sampled := make(collector.MetricsByCounter, len(metrics))
+	for counter, values := range metrics {
+		if len(fields) > 0 && !slices.Contains(fields, counter.FieldName) {
+			continue
+		}
+		var kept []collector.Metric
+		for _, metric := range values {
+			if isSampled(metric.GPU, r.config.SampleRate) {
+				kept = append(kept, metric)
+			}
+		}
+		if len(kept) > 0 {
+			sampled[counter] = kept
+		}
+	}
+	return sampled
+}
+
+// isSampled tells whether the entity is in the sample. Entities are sampled evenly by index,
+// e.g. every other one at a rate of 0.5, so MIG instances are sampled along with their GPU.
+func isSampled(entity string, rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	index, err := strconv.ParseUint(entity, 10, 64)
+	if err != nil {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(entity))
+		index = h.Sum64() % 1000
+	}
+	return uint64(float64(index+1)*rate) != uint64(float64(index)*rate)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rendermetrics
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func getSamplingMetrics(gpus int) collector.MetricsByCounter {
+	metrics := collector.MetricsByCounter{}
+	temp := counters.Counter{FieldID: 150, FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge"}
+	power := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	for _, counter := range []counters.Counter{temp, power} {
+		for i := 0; i < gpus; i++ {
+			metrics[counter] = append(metrics[counter], collector.Metric{
+				Counter:   counter,
+				Value:     "42",
+				GPU:       fmt.Sprintf("%d", i),
+				GPUDevice: fmt.Sprintf("nvidia%d", i),
+				UUID:      "UUID",
+			})
+		}
+	}
+	return metrics
+}
+
+func TestRenderGroupSampleRate(t *testing.T) {
+	w := &bytes.Buffer{}
+	renderer := NewRenderer(&appconfig.Config{SampleRate: 0.5})
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, getSamplingMetrics(16)))
+
+	rendered := strings.Count(w.String(), "DCGM_FI_DEV_GPU_TEMP{")
+	assert.InDelta(t, 8, rendered, 1, "roughly half the GPUs are rendered")
+	assert.Equal(t, rendered, strings.Count(w.String(), "DCGM_FI_DEV_POWER_USAGE{"),
+		"all the fields of a sampled GPU are rendered")
+}
+
+func TestRenderGroupSampleFields(t *testing.T) {
+	w := &bytes.Buffer{}
+	renderer := NewRenderer(&appconfig.Config{
+		SampleFields: map[dcgm.Field_Entity_Group][]string{dcgm.FE_GPU: {"DCGM_FI_DEV_GPU_TEMP"}},
+	})
+	require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, getSamplingMetrics(4)))
+
+	assert.Equal(t, 4, strings.Count(w.String(), "DCGM_FI_DEV_GPU_TEMP{"))
+	assert.NotContains(t, w.String(), "DCGM_FI_DEV_POWER_USAGE")
+}
+
+func TestIsSampled(t *testing.T) {
+	var sampled []string
+	for i := 0; i < 6; i++ {
+		if isSampled(fmt.Sprintf("%d", i), 0.5) {
+			sampled = append(sampled, fmt.Sprintf("%d", i))
+		}
+	}
+	assert.Equal(t, []string{"1", "3", "5"}, sampled, "every other GPU")
+	assert.True(t, isSampled("7", 0), "everything is sampled when sampling is disabled")
+	assert.True(t, isSampled("7", 1))
+}
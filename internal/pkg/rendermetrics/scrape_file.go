@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+// ScrapeFile writes the most recent rendered scrape to a file, for debugging. The file is
+// replaced atomically, through a temporary file renamed over it, in the background so that the
+// scrape is not slowed down. The outputs stored while a write is in progress are coalesced, only
+// the most recent is written next.
+type ScrapeFile struct {
+	path     string
+	maxBytes int
+
+	mu      sync.Mutex
+	pending []byte
+	writing bool
+	wg      sync.WaitGroup
+}
+
+func NewScrapeFile(path string, maxBytes int) *ScrapeFile {
+	return &ScrapeFile{
+		path:     path,
+		maxBytes: maxBytes,
+	}
+}
+
+// Store writes a copy of the output to the file in the background. An output larger than the
+// byte bound is cut after its last line within the bound.
+func (f *ScrapeFile) Store(output []byte) {
+	if f.maxBytes > 0 && len(output) > f.maxBytes {
+		output = output[:bytes.LastIndexByte(output[:f.maxBytes], '\n')+1]
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pending = append([]byte{}, output...)
+	if f.writing {
+		return
+	}
+	f.writing = true
+	f.wg.Add(1)
+	go f.writePending()
+}
+
+// Wait waits for the file to be written with the outputs stored so far.
+func (f *ScrapeFile) Wait() {
+	f.wg.Wait()
+}
+
+// writePending writes the pending outputs until there is none left
+func (f *ScrapeFile) writePending() {
+	defer f.wg.Done()
+	for {
+		f.mu.Lock()
+		output := f.pending
+		f.pending = nil
+		if output == nil {
+			f.writing = false
+			f.mu.Unlock()
+			return
+		}
+		f.mu.Unlock()
+
+		if err := writeFileAtomic(f.path, output); err != nil {
+			slog.Warn("Failed to write the rendered scrape",
+				slog.String("file", f.path), slog.String(logging.ErrorKey, err.Error()))
+		}
+	}
+}
+
+// writeFileAtomic replaces the file with the data, so that the file is never read half written
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
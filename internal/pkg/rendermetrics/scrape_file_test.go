@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrapeFileKeepsLastScrape(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scrape.prom")
+	file := NewScrapeFile(path, 0)
+
+	file.Store([]byte("scrape 1\n"))
+	file.Wait()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "scrape 1\n", string(data))
+
+	output := []byte("scrape 2\n")
+	file.Store(output)
+	output[0] = 'S'
+	file.Wait()
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "scrape 2\n", string(data), "the file is replaced with a copy of the next scrape")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestScrapeFileBoundedByBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scrape.prom")
+	file := NewScrapeFile(path, 20)
+
+	file.Store([]byte("0123456789\nabcdefghij\nABCDEFGHIJ\n"))
+	file.Wait()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789\n", string(data), "the output is cut after its last line within the bound")
+}
+
+func TestScrapeFileWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "scrape.prom")
+	file := NewScrapeFile(path, 0)
+
+	// a failed write is logged and does not hold up the next ones
+	file.Store([]byte("scrape 1\n"))
+	file.Wait()
+	file.Store([]byte("scrape 2\n"))
+	file.Wait()
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// RenderedScrape is the output of a scrape along with the time it was rendered.
+type RenderedScrape struct {
+	Time   time.Time
+	Output []byte
+}
+
+// ScrapeHistory is a ring buffer retaining the most recent rendered scrapes,
+// bounded both by the number of scrapes and by their total size.
+type ScrapeHistory struct {
+	mu       sync.Mutex
+	maxCount int
+	maxBytes int
+	scrapes  []RenderedScrape
+	size     int
+}
+
+func NewScrapeHistory(maxCount, maxBytes int) *ScrapeHistory {
+	return &ScrapeHistory{
+		maxCount: maxCount,
+		maxBytes: maxBytes,
+	}
+}
+
+// Add retains a copy of the output, evicting the oldest scrapes to stay within the bounds.
+// An output larger than the byte bound on its own is not retained.
+func (h *ScrapeHistory) Add(t time.Time, output []byte) {
+	if h.maxBytes > 0 && len(output) > h.maxBytes {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.scrapes = append(h.scrapes, RenderedScrape{Time: t, Output: slices.Clone(output)})
+	h.size += len(output)
+
+	for len(h.scrapes) > h.maxCount || (h.maxBytes > 0 && h.size > h.maxBytes) {
+		h.size -= len(h.scrapes[0].Output)
+		h.scrapes[0] = RenderedScrape{}
+		h.scrapes = h.scrapes[1:]
+	}
+}
+
+// Scrapes returns the retained scrapes, oldest first.
+func (h *ScrapeHistory) Scrapes() []RenderedScrape {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.scrapes)
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrapeHistoryEvictsOldest(t *testing.T) {
+	const maxCount = 3
+	history := NewScrapeHistory(maxCount, 0)
+
+	start := time.Now()
+	for i := 0; i <= maxCount; i++ {
+		history.Add(start.Add(time.Duration(i)*time.Second), []byte(fmt.Sprintf("scrape %d\n", i)))
+	}
+
+	scrapes := history.Scrapes()
+	require.Len(t, scrapes, maxCount)
+	assert.Equal(t, "scrape 1\n", string(scrapes[0].Output), "the oldest scrape is evicted")
+	assert.Equal(t, start.Add(time.Second), scrapes[0].Time)
+	assert.Equal(t, "scrape 3\n", string(scrapes[maxCount-1].Output))
+}
+
+func TestScrapeHistoryBoundedByBytes(t *testing.T) {
+	history := NewScrapeHistory(10, 20)
+
+	history.Add(time.Now(), []byte("0123456789"))
+	history.Add(time.Now(), []byte("abcdefghij"))
+	require.Len(t, history.Scrapes(), 2)
+
+	history.Add(time.Now(), []byte("ABCDEFGHIJ"))
+	scrapes := history.Scrapes()
+	require.Len(t, scrapes, 2)
+	assert.Equal(t, "abcdefghij", string(scrapes[0].Output))
+
+	history.Add(time.Now(), []byte("this output is larger than the bound"))
+	assert.Len(t, history.Scrapes(), 2, "an output larger than the bound is not retained")
+}
+
+func TestScrapeHistoryCopiesOutput(t *testing.T) {
+	history := NewScrapeHistory(1, 0)
+	output := []byte("scrape")
+	history.Add(time.Now(), output)
+	output[0] = 'S'
+	assert.Equal(t, "scrape", string(history.Scrapes()[0].Output))
+}
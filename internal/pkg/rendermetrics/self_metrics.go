@@ -0,0 +1,341 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+const (
+	renderDurationMetric         = "dcgm_exporter_render_duration_seconds"
+	renderDeadlineExceededMetric = "dcgm_exporter_render_deadline_exceeded"
+	mappingConflictsMetric       = "dcgm_hpc_mapping_conflicts"
+	mappingOversizeMetric        = "dcgm_hpc_mapping_oversize"
+	mappingCoverageMetric        = "dcgm_hpc_mapping_coverage_ratio"
+	mappingFilesMetric           = "dcgm_hpc_mapping_files"
+	mappingFileInfoMetric        = "dcgm_hpc_mapping_file_info"
+	mappingAgeMetric             = "dcgm_hpc_mapping_age_seconds"
+	groupUpMetric                = "dcgm_exporter_group_up"
+	scrapePhaseMetric            = "dcgm_exporter_scrape_phase_seconds"
+	// slurmRenderGroup is the group label of the time spent in RenderSlurm
+	slurmRenderGroup = "slurm"
+)
+
+const (
+	renderDurationHelp         = "Time spent rendering the metrics of an entity group on the last scrape"
+	scrapePhaseHelp            = "Time spent in each phase of the scrape, summed over the entity groups"
+	groupUpHelp                = "Whether the entity group is collected"
+	mappingConflictsHelp       = "Number of times a GPU was claimed by several HPC job mapping files on a scrape"
+	mappingOversizeHelp        = "Number of times an HPC job mapping file was skipped for exceeding the maximum size"
+	mappingCoverageHelp        = "Fraction of the active GPUs mapped to an HPC job on the last scrape"
+	mappingFilesHelp           = "Number of HPC job mapping files read on the last scrape"
+	mappingFileInfoHelp        = "Most recently modified HPC job mapping files read on the last scrape"
+	mappingAgeHelp             = "Time since the HPC job mapping applied was fetched from its backend"
+	renderDeadlineExceededHelp = "Number of scrapes whose rendering was aborted for exceeding the render deadline"
+)
+
+// The phases of a scrape, as passed to RenderSelfMetrics along with the render durations of the groups
+const (
+	// ScrapePhaseCollect is the time spent gathering the metrics from the collectors
+	ScrapePhaseCollect = "collect"
+	// ScrapePhaseMap is the time spent in the transformations, e.g. the job mappers, of every group
+	ScrapePhaseMap = "map"
+	// ScrapePhaseRender is the time spent rendering every group
+	ScrapePhaseRender = "render"
+)
+
+func (s Scrape) observeRenderDuration(group string, d time.Duration) {
+	if s.RenderDurations != nil {
+		s.RenderDurations[group] = d
+	}
+}
+
+// RenderSelfMetrics renders the metrics the exporter keeps about itself, when enabled, with the
+// time spent rendering each group and in each phase of the scrape being rendered. It is expected
+// to be called once per scrape, after every group is rendered.
+func (r *Renderer) RenderSelfMetrics(w io.Writer, renderDurations, phases map[string]time.Duration) error {
+	if !r.config.EnableSelfMetrics {
+		return nil
+	}
+	w = r.lineWriter(w)
+
+	var sb strings.Builder
+	staticLabels := r.staticLabelPairs()
+	if len(renderDurations) > 0 {
+		fmt.Fprintf(&sb, "# HELP %s %s\n", renderDurationMetric, renderDurationHelp)
+		fmt.Fprintf(&sb, "# TYPE %s gauge\n", renderDurationMetric)
+		for _, group := range slices.Sorted(maps.Keys(renderDurations)) {
+			fmt.Fprintf(&sb, "%s{group=\"%s\"%s} %f\n",
+				renderDurationMetric, group, staticLabels, renderDurations[group].Seconds())
+		}
+	}
+	if len(phases) > 0 {
+		fmt.Fprintf(&sb, "# HELP %s %s\n", scrapePhaseMetric, scrapePhaseHelp)
+		fmt.Fprintf(&sb, "# TYPE %s gauge\n", scrapePhaseMetric)
+		for _, phase := range slices.Sorted(maps.Keys(phases)) {
+			fmt.Fprintf(&sb, "%s{phase=\"%s\"%s} %f\n",
+				scrapePhaseMetric, phase, staticLabels, phases[phase].Seconds())
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CollectSelfMetrics is RenderSelfMetrics in the registry render mode
+func (r *Renderer) CollectSelfMetrics(set *MetricSet, renderDurations, phases map[string]time.Duration) error {
+	if !r.config.EnableSelfMetrics {
+		return nil
+	}
+	for _, group := range slices.Sorted(maps.Keys(renderDurations)) {
+		if err := set.add(r.newConstMetric(renderDurationMetric, renderDurationHelp, prometheus.GaugeValue,
+			renderDurations[group].Seconds(), r.withStaticLabels(labelPair{name: "group", value: group}))); err != nil {
+			return err
+		}
+	}
+	for _, phase := range slices.Sorted(maps.Keys(phases)) {
+		if err := set.add(r.newConstMetric(scrapePhaseMetric, scrapePhaseHelp, prometheus.GaugeValue,
+			phases[phase].Seconds(), r.withStaticLabels(labelPair{name: "phase", value: phase}))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupNames are the group label values of the entity groups, as named by the per group parameters
+var groupNames = map[dcgm.Field_Entity_Group]string{
+	dcgm.FE_GPU:      "gpu",
+	dcgm.FE_SWITCH:   "switch",
+	dcgm.FE_LINK:     "link",
+	dcgm.FE_CPU:      "cpu",
+	dcgm.FE_CPU_CORE: "cpu_core",
+}
+
+// RenderGroupsUp renders a health series set to 1 for each of the collected entity groups,
+// including the groups without any metric, so that an empty group can be told from a group
+// that is not collected.
+func (r *Renderer) RenderGroupsUp(w io.Writer, groups []dcgm.Field_Entity_Group) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	w = r.lineWriter(w)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n", groupUpMetric, groupUpHelp)
+	fmt.Fprintf(&sb, "# TYPE %s gauge\n", groupUpMetric)
+	staticLabels := r.staticLabelPairs()
+	for _, group := range groups {
+		fmt.Fprintf(&sb, "%s{group=\"%s\"%s} 1\n", groupUpMetric, groupName(group), staticLabels)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CollectGroupsUp is RenderGroupsUp in the registry render mode
+func (r *Renderer) CollectGroupsUp(set *MetricSet, groups []dcgm.Field_Entity_Group) error {
+	for _, group := range groups {
+		if err := set.add(r.newConstMetric(groupUpMetric, groupUpHelp, prometheus.GaugeValue, 1,
+			r.withStaticLabels(labelPair{name: "group", value: groupName(group)}))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupName returns the group label value of the entity group
+func groupName(group dcgm.Field_Entity_Group) string {
+	if name, ok := groupNames[group]; ok {
+		return name
+	}
+	return strings.ToLower(group.String())
+}
+
+// RenderMappingConflicts renders the number of times a GPU was claimed by several HPC job
+// mapping files on a scrape.
+func (r *Renderer) RenderMappingConflicts(w io.Writer, conflicts uint64) error {
+	return r.renderCounter(w, mappingConflictsMetric, mappingConflictsHelp, conflicts)
+}
+
+// CollectMappingConflicts is RenderMappingConflicts in the registry render mode
+func (r *Renderer) CollectMappingConflicts(set *MetricSet, conflicts uint64) error {
+	return r.collectCounter(set, mappingConflictsMetric, mappingConflictsHelp, conflicts)
+}
+
+// RenderMappingOversize renders the number of times an HPC job mapping file was skipped for
+// exceeding the maximum size.
+func (r *Renderer) RenderMappingOversize(w io.Writer, oversize uint64) error {
+	return r.renderCounter(w, mappingOversizeMetric, mappingOversizeHelp, oversize)
+}
+
+// CollectMappingOversize is RenderMappingOversize in the registry render mode
+func (r *Renderer) CollectMappingOversize(set *MetricSet, oversize uint64) error {
+	return r.collectCounter(set, mappingOversizeMetric, mappingOversizeHelp, oversize)
+}
+
+// RenderMappingCoverage renders the fraction of the active GPUs mapped to a job, labeled by
+// hostname. Nothing is rendered when no GPU is active.
+func (r *Renderer) RenderMappingCoverage(w io.Writer, coverage transformation.MappingCoverage) error {
+	if coverage.Active == 0 {
+		return nil
+	}
+	w = r.lineWriter(w)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n", mappingCoverageMetric, mappingCoverageHelp)
+	fmt.Fprintf(&sb, "# TYPE %s gauge\n", mappingCoverageMetric)
+	fmt.Fprintf(&sb, "%s{Hostname=\"%s\"%s} %g\n", mappingCoverageMetric, r.labelValue(r.coverageHostname(coverage)),
+		r.staticLabelPairs(), float64(coverage.Mapped)/float64(coverage.Active))
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CollectMappingCoverage is RenderMappingCoverage in the registry render mode
+func (r *Renderer) CollectMappingCoverage(set *MetricSet, coverage transformation.MappingCoverage) error {
+	if coverage.Active == 0 {
+		return nil
+	}
+	return set.add(r.newConstMetric(mappingCoverageMetric, mappingCoverageHelp, prometheus.GaugeValue,
+		float64(coverage.Mapped)/float64(coverage.Active),
+		r.withStaticLabels(labelPair{name: "Hostname", value: r.coverageHostname(coverage)})))
+}
+
+// coverageHostname returns the hostname the coverage is labeled with, that of the GPU metrics
+func (r *Renderer) coverageHostname(coverage transformation.MappingCoverage) string {
+	if override, ok := r.config.HostnameOverrides[dcgm.FE_GPU]; ok {
+		return override
+	}
+	return coverage.Hostname
+}
+
+// RenderMappingFiles renders the number of HPC job mapping files read on the last scrape, and an
+// info series for each of the most recent files when they are listed.
+func (r *Renderer) RenderMappingFiles(w io.Writer, count int, recent []string) error {
+	w = r.lineWriter(w)
+	staticLabels := r.staticLabelPairs()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n", mappingFilesMetric, mappingFilesHelp)
+	fmt.Fprintf(&sb, "# TYPE %s gauge\n", mappingFilesMetric)
+	labels := ""
+	if staticLabels != "" {
+		labels = "{" + strings.TrimPrefix(staticLabels, ",") + "}"
+	}
+	fmt.Fprintf(&sb, "%s%s %d\n", mappingFilesMetric, labels, count)
+	if len(recent) > 0 {
+		fmt.Fprintf(&sb, "# HELP %s %s\n", mappingFileInfoMetric, mappingFileInfoHelp)
+		fmt.Fprintf(&sb, "# TYPE %s gauge\n", mappingFileInfoMetric)
+		for _, file := range recent {
+			fmt.Fprintf(&sb, "%s{file=\"%s\"%s} 1\n", mappingFileInfoMetric, r.labelValue(file), staticLabels)
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CollectMappingFiles is RenderMappingFiles in the registry render mode
+func (r *Renderer) CollectMappingFiles(set *MetricSet, count int, recent []string) error {
+	if err := set.add(r.newConstMetric(mappingFilesMetric, mappingFilesHelp, prometheus.GaugeValue,
+		float64(count), r.withStaticLabels())); err != nil {
+		return err
+	}
+	for _, file := range recent {
+		if err := set.add(r.newConstMetric(mappingFileInfoMetric, mappingFileInfoHelp, prometheus.GaugeValue,
+			1, r.withStaticLabels(labelPair{name: "file", value: file}))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderMappingAge renders the time since the HPC job mapping applied was fetched from its backend,
+// which grows while the backend fails.
+func (r *Renderer) RenderMappingAge(w io.Writer, age time.Duration) error {
+	w = r.lineWriter(w)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n", mappingAgeMetric, mappingAgeHelp)
+	fmt.Fprintf(&sb, "# TYPE %s gauge\n", mappingAgeMetric)
+	labels := ""
+	if staticLabels := r.staticLabelPairs(); staticLabels != "" {
+		labels = "{" + strings.TrimPrefix(staticLabels, ",") + "}"
+	}
+	fmt.Fprintf(&sb, "%s%s %f\n", mappingAgeMetric, labels, age.Seconds())
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CollectMappingAge is RenderMappingAge in the registry render mode
+func (r *Renderer) CollectMappingAge(set *MetricSet, age time.Duration) error {
+	return set.add(r.newConstMetric(mappingAgeMetric, mappingAgeHelp, prometheus.GaugeValue, age.Seconds(),
+		r.withStaticLabels()))
+}
+
+// RenderDeadlineExceeded renders the number of renderings aborted for exceeding their deadline,
+// when a render deadline is configured.
+func (r *Renderer) RenderDeadlineExceeded(w io.Writer) error {
+	if r.config.RenderDeadline <= 0 {
+		return nil
+	}
+	return r.renderCounter(w, renderDeadlineExceededMetric, renderDeadlineExceededHelp, r.deadlineExceeded.Load())
+}
+
+// CollectDeadlineExceeded is RenderDeadlineExceeded in the registry render mode
+func (r *Renderer) CollectDeadlineExceeded(set *MetricSet) error {
+	if r.config.RenderDeadline <= 0 {
+		return nil
+	}
+	return r.collectCounter(set, renderDeadlineExceededMetric, renderDeadlineExceededHelp, r.deadlineExceeded.Load())
+}
+
+// renderCounter renders a counter labeled by the static labels only
+func (r *Renderer) renderCounter(w io.Writer, name, help string, value uint64) error {
+	w = r.lineWriter(w)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&sb, "# TYPE %s counter\n", name)
+	labels := ""
+	if staticLabels := r.staticLabelPairs(); staticLabels != "" {
+		labels = "{" + strings.TrimPrefix(staticLabels, ",") + "}"
+	}
+	fmt.Fprintf(&sb, "%s%s %d\n", name, labels, value)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// collectCounter is renderCounter in the registry render mode
+func (r *Renderer) collectCounter(set *MetricSet, name, help string, value uint64) error {
+	return set.add(r.newConstMetric(name, help, prometheus.CounterValue, float64(value), r.withStaticLabels()))
+}
+
+// withStaticLabels returns the labels followed by the static labels
+func (r *Renderer) withStaticLabels(labels ...labelPair) []labelPair {
+	return append(labels, r.staticLabelList()...)
+}
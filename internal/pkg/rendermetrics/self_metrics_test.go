@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+func TestRenderSelfMetricsRenderDuration(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{EnableSelfMetrics: true})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderSelfMetrics(w, nil, nil))
+	assert.Empty(t, w.String(), "nothing is rendered before the first scrape")
+
+	scrape := Scrape{RenderDurations: map[string]time.Duration{}}
+	ctx := context.Background()
+	require.NoError(t, renderer.RenderGroupContext(ctx, io.Discard, dcgm.FE_GPU, getMetricsByCounterWithTestMetric(), scrape))
+	require.NoError(t, renderer.RenderGroupContext(ctx, io.Discard, dcgm.FE_SWITCH, getSwitchMetricsByCounter(""), scrape))
+
+	assert.Contains(t, scrape.RenderDurations, "GPU")
+	assert.Contains(t, scrape.RenderDurations, "NvSwitch")
+	assert.Contains(t, scrape.RenderDurations, slurmRenderGroup)
+
+	require.NoError(t, renderer.RenderSelfMetrics(w, scrape.RenderDurations, nil))
+	assert.Contains(t, w.String(), "# TYPE dcgm_exporter_render_duration_seconds gauge\n")
+	assert.Contains(t, w.String(), `dcgm_exporter_render_duration_seconds{group="GPU"} `)
+	assert.Contains(t, w.String(), `dcgm_exporter_render_duration_seconds{group="NvSwitch"} `)
+	assert.Contains(t, w.String(), `dcgm_exporter_render_duration_seconds{group="slurm"} `)
+
+	// the groups of another scrape are not mixed in
+	other := Scrape{RenderDurations: map[string]time.Duration{}}
+	require.NoError(t, renderer.RenderGroupContext(ctx, io.Discard, dcgm.FE_CPU, getMetricsByCounterWithTestMetric(), other))
+	w.Reset()
+	require.NoError(t, renderer.RenderSelfMetrics(w, other.RenderDurations, nil))
+	assert.Equal(t, 1, strings.Count(w.String(), "dcgm_exporter_render_duration_seconds{"), w.String())
+	assert.Contains(t, w.String(), `dcgm_exporter_render_duration_seconds{group="CPU"} `)
+}
+
+func TestRenderSelfMetricsScrapePhases(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{EnableSelfMetrics: true})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderSelfMetrics(w, nil, map[string]time.Duration{
+		ScrapePhaseCollect: time.Second, ScrapePhaseMap: 2 * time.Second,
+	}))
+	assert.Contains(t, w.String(), `dcgm_exporter_scrape_phase_seconds{phase="collect"} 1.000000`)
+	assert.Contains(t, w.String(), `dcgm_exporter_scrape_phase_seconds{phase="map"} 2.000000`)
+
+	// the phases of another scrape are not mixed in
+	w.Reset()
+	require.NoError(t, renderer.RenderSelfMetrics(w, nil, map[string]time.Duration{ScrapePhaseRender: time.Second}))
+	assert.Equal(t, 1, strings.Count(w.String(), "dcgm_exporter_scrape_phase_seconds{"), w.String())
+	assert.Contains(t, w.String(), `dcgm_exporter_scrape_phase_seconds{phase="render"} 1.000000`)
+}
+
+func TestRenderSelfMetricsDisabled(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{})
+	renderDurations := map[string]time.Duration{}
+	require.NoError(t, renderer.RenderGroupContext(context.Background(), io.Discard, dcgm.FE_GPU,
+		getMetricsByCounterWithTestMetric(), Scrape{RenderDurations: renderDurations}))
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderSelfMetrics(w, renderDurations, nil))
+	assert.Empty(t, w.String())
+}
+
+func TestRenderMappingCoverage(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderMappingCoverage(w, transformation.MappingCoverage{Hostname: "node1"}))
+	assert.Empty(t, w.String(), "nothing is rendered without active GPUs")
+
+	require.NoError(t, renderer.RenderMappingCoverage(w,
+		transformation.MappingCoverage{Hostname: "node1", Active: 2, Mapped: 1}))
+	assert.Contains(t, w.String(), "# TYPE dcgm_hpc_mapping_coverage_ratio gauge\n")
+	assert.Contains(t, w.String(), `dcgm_hpc_mapping_coverage_ratio{Hostname="node1"} 0.5`+"\n")
+
+	w.Reset()
+	require.NoError(t, renderer.RenderMappingCoverage(w,
+		transformation.MappingCoverage{Hostname: `node"1`, Active: 2, Mapped: 1}))
+	assert.Contains(t, w.String(), `dcgm_hpc_mapping_coverage_ratio{Hostname="node\"1"} 0.5`+"\n")
+
+	w.Reset()
+	require.NoError(t, NewRenderer(&appconfig.Config{LabelEscaping: appconfig.LabelEscapingStrip}).RenderMappingCoverage(w,
+		transformation.MappingCoverage{Hostname: `node"1`, Active: 2, Mapped: 1}))
+	assert.Contains(t, w.String(), `dcgm_hpc_mapping_coverage_ratio{Hostname="node1"} 0.5`+"\n")
+}
+
+func TestRenderMappingFiles(t *testing.T) {
+	renderer := NewRenderer(&appconfig.Config{})
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderMappingFiles(w, 3, nil))
+	assert.Equal(t, `# HELP dcgm_hpc_mapping_files Number of HPC job mapping files read on the last scrape
+# TYPE dcgm_hpc_mapping_files gauge
+dcgm_hpc_mapping_files 3
+`, w.String(), "no info series without listed files")
+
+	w.Reset()
+	require.NoError(t, renderer.RenderMappingFiles(w, 3, []string{"2", `job"1`}))
+	assert.Contains(t, w.String(), "dcgm_hpc_mapping_files 3\n")
+	assert.Contains(t, w.String(), "# TYPE dcgm_hpc_mapping_file_info gauge\n")
+	assert.Contains(t, w.String(), `dcgm_hpc_mapping_file_info{file="2"} 1`+"\n")
+	assert.Contains(t, w.String(), `dcgm_hpc_mapping_file_info{file="job\"1"} 1`+"\n")
+
+	w.Reset()
+	renderer = NewRenderer(&appconfig.Config{LabelEscaping: appconfig.LabelEscapingStrip})
+	require.NoError(t, renderer.RenderMappingFiles(w, 1, []string{`job"1`}))
+	assert.Contains(t, w.String(), `dcgm_hpc_mapping_file_info{file="job1"} 1`+"\n")
+}
+
+func TestRenderMappingAge(t *testing.T) {
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderMappingAge(w, 90*time.Second))
+	assert.Equal(t, `# HELP dcgm_hpc_mapping_age_seconds Time since the HPC job mapping applied was fetched from its backend
+# TYPE dcgm_hpc_mapping_age_seconds gauge
+dcgm_hpc_mapping_age_seconds 90.000000
+`, w.String())
+}
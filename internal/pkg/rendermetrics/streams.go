@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+// slurmSeriesName is the name the job series are routed by
+const slurmSeriesName = "nvidia_gpu_jobId"
+
+// MetricStreams routes the rendered counters to writers by the prefix of their field name. The
+// longest matching prefix wins, and the counters matching no prefix go to the default writer.
+type MetricStreams struct {
+	ByPrefix map[string]io.Writer
+	Default  io.Writer
+}
+
+// streamOf returns the index of the writer of the name, the writers of the prefixes following the
+// default writer
+func streamOf(name string, prefixes []string) int {
+	for i, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// RenderGroupStreams renders the metrics of the group for the scrape like RenderGroupContext, each
+// counter being written to its stream. A counter's alternate series goes with it, and the job
+// series of the GPUs are routed by the nvidia_gpu_jobId name.
+func (r *Renderer) RenderGroupStreams(
+	streams MetricStreams, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter, scrape Scrape,
+) error {
+	tmpl, ok := r.templates[group]
+	if !ok {
+		return fmt.Errorf("unexpected group: %s", group.String())
+	}
+	metrics, err := r.prepareMetrics(group, metrics)
+	if err != nil {
+		return err
+	}
+
+	prefixes := slices.SortedFunc(maps.Keys(streams.ByPrefix), func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	writers := []io.Writer{r.lineWriter(streams.Default)}
+	for _, prefix := range prefixes {
+		writers = append(writers, r.lineWriter(streams.ByPrefix[prefix]))
+	}
+	partitions := make([]collector.MetricsByCounter, len(writers))
+	for counter, values := range metrics {
+		i := streamOf(counter.FieldName, prefixes)
+		if partitions[i] == nil {
+			partitions[i] = collector.MetricsByCounter{}
+		}
+		partitions[i][counter] = values
+	}
+
+	start := time.Now()
+	for i, partition := range partitions {
+		if partition == nil {
+			continue
+		}
+		if err := tmpl.Execute(writers[i], partition); err != nil {
+			return err
+		}
+	}
+	scrape.observeRenderDuration(group.String(), time.Since(start))
+	r.notifyMetricCallback(group, metrics)
+	if group == dcgm.FE_GPU {
+		start = time.Now()
+		err := r.RenderSlurm(writers[streamOf(slurmSeriesName, prefixes)], metrics, scrape.JobSeriesStale)
+		scrape.observeRenderDuration(slurmRenderGroup, time.Since(start))
+		if err != nil {
+			return err
+		}
+	}
+	for _, w := range writers {
+		if f, ok := w.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+func TestRenderGroupStreams(t *testing.T) {
+	temp := counters.Counter{FieldID: 150, FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge"}
+	smActive := counters.Counter{FieldID: 1002, FieldName: "DCGM_FI_PROF_SM_ACTIVE", PromType: "gauge"}
+	power := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetric := func(counter counters.Counter, value string) collector.Metric {
+		return collector.Metric{
+			Counter: counter, Value: value, GPU: "0", UUID: "UUID", GPUUUID: "GPU-0", AlterUUID: "GPU-0",
+			Hostname: "node1", Attributes: map[string]string{transformation.HpcJobAttribute: "42"},
+		}
+	}
+	metrics := collector.MetricsByCounter{
+		temp:     {newMetric(temp, "40")},
+		smActive: {newMetric(smActive, "0.5")},
+		power:    {newMetric(power, "100")},
+	}
+
+	var thermal, profiling, other bytes.Buffer
+	streams := MetricStreams{
+		ByPrefix: map[string]io.Writer{
+			"DCGM_FI_DEV_GPU_": &thermal,
+			"DCGM_FI_PROF_":    &profiling,
+		},
+		Default: &other,
+	}
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderGroupStreams(streams, dcgm.FE_GPU, metrics, Scrape{}))
+
+	assert.Contains(t, thermal.String(), "DCGM_FI_DEV_GPU_TEMP{")
+	assert.NotContains(t, thermal.String(), "DCGM_FI_PROF_SM_ACTIVE")
+	assert.NotContains(t, thermal.String(), "DCGM_FI_DEV_POWER_USAGE")
+	assert.NotContains(t, thermal.String(), "nvidia_gpu_jobId")
+
+	assert.Contains(t, profiling.String(), "DCGM_FI_PROF_SM_ACTIVE{")
+	assert.NotContains(t, profiling.String(), "DCGM_FI_DEV_GPU_TEMP")
+	assert.NotContains(t, profiling.String(), "DCGM_FI_DEV_POWER_USAGE")
+	assert.NotContains(t, profiling.String(), "nvidia_gpu_jobId")
+
+	assert.Contains(t, other.String(), "DCGM_FI_DEV_POWER_USAGE{")
+	assert.Contains(t, other.String(), `nvidia_gpu_jobId{minor_number="0"`, "the job series match no prefix")
+	assert.NotContains(t, other.String(), "DCGM_FI_DEV_GPU_TEMP")
+	assert.NotContains(t, other.String(), "DCGM_FI_PROF_SM_ACTIVE")
+}
+
+func Test_streamOf(t *testing.T) {
+	prefixes := []string{"DCGM_FI_DEV_GPU_", "DCGM_FI_DEV_"}
+	assert.Equal(t, 1, streamOf("DCGM_FI_DEV_GPU_TEMP", prefixes), "the longest prefix, sorted first, wins")
+	assert.Equal(t, 2, streamOf("DCGM_FI_DEV_POWER_USAGE", prefixes))
+	assert.Equal(t, 0, streamOf("DCGM_FI_PROF_SM_ACTIVE", prefixes))
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rendermetrics
+
+import (
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+// quantileLabel carries the quantile of the quantile samples of a summary
+const quantileLabel = "quantile"
+
+// summaryQuantiles are the quantiles rendered for the summary fields
+var summaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// sample is a line of a series: the value of a gauge or a counter, or a quantile, the sum or
+// the count of a summary
+type sample struct {
+	Suffix   string
+	Quantile string
+	Value    string
+}
+
+// valueSamples is the samples template function rendering the value of the metric
+func valueSamples(_ counters.Counter, metric collector.Metric) []sample {
+	return []sample{{Value: metric.Value}}
+}
+
+// isSummary tells whether the counter is one of the configured summary fields
+func (r *Renderer) isSummary(counter counters.Counter) bool {
+	return slices.Contains(r.config.SummaryFields, counter.FieldName)
+}
+
+// promType is the promType template function, rendering summary for the summary fields
+func (r *Renderer) promType(counter counters.Counter) string {
+	if r.isSummary(counter) {
+		return "summary"
+	}
+	return counter.PromType
+}
+
+// samples is the samples template function. The summary fields render the quantiles of the values
+// sampled over the summary window, and the sum and the count of all the sampled values, which
+// are cumulative like those of any summary; the other fields render their value.
+func (r *Renderer) samples(counter counters.Counter, metric collector.Metric) []sample {
+	if !r.isSummary(counter) {
+		return valueSamples(counter, metric)
+	}
+	samples := make([]sample, 0, len(summaryQuantiles)+2)
+	for q, v := range summaryQuantileValues(metric) {
+		samples = append(samples, sample{Quantile: formatFloat(q), Value: formatFloat(v)})
+	}
+	return append(samples,
+		sample{Suffix: "_sum", Value: formatFloat(metric.SampleSum)},
+		sample{Suffix: "_count", Value: strconv.FormatUint(metric.SampleCount, 10)})
+}
+
+// summaryQuantileValues returns the summary quantiles of the sampled values of the metric, in the
+// order of summaryQuantiles
+func summaryQuantileValues(metric collector.Metric) iter.Seq2[float64, float64] {
+	sorted := slices.Sorted(slices.Values(metric.Samples))
+	return func(yield func(float64, float64) bool) {
+		for _, q := range summaryQuantiles {
+			if !yield(q, quantile(sorted, q)) {
+				return
+			}
+		}
+	}
+}
+
+// quantile returns the q-quantile of the sorted values by the nearest rank, NaN without values
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// formatFloat renders a summary value with the fewest digits that represent it
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
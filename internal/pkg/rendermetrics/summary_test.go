@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rendermetrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestRenderGroupSummaryFields(t *testing.T) {
+	for _, mode := range []string{appconfig.RenderModeTemplate, appconfig.RenderModeRegistry} {
+		t.Run(mode, func(t *testing.T) {
+			testRenderGroupSummaryFields(t, mode)
+		})
+	}
+}
+
+func testRenderGroupSummaryFields(t *testing.T, mode string) {
+	util := counters.Counter{FieldID: 203, FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge", Help: "GPU utilization (in %)."}
+	temp := counters.Counter{FieldID: 150, FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Help: "GPU temperature (in C)."}
+	samples := []float64{30, 10, 50, 20, 40, 60, 70, 80, 90, 100}
+	metrics := collector.MetricsByCounter{
+		util: {
+			{
+				GPU: "0", UUID: "UUID", Hostname: "testhost", Counter: util, Value: "100", Samples: samples,
+				SampleSum: 1550, SampleCount: 30,
+			},
+			{GPU: "1", UUID: "UUID", Hostname: "testhost", Counter: util, Value: "5"},
+		},
+		temp: {{GPU: "0", UUID: "UUID", Hostname: "testhost", Counter: temp, Value: "40", Samples: []float64{1, 2}}},
+	}
+
+	w := &bytes.Buffer{}
+	renderer := NewRenderer(&appconfig.Config{SummaryFields: []string{"DCGM_FI_DEV_GPU_UTIL"}, RenderMode: mode})
+	if mode == appconfig.RenderModeRegistry {
+		w.Write(collectedGroup(t, renderer, dcgm.FE_GPU, metrics))
+	} else {
+		require.NoError(t, renderer.RenderGroup(w, dcgm.FE_GPU, metrics))
+	}
+
+	assert.Contains(t, w.String(), "# TYPE DCGM_FI_DEV_GPU_UTIL summary\n")
+	if mode == appconfig.RenderModeTemplate {
+		assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_UTIL{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost",quantile="0.9"} 90`+"\n")
+		assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_UTIL_sum{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 1550`+"\n")
+		assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_UTIL_count{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 30`+"\n")
+		assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_TEMP{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="testhost"} 40`+"\n",
+			"the fields not designated render their value")
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(w)
+	require.NoError(t, err)
+	family := families["DCGM_FI_DEV_GPU_UTIL"]
+	require.NotNil(t, family)
+	assert.Equal(t, dto.MetricType_SUMMARY, family.GetType())
+	require.Len(t, family.GetMetric(), 2)
+
+	for _, metric := range family.GetMetric() {
+		summary := metric.GetSummary()
+		require.NotNil(t, summary)
+		require.Len(t, summary.GetQuantile(), 3)
+		switch gpuLabel(metric) {
+		case "0":
+			assert.Equal(t, uint64(30), summary.GetSampleCount(), "the count is cumulative")
+			assert.Equal(t, 1550.0, summary.GetSampleSum(), "the sum is cumulative")
+			assert.Equal(t, 0.5, summary.GetQuantile()[0].GetQuantile())
+			assert.Equal(t, 50.0, summary.GetQuantile()[0].GetValue())
+			assert.Equal(t, 100.0, summary.GetQuantile()[2].GetValue())
+		case "1":
+			assert.Zero(t, summary.GetSampleCount(), "no values were sampled")
+			assert.True(t, math.IsNaN(summary.GetQuantile()[0].GetValue()))
+		default:
+			t.Fatalf("unexpected series %v", metric)
+		}
+	}
+	assert.Equal(t, dto.MetricType_GAUGE, families["DCGM_FI_DEV_GPU_TEMP"].GetType())
+}
+
+func gpuLabel(metric *dto.Metric) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == "gpu" {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
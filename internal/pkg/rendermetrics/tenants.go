@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+// TenantFilter selects the GPU metrics of a tenant: the metrics of the GPUs it owns, including
+// their MIG instances, and the metrics whose owner attribute, e.g. an account, it owns.
+type TenantFilter struct {
+	gpus      map[string]struct{}
+	attribute string
+	owners    map[string]struct{}
+}
+
+// NewTenantFilter returns the filter of the tenant, whose owners are values of the attribute
+func NewTenantFilter(tenant appconfig.Tenant, attribute string) TenantFilter {
+	f := TenantFilter{
+		gpus:      make(map[string]struct{}, len(tenant.GPUUUIDs)),
+		attribute: attribute,
+		owners:    make(map[string]struct{}, len(tenant.Owners)),
+	}
+	for _, uuid := range tenant.GPUUUIDs {
+		f.gpus[uuid] = struct{}{}
+	}
+	for _, owner := range tenant.Owners {
+		f.owners[owner] = struct{}{}
+	}
+	return f
+}
+
+func (f TenantFilter) owns(metric collector.Metric) bool {
+	if _, ok := f.gpus[metric.GPUUUID]; ok {
+		return true
+	}
+	if f.attribute == "" {
+		return false
+	}
+	owner, ok := metric.Attributes[f.attribute]
+	if !ok {
+		owner, ok = metric.Labels[f.attribute]
+	}
+	if !ok {
+		return false
+	}
+	_, ok = f.owners[owner]
+	return ok
+}
+
+func (f TenantFilter) filter(metrics collector.MetricsByCounter) collector.MetricsByCounter {
+	filtered := make(collector.MetricsByCounter, len(metrics))
+	for counter, values := range metrics {
+		var owned []collector.Metric
+		for _, metric := range values {
+			if f.owns(metric) {
+				owned = append(owned, metric)
+			}
+		}
+		if len(owned) > 0 {
+			filtered[counter] = owned
+		}
+	}
+	return filtered
+}
+
+// RenderTenantGroup renders the metrics of the group owned by the tenant. Only GPUs are owned by
+// tenants, nothing is rendered for the other groups.
+func (r *Renderer) RenderTenantGroup(
+	w io.Writer, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter, tenant TenantFilter,
+) error {
+	if group != dcgm.FE_GPU {
+		return nil
+	}
+	return r.RenderGroup(w, group, tenant.filter(metrics))
+}
+
+// tenantAttribute carries the tenant owning the GPU of a metric, in the label tenant label mode
+const tenantAttribute = "tenant"
+
+// namedTenant is the filter of a tenant along with its name
+type namedTenant struct {
+	name   string
+	filter TenantFilter
+}
+
+// namedTenants returns the filters of the tenants, ordered by name
+func namedTenants(c *appconfig.Config) []namedTenant {
+	var tenants []namedTenant
+	for _, name := range slices.Sorted(maps.Keys(c.Tenants)) {
+		tenants = append(tenants, namedTenant{name: name, filter: NewTenantFilter(c.Tenants[name], c.TenantAttribute)})
+	}
+	return tenants
+}
+
+// tenantOf returns the first tenant, by name, owning the metric, or "" when no tenant owns it
+func (r *Renderer) tenantOf(metric collector.Metric) string {
+	for _, tenant := range r.tenants {
+		if tenant.filter.owns(metric) {
+			return tenant.name
+		}
+	}
+	return ""
+}
+
+// withTenants marks the GPU metrics with the tenant owning them, according to the tenant label
+// mode: either the tenant attribute is set, or the tenant name and an underscore prefix the name
+// of their series. The metrics no tenant owns are left as they are. Attributes maps are shared by
+// the metrics of an entity, so they are cloned rather than modified.
+func (r *Renderer) withTenants(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+) collector.MetricsByCounter {
+	if group != dcgm.FE_GPU || len(r.tenants) == 0 {
+		return metrics
+	}
+	if r.config.TenantLabelMode == appconfig.TenantLabelModeLabel {
+		for _, values := range metrics {
+			for i, metric := range values {
+				tenant := r.tenantOf(metric)
+				if tenant == "" {
+					continue
+				}
+				attributes := make(map[string]string, len(metric.Attributes)+1)
+				maps.Copy(attributes, metric.Attributes)
+				attributes[tenantAttribute] = tenant
+				values[i].Attributes = attributes
+			}
+		}
+		return metrics
+	}
+
+	prefixed := make(collector.MetricsByCounter, len(metrics))
+	for counter, values := range metrics {
+		for _, metric := range values {
+			tenantCounter := counter
+			if tenant := r.tenantOf(metric); tenant != "" {
+				tenantCounter.FieldName = tenant + "_" + counter.FieldName
+				if counter.AlterFieldName != "" {
+					tenantCounter.AlterFieldName = tenant + "_" + counter.AlterFieldName
+				}
+				metric.Counter = tenantCounter
+			}
+			prefixed[tenantCounter] = append(prefixed[tenantCounter], metric)
+		}
+	}
+	return prefixed
+}
+
+// ValidateTenantPrefixes checks that the tenant names are legal metric name prefixes
+func ValidateTenantPrefixes(tenants map[string]appconfig.Tenant) error {
+	for name := range tenants {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("tenant %q is not a valid metric name prefix", name)
+		}
+	}
+	return nil
+}
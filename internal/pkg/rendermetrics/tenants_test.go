@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestRenderTenantGroup(t *testing.T) {
+	counter := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Help: "GPU temperature (in C)."}
+	newMetric := func(gpu, uuid string, attributes map[string]string) collector.Metric {
+		return collector.Metric{
+			Counter: counter, Value: "42", GPU: gpu, GPUUUID: uuid, Hostname: "node1",
+			Labels: map[string]string{}, Attributes: attributes,
+		}
+	}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			newMetric("0", "GPU-0", map[string]string{}),
+			newMetric("1", "GPU-1", map[string]string{"account": "physics"}),
+			newMetric("2", "GPU-2", map[string]string{"account": "chemistry"}),
+			newMetric("3", "GPU-3", map[string]string{}),
+		},
+	}
+
+	renderer := NewRenderer(&appconfig.Config{})
+	render := func(tenant appconfig.Tenant) string {
+		w := &bytes.Buffer{}
+		require.NoError(t, renderer.RenderTenantGroup(w, dcgm.FE_GPU, metrics, NewTenantFilter(tenant, "account")))
+		return w.String()
+	}
+
+	physics := render(appconfig.Tenant{GPUUUIDs: []string{"GPU-0"}, Owners: []string{"physics"}})
+	assert.Contains(t, physics, `gpu="0"`)
+	assert.Contains(t, physics, `gpu="1"`)
+	assert.NotContains(t, physics, `gpu="2"`)
+	assert.NotContains(t, physics, `gpu="3"`)
+
+	chemistry := render(appconfig.Tenant{Owners: []string{"chemistry"}})
+	assert.Contains(t, chemistry, `gpu="2"`)
+	assert.NotContains(t, chemistry, `gpu="0"`)
+	assert.NotContains(t, chemistry, `gpu="1"`)
+	assert.NotContains(t, chemistry, `gpu="3"`)
+
+	assert.NotContains(t, render(appconfig.Tenant{Owners: []string{"biology"}}), "DCGM_FI_DEV_GPU_TEMP{")
+
+	w := &bytes.Buffer{}
+	require.NoError(t, renderer.RenderTenantGroup(w, dcgm.FE_SWITCH, metrics, NewTenantFilter(appconfig.Tenant{}, "")))
+	assert.Empty(t, w.String(), "only GPUs are owned by tenants")
+}
+
+func TestRenderGroupTenantLabelMode(t *testing.T) {
+	counter := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Help: "GPU temperature (in C)."}
+	metrics := func() collector.MetricsByCounter {
+		newMetric := func(gpu, account string) collector.Metric {
+			attributes := map[string]string{}
+			if account != "" {
+				attributes["account"] = account
+			}
+			return collector.Metric{
+				Counter: counter, Value: "42", GPU: gpu, UUID: "UUID", GPUUUID: "GPU-" + gpu, Hostname: "node1",
+				Labels: map[string]string{}, Attributes: attributes,
+			}
+		}
+		return collector.MetricsByCounter{
+			counter: {newMetric("0", "physics"), newMetric("1", "chemistry"), newMetric("2", "")},
+		}
+	}
+	config := func(mode string) *appconfig.Config {
+		return &appconfig.Config{
+			Tenants: map[string]appconfig.Tenant{
+				"phys": {Owners: []string{"physics"}},
+				"chem": {Owners: []string{"chemistry"}},
+			},
+			TenantAttribute: "account",
+			TenantLabelMode: mode,
+		}
+	}
+
+	w := &bytes.Buffer{}
+	require.NoError(t, NewRenderer(config(appconfig.TenantLabelModeLabel)).RenderGroup(w, dcgm.FE_GPU, metrics()))
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_TEMP{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="node1",account="physics",tenant="phys"} 42`)
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_TEMP{gpu="1",UUID="",pci_bus_id="",device="",modelName="",Hostname="node1",account="chemistry",tenant="chem"} 42`)
+	assert.Contains(t, w.String(), `DCGM_FI_DEV_GPU_TEMP{gpu="2",UUID="",pci_bus_id="",device="",modelName="",Hostname="node1"} 42`,
+		"the GPUs without a tenant are left as they are")
+
+	w.Reset()
+	require.NoError(t, NewRenderer(config(appconfig.TenantLabelModePrefix)).RenderGroup(w, dcgm.FE_GPU, metrics()))
+	assert.Contains(t, w.String(), "# TYPE phys_DCGM_FI_DEV_GPU_TEMP gauge\n")
+	assert.Contains(t, w.String(), `phys_DCGM_FI_DEV_GPU_TEMP{gpu="0",UUID="",pci_bus_id="",device="",modelName="",Hostname="node1",account="physics"} 42`)
+	assert.Contains(t, w.String(), "# TYPE chem_DCGM_FI_DEV_GPU_TEMP gauge\n")
+	assert.Contains(t, w.String(), `chem_DCGM_FI_DEV_GPU_TEMP{gpu="1",UUID="",pci_bus_id="",device="",modelName="",Hostname="node1",account="chemistry"} 42`)
+	assert.Contains(t, w.String(), `
+DCGM_FI_DEV_GPU_TEMP{gpu="2",UUID="",pci_bus_id="",device="",modelName="",Hostname="node1"} 42`)
+
+	w.Reset()
+	require.NoError(t, NewRenderer(config(appconfig.TenantLabelModeNone)).RenderGroup(w, dcgm.FE_GPU, metrics()))
+	assert.NotContains(t, w.String(), "tenant=")
+	assert.NotContains(t, w.String(), "phys_")
+}
+
+func TestValidateTenantPrefixes(t *testing.T) {
+	require.NoError(t, ValidateTenantPrefixes(map[string]appconfig.Tenant{"team_a": {}, "TeamB": {}}))
+	assert.Error(t, ValidateTenantPrefixes(map[string]appconfig.Tenant{"team-a": {}}))
+	assert.Error(t, ValidateTenantPrefixes(map[string]appconfig.Tenant{"1team": {}}))
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+const (
+	zonePowerMetric = "dcgm_zone_gpu_power_watts"
+	// zonePowerField is the field summed by power zone, matched by id as aliases rename it
+	zonePowerField = dcgm.Short(dcgm.DCGM_FI_DEV_POWER_USAGE)
+	// unknownPowerZone is the zone of the GPUs missing from the power zone mapping
+	unknownPowerZone = "unknown"
+)
+
+// powerZoneOf returns the power zone of the GPU of the metric, mapped by GPU UUID or else by
+// hostname
+func (r *Renderer) powerZoneOf(metric collector.Metric) string {
+	if zone, ok := r.config.PowerZones[metric.GPUUUID]; ok {
+		return zone
+	}
+	if zone, ok := r.config.PowerZones[metric.Hostname]; ok {
+		return zone
+	}
+	return unknownPowerZone
+}
+
+// RenderZonePower renders the power drawn by the GPUs of the node in each power zone as
+// dcgm_zone_gpu_power_watts, labeled with the zone, for rack power management. Each GPU counts
+// once, whatever the number of its jobs, and MIG instances are skipped. Nothing is rendered
+// without a power zone mapping or power metrics.
+func (r *Renderer) RenderZonePower(w io.Writer, metrics collector.MetricsByCounter) error {
+	hostname, zones := r.zonePowers(metrics)
+	if len(zones) == 0 {
+		return nil
+	}
+
+	w = r.lineWriter(w)
+	staticLabels := r.staticLabelPairs()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n", zonePowerMetric, zonePowerHelp)
+	fmt.Fprintf(&sb, "# TYPE %s gauge\n", zonePowerMetric)
+	for _, zone := range slices.Sorted(maps.Keys(zones)) {
+		fmt.Fprintf(&sb, "%s{zone=\"%s\"", zonePowerMetric, r.labelValue(zone))
+		if hostname != "" {
+			fmt.Fprintf(&sb, ",Hostname=\"%s\"", r.labelValue(hostname))
+		}
+		fmt.Fprintf(&sb, "%s} %s\n", staticLabels, strconv.FormatFloat(zones[zone], 'f', -1, 64))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// CollectZonePower is RenderZonePower in the registry render mode
+func (r *Renderer) CollectZonePower(set *MetricSet, metrics collector.MetricsByCounter) error {
+	hostname, zones := r.zonePowers(metrics)
+	for _, zone := range slices.Sorted(maps.Keys(zones)) {
+		labels := []labelPair{{name: "zone", value: zone}}
+		if hostname != "" {
+			labels = append(labels, labelPair{name: "Hostname", value: hostname})
+		}
+		if err := set.add(r.newConstMetric(zonePowerMetric, zonePowerHelp, prometheus.GaugeValue, zones[zone],
+			r.withStaticLabels(labels...))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const zonePowerHelp = "Power drawn by the GPUs of the node in the power zone (in W)"
+
+// zonePowers returns the power drawn by the GPUs of the metrics in each power zone, along with
+// their hostname; no zone without a power zone mapping
+func (r *Renderer) zonePowers(metrics collector.MetricsByCounter) (string, map[string]float64) {
+	if len(r.config.PowerZones) == 0 {
+		return "", nil
+	}
+
+	hostname := ""
+	zones := map[string]float64{}
+	seen := map[string]struct{}{}
+	for counter, values := range metrics {
+		if counter.FieldID != zonePowerField {
+			continue
+		}
+		for _, metric := range values {
+			if metric.MigProfile != "" {
+				continue
+			}
+			if _, ok := seen[metric.GPUUUID+"/"+metric.GPU]; ok {
+				continue
+			}
+			value, err := strconv.ParseFloat(metric.Value, 64)
+			if err != nil {
+				continue
+			}
+			seen[metric.GPUUUID+"/"+metric.GPU] = struct{}{}
+			hostname = metric.Hostname
+			zones[r.powerZoneOf(metric)] += value
+		}
+	}
+	if override, ok := r.config.HostnameOverrides[dcgm.FE_GPU]; ok {
+		hostname = override
+	}
+	return hostname, zones
+}
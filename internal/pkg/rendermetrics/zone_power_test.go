@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rendermetrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestRenderZonePower(t *testing.T) {
+	powerCounter := counters.Counter{
+		FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Help: "Power draw (in W).",
+	}
+	tempCounter := counters.Counter{
+		FieldID: 150, FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Help: "GPU temperature (in C).",
+	}
+	newMetric := func(counter counters.Counter, gpu, value string, attributes map[string]string) collector.Metric {
+		return collector.Metric{
+			Counter: counter, GPU: gpu, GPUUUID: "GPU-" + gpu, Value: value, Hostname: "node1", Attributes: attributes,
+		}
+	}
+	metrics := collector.MetricsByCounter{
+		powerCounter: {
+			newMetric(powerCounter, "0", "250.5", nil),
+			// a GPU shared by two jobs counts once
+			newMetric(powerCounter, "1", "300", map[string]string{"jobid": "1"}),
+			newMetric(powerCounter, "1", "300", map[string]string{"jobid": "2"}),
+			newMetric(powerCounter, "2", "400", nil),
+			{Counter: powerCounter, GPU: "2", GPUInstanceID: "1", MigProfile: "1g.10gb", Value: "5", Hostname: "node1"},
+			newMetric(powerCounter, "3", "100", nil),
+		},
+		tempCounter: {newMetric(tempCounter, "0", "40", nil)},
+	}
+
+	var w bytes.Buffer
+	require.NoError(t, NewRenderer(&appconfig.Config{}).RenderZonePower(&w, metrics))
+	assert.Empty(t, w.String(), "the zones are only rendered when mapped")
+
+	renderer := NewRenderer(&appconfig.Config{PowerZones: map[string]string{
+		"GPU-0": "rack-a",
+		"GPU-1": "rack-a",
+		"GPU-2": "rack-b",
+	}})
+	require.NoError(t, renderer.RenderZonePower(&w, metrics))
+	assert.Equal(t, `# HELP dcgm_zone_gpu_power_watts Power drawn by the GPUs of the node in the power zone (in W)
+# TYPE dcgm_zone_gpu_power_watts gauge
+dcgm_zone_gpu_power_watts{zone="rack-a",Hostname="node1"} 550.5
+dcgm_zone_gpu_power_watts{zone="rack-b",Hostname="node1"} 400
+dcgm_zone_gpu_power_watts{zone="unknown",Hostname="node1"} 100
+`, w.String())
+
+	w.Reset()
+	renderer = NewRenderer(&appconfig.Config{PowerZones: map[string]string{"GPU-2": "rack-b", "node1": "rack-a"}})
+	require.NoError(t, renderer.RenderZonePower(&w, metrics))
+	assert.Contains(t, w.String(), `{zone="rack-a",Hostname="node1"} 650.5`, "GPUs are mapped by hostname too")
+	assert.Contains(t, w.String(), `{zone="rack-b",Hostname="node1"} 400`, "the GPU UUID takes precedence")
+
+	// an alias renames the power field, which is still summed
+	aliasCounter := powerCounter
+	aliasCounter.FieldName = "gpu_power_watts"
+	w.Reset()
+	require.NoError(t, renderer.RenderZonePower(&w, collector.MetricsByCounter{
+		aliasCounter: {newMetric(aliasCounter, "2", "400", nil)},
+	}))
+	assert.Contains(t, w.String(), `{zone="rack-b",Hostname="node1"} 400`, "the power field is matched by id")
+
+	w.Reset()
+	require.NoError(t, renderer.RenderZonePower(&w, collector.MetricsByCounter{tempCounter: metrics[tempCounter]}))
+	assert.Empty(t, w.String(), "nothing is rendered without power metrics")
+}
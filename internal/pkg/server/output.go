@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/rendermetrics"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+// scrapeOutput is where a scrape is rendered: the text of the templates, or the constant metrics of
+// the registry render mode
+type scrapeOutput interface {
+	group(ctx context.Context, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+		scrape rendermetrics.Scrape) error
+	groupsUp(groups []dcgm.Field_Entity_Group) error
+	mappingConflicts(conflicts uint64) error
+	mappingFiles(count int, recent []string) error
+	mappingOversize(oversize uint64) error
+	mappingCoverage(coverage transformation.MappingCoverage) error
+	mappingAge(age time.Duration) error
+	// exporter renders the metrics about the exporter itself
+	exporter(renderDurations, phases map[string]time.Duration) error
+}
+
+// textOutput renders a scrape as text
+type textOutput struct {
+	renderer *rendermetrics.Renderer
+	w        io.Writer
+}
+
+func (o textOutput) group(
+	ctx context.Context, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+	scrape rendermetrics.Scrape,
+) error {
+	if err := o.renderer.RenderGroupContext(ctx, o.w, group, metrics, scrape); err != nil {
+		return err
+	}
+	if group != dcgm.FE_GPU {
+		return nil
+	}
+	if err := o.renderer.RenderNodeGPUUtil(o.w, metrics); err != nil {
+		return err
+	}
+	return o.renderer.RenderZonePower(o.w, metrics)
+}
+
+func (o textOutput) groupsUp(groups []dcgm.Field_Entity_Group) error {
+	return o.renderer.RenderGroupsUp(o.w, groups)
+}
+
+func (o textOutput) mappingConflicts(conflicts uint64) error {
+	return o.renderer.RenderMappingConflicts(o.w, conflicts)
+}
+
+func (o textOutput) mappingFiles(count int, recent []string) error {
+	return o.renderer.RenderMappingFiles(o.w, count, recent)
+}
+
+func (o textOutput) mappingOversize(oversize uint64) error {
+	return o.renderer.RenderMappingOversize(o.w, oversize)
+}
+
+func (o textOutput) mappingCoverage(coverage transformation.MappingCoverage) error {
+	return o.renderer.RenderMappingCoverage(o.w, coverage)
+}
+
+func (o textOutput) mappingAge(age time.Duration) error {
+	return o.renderer.RenderMappingAge(o.w, age)
+}
+
+func (o textOutput) exporter(renderDurations, phases map[string]time.Duration) error {
+	if err := o.renderer.RenderDeadlineExceeded(o.w); err != nil {
+		return err
+	}
+	if err := o.renderer.RenderDuplicateSeriesDropped(o.w); err != nil {
+		return err
+	}
+	if err := o.renderer.RenderRegistrySeriesDropped(o.w); err != nil {
+		return err
+	}
+	return o.renderer.RenderSelfMetrics(o.w, renderDurations, phases)
+}
+
+// registryOutput collects a scrape as the constant metrics of the registry render mode
+type registryOutput struct {
+	renderer *rendermetrics.Renderer
+	set      *rendermetrics.MetricSet
+}
+
+func (o registryOutput) group(
+	ctx context.Context, group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter,
+	scrape rendermetrics.Scrape,
+) error {
+	if err := o.renderer.CollectGroupContext(ctx, o.set, group, metrics, scrape); err != nil {
+		return err
+	}
+	if group != dcgm.FE_GPU {
+		return nil
+	}
+	if err := o.renderer.CollectNodeGPUUtil(o.set, metrics); err != nil {
+		return err
+	}
+	return o.renderer.CollectZonePower(o.set, metrics)
+}
+
+func (o registryOutput) groupsUp(groups []dcgm.Field_Entity_Group) error {
+	return o.renderer.CollectGroupsUp(o.set, groups)
+}
+
+func (o registryOutput) mappingConflicts(conflicts uint64) error {
+	return o.renderer.CollectMappingConflicts(o.set, conflicts)
+}
+
+func (o registryOutput) mappingFiles(count int, recent []string) error {
+	return o.renderer.CollectMappingFiles(o.set, count, recent)
+}
+
+func (o registryOutput) mappingOversize(oversize uint64) error {
+	return o.renderer.CollectMappingOversize(o.set, oversize)
+}
+
+func (o registryOutput) mappingCoverage(coverage transformation.MappingCoverage) error {
+	return o.renderer.CollectMappingCoverage(o.set, coverage)
+}
+
+func (o registryOutput) mappingAge(age time.Duration) error {
+	return o.renderer.CollectMappingAge(o.set, age)
+}
+
+func (o registryOutput) exporter(renderDurations, phases map[string]time.Duration) error {
+	if err := o.renderer.CollectDeadlineExceeded(o.set); err != nil {
+		return err
+	}
+	if err := o.renderer.CollectDuplicateSeriesDropped(o.set); err != nil {
+		return err
+	}
+	if err := o.renderer.CollectRegistrySeriesDropped(o.set); err != nil {
+		return err
+	}
+	return o.renderer.CollectSelfMetrics(o.set, renderDurations, phases)
+}
+
+// bufferedResponse buffers the response of the registry handler, for the server to handle the
+// text output as that of the template render mode before writing it
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+// text tells whether the response is a successful scrape in the text format
+func (b *bufferedResponse) text() bool {
+	return b.status == http.StatusOK && strings.HasPrefix(b.header.Get("Content-Type"), "text/plain")
+}
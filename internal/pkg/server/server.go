@@ -20,18 +20,27 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/exporter-toolkit/web"
 
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/debug"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/devicewatchlistmanager"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/registry"
@@ -40,7 +49,12 @@ import (
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/utils"
 )
 
-const internalServerError = "internal server error"
+const (
+	internalServerError = "internal server error"
+
+	mappingAgeHeader   = "X-DCGM-HPC-Mapping-Age"
+	mappingFilesHeader = "X-DCGM-HPC-Mapping-Files"
+)
 
 func NewMetricsServer(
 	c *appconfig.Config,
@@ -50,6 +64,11 @@ func NewMetricsServer(
 ) (*MetricsServer, func(), error) {
 	router := mux.NewRouter()
 
+	transformations, err := transformation.GetTransformations(c)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
 	// Initialize file dumper
 	fileDumper := debug.NewFileDumper(c.DumpConfig)
 
@@ -69,9 +88,22 @@ func NewMetricsServer(
 		metrics:                "",
 		registry:               registry,
 		config:                 c,
-		transformations:        transformation.GetTransformations(c),
+		transformations:        transformations,
 		deviceWatchListManager: deviceWatchListManager,
 		fileDumper:             fileDumper,
+		renderer:               rendermetrics.NewRenderer(c),
+	}
+	if len(c.RoundedFields) > 0 {
+		serverv1.SetValueFormatter(collector.NewRoundingValueFormatter(c.RoundedFields))
+	}
+	if c.ScrapeHistoryCount > 0 {
+		serverv1.scrapeHistory = rendermetrics.NewScrapeHistory(c.ScrapeHistoryCount, c.ScrapeHistoryMaxBytes)
+	}
+	if c.ScrapeFile != "" {
+		serverv1.scrapeFile = rendermetrics.NewScrapeFile(c.ScrapeFile, c.ScrapeFileMaxBytes)
+	}
+	if c.RenderMode == appconfig.RenderModeRegistry {
+		serverv1.registryHandler = serverv1.newRegistryHandler()
 	}
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -92,19 +124,32 @@ func NewMetricsServer(
 
 	router.HandleFunc("/health", serverv1.Health)
 	router.HandleFunc("/metrics", serverv1.Metrics)
-
-	var podMapper *transformation.PodMapper
-	for _, t := range serverv1.transformations {
-		if pm, ok := t.(*transformation.PodMapper); ok {
-			podMapper = pm
-			break
+	router.HandleFunc("/metrics/jsonl", serverv1.MetricsJSONLines)
+	router.HandleFunc("/metrics/last", serverv1.MetricsLast)
+	router.HandleFunc("/metrics/jobs", serverv1.MetricsJobs)
+	if c.EnableDeltaEndpoint {
+		serverv1.deltaFilter = rendermetrics.NewDeltaFilter()
+	}
+	router.HandleFunc("/metrics/delta", serverv1.MetricsDelta)
+	if len(c.Tenants) > 0 {
+		serverv1.tenants = make(map[string]rendermetrics.TenantFilter, len(c.Tenants))
+		for name, tenant := range c.Tenants {
+			serverv1.tenants[name] = rendermetrics.NewTenantFilter(tenant, c.TenantAttribute)
 		}
+		router.HandleFunc("/metrics/tenants/{tenant}", serverv1.MetricsTenant)
 	}
 
+	// the transformations with background work or caches release them on shutdown and reload
 	cleanup := func() {
-		if podMapper != nil && c.KubernetesEnableDRA && podMapper.ResourceSliceManager != nil {
-			slog.Info("Stopping ResourceSliceManager")
-			podMapper.ResourceSliceManager.Stop()
+		for _, t := range serverv1.transformations {
+			closer, ok := t.(io.Closer)
+			if !ok {
+				continue
+			}
+			if err := closer.Close(); err != nil {
+				slog.Warn(fmt.Sprintf("Failed to close the %s transformation", t.Name()),
+					slog.String(logging.ErrorKey, err.Error()))
+			}
 		}
 	}
 
@@ -172,25 +217,164 @@ func (s *MetricsServer) Run(ctx context.Context, stop chan interface{}, wg *sync
 	}
 }
 
+// SetMetricCallback registers a callback invoked with each metric rendered on /metrics.
+func (s *MetricsServer) SetMetricCallback(callback rendermetrics.MetricCallback) {
+	s.renderer.SetMetricCallback(callback)
+}
+
+// SetValueFormatter replaces the formatter of the values rendered on /metrics, including the
+// values computed by the transformations.
+func (s *MetricsServer) SetValueFormatter(formatter collector.ValueFormatter) {
+	s.renderer.SetValueFormatter(formatter)
+	for _, t := range s.transformations {
+		if setter, ok := t.(transformation.ValueFormatterSetter); ok {
+			setter.SetValueFormatter(formatter)
+		}
+	}
+}
+
+// SetMappingKeyResolver replaces how the GPUs of the metrics are looked up in the job mappings,
+// e.g. to prefer a site-specific key; it must be set before the server starts.
+func (s *MetricsServer) SetMappingKeyResolver(resolver transformation.MappingKeyResolver) {
+	for _, t := range s.transformations {
+		if setter, ok := t.(transformation.MappingKeyResolverSetter); ok {
+			setter.SetMappingKeyResolver(resolver)
+		}
+	}
+}
+
+// ReloadTransformations applies the job mapping settings of the configuration to the running
+// transformations and to the per-job series, e.g. after the settings were re-read on SIGHUP.
+func (s *MetricsServer) ReloadTransformations(c *appconfig.Config) {
+	for _, t := range s.transformations {
+		if reloader, ok := t.(transformation.MappingSettingsReloader); ok {
+			reloader.Reload(c)
+		}
+	}
+	s.renderer.SetJobPlaceholder(c.HPCJobPlaceholder)
+}
+
 func (s *MetricsServer) fatal() {
 	os.Exit(1)
 }
 
-func (s *MetricsServer) Metrics(w http.ResponseWriter, _ *http.Request) {
+func (s *MetricsServer) Metrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	metricGroups, err := s.registry.Gather()
+	if s.registryHandler != nil {
+		s.metricsRegistry(w, r)
+		return
+	}
+	output, ok := s.scrape(w, false)
+	if !ok {
+		return
+	}
+	s.writeMetrics(w, http.StatusOK, output, true)
+}
+
+// newRegistryHandler returns the handler of /metrics in the registry render mode, serving the
+// scrapes collected to a registry kept across scrapes
+func (s *MetricsServer) newRegistryHandler() http.Handler {
+	gatherer := s.renderer.NewGatherer(s.collectScrape)
+	handler := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+		// the output is not compressed, like that of the other endpoints
+		DisableCompression: true,
+	})
+	if s.config == nil || !s.config.EnableOpenMetrics {
+		return handler
+	}
+	return openMetricsHandler(gatherer, handler)
+}
+
+// openMetricsHandler serves the gathered families in the OpenMetrics format, with the # UNIT lines
+// and unit suffixes promhttp leaves out, to the scrapers asking for it; handler serves the other
+// formats. As with promhttp.ContinueOnError, a failed gather is an error only when nothing was
+// gathered.
+func openMetricsHandler(gatherer prometheus.Gatherer, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format.FormatType() != expfmt.TypeOpenMetrics {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		families, err := gatherer.Gather()
+		if err != nil && len(families) == 0 {
+			slog.Error("Failed to gather metrics", slog.String(logging.ErrorKey, err.Error()))
+			http.Error(w, internalServerError, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", string(format))
+		encoder := expfmt.NewEncoder(w, format, expfmt.WithUnit())
+		for _, family := range families {
+			if err := encoder.Encode(family); err != nil {
+				slog.Error("Failed to encode metrics", slog.String(logging.ErrorKey, err.Error()))
+				return
+			}
+		}
+		if closer, ok := encoder.(expfmt.Closer); ok {
+			if err := closer.Close(); err != nil {
+				slog.Error("Failed to encode metrics", slog.String(logging.ErrorKey, err.Error()))
+			}
+		}
+	})
+}
+
+// metricsRegistry serves /metrics in the registry render mode, through the registry handler. Its
+// output is handled as that of the template render mode when in the text format.
+func (s *MetricsServer) metricsRegistry(w http.ResponseWriter, r *http.Request) {
+	response := newBufferedResponse()
+	s.registryHandler.ServeHTTP(response, r)
+	for name, values := range response.header {
+		w.Header()[name] = values
+	}
+	s.writeMetrics(w, response.status, response.body.Bytes(), response.text())
+}
+
+// writeMetrics writes the output of a scrape of /metrics, along with the checksum trailer, to the
+// scrape history and the scrape file when it is text
+func (s *MetricsServer) writeMetrics(w http.ResponseWriter, status int, output []byte, text bool) {
+	if text {
+		if s.config != nil && s.config.EnableChecksumTrailer {
+			output = s.renderer.AppendChecksum(output)
+		}
+		if s.scrapeHistory != nil {
+			s.scrapeHistory.Add(time.Now(), output)
+		}
+		if s.scrapeFile != nil {
+			s.scrapeFile.Store(output)
+		}
+	}
+	s.setMappingFreshnessHeaders(w)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	_, err := w.Write(output)
 	if err != nil {
-		slog.Error("Failed to gather metrics from collectors", slog.String(logging.ErrorKey, err.Error()))
-		http.Error(w, internalServerError, http.StatusInternalServerError)
+		slog.Error("Failed to write response.", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, "failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// MetricsDelta serves the series whose value changed since the previous scrape of the endpoint.
+func (s *MetricsServer) MetricsDelta(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if s.deltaFilter == nil {
+		http.Error(w, "delta endpoint is disabled", http.StatusNotFound)
+		return
+	}
+	output, ok := s.scrape(w, true)
+	if !ok {
 		return
 	}
 	var buf bytes.Buffer
-	err = s.render(&buf, metricGroups)
-	if err != nil {
+	if err := s.deltaFilter.Filter(&buf, output); err != nil {
+		slog.Error("Failed to filter the unchanged series", slog.String(logging.ErrorKey, err.Error()))
 		http.Error(w, internalServerError, http.StatusInternalServerError)
 		return
 	}
-	_, err = w.Write(buf.Bytes())
+	s.setMappingFreshnessHeaders(w)
+	_, err := w.Write(buf.Bytes())
 	if err != nil {
 		slog.Error("Failed to write response.", slog.String(logging.ErrorKey, err.Error()))
 		http.Error(w, "failed to write response", http.StatusInternalServerError)
@@ -198,8 +382,159 @@ func (s *MetricsServer) Metrics(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
-func (s *MetricsServer) render(w io.Writer, metricGroups registry.MetricsByCounterGroup) error {
+// scrape gathers and renders the metrics as text, within the render deadline, or renders the shared
+// gather when shared. On failure the error is served and false is returned.
+func (s *MetricsServer) scrape(w http.ResponseWriter, shared bool) ([]byte, bool) {
+	var buf bytes.Buffer
+	if err := s.renderScrape(textOutput{renderer: s.renderer, w: &buf}, shared); err != nil {
+		http.Error(w, internalServerError, http.StatusInternalServerError)
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// collectScrape gathers the metrics and collects them to the set, in the registry render mode
+func (s *MetricsServer) collectScrape(set *rendermetrics.MetricSet) error {
+	return s.renderScrape(registryOutput{renderer: s.renderer, set: set}, false)
+}
+
+// renderScrape gathers and renders the metrics to the output, within the render deadline, or renders
+// the shared gather when shared. The groups rendered before the deadline are kept.
+func (s *MetricsServer) renderScrape(out scrapeOutput, shared bool) error {
+	start := time.Now()
+	var metricGroups registry.MetricsByCounterGroup
+	var err error
+	if shared {
+		metricGroups, err = s.sharedGather()
+	} else {
+		metricGroups, err = s.registry.Gather()
+	}
+	// the phases are timed per scrape, as the endpoints may be scraped concurrently
+	phases := map[string]time.Duration{rendermetrics.ScrapePhaseCollect: time.Since(start)}
+	if err != nil {
+		slog.Error("Failed to gather metrics from collectors", slog.String(logging.ErrorKey, err.Error()))
+		return err
+	}
+	ctx := context.Background()
+	if s.config != nil && s.config.RenderDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.RenderDeadline)
+		defer cancel()
+	}
+	err = s.render(ctx, out, metricGroups, !shared, phases)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// the groups rendered before the deadline are served rather than failing the scrape
+		slog.Warn("Rendering exceeded the render deadline, some groups are left out",
+			slog.Duration("deadline", s.config.RenderDeadline))
+		return nil
+	}
+	return err
+}
+
+// setMappingFreshnessHeaders reports the age of the newest HPC job mapping file and the number of
+// mapping files read during the scrape, when a transformation reads mapping files.
+func (s *MetricsServer) setMappingFreshnessHeaders(w http.ResponseWriter) {
+	for _, t := range s.transformations {
+		reporter, ok := t.(transformation.MappingFreshnessReporter)
+		if !ok {
+			continue
+		}
+		newest, files := reporter.MappingFreshness()
+		w.Header().Set(mappingFilesHeader, strconv.Itoa(files))
+		if files > 0 {
+			w.Header().Set(mappingAgeHeader, strconv.Itoa(int(time.Since(newest).Seconds())))
+		}
+		return
+	}
+}
+
+// mappingStale tells whether the newest HPC job mapping file is older than the stale threshold
+func (s *MetricsServer) mappingStale() bool {
+	if s.config == nil || s.config.HPCMappingStaleAfter <= 0 {
+		return false
+	}
+	for _, t := range s.transformations {
+		if reporter, ok := t.(transformation.MappingFreshnessReporter); ok {
+			newest, files := reporter.MappingFreshness()
+			return files > 0 && time.Since(newest) > s.config.HPCMappingStaleAfter
+		}
+	}
+	return false
+}
+
+// sharedGather returns the most recent transformed gather when it is younger than the collect
+// interval, and otherwise gathers and transforms the metrics of the rendered groups anew. The
+// endpoints other than /metrics render it rather than transform the metrics again, which would
+// advance the state of the transformations, e.g. the counter resets and the mapping conflicts.
+func (s *MetricsServer) sharedGather() (registry.MetricsByCounterGroup, error) {
+	s.gatherMu.Lock()
+	defer s.gatherMu.Unlock()
+	if s.lastGather != nil && time.Since(s.gatheredAt) < s.gatherReuseInterval() {
+		return s.lastGather, nil
+	}
+
+	metricGroups, err := s.registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	transformed := registry.MetricsByCounterGroup{}
+	for group, metrics := range metricGroups {
+		if !s.renderer.GroupEnabled(group) {
+			continue
+		}
+		deviceWatchList, exists := s.deviceWatchListManager.EntityWatchList(group)
+		if !exists {
+			continue
+		}
+		if err := s.transform(group, metrics, deviceWatchList.DeviceInfo(), "", ""); err != nil {
+			return nil, err
+		}
+		transformed[group] = metrics
+	}
+	s.lastGather, s.gatheredAt = transformed, time.Now()
+	return transformed, nil
+}
+
+// storeGather shares the transformed gather of a scrape with the other endpoints
+func (s *MetricsServer) storeGather(transformed registry.MetricsByCounterGroup) {
+	s.gatherMu.Lock()
+	defer s.gatherMu.Unlock()
+	s.lastGather, s.gatheredAt = transformed, time.Now()
+}
+
+// gatherReuseInterval is how long a transformed gather is shared, the collect interval, since
+// the collectors don't have newer values before
+func (s *MetricsServer) gatherReuseInterval() time.Duration {
+	if s.config == nil {
+		return 0
+	}
+	return time.Duration(s.config.CollectInterval) * time.Millisecond
+}
+
+// render renders the groups, and the metrics about the exporter, until the context is done. The
+// groups not started by then are left out and the context error is returned once the metrics
+// about the exporter are rendered. The groups are transformed first unless already transformed,
+// in which case transform is false; a gather transformed whole is shared with the other endpoints.
+// The time spent mapping and rendering the groups is added to the phases of the scrape.
+func (s *MetricsServer) render(
+	ctx context.Context, out scrapeOutput, metricGroups registry.MetricsByCounterGroup, transform bool,
+	phases map[string]time.Duration,
+) error {
+	if s.renderer.GenerationLabelEnabled() {
+		s.generationMu.Lock()
+		defer s.generationMu.Unlock()
+		s.generation++
+		s.renderer.SetGeneration(s.generation)
+	}
+	// the phases are summed over the groups
+	var mapping, rendering time.Duration
+	renderDurations := map[string]time.Duration{}
+	var ctxErr error
+	transformed := registry.MetricsByCounterGroup{}
 	for group, metrics := range metricGroups {
+		if !s.renderer.GroupEnabled(group) {
+			continue
+		}
 		deviceWatchList, exists := s.deviceWatchListManager.EntityWatchList(group)
 		if exists {
 
@@ -207,7 +542,7 @@ func (s *MetricsServer) render(w io.Writer, metricGroups registry.MetricsByCount
 			var metricsFile, deviceInfoFile string
 			var err error
 
-			if s.fileDumper != nil {
+			if transform && s.fileDumper != nil {
 				metricsFile, err = s.fileDumper.DumpToFile(metrics, "metrics", group.String())
 				if err != nil {
 					slog.Warn("Failed to write metrics debug file",
@@ -223,34 +558,30 @@ func (s *MetricsServer) render(w io.Writer, metricGroups registry.MetricsByCount
 				}
 			}
 
-			// Log summary information with file references
-			slog.Debug("Applying transformations",
-				slog.String(logging.FieldEntityGroupKey, group.String()),
-				slog.Int("metrics_count", len(metrics)),
-				slog.Int("transformations_count", len(s.transformations)),
-				slog.String("metrics_debug_file", metricsFile),
-				slog.String("deviceinfo_debug_file", deviceInfoFile),
-			)
-
-			for _, transformation := range s.transformations {
-				transformErr := transformation.Process(metrics, deviceWatchList.DeviceInfo())
-				if transformErr != nil {
-					slog.LogAttrs(context.Background(), slog.LevelError, "Failed to apply transformations on metrics",
-						slog.String(logging.ErrorKey, transformErr.Error()),
-						slog.String(logging.FieldEntityGroupKey, group.String()),
-						slog.String("transformation", transformation.Name()),
-						slog.Int("metrics_count", len(metrics)),
-						slog.String("metrics_debug_file", metricsFile),
-						slog.String("deviceinfo_debug_file", deviceInfoFile),
-					)
-					return transformErr
-				}
+			start := time.Now()
+			if transform {
+				err = s.transform(group, metrics, deviceWatchList.DeviceInfo(), metricsFile, deviceInfoFile)
+				transformed[group] = metrics
+			}
+			mapping += time.Since(start)
+			if err != nil {
+				return err
 			}
 			slog.Debug("Rendering metrics",
 				slog.String(logging.FieldEntityGroupKey, group.String()),
 				slog.Int("metrics_count", len(metrics)),
 				slog.String("metrics_debug_file", metricsFile))
-			err = rendermetrics.RenderGroup(w, group, metrics)
+			scrape := rendermetrics.Scrape{RenderDurations: renderDurations}
+			if group == dcgm.FE_GPU {
+				scrape.JobSeriesStale = s.mappingStale()
+			}
+			start = time.Now()
+			err = out.group(ctx, group, metrics, scrape)
+			rendering += time.Since(start)
+			if err != nil && errors.Is(err, ctx.Err()) {
+				ctxErr = err
+				break
+			}
 			if err != nil {
 				slog.LogAttrs(context.Background(), slog.LevelError, "Failed to renderGroup metrics",
 					slog.String(logging.ErrorKey, err.Error()),
@@ -263,9 +594,191 @@ func (s *MetricsServer) render(w io.Writer, metricGroups registry.MetricsByCount
 			}
 		}
 	}
+	phases[rendermetrics.ScrapePhaseMap] = mapping
+	phases[rendermetrics.ScrapePhaseRender] = rendering
+	if transform && ctxErr == nil {
+		s.storeGather(transformed)
+	}
+	var collected []dcgm.Field_Entity_Group
+	for _, group := range s.registry.Groups() {
+		if _, exists := s.deviceWatchListManager.EntityWatchList(group); exists && s.renderer.GroupEnabled(group) {
+			collected = append(collected, group)
+		}
+	}
+	if err := out.groupsUp(collected); err != nil {
+		return err
+	}
+	for _, t := range s.transformations {
+		if reporter, ok := t.(transformation.MappingConflictReporter); ok {
+			if err := out.mappingConflicts(reporter.MappingConflicts()); err != nil {
+				return err
+			}
+		}
+		if reporter, ok := t.(transformation.MappingFilesReporter); ok {
+			count, recent := reporter.MappingFiles()
+			if err := out.mappingFiles(count, recent); err != nil {
+				return err
+			}
+		}
+		if reporter, ok := t.(transformation.MappingOversizeReporter); ok {
+			if err := out.mappingOversize(reporter.MappingOversize()); err != nil {
+				return err
+			}
+		}
+		if reporter, ok := t.(transformation.MappingCoverageReporter); ok {
+			if err := out.mappingCoverage(reporter.MappingCoverage()); err != nil {
+				return err
+			}
+		}
+		// nothing is rendered before the mapping is first fetched
+		if reporter, ok := t.(transformation.MappingAgeReporter); ok && !reporter.MappingUpdatedAt().IsZero() {
+			if err := out.mappingAge(time.Since(reporter.MappingUpdatedAt())); err != nil {
+				return err
+			}
+		}
+	}
+	if err := out.exporter(renderDurations, phases); err != nil {
+		return err
+	}
+	return ctxErr
+}
+
+func (s *MetricsServer) transform(
+	group dcgm.Field_Entity_Group, metrics collector.MetricsByCounter, deviceInfo deviceinfo.Provider,
+	metricsFile, deviceInfoFile string,
+) error {
+	// Log summary information with file references
+	slog.Debug("Applying transformations",
+		slog.String(logging.FieldEntityGroupKey, group.String()),
+		slog.Int("metrics_count", len(metrics)),
+		slog.Int("transformations_count", len(s.transformations)),
+		slog.String("metrics_debug_file", metricsFile),
+		slog.String("deviceinfo_debug_file", deviceInfoFile),
+	)
+
+	for _, transformation := range s.transformations {
+		transformErr := transformation.Process(metrics, deviceInfo)
+		if transformErr != nil {
+			slog.LogAttrs(context.Background(), slog.LevelError, "Failed to apply transformations on metrics",
+				slog.String(logging.ErrorKey, transformErr.Error()),
+				slog.String(logging.FieldEntityGroupKey, group.String()),
+				slog.String("transformation", transformation.Name()),
+				slog.Int("metrics_count", len(metrics)),
+				slog.String("metrics_debug_file", metricsFile),
+				slog.String("deviceinfo_debug_file", deviceInfoFile),
+			)
+			return transformErr
+		}
+	}
 	return nil
 }
 
+// MetricsJSONLines serves the transformed metrics of all entity groups as JSON Lines.
+func (s *MetricsServer) MetricsJSONLines(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	metricGroups, err := s.sharedGather()
+	if err != nil {
+		slog.Error("Failed to gather metrics from collectors", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, internalServerError, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	for group, metrics := range metricGroups {
+		err = rendermetrics.RenderJSONLines(&buf, group, metrics)
+		if err != nil {
+			slog.Error("Failed to render metrics as JSON Lines", slog.String(logging.ErrorKey, err.Error()),
+				slog.String(logging.FieldEntityGroupKey, group.String()))
+			http.Error(w, internalServerError, http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		slog.Error("Failed to write response.", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, "failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// MetricsTenant serves the GPU metrics of the GPUs owned by the tenant of the request path.
+func (s *MetricsServer) MetricsTenant(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	tenant, ok := s.tenants[mux.Vars(r)["tenant"]]
+	if !ok {
+		http.Error(w, "unknown tenant", http.StatusNotFound)
+		return
+	}
+	metricGroups, err := s.sharedGather()
+	if err != nil {
+		slog.Error("Failed to gather metrics from collectors", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, internalServerError, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if metrics, ok := metricGroups[dcgm.FE_GPU]; ok {
+		err = s.renderer.RenderTenantGroup(&buf, dcgm.FE_GPU, metrics, tenant)
+		if err != nil {
+			slog.Error("Failed to render tenant metrics", slog.String(logging.ErrorKey, err.Error()))
+			http.Error(w, internalServerError, http.StatusInternalServerError)
+			return
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		slog.Error("Failed to write response.", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, "failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// MetricsJobs serves the GPU metrics mapped to HPC jobs aggregated per job.
+func (s *MetricsServer) MetricsJobs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	metricGroups, err := s.sharedGather()
+	if err != nil {
+		slog.Error("Failed to gather metrics from collectors", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, internalServerError, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if metrics, ok := metricGroups[dcgm.FE_GPU]; ok {
+		err = s.renderer.RenderJobs(&buf, metrics)
+		if err != nil {
+			slog.Error("Failed to render job metrics", slog.String(logging.ErrorKey, err.Error()))
+			http.Error(w, internalServerError, http.StatusInternalServerError)
+			return
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		slog.Error("Failed to write response.", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, "failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// MetricsLast serves the most recent rendered scrapes, oldest first, each preceded by the time it was rendered.
+func (s *MetricsServer) MetricsLast(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if s.scrapeHistory == nil {
+		http.Error(w, "scrape history is disabled", http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	for _, scrape := range s.scrapeHistory.Scrapes() {
+		fmt.Fprintf(&buf, "# scrape at %s\n", scrape.Time.Format(time.RFC3339Nano))
+		buf.Write(scrape.Output)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		slog.Error("Failed to write response.", slog.String(logging.ErrorKey, err.Error()))
+		http.Error(w, "failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
+
 func (s *MetricsServer) Health(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	_, err := w.Write([]byte("KO"))
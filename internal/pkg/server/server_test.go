@@ -21,12 +21,18 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
 	mockcollectorpkg "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/collector"
@@ -36,15 +42,20 @@ import (
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/devicewatcher"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/devicewatchlistmanager"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/registry"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/rendermetrics"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
 )
 
 const expectedResponse = `# HELP TEST_METRIC 
 # TYPE TEST_METRIC gauge
 TEST_METRIC{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="",device="nvidia0",modelName="NVIDIA T400 4GB",Hostname="testhost"} 42
+# HELP dcgm_exporter_group_up Whether the entity group is collected
+# TYPE dcgm_exporter_group_up gauge
+dcgm_exporter_group_up{group="gpu"} 1
 `
 
 var deviceWatcher = devicewatcher.NewDeviceWatcher()
@@ -60,6 +71,7 @@ func getMetricsByCounterWithTestMetric() collector.MetricsByCounter {
 		Hostname:     "testhost",
 		UUID:         "UUID",
 		GPUUUID:      "GPU-00000000-0000-0000-0000-000000000000",
+		AlterUUID:    "GPU-00000000-0000-0000-0000-000000000000",
 		Counter:      counter,
 		Value:        "42",
 		Attributes:   map[string]string{},
@@ -106,6 +118,23 @@ func TestMetrics(t *testing.T) {
 				assert.Equal(t, expectedResponse, recorder.Body.String())
 			},
 		},
+		{
+			name:  "Returns the group up series when the group is empty",
+			group: dcgm.FE_GPU,
+			collector: func() collector.Collector {
+				mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+				mockCollector.EXPECT().GetMetrics().Return(collector.MetricsByCounter{}, nil).AnyTimes()
+				return mockCollector
+			},
+			transformer: func() transformation.Transform {
+				return mocktransformation.NewMockTransform(ctrl)
+			},
+			assert: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.NotContains(t, recorder.Body.String(), "TEST_METRIC")
+				assert.Contains(t, recorder.Body.String(), "dcgm_exporter_group_up{group=\"gpu\"} 1\n")
+			},
+		},
 		{
 			name:  "Returns 500 when Collector return error",
 			group: dcgm.FE_GPU,
@@ -192,6 +221,7 @@ func TestMetrics(t *testing.T) {
 				transformations: []transformation.Transform{
 					tt.transformer(),
 				},
+				renderer: rendermetrics.NewRenderer(&appconfig.Config{}),
 			}
 
 			recorder := httptest.NewRecorder()
@@ -256,6 +286,7 @@ func TestMetricsReturnsErrorWhenClientClosedConnection(t *testing.T) {
 			return mockDeviceWatchListManager
 		}(),
 		transformations: []transformation.Transform{},
+		renderer:        rendermetrics.NewRenderer(&appconfig.Config{}),
 	}
 	recorder := &mockResponseWriter{}
 	metricServer.Metrics(recorder, nil)
@@ -276,3 +307,568 @@ func TestHealthReturnsOKWhenWriteReturnsError(t *testing.T) {
 	metricServer.Health(recorder, nil)
 	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 }
+
+func TestMetricsMappingFreshnessHeaders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0"), []byte("job1 1000\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1"), []byte("job2 1000\n"), 0o644))
+
+	tests := []struct {
+		name   string
+		config *appconfig.Config
+		assert func(*testing.T, http.Header)
+	}{
+		{
+			name:   "When HPC job mapping is enabled",
+			config: &appconfig.Config{HPCJobMappingDir: dir},
+			assert: func(t *testing.T, header http.Header) {
+				assert.Equal(t, "2", header.Get("X-DCGM-HPC-Mapping-Files"))
+				assert.Equal(t, "0", header.Get("X-DCGM-HPC-Mapping-Age"))
+			},
+		},
+		{
+			name:   "When HPC job mapping is disabled",
+			config: &appconfig.Config{},
+			assert: func(t *testing.T, header http.Header) {
+				assert.NotContains(t, header, "X-Dcgm-Hpc-Mapping-Files")
+				assert.NotContains(t, header, "X-Dcgm-Hpc-Mapping-Age")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+			mockCollector.EXPECT().GetMetrics().Return(getMetricsByCounterWithTestMetric(), nil).AnyTimes()
+
+			reg := registry.NewRegistry()
+			entityCollectorTuple := collector.EntityCollectorTuple{}
+			entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+			entityCollectorTuple.SetCollector(mockCollector)
+			reg.Register(entityCollectorTuple)
+
+			mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+			mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+			mockDeviceInfo.EXPECT().GOpts().Return(appconfig.DeviceOptions{}).AnyTimes()
+			mockDeviceInfo.EXPECT().GPUCount().Return(uint(0)).AnyTimes()
+
+			watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+			mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+			mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+			transformations, err := transformation.GetTransformations(tt.config)
+			require.NoError(t, err)
+			metricServer := &MetricsServer{
+				registry:               reg,
+				deviceWatchListManager: mockDeviceWatchListManager,
+				transformations:        transformations,
+				renderer:               rendermetrics.NewRenderer(tt.config),
+			}
+
+			recorder := httptest.NewRecorder()
+			metricServer.Metrics(recorder, nil)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			tt.assert(t, recorder.Header())
+		})
+	}
+}
+
+func TestMetricsStaleJobSeries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0"), []byte("51234567 1000\n"), 0o644))
+
+	scrape := func(t *testing.T, staleAfter time.Duration) string {
+		mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+		mockCollector.EXPECT().GetMetrics().Return(getMetricsByCounterWithTestMetric(), nil).AnyTimes()
+
+		reg := registry.NewRegistry()
+		entityCollectorTuple := collector.EntityCollectorTuple{}
+		entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+		entityCollectorTuple.SetCollector(mockCollector)
+		reg.Register(entityCollectorTuple)
+
+		mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+		mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+		mockDeviceInfo.EXPECT().GOpts().Return(appconfig.DeviceOptions{}).AnyTimes()
+		mockDeviceInfo.EXPECT().GPUCount().Return(uint(1)).AnyTimes()
+		mockDeviceInfo.EXPECT().GPU(gomock.Any()).Return(deviceinfo.GPUInfo{}).AnyTimes()
+
+		watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+		mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+		mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+		config := &appconfig.Config{HPCJobMappingDir: dir, HPCMappingStaleAfter: staleAfter}
+		transformations, err := transformation.GetTransformations(config)
+		require.NoError(t, err)
+		metricServer := &MetricsServer{
+			registry:               reg,
+			deviceWatchListManager: mockDeviceWatchListManager,
+			config:                 config,
+			transformations:        transformations,
+			renderer:               rendermetrics.NewRenderer(config),
+		}
+
+		recorder := httptest.NewRecorder()
+		metricServer.Metrics(recorder, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		return recorder.Body.String()
+	}
+
+	assert.Contains(t, scrape(t, time.Hour), `nvidia_gpu_jobId{`, "the mapping is fresh")
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "0"), old, old))
+	body := scrape(t, time.Hour)
+	assert.NotContains(t, body, `nvidia_gpu_jobId{`, "the job series of a stale mapping are left out")
+	assert.NotContains(t, body, `nvidia_gpu_jobUid{`)
+	assert.Contains(t, body, `jobid="51234567"`, "the GPU series keep their job labels")
+
+	assert.Contains(t, scrape(t, 0), `nvidia_gpu_jobId{`, "no threshold")
+}
+
+func TestReloadTransformationsPlaceholder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// a counter, which has a job series
+	metrics := getMetricsByCounterWithTestMetric()
+	for counter, values := range metrics {
+		delete(metrics, counter)
+		counter.PromType = "counter"
+		metrics[counter] = values
+	}
+	mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+	mockCollector.EXPECT().GetMetrics().Return(metrics, nil).AnyTimes()
+
+	reg := registry.NewRegistry()
+	entityCollectorTuple := collector.EntityCollectorTuple{}
+	entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+	entityCollectorTuple.SetCollector(mockCollector)
+	reg.Register(entityCollectorTuple)
+
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GOpts().Return(appconfig.DeviceOptions{}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(1)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).Return(deviceinfo.GPUInfo{}).AnyTimes()
+
+	watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+	mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+	mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+	// the GPU has no mapping file, so it gets the placeholder, which the id rewriter leaves as is
+	config := &appconfig.Config{
+		HPCJobMappingDir:        t.TempDir(),
+		HPCJobPlaceholder:       "none",
+		HPCIDRewriteRegex:       regexp.MustCompile(`^(\w+)$`),
+		HPCIDRewriteReplacement: "job-$1",
+		HPCIDRewriteAttributes:  []string{transformation.HpcJobAttribute},
+	}
+	transformations, err := transformation.GetTransformations(config)
+	require.NoError(t, err)
+	metricServer := &MetricsServer{
+		registry:               reg,
+		deviceWatchListManager: mockDeviceWatchListManager,
+		config:                 config,
+		transformations:        transformations,
+		renderer:               rendermetrics.NewRenderer(config),
+	}
+
+	scrape := func(t *testing.T, endpoint http.HandlerFunc) string {
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		return recorder.Body.String()
+	}
+
+	assert.Contains(t, scrape(t, metricServer.Metrics), `jobid="none"`)
+
+	reloaded := *config
+	reloaded.HPCJobPlaceholder = "idle"
+	metricServer.ReloadTransformations(&reloaded)
+
+	body := scrape(t, metricServer.Metrics)
+	assert.Contains(t, body, `jobid="idle"`, "the reloaded placeholder is set and not rewritten")
+	assert.NotContains(t, body, `jobid="none"`)
+	assert.NotContains(t, body, `jobid="job-idle"`)
+	assert.NotContains(t, scrape(t, metricServer.MetricsJobs), `idle`, "the reloaded placeholder has no job series")
+}
+
+func TestMetricsGenerationLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0"), []byte("51234567 1000\n"), 0o644))
+
+	mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+	mockCollector.EXPECT().GetMetrics().DoAndReturn(func() (collector.MetricsByCounter, error) {
+		return getMetricsByCounterWithTestMetric(), nil
+	}).AnyTimes()
+
+	reg := registry.NewRegistry()
+	entityCollectorTuple := collector.EntityCollectorTuple{}
+	entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+	entityCollectorTuple.SetCollector(mockCollector)
+	reg.Register(entityCollectorTuple)
+
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GOpts().Return(appconfig.DeviceOptions{}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(1)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).Return(deviceinfo.GPUInfo{}).AnyTimes()
+
+	watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+	mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+	mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+	config := &appconfig.Config{HPCJobMappingDir: dir, EnableGenerationLabel: true}
+	transformations, err := transformation.GetTransformations(config)
+	require.NoError(t, err)
+	metricServer := &MetricsServer{
+		registry:               reg,
+		deviceWatchListManager: mockDeviceWatchListManager,
+		config:                 config,
+		transformations:        transformations,
+		renderer:               rendermetrics.NewRenderer(config),
+	}
+
+	for _, generation := range []string{"1", "2"} {
+		recorder := httptest.NewRecorder()
+		metricServer.Metrics(recorder, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var series []string
+		for _, line := range strings.Split(recorder.Body.String(), "\n") {
+			if line != "" && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+				series = append(series, line)
+			}
+		}
+		require.NotEmpty(t, series)
+		assert.Contains(t, recorder.Body.String(), "nvidia_gpu_jobId{", "the job series are labeled too")
+		for _, line := range series {
+			assert.Contains(t, line, `collection_generation="`+generation+`"`)
+		}
+	}
+}
+
+func TestMetricsRegistryMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0"), []byte("51234567 1000\n"), 0o644))
+
+	mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+	mockCollector.EXPECT().GetMetrics().DoAndReturn(func() (collector.MetricsByCounter, error) {
+		return getMetricsByCounterWithTestMetric(), nil
+	}).AnyTimes()
+
+	reg := registry.NewRegistry()
+	entityCollectorTuple := collector.EntityCollectorTuple{}
+	entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+	entityCollectorTuple.SetCollector(mockCollector)
+	reg.Register(entityCollectorTuple)
+
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GOpts().Return(appconfig.DeviceOptions{}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(1)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).Return(deviceinfo.GPUInfo{}).AnyTimes()
+
+	watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+	mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+	mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+	config := &appconfig.Config{
+		HPCJobMappingDir:      dir,
+		RenderMode:            appconfig.RenderModeRegistry,
+		EnableSelfMetrics:     true,
+		EnableChecksumTrailer: true,
+	}
+	transformations, err := transformation.GetTransformations(config)
+	require.NoError(t, err)
+	metricServer := &MetricsServer{
+		registry:               reg,
+		deviceWatchListManager: mockDeviceWatchListManager,
+		config:                 config,
+		transformations:        transformations,
+		renderer:               rendermetrics.NewRenderer(config),
+		scrapeHistory:          rendermetrics.NewScrapeHistory(1, 0),
+	}
+	metricServer.registryHandler = metricServer.newRegistryHandler()
+
+	recorder := httptest.NewRecorder()
+	metricServer.Metrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+	assert.Equal(t, "1", recorder.Header().Get("X-DCGM-HPC-Mapping-Files"))
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, "TEST_METRIC{")
+	assert.Contains(t, body, `nvidia_gpu_jobId{`, "the Slurm job series are collected")
+	assert.Contains(t, body, `dcgm_exporter_group_up{group="gpu"} 1`)
+	assert.Contains(t, body, "dcgm_hpc_mapping_files 1\n", "the mapping series are collected")
+	assert.Contains(t, body, `dcgm_exporter_scrape_phase_seconds{phase="collect"}`, "the self metrics are collected")
+	assert.Contains(t, body, "dcgm_exporter_registry_series_dropped 0\n")
+
+	output, _, found := strings.Cut(body, "# CHECKSUM sha256 ")
+	require.True(t, found, "the text output ends with the checksum trailer")
+	assert.Equal(t, body, string(metricServer.renderer.AppendChecksum([]byte(output))))
+	assert.Len(t, metricServer.scrapeHistory.Scrapes(), 1, "the text output is kept in the scrape history")
+
+	// the registry is kept across scrapes
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("Accept", "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited")
+	metricServer.Metrics(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/vnd.google.protobuf"))
+	assert.NotContains(t, recorder.Body.String(), "# CHECKSUM", "only the text output has a checksum trailer")
+	assert.Len(t, metricServer.scrapeHistory.Scrapes(), 1)
+}
+
+func TestMetricsRegistryModeOpenMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	temperature := counters.Counter{FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge", Unit: "celsius"}
+	metrics := getMetricsByCounterWithTestMetric()
+	metrics[temperature] = []collector.Metric{{
+		GPU: "0", UUID: "UUID", GPUUUID: "GPU-0", Hostname: "testhost", Counter: temperature, Value: "40",
+		Attributes: map[string]string{},
+	}}
+	mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+	mockCollector.EXPECT().GetMetrics().Return(metrics, nil).AnyTimes()
+
+	reg := registry.NewRegistry()
+	entityCollectorTuple := collector.EntityCollectorTuple{}
+	entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+	entityCollectorTuple.SetCollector(mockCollector)
+	reg.Register(entityCollectorTuple)
+
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+
+	watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+	mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+	mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+	config := &appconfig.Config{RenderMode: appconfig.RenderModeRegistry, EnableOpenMetrics: true}
+	metricServer := &MetricsServer{
+		registry:               reg,
+		deviceWatchListManager: mockDeviceWatchListManager,
+		config:                 config,
+		renderer:               rendermetrics.NewRenderer(config),
+	}
+	metricServer.registryHandler = metricServer.newRegistryHandler()
+
+	scrape := func(t *testing.T, accept string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		request.Header.Set("Accept", accept)
+		metricServer.Metrics(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		return recorder
+	}
+
+	recorder := scrape(t, "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/openmetrics-text"))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# UNIT DCGM_FI_DEV_GPU_TEMP_celsius celsius\n")
+	assert.Contains(t, body, "\nDCGM_FI_DEV_GPU_TEMP_celsius{")
+	assert.NotContains(t, body, "# UNIT TEST_METRIC", "the fields without a known unit have no unit")
+	assert.True(t, strings.HasSuffix(body, "# EOF\n"))
+
+	recorder = scrape(t, "text/plain;version=0.0.4")
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, recorder.Body.String(), "\nDCGM_FI_DEV_GPU_TEMP{", "the text format has no unit suffix")
+	assert.NotContains(t, recorder.Body.String(), "# UNIT")
+}
+
+func TestMetricsScrapePhases(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+	mockCollector.EXPECT().GetMetrics().DoAndReturn(func() (collector.MetricsByCounter, error) {
+		time.Sleep(10 * time.Millisecond)
+		return getMetricsByCounterWithTestMetric(), nil
+	}).AnyTimes()
+
+	reg := registry.NewRegistry()
+	entityCollectorTuple := collector.EntityCollectorTuple{}
+	entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+	entityCollectorTuple.SetCollector(mockCollector)
+	reg.Register(entityCollectorTuple)
+
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GOpts().Return(appconfig.DeviceOptions{}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(1)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).Return(deviceinfo.GPUInfo{}).AnyTimes()
+
+	watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+	mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+	mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+	config := &appconfig.Config{HPCJobMappingDir: t.TempDir(), EnableSelfMetrics: true}
+	transformations, err := transformation.GetTransformations(config)
+	require.NoError(t, err)
+	metricServer := &MetricsServer{
+		registry:               reg,
+		deviceWatchListManager: mockDeviceWatchListManager,
+		config:                 config,
+		transformations:        transformations,
+		renderer:               rendermetrics.NewRenderer(config),
+	}
+
+	recorder := httptest.NewRecorder()
+	metricServer.Metrics(recorder, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	phases := map[string]float64{}
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		series, value, found := strings.Cut(line, " ")
+		if !found || !strings.HasPrefix(series, "dcgm_exporter_scrape_phase_seconds{") {
+			continue
+		}
+		phase := strings.TrimSuffix(strings.TrimPrefix(series, `dcgm_exporter_scrape_phase_seconds{phase="`), `"}`)
+		seconds, err := strconv.ParseFloat(value, 64)
+		require.NoError(t, err, line)
+		phases[phase] = seconds
+	}
+	require.Len(t, phases, 3, recorder.Body.String())
+	assert.GreaterOrEqual(t, phases["collect"], 0.01, "the collector takes 10ms")
+	assert.Less(t, phases["collect"], 5.0)
+	for _, phase := range []string{"map", "render"} {
+		assert.Contains(t, phases, phase)
+		assert.GreaterOrEqual(t, phases[phase], 0.0)
+		assert.Less(t, phases[phase], 5.0)
+	}
+}
+
+func TestMetricsScrapeFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	value := 0
+	mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+	mockCollector.EXPECT().GetMetrics().DoAndReturn(func() (collector.MetricsByCounter, error) {
+		value++
+		metrics := getMetricsByCounterWithTestMetric()
+		for _, values := range metrics {
+			for i := range values {
+				values[i].Value = strconv.Itoa(value)
+			}
+		}
+		return metrics, nil
+	}).AnyTimes()
+
+	reg := registry.NewRegistry()
+	entityCollectorTuple := collector.EntityCollectorTuple{}
+	entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+	entityCollectorTuple.SetCollector(mockCollector)
+	reg.Register(entityCollectorTuple)
+
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+
+	watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+	mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+	mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+	path := filepath.Join(t.TempDir(), "scrape.prom")
+	config := &appconfig.Config{ScrapeFile: path}
+	metricServer := &MetricsServer{
+		registry:               reg,
+		deviceWatchListManager: mockDeviceWatchListManager,
+		config:                 config,
+		renderer:               rendermetrics.NewRenderer(config),
+		scrapeFile:             rendermetrics.NewScrapeFile(path, 0),
+	}
+
+	for _, want := range []string{"} 1\n", "} 2\n"} {
+		recorder := httptest.NewRecorder()
+		metricServer.Metrics(recorder, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Contains(t, recorder.Body.String(), want)
+
+		metricServer.scrapeFile.Wait()
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, recorder.Body.String(), string(data), "the file holds the last scrape")
+	}
+}
+
+// countingTransform counts the metrics it processes
+type countingTransform struct {
+	processed int
+}
+
+func (c *countingTransform) Process(metrics collector.MetricsByCounter, _ deviceinfo.Provider) error {
+	for _, values := range metrics {
+		c.processed += len(values)
+	}
+	return nil
+}
+
+func (c *countingTransform) Name() string {
+	return "countingTransform"
+}
+
+func TestSideEndpointsShareTheTransformedGather(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	gathers := 0
+	mockCollector := mockcollectorpkg.NewMockCollector(ctrl)
+	mockCollector.EXPECT().GetMetrics().DoAndReturn(func() (collector.MetricsByCounter, error) {
+		gathers++
+		return getMetricsByCounterWithTestMetric(), nil
+	}).AnyTimes()
+
+	reg := registry.NewRegistry()
+	entityCollectorTuple := collector.EntityCollectorTuple{}
+	entityCollectorTuple.SetEntity(dcgm.FE_GPU)
+	entityCollectorTuple.SetCollector(mockCollector)
+	reg.Register(entityCollectorTuple)
+
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+
+	watchList := *devicewatchlistmanager.NewWatchList(mockDeviceInfo, []dcgm.Short{42}, nil, deviceWatcher, 1)
+	mockDeviceWatchListManager := mockdevicewatchlistmanager.NewMockManager(ctrl)
+	mockDeviceWatchListManager.EXPECT().EntityWatchList(dcgm.FE_GPU).Return(watchList, true).AnyTimes()
+
+	transform := &countingTransform{}
+	config := &appconfig.Config{CollectInterval: int(time.Hour.Milliseconds()), EnableDeltaEndpoint: true}
+	metricServer := &MetricsServer{
+		registry:               reg,
+		deviceWatchListManager: mockDeviceWatchListManager,
+		config:                 config,
+		transformations:        []transformation.Transform{transform},
+		renderer:               rendermetrics.NewRenderer(config),
+		deltaFilter:            rendermetrics.NewDeltaFilter(),
+	}
+
+	recorder := httptest.NewRecorder()
+	metricServer.Metrics(recorder, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, 1, transform.processed)
+
+	for _, endpoint := range []http.HandlerFunc{
+		metricServer.MetricsJSONLines, metricServer.MetricsJobs, metricServer.MetricsDelta,
+	} {
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	}
+	assert.Equal(t, 1, gathers, "the side endpoints render the gather of the scrape")
+	assert.Equal(t, 1, transform.processed, "the metrics are transformed once per gather")
+
+	metricServer.gatheredAt = time.Now().Add(-2 * time.Hour)
+	recorder = httptest.NewRecorder()
+	metricServer.MetricsJSONLines(recorder, nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "TEST_METRIC")
+	assert.Equal(t, 2, gathers, "a gather older than the collect interval is gathered anew")
+	assert.Equal(t, 2, transform.processed)
+}
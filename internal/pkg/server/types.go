@@ -19,6 +19,7 @@ package server
 import (
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/prometheus/exporter-toolkit/web"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/debug"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/devicewatchlistmanager"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/registry"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/rendermetrics"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
 )
 
@@ -41,4 +43,21 @@ type MetricsServer struct {
 	transformations        []transformation.Transform
 	deviceWatchListManager devicewatchlistmanager.Manager
 	fileDumper             *debug.FileDumper
+	renderer               *rendermetrics.Renderer
+	scrapeHistory          *rendermetrics.ScrapeHistory
+	scrapeFile             *rendermetrics.ScrapeFile
+	deltaFilter            *rendermetrics.DeltaFilter
+	tenants                map[string]rendermetrics.TenantFilter
+	// registryHandler serves /metrics in the registry render mode
+	registryHandler http.Handler
+
+	// generationMu serializes the renderings when the series are labeled with their generation
+	generationMu sync.Mutex
+	generation   uint64
+
+	// the most recent transformed gather is shared by the endpoints rendering it within the
+	// collect interval, so that the stateful transformations advance once per gather
+	gatherMu   sync.Mutex
+	lastGather registry.MetricsByCounterGroup
+	gatheredAt time.Time
 }
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package pipelinetest runs hand-built metrics through the transformations and the renderer,
+// so the metrics pipeline can be tested end to end without DCGM or a GPU.
+package pipelinetest
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/rendermetrics"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
+)
+
+const hostname = "testhost"
+
+// FakeProvider is a deviceinfo.Provider of a fixed set of GPUs
+type FakeProvider struct {
+	gpus     []deviceinfo.GPUInfo
+	infoType dcgm.Field_Entity_Group
+}
+
+var _ deviceinfo.Provider = (*FakeProvider)(nil)
+
+// GPU describes a GPU of the FakeProvider
+type GPU struct {
+	UUID     string
+	PCIBusID string
+	Serial   string
+	Model    string
+	MIG      []MIGInstance
+}
+
+// MIGInstance describes a MIG instance of a GPU of the FakeProvider
+type MIGInstance struct {
+	InstanceID uint
+	UUID       string
+	Profile    string
+}
+
+// NewFakeProvider returns a provider of GPU entities; the GPUs are numbered in the given order.
+func NewFakeProvider(gpus ...GPU) *FakeProvider {
+	p := &FakeProvider{infoType: dcgm.FE_GPU}
+	for i, gpu := range gpus {
+		info := deviceinfo.GPUInfo{
+			DeviceInfo: dcgm.Device{
+				GPU:         uint(i),
+				UUID:        gpu.UUID,
+				PCI:         dcgm.PCIInfo{BusID: gpu.PCIBusID},
+				Identifiers: dcgm.DeviceIdentifiers{Model: gpu.Model, Serial: gpu.Serial},
+			},
+			MigEnabled: len(gpu.MIG) > 0,
+		}
+		for _, mig := range gpu.MIG {
+			info.GPUInstances = append(info.GPUInstances, deviceinfo.GPUInstanceInfo{
+				Info:        dcgm.MigEntityInfo{GpuUuid: gpu.UUID, NvmlGpuIndex: uint(i), NvmlInstanceId: mig.InstanceID},
+				ProfileName: mig.Profile,
+				EntityId:    mig.InstanceID,
+				UUID:        mig.UUID,
+			})
+		}
+		p.gpus = append(p.gpus, info)
+	}
+	return p
+}
+
+// Metric returns a metric of the i-th GPU as the collector would build it
+func (p *FakeProvider) Metric(counter counters.Counter, i uint, value string) collector.Metric {
+	d := p.gpus[i].DeviceInfo
+	return collector.Metric{
+		Counter:      counter,
+		Value:        value,
+		UUID:         "UUID",
+		GPU:          fmt.Sprintf("%d", d.GPU),
+		GPUUUID:      d.UUID,
+		GPUDevice:    fmt.Sprintf("nvidia%d", d.GPU),
+		GPUModelName: d.Identifiers.Model,
+		GPUPCIBusID:  d.PCI.BusID,
+		Hostname:     hostname,
+		Labels:       map[string]string{},
+		Attributes:   map[string]string{},
+	}
+}
+
+// MIGMetric returns a metric of the MIG instance of the i-th GPU as the collector would build it
+func (p *FakeProvider) MIGMetric(counter counters.Counter, i uint, instanceID uint, value string) collector.Metric {
+	m := p.Metric(counter, i, value)
+	for _, instance := range p.gpus[i].GPUInstances {
+		if instance.Info.NvmlInstanceId == instanceID {
+			m.MigProfile = instance.ProfileName
+		}
+	}
+	m.GPUInstanceID = fmt.Sprintf("%d", instanceID)
+	return m
+}
+
+func (p *FakeProvider) GPUCount() uint {
+	return uint(len(p.gpus))
+}
+
+func (p *FakeProvider) GPUs() []deviceinfo.GPUInfo {
+	return p.gpus
+}
+
+func (p *FakeProvider) GPU(i uint) deviceinfo.GPUInfo {
+	return p.gpus[i]
+}
+
+func (p *FakeProvider) Switches() []deviceinfo.SwitchInfo {
+	return nil
+}
+
+func (p *FakeProvider) Switch(uint) deviceinfo.SwitchInfo {
+	return deviceinfo.SwitchInfo{}
+}
+
+func (p *FakeProvider) CPUs() []deviceinfo.CPUInfo {
+	return nil
+}
+
+func (p *FakeProvider) CPU(uint) deviceinfo.CPUInfo {
+	return deviceinfo.CPUInfo{}
+}
+
+func (p *FakeProvider) GOpts() appconfig.DeviceOptions {
+	return appconfig.DeviceOptions{Flex: true}
+}
+
+func (p *FakeProvider) SOpts() appconfig.DeviceOptions {
+	return appconfig.DeviceOptions{}
+}
+
+func (p *FakeProvider) COpts() appconfig.DeviceOptions {
+	return appconfig.DeviceOptions{}
+}
+
+func (p *FakeProvider) InfoType() dcgm.Field_Entity_Group {
+	return p.infoType
+}
+
+func (p *FakeProvider) IsCPUWatched(uint) bool {
+	return false
+}
+
+func (p *FakeProvider) IsCoreWatched(uint, uint) bool {
+	return false
+}
+
+func (p *FakeProvider) IsSwitchWatched(uint) bool {
+	return false
+}
+
+func (p *FakeProvider) IsLinkWatched(uint, uint) bool {
+	return false
+}
+
+// Render runs the metrics of the GPU group through the transformations enabled by the config
+// and renders them the way the metrics server does, returning the rendered text.
+func Render(config *appconfig.Config, provider *FakeProvider, metrics collector.MetricsByCounter) (string, error) {
+	transformations, err := transformation.GetTransformations(config)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range transformations {
+		if err := t.Process(metrics, provider); err != nil {
+			return "", fmt.Errorf("%s: %w", t.Name(), err)
+		}
+	}
+
+	var w bytes.Buffer
+	if err := rendermetrics.NewRenderer(config).RenderGroup(&w, dcgm.FE_GPU, metrics); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
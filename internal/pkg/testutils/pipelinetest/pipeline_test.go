@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pipelinetest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/testutils"
+)
+
+func TestRenderHPCJobMapping(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "GPU-00000000-0000-0000-0000-000000000000"),
+		[]byte("1234 1000\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "MIG-11111111-1111-1111-1111-111111111111"),
+		[]byte("5678 2000\n"), 0o644))
+
+	provider := NewFakeProvider(
+		GPU{UUID: "GPU-00000000-0000-0000-0000-000000000000", PCIBusID: "00000000:3B:00.0", Model: "NVIDIA A100"},
+		GPU{UUID: "GPU-22222222-2222-2222-2222-222222222222", Model: "NVIDIA A100", MIG: []MIGInstance{
+			{InstanceID: 1, UUID: "MIG-11111111-1111-1111-1111-111111111111", Profile: "1g.10gb"},
+		}},
+	)
+	counter := testutils.SampleGPUTempCounter
+	metrics := collector.MetricsByCounter{
+		counter: {
+			provider.Metric(counter, 0, "42"),
+			provider.MIGMetric(counter, 1, 1, "43"),
+		},
+	}
+
+	out, err := Render(&appconfig.Config{HPCJobMappingDir: dir}, provider, metrics)
+	require.NoError(t, err)
+
+	assert.Contains(t, out,
+		`DCGM_FI_DEV_GPU_TEMP{gpu="0",UUID="GPU-00000000-0000-0000-0000-000000000000",pci_bus_id="00000000:3B:00.0",device="nvidia0",modelName="NVIDIA A100",Hostname="testhost",jobid="1234",mapping_source="file",userid="1000"} 42`)
+	assert.Contains(t, out,
+		`nvidia_gpu_jobId{minor_number="1",uuid="MIG-11111111-1111-1111-1111-111111111111",device="nvidia1",modelName="NVIDIA A100",GPU_I_PROFILE="1g.10gb",GPU_I_ID="1",Hostname="testhost",jobid="5678",userid="2000"} 5678`)
+}
+
+func TestRenderFieldAlias(t *testing.T) {
+	provider := NewFakeProvider(
+		GPU{UUID: "GPU-00000000-0000-0000-0000-000000000000", Model: "NVIDIA H100"},
+		GPU{UUID: "GPU-11111111-1111-1111-1111-111111111111", Model: "NVIDIA T400"},
+	)
+	memTemp := testutils.SampleGPUTempCounter
+	memTemp.FieldID, memTemp.FieldName = dcgm.DCGM_FI_DEV_MEMORY_TEMP, "DCGM_FI_DEV_MEMORY_TEMP"
+	gpuTemp := testutils.SampleGPUTempCounter
+	metrics := collector.MetricsByCounter{
+		memTemp: {provider.Metric(memTemp, 0, "60")},
+		gpuTemp: {provider.Metric(gpuTemp, 1, "45")},
+	}
+
+	out, err := Render(&appconfig.Config{
+		FieldAliases: map[string][]string{"gpu_temperature": {"DCGM_FI_DEV_MEMORY_TEMP", "DCGM_FI_DEV_GPU_TEMP"}},
+	}, provider, metrics)
+	require.NoError(t, err)
+
+	assert.Contains(t, out, `device="nvidia0",modelName="NVIDIA H100",Hostname="testhost",source_field="DCGM_FI_DEV_MEMORY_TEMP"} 60`)
+	assert.Contains(t, out, `device="nvidia1",modelName="NVIDIA T400",Hostname="testhost",source_field="DCGM_FI_DEV_GPU_TEMP"} 45`)
+	assert.Equal(t, 2, strings.Count(out, "gpu_temperature{"))
+	assert.Equal(t, 1, strings.Count(out, "# TYPE gpu_temperature"))
+	assert.NotContains(t, out, "DCGM_FI_DEV_MEMORY_TEMP{")
+}
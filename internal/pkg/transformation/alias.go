@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// fieldAliaser renders fields measuring the same thing, e.g. on different GPU generations, as a
+// single series. Per GPU the first field of the alias reporting a value is rendered under the
+// alias name, with the source field as an attribute, and the fields aren't rendered themselves.
+type fieldAliaser struct {
+	Config *appconfig.Config
+}
+
+func newFieldAliaser(c *appconfig.Config) *fieldAliaser {
+	slog.Info(fmt.Sprintf("Field aliases are enabled for %d aliases", len(c.FieldAliases)))
+	return &fieldAliaser{
+		Config: c,
+	}
+}
+
+func (p *fieldAliaser) Name() string {
+	return "fieldAliaser"
+}
+
+func (p *fieldAliaser) Process(metrics collector.MetricsByCounter, _ deviceinfo.Provider) error {
+	for _, alias := range slices.Sorted(maps.Keys(p.Config.FieldAliases)) {
+		var aliasCounter counters.Counter
+		// the metrics of each GPU, from the field with the highest precedence
+		selected := map[string][]collector.Metric{}
+		var order []string
+
+		for _, field := range p.Config.FieldAliases[alias] {
+			for counter, values := range metrics {
+				if counter.FieldName != field {
+					continue
+				}
+				if aliasCounter.FieldName == "" {
+					aliasCounter = counter
+					aliasCounter.FieldName = alias
+					aliasCounter.AlterFieldName = ""
+				}
+				for _, metric := range values {
+					key := promotionKey(metric)
+					if _, exists := selected[key]; !exists {
+						order = append(order, key)
+					}
+					if precedes(selected[key], field) {
+						continue
+					}
+					metric.Counter = aliasCounter
+					metric.Attributes = maps.Clone(metric.Attributes)
+					if metric.Attributes == nil {
+						metric.Attributes = map[string]string{}
+					}
+					metric.Attributes[SourceFieldAttribute] = field
+					selected[key] = append(selected[key], metric)
+				}
+				delete(metrics, counter)
+			}
+		}
+
+		for _, key := range order {
+			metrics[aliasCounter] = append(metrics[aliasCounter], selected[key]...)
+		}
+	}
+
+	return nil
+}
+
+// precedes tells whether the GPU already has metrics from a field other than field, which
+// then has precedence as the fields are visited in precedence order
+func precedes(selected []collector.Metric, field string) bool {
+	return len(selected) > 0 && selected[0].Attributes[SourceFieldAttribute] != field
+}
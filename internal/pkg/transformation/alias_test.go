@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transformation
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestFieldAliaserProcess(t *testing.T) {
+	memTempCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_MEMORY_TEMP,
+		FieldName: "DCGM_FI_DEV_MEMORY_TEMP",
+		PromType:  "gauge",
+		Help:      "Memory temperature (in C).",
+	}
+	gpuTempCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_TEMP,
+		FieldName: "DCGM_FI_DEV_GPU_TEMP",
+		PromType:  "gauge",
+		Help:      "GPU temperature (in C).",
+	}
+	utilCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_UTIL,
+		FieldName: "DCGM_FI_DEV_GPU_UTIL",
+		PromType:  "gauge",
+	}
+
+	metrics := collector.MetricsByCounter{
+		// GPU 0 reports the memory temperature only, GPU 1 both, GPU 2 the GPU temperature only
+		memTempCounter: {
+			{GPU: "0", Value: "60", Counter: memTempCounter, Attributes: map[string]string{}},
+			{GPU: "1", Value: "61", Counter: memTempCounter, Attributes: map[string]string{}},
+		},
+		gpuTempCounter: {
+			{GPU: "1", Value: "51", Counter: gpuTempCounter, Attributes: map[string]string{}},
+			{GPU: "2", Value: "52", Counter: gpuTempCounter},
+		},
+		utilCounter: {
+			{GPU: "0", Value: "87", Counter: utilCounter, Attributes: map[string]string{}},
+		},
+	}
+
+	mapper := newFieldAliaser(&appconfig.Config{
+		FieldAliases: map[string][]string{
+			"gpu_temperature": {"DCGM_FI_DEV_MEMORY_TEMP", "DCGM_FI_DEV_GPU_TEMP"},
+		},
+	})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics, 2, "the aliased fields are rendered under the alias only")
+	assert.Len(t, metrics[utilCounter], 1)
+
+	var aliased []collector.Metric
+	for counter, values := range metrics {
+		if counter.FieldName == "gpu_temperature" {
+			assert.Equal(t, "gauge", counter.PromType)
+			aliased = values
+		}
+	}
+	require.Len(t, aliased, 3, "one series per GPU")
+
+	got := map[string]collector.Metric{}
+	for _, metric := range aliased {
+		got[metric.GPU] = metric
+	}
+	assert.Equal(t, "60", got["0"].Value)
+	assert.Equal(t, "DCGM_FI_DEV_MEMORY_TEMP", got["0"].Attributes[SourceFieldAttribute])
+	assert.Equal(t, "61", got["1"].Value, "the first field of the alias takes precedence")
+	assert.Equal(t, "DCGM_FI_DEV_MEMORY_TEMP", got["1"].Attributes[SourceFieldAttribute])
+	assert.Equal(t, "52", got["2"].Value)
+	assert.Equal(t, "DCGM_FI_DEV_GPU_TEMP", got["2"].Attributes[SourceFieldAttribute])
+}
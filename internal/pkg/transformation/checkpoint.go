@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+// deviceCheckpoint is the part of the kubelet device plugin checkpoint the mapper uses, e.g.
+//
+//	{"Data": {"PodDeviceEntries": [{"PodUID": "...", "ContainerName": "...",
+//	  "ResourceName": "nvidia.com/gpu", "DeviceIDs": {"0": ["GPU-..."]}, "AllocResp": "..."}],
+//	  "RegisteredDevices": {...}}, "Checksum": 123}
+type deviceCheckpoint struct {
+	Data *struct {
+		PodDeviceEntries []checkpointEntry
+	}
+}
+
+type checkpointEntry struct {
+	PodUID        string
+	ContainerName string
+	ResourceName  string
+	// DeviceIDs are the device ids by NUMA node, or a list of device ids before kubelet 1.20
+	DeviceIDs json.RawMessage
+}
+
+// deviceIDs returns the device ids of the entry, and false when they are in an unknown shape
+func (e checkpointEntry) deviceIDs() ([]string, bool) {
+	var byNUMANode map[string][]string
+	if err := json.Unmarshal(e.DeviceIDs, &byNUMANode); err == nil {
+		var ids []string
+		for _, node := range slices.Sorted(maps.Keys(byNUMANode)) {
+			ids = append(ids, byNUMANode[node]...)
+		}
+		return ids, true
+	}
+	var ids []string
+	if err := json.Unmarshal(e.DeviceIDs, &ids); err == nil {
+		return ids, true
+	}
+	return nil, false
+}
+
+// checkpointContainer is a container holding a device
+type checkpointContainer struct {
+	podUID    string
+	container string
+}
+
+// checkpointMapper attributes the GPU metrics to the pods holding the GPUs, as recorded by the
+// kubelet in its device plugin checkpoint. The checkpoint has the pod UIDs but neither the pod
+// names nor the namespaces, so the metrics get the pod_uid and container attributes.
+type checkpointMapper struct {
+	Config *appconfig.Config
+
+	devices deviceReadiness
+}
+
+func newCheckpointMapper(c *appconfig.Config) *checkpointMapper {
+	slog.Info(fmt.Sprintf("Pod mapping is enabled and reads the %q device plugin checkpoint",
+		c.KubernetesDeviceCheckpoint))
+	return &checkpointMapper{
+		Config: c,
+	}
+}
+
+func (p *checkpointMapper) Name() string {
+	return "checkpointMapper"
+}
+
+func (p *checkpointMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	data, err := readCheckpoint(p.Config.KubernetesDeviceCheckpoint, p.Config.HPCMaxMappingFileBytes)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Unable to read the device plugin checkpoint '%s'. Ignoring.",
+			p.Config.KubernetesDeviceCheckpoint), slog.String(logging.ErrorKey, err.Error()))
+		return nil
+	}
+
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	deviceContainers, err := p.parseCheckpoint(data, sysInfo)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Unable to parse the device plugin checkpoint '%s'. Ignoring.",
+			p.Config.KubernetesDeviceCheckpoint), slog.String(logging.ErrorKey, err.Error()))
+		return nil
+	}
+
+	slog.Debug(fmt.Sprintf("Device to container mapping: %+v", deviceContainers))
+
+	for counter := range metrics {
+		var mappedMetrics []collector.Metric
+		for _, metric := range metrics[counter] {
+			uuid := metric.GPUUUID
+			if metric.MigProfile != "" {
+				uuid = migUUIDOf(sysInfo, metric)
+			}
+			containers := deviceContainers[uuid]
+			if len(containers) == 0 {
+				mappedMetrics = append(mappedMetrics, metric)
+				continue
+			}
+			for _, container := range containers {
+				mapped := metric
+				mapped.Attributes = maps.Clone(metric.Attributes)
+				if mapped.Attributes == nil {
+					mapped.Attributes = map[string]string{}
+				}
+				mapped.Attributes[uidAttribute] = container.podUID
+				mapped.Attributes[containerAttribute] = container.container
+				mapped.Attributes[MappingSourceAttribute] = mappingSourceCheckpoint
+				mappedMetrics = append(mappedMetrics, mapped)
+			}
+		}
+		metrics[counter] = mappedMetrics
+	}
+
+	return nil
+}
+
+// readCheckpoint reads the checkpoint, which must not exceed maxBytes unless it is 0, as the
+// mapping files
+func readCheckpoint(path string, maxBytes int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if maxBytes > 0 {
+		return readLimited(file, maxBytes)
+	}
+	return io.ReadAll(file)
+}
+
+// parseCheckpoint returns the containers holding each GPU or MIG instance, by UUID. The entries of
+// other resources and the entries in an unknown shape are skipped.
+func (p *checkpointMapper) parseCheckpoint(
+	data []byte, sysInfo deviceinfo.Provider,
+) (map[string][]checkpointContainer, error) {
+	var checkpoint deviceCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.Data == nil {
+		return nil, fmt.Errorf("unknown checkpoint format, no Data")
+	}
+
+	deviceContainers := map[string][]checkpointContainer{}
+	for _, entry := range checkpoint.Data.PodDeviceEntries {
+		if !p.isNvidiaResource(entry.ResourceName) {
+			continue
+		}
+		ids, ok := entry.deviceIDs()
+		if !ok || entry.PodUID == "" {
+			slog.Debug(fmt.Sprintf("Skipping device plugin checkpoint entry in an unknown format: %+v", entry))
+			continue
+		}
+		container := checkpointContainer{podUID: entry.PodUID, container: entry.ContainerName}
+		for _, id := range ids {
+			uuid := deviceUUID(sysInfo, id)
+			if uuid == "" {
+				slog.Debug(fmt.Sprintf("Skipping unknown device %q of pod %s", id, entry.PodUID))
+				continue
+			}
+			if !slices.Contains(deviceContainers[uuid], container) {
+				deviceContainers[uuid] = append(deviceContainers[uuid], container)
+			}
+		}
+	}
+
+	return deviceContainers, nil
+}
+
+func (p *checkpointMapper) isNvidiaResource(resourceName string) bool {
+	return resourceName == appconfig.NvidiaResourceName ||
+		slices.Contains(p.Config.NvidiaResourceNames, resourceName) ||
+		strings.HasPrefix(resourceName, appconfig.NvidiaMigResourcePrefix)
+}
+
+// deviceUUID returns the GPU or MIG UUID of a device plugin device id, which is either the UUID,
+// the UUID followed by ::<replica> for shared GPUs, or the index of the GPU, and "" when the GPU
+// is not found.
+func deviceUUID(sysInfo deviceinfo.Provider, id string) string {
+	id, _, _ = strings.Cut(id, "::")
+	if strings.HasPrefix(id, "GPU-") || strings.HasPrefix(id, "MIG-") {
+		return id
+	}
+	index, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || sysInfo == nil || uint(index) >= sysInfo.GPUCount() {
+		return ""
+	}
+	return sysInfo.GPU(uint(index)).DeviceInfo.UUID
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// testCheckpoint is a kubelet device plugin checkpoint with a pod holding GPU 0, two pods sharing
+// GPU 1 by time-slicing, a pod holding GPU 2 by index, and entries to be skipped
+const testCheckpoint = `{
+  "Data": {
+    "PodDeviceEntries": [
+      {"PodUID": "pod-a", "ContainerName": "train", "ResourceName": "nvidia.com/gpu",
+       "DeviceIDs": {"0": ["GPU-00000000-0000-0000-0000-000000000000"]}, "AllocResp": "CgA="},
+      {"PodUID": "pod-b", "ContainerName": "infer", "ResourceName": "nvidia.com/gpu",
+       "DeviceIDs": {"1": ["GPU-11111111-1111-1111-1111-111111111111::0"]}, "AllocResp": "CgA="},
+      {"PodUID": "pod-c", "ContainerName": "infer", "ResourceName": "nvidia.com/gpu",
+       "DeviceIDs": {"1": ["GPU-11111111-1111-1111-1111-111111111111::1"]}, "AllocResp": "CgA="},
+      {"PodUID": "pod-d", "ContainerName": "legacy", "ResourceName": "nvidia.com/gpu",
+       "DeviceIDs": ["2"], "AllocResp": "CgA="},
+      {"PodUID": "pod-e", "ContainerName": "fpga", "ResourceName": "example.com/fpga",
+       "DeviceIDs": {"0": ["GPU-22222222-2222-2222-2222-222222222222"]}, "AllocResp": "CgA="},
+      {"PodUID": "pod-f", "ContainerName": "future", "ResourceName": "nvidia.com/gpu",
+       "DeviceIDs": {"devices": {"0": "GPU-22222222-2222-2222-2222-222222222222"}}}
+    ],
+    "RegisteredDevices": {"nvidia.com/gpu": ["GPU-00000000-0000-0000-0000-000000000000"]}
+  },
+  "Checksum": 1234567890
+}`
+
+func TestCheckpointMapperProcess(t *testing.T) {
+	checkpoint := filepath.Join(t.TempDir(), "kubelet_internal_checkpoint")
+	require.NoError(t, sysOS.WriteFile(checkpoint, []byte(testCheckpoint), 0o644))
+
+	uuids := []string{
+		"GPU-00000000-0000-0000-0000-000000000000",
+		"GPU-11111111-1111-1111-1111-111111111111",
+		"GPU-22222222-2222-2222-2222-222222222222",
+		"GPU-33333333-3333-3333-3333-333333333333",
+	}
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(len(uuids))).AnyTimes()
+	for i, uuid := range uuids {
+		mockDeviceInfo.EXPECT().GPU(uint(i)).Return(deviceinfo.GPUInfo{
+			DeviceInfo: dcgm.Device{UUID: uuid},
+		}).AnyTimes()
+	}
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{counter: {}}
+	for i, uuid := range uuids {
+		metrics[counter] = append(metrics[counter], collector.Metric{
+			GPU: strconv.Itoa(i), GPUUUID: uuid, Value: "42", Counter: counter, Attributes: map[string]string{},
+		})
+	}
+
+	mapper := newCheckpointMapper(&appconfig.Config{KubernetesDeviceCheckpoint: checkpoint})
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+	var got []string
+	for _, metric := range metrics[counter] {
+		got = append(got, metric.GPU+":"+metric.Attributes[uidAttribute]+"/"+metric.Attributes[containerAttribute])
+	}
+	assert.Equal(t, []string{"0:pod-a/train", "1:pod-b/infer", "1:pod-c/infer", "2:pod-d/legacy", "3:/"}, got)
+
+	for _, metric := range metrics[counter][:4] {
+		assert.Equal(t, "checkpoint", metric.Attributes[MappingSourceAttribute])
+	}
+	assert.NotContains(t, metrics[counter][4].Attributes, MappingSourceAttribute, "unmapped GPUs have no mapping source")
+}
+
+func TestCheckpointMapperUnknownFormat(t *testing.T) {
+	mapper := newCheckpointMapper(&appconfig.Config{})
+
+	_, err := mapper.parseCheckpoint([]byte(`{"Entries": []}`), nil)
+	assert.Error(t, err, "a checkpoint without Data is in an unknown format")
+
+	_, err = mapper.parseCheckpoint([]byte(`not json`), nil)
+	assert.Error(t, err)
+
+	deviceContainers, err := mapper.parseCheckpoint([]byte(`{"Data": {"PodDeviceEntries": []}}`), nil)
+	require.NoError(t, err)
+	assert.Empty(t, deviceContainers)
+}
+
+func TestReadCheckpointLimit(t *testing.T) {
+	checkpoint := filepath.Join(t.TempDir(), "kubelet_internal_checkpoint")
+	require.NoError(t, sysOS.WriteFile(checkpoint, []byte(testCheckpoint), 0o644))
+
+	_, err := readCheckpoint(checkpoint, 16)
+	assert.ErrorIs(t, err, errMappingFileOversize)
+
+	data, err := readCheckpoint(checkpoint, 0)
+	require.NoError(t, err)
+	assert.Equal(t, testCheckpoint, string(data), "no limit when 0")
+}
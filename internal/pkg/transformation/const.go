@@ -24,8 +24,58 @@ const (
 	uidAttribute       = "pod_uid"
 	vgpuAttribute      = "vgpu"
 
-	HpcJobAttribute  = "jobid"
-	HpcUserAttribute = "userid"
+	HpcJobAttribute     = "jobid"
+	HpcUserAttribute    = "userid"
+	HpcAccountAttribute = "account"
+
+	// MappingSourceAttribute records which mapper attributed the job on a metric
+	MappingSourceAttribute  = "mapping_source"
+	mappingSourceFile       = "file"
+	mappingSourceSocket     = "socket"
+	mappingSourceDatabase   = "database"
+	mappingSourceMPS        = "mps"
+	mappingSourceHTTP       = "http"
+	mappingSourceEnv        = "env"
+	mappingSourceProc       = "proc"
+	mappingSourceKubernetes = "kubernetes"
+	mappingSourceCheckpoint = "checkpoint"
+
+	// MappingFileAttribute records the base name of the mapping file a metric's job was read from
+	MappingFileAttribute = "mapping_file"
+
+	// SharingAttribute tells whether the GPU of a mapped metric is held by a single job or shared by several
+	SharingAttribute = "sharing"
+	sharingExclusive = "exclusive"
+	sharingShared    = "shared"
+
+	// CounterResetAttribute marks a counter sample lower than the sample of the previous scrape
+	CounterResetAttribute = "counter_reset"
+
+	// NUMANodeAttribute is the NUMA node of the GPU a metric belongs to
+	NUMANodeAttribute = "numa_node"
+
+	// SerialAttribute is the serial number of the GPU a metric belongs to
+	SerialAttribute = "serial"
+
+	// PowerLimitAttribute is the enforced power limit of the GPU a metric belongs to, in watts
+	PowerLimitAttribute = "power_limit_watts"
+
+	// ThrottleReasonAttribute is the comma-joined clock throttle reasons of the GPU a metric belongs to
+	ThrottleReasonAttribute = "throttle_reason"
+
+	// HealthAttribute is the worst health status, PASS, WARN or FAIL, of the GPU a metric belongs to
+	HealthAttribute = "health"
+
+	// UtilBandAttribute is the configured band of the utilization of the GPU a metric belongs to
+	UtilBandAttribute = "util_band"
+
+	// MIGIdentityAttribute marks the metrics of MIG instances whose instance doesn't resolve,
+	// which are not mapped to jobs
+	MIGIdentityAttribute  = "mig_identity"
+	migIdentityIncomplete = "incomplete"
+
+	// SourceFieldAttribute records the DCGM field an aliased series was rendered from
+	SourceFieldAttribute = "source_field"
 
 	oldPodAttribute       = "pod_name"
 	oldNamespaceAttribute = "pod_namespace"
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// counterSeries identifies the series of a counter on an entity of a group, by name as the
+// derived counters have no field id. The device tells apart the entities with the same index on
+// different parents, e.g. the links of the NVSwitches.
+type counterSeries struct {
+	device string
+	gpu    string
+	name   string
+}
+
+// counterResetMarker sets the counter reset attribute on the counter metrics whose value is lower
+// than on the previous scrape, as it happens when a GPU is reset.
+type counterResetMarker struct {
+	Config *appconfig.Config
+
+	mu sync.Mutex
+	// lastCounterValues are the counter values of the previous scrape of each entity group
+	lastCounterValues map[dcgm.Field_Entity_Group]map[counterSeries]float64
+}
+
+func newCounterResetMarker(c *appconfig.Config) *counterResetMarker {
+	slog.Info("Counter reset label is enabled")
+	return &counterResetMarker{Config: c}
+}
+
+func (p *counterResetMarker) Name() string {
+	return "counterResetMarker"
+}
+
+func (p *counterResetMarker) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	group := dcgm.FE_GPU
+	if sysInfo != nil {
+		group = sysInfo.InfoType()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lastCounterValues == nil {
+		p.lastCounterValues = map[dcgm.Field_Entity_Group]map[counterSeries]float64{}
+	}
+	// the values are replaced for the group, so the series missing from the scrape are dropped
+	last := p.lastCounterValues[group]
+	current := map[counterSeries]float64{}
+
+	for counter, values := range metrics {
+		if counter.PromType != "counter" {
+			continue
+		}
+		for i, metric := range values {
+			value, err := strconv.ParseFloat(metric.Value, 64)
+			if err != nil {
+				continue
+			}
+			// the GPU UUID is part of the key as the index of a GPU may change, e.g. after a hot reset
+			key := counterSeries{device: metric.GPUDevice, gpu: metric.GPUUUID + "/" + metric.GPU, name: counter.FieldName}
+			if metric.MigProfile != "" {
+				key.gpu += "." + metric.GPUInstanceID
+			}
+			if lastValue, seen := last[key]; seen && value < lastValue {
+				if values[i].Attributes == nil {
+					values[i].Attributes = map[string]string{}
+				}
+				values[i].Attributes[CounterResetAttribute] = "true"
+			}
+			current[key] = value
+		}
+	}
+	p.lastCounterValues[group] = current
+
+	return nil
+}
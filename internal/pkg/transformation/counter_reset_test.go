@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestCounterResetMarkerProcess(t *testing.T) {
+	energyCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION,
+		FieldName: "DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION",
+		PromType:  "counter",
+	}
+	powerCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_POWER_USAGE,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    []bool
+	}{
+		{name: "When counter resets are marked", enabled: true, want: []bool{false, false, true, false}},
+		{name: "When counter resets are not marked", enabled: false, want: []bool{false, false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformations, err := GetTransformations(&appconfig.Config{HPCCounterResetAttribute: tt.enabled})
+			require.NoError(t, err)
+
+			for scrape, value := range []string{"100", "250", "3", "40"} {
+				metrics := collector.MetricsByCounter{
+					energyCounter: {{GPU: "0", GPUUUID: "GPU-0", Value: value, Counter: energyCounter}},
+					// gauges going down are not resets
+					powerCounter: {{GPU: "0", GPUUUID: "GPU-0", Value: value, Counter: powerCounter}},
+				}
+				for _, transformation := range transformations {
+					require.NoError(t, transformation.Process(metrics, nil))
+				}
+
+				require.Len(t, metrics[energyCounter], 1)
+				if tt.want[scrape] {
+					assert.Equal(t, "true", metrics[energyCounter][0].Attributes[CounterResetAttribute], "scrape %d", scrape)
+				} else {
+					assert.NotContains(t, metrics[energyCounter][0].Attributes, CounterResetAttribute, "scrape %d", scrape)
+				}
+				assert.NotContains(t, metrics[powerCounter][0].Attributes, CounterResetAttribute)
+			}
+		})
+	}
+}
+
+func TestCounterResetMarkerProcessLinks(t *testing.T) {
+	linkCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_NVSWITCH_LINK_THROUGHPUT_TX,
+		FieldName: "DCGM_FI_DEV_NVSWITCH_LINK_THROUGHPUT_TX",
+		PromType:  "counter",
+	}
+	gpuCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION,
+		FieldName: "DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION",
+		PromType:  "counter",
+	}
+
+	ctrl := gomock.NewController(t)
+	linkInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	linkInfo.EXPECT().InfoType().Return(dcgm.FE_LINK).AnyTimes()
+	gpuInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	gpuInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+
+	marker := newCounterResetMarker(&appconfig.Config{HPCCounterResetAttribute: true})
+
+	// the links have the same index on both switches
+	scrape := func(switch0, switch1 string) []collector.Metric {
+		t.Helper()
+		metrics := collector.MetricsByCounter{linkCounter: {
+			{GPU: "0", GPUDevice: "nvswitch0", Value: switch0, Counter: linkCounter},
+			{GPU: "0", GPUDevice: "nvswitch1", Value: switch1, Counter: linkCounter},
+		}}
+		if switch1 == "" {
+			metrics[linkCounter] = metrics[linkCounter][:1]
+		}
+		require.NoError(t, marker.Process(metrics, linkInfo))
+		// the GPU group scraped in between doesn't drop the values of the links
+		require.NoError(t, marker.Process(collector.MetricsByCounter{gpuCounter: {
+			{GPU: "0", GPUUUID: "GPU-0", Value: "1", Counter: gpuCounter},
+		}}, gpuInfo))
+		return metrics[linkCounter]
+	}
+
+	reset := func(metric collector.Metric) bool {
+		return metric.Attributes[CounterResetAttribute] == "true"
+	}
+
+	links := scrape("1000", "10")
+	assert.False(t, reset(links[0]))
+	assert.False(t, reset(links[1]))
+
+	links = scrape("2000", "20")
+	assert.False(t, reset(links[0]))
+	assert.False(t, reset(links[1]), "the link of nvswitch1 is not compared with the link of nvswitch0")
+
+	links = scrape("5", "30")
+	assert.True(t, reset(links[0]))
+	assert.False(t, reset(links[1]))
+
+	// the value of the link missing from a scrape is dropped
+	scrape("10", "")
+	links = scrape("20", "1")
+	assert.False(t, reset(links[0]))
+	assert.False(t, reset(links[1]), "the link is not compared with its value before it went missing")
+}
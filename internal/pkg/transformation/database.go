@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	// registers the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+const (
+	databaseTimeout = 2 * time.Second
+	// databaseBusyTimeout is how long a query waits for a writer holding a lock on the database
+	databaseBusyTimeout = time.Second
+)
+
+// databaseMapper reads the jobs using each GPU from a SQLite database maintained by a local
+// daemon. The configured query returns (gpu_uuid, jobid, userid) rows, the userid may be NULL.
+//
+// The database is opened read-only on every refresh, so that it may be replaced or written
+// concurrently, in WAL mode or not, by the daemon.
+type databaseMapper struct {
+	keyedJobMapper
+
+	slots backendSlots
+}
+
+func newDatabaseMapper(c *appconfig.Config) *databaseMapper {
+	slog.Info(fmt.Sprintf("HPC job mapping is enabled and queries the %q database", c.HPCJobMappingDB))
+	p := &databaseMapper{slots: newBackendSlots(c.HPCJobMappingConcurrency)}
+	p.init(c)
+	return p
+}
+
+func (p *databaseMapper) Name() string {
+	return "databaseMapper"
+}
+
+func (p *databaseMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	ttl := mappingRefreshInterval(p.Config.HPCJobMappingDBTTL, p.Config.HPCJobMappingDBRefresh)
+	stale := p.gpuToJobMap == nil || now.Sub(p.fetchedAt) >= ttl
+	p.mu.Unlock()
+
+	// like the socket, the database is queried without holding the lock
+	if stale && p.slots.tryAcquire() {
+		gpuToJobMap, err := queryJobDatabase(p.Config.HPCJobMappingDB, p.Config.HPCJobMappingDBQuery)
+		p.slots.release()
+
+		p.mu.Lock()
+		if err != nil {
+			p.warnFailure(now, fmt.Sprintf("Unable to query HPC job mapping database '%s'. Ignoring.",
+				p.Config.HPCJobMappingDB), slog.String(logging.ErrorKey, err.Error()))
+			gpuToJobMap = map[string][]string{}
+		}
+		if !now.Before(p.fetchedAt) {
+			p.gpuToJobMap = gpuToJobMap
+			p.fetchedAt = now
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	gpuToJobMap := p.gpuToJobMap
+	p.mu.Unlock()
+
+	applyJobMapping(metrics, sysInfo, p.jobMapping(mappingSourceDatabase, gpuToJobMap))
+
+	return nil
+}
+
+func queryJobDatabase(dbPath, query string) (map[string][]string, error) {
+	// the driver would create a missing database, even read-only
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+
+	dsn := url.URL{
+		Scheme:   "file",
+		Path:     dbPath,
+		RawQuery: fmt.Sprintf("mode=ro&_busy_timeout=%d", databaseBusyTimeout.Milliseconds()),
+	}
+	db, err := sql.Open("sqlite3", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), databaseTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gpuToJobMap := make(map[string][]string)
+	for rows.Next() {
+		var uuid, job, user sql.NullString
+		if err := rows.Scan(&uuid, &job, &user); err != nil {
+			return nil, err
+		}
+		if uuid.String == "" || job.String == "" {
+			slog.Debug(fmt.Sprintf("HPC database mapper: skipping row without GPU or job %q %q", uuid.String, job.String))
+			continue
+		}
+		// the jobs are in the format of the mapping files: "jobid" or "jobid userid"
+		entry := job.String
+		if user.String != "" {
+			entry += " " + user.String
+		}
+		gpuToJobMap[uuid.String] = append(gpuToJobMap[uuid.String], entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug(fmt.Sprintf("GPU to job mapping: %+v", gpuToJobMap))
+
+	return gpuToJobMap, nil
+}
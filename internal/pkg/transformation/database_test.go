@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+const testJobDatabaseQuery = "SELECT gpu_uuid, jobid, userid FROM allocations"
+
+// newJobDatabase creates a WAL mode SQLite database with the allocations and keeps it open,
+// like the daemon maintaining it would.
+func newJobDatabase(t *testing.T, allocations [][3]any) (string, *sql.DB) {
+	dbPath := filepath.Join(t.TempDir(), "allocations.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("PRAGMA journal_mode=WAL")
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE allocations (gpu_uuid TEXT, jobid INTEGER, userid INTEGER)")
+	require.NoError(t, err)
+	for _, allocation := range allocations {
+		_, err = db.Exec("INSERT INTO allocations VALUES (?, ?, ?)", allocation[:]...)
+		require.NoError(t, err)
+	}
+
+	return dbPath, db
+}
+
+func TestDatabaseMapperProcess(t *testing.T) {
+	const gpu0UUID = "GPU-00000000-0000-0000-0000-000000000000"
+	const gpu1UUID = "GPU-11111111-1111-1111-1111-111111111111"
+
+	dbPath, db := newJobDatabase(t, [][3]any{
+		{gpu0UUID, 51234567, 1000},
+		{gpu0UUID, 51234568, nil},
+	})
+
+	counter := counters.Counter{
+		FieldID:   155,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, gpu0UUID, gpu1UUID) }
+
+	now := time.Now()
+	mapper := newDatabaseMapper(&appconfig.Config{
+		HPCJobMappingDB:      dbPath,
+		HPCJobMappingDBQuery: testJobDatabaseQuery,
+		HPCJobMappingDBTTL:   10 * time.Second,
+	})
+	mapper.now = func() time.Time { return now }
+
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 3)
+	assert.Equal(t, "51234567", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "1000", metrics[counter][0].Attributes[HpcUserAttribute])
+	assert.Equal(t, "database", metrics[counter][0].Attributes[MappingSourceAttribute])
+	assert.Equal(t, "51234568", metrics[counter][1].Attributes[HpcJobAttribute])
+	assert.NotContains(t, metrics[counter][1].Attributes, HpcUserAttribute)
+	assert.NotContains(t, metrics[counter][2].Attributes, HpcJobAttribute)
+
+	// the database is updated concurrently, results are cached within the TTL
+	_, err := db.Exec("INSERT INTO allocations VALUES (?, ?, ?)", gpu1UUID, 51234569, 2000)
+	require.NoError(t, err)
+
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.NotContains(t, metrics[counter][2].Attributes, HpcJobAttribute)
+
+	now = now.Add(10 * time.Second)
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	require.Len(t, metrics[counter], 3)
+	assert.Equal(t, "51234569", metrics[counter][2].Attributes[HpcJobAttribute])
+}
+
+func TestDatabaseMapperProcessWhenDatabaseIsMissing(t *testing.T) {
+	counter := counters.Counter{
+		FieldID:   155,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+	metrics := newGPUMetrics(counter, "GPU-00000000-0000-0000-0000-000000000000")
+
+	dbPath := filepath.Join(t.TempDir(), "missing.db")
+	mapper := newDatabaseMapper(&appconfig.Config{HPCJobMappingDB: dbPath, HPCJobMappingDBQuery: testJobDatabaseQuery})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 1)
+	assert.NotContains(t, metrics[counter][0].Attributes, HpcJobAttribute)
+	assert.NoFileExists(t, dbPath, "the missing database must not be created")
+}
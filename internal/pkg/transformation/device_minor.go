@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+const procDriverGPUs = "/proc/driver/nvidia/gpus"
+
+// deviceMinorMapper sets the minor number of the /dev/nvidia<minor> device of the GPU on GPU
+// metrics, as reported by the driver, since it may differ from the DCGM index of the GPU.
+type deviceMinorMapper struct {
+	Config *appconfig.Config
+
+	// driverGPUs is the procfs directory of the GPUs known to the driver
+	driverGPUs string
+
+	mu sync.Mutex
+	// minors are the device minor numbers by PCI bus id, empty when unknown
+	minors map[string]string
+}
+
+func newDeviceMinorMapper(c *appconfig.Config) *deviceMinorMapper {
+	slog.Info("Device minor number resolution is enabled")
+	return &deviceMinorMapper{
+		Config:     c,
+		driverGPUs: procDriverGPUs,
+		minors:     map[string]string{},
+	}
+}
+
+func (p *deviceMinorMapper) Name() string {
+	return "deviceMinorMapper"
+}
+
+func (p *deviceMinorMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if sysInfo != nil && sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for counter := range metrics {
+		for i, metric := range metrics[counter] {
+			busID := metric.GPUPCIBusID
+			if busID == "" {
+				busID = gpuPCIBusID(sysInfo, metric.GPU)
+			}
+			if busID == "" {
+				continue
+			}
+			minor, ok := p.minors[busID]
+			if !ok {
+				minor = readDeviceMinor(p.driverGPUs, busID)
+				p.minors[busID] = minor
+			}
+			metrics[counter][i].DeviceMinor = minor
+		}
+	}
+
+	return nil
+}
+
+// readDeviceMinor returns the "Device Minor" of the GPU information of the driver, read from the
+// procfs directory of the GPUs known to the driver, or an empty string when it can't be determined.
+func readDeviceMinor(driverGPUs, busID string) string {
+	file, err := os.Open(path.Join(driverGPUs, sysfsBusID(busID), "information"))
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Device minor mapper: unable to read the information of the %q device: %v", busID, err))
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(name) != "Device Minor" {
+			continue
+		}
+		minor, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || minor < 0 {
+			return ""
+		}
+		return strconv.Itoa(minor)
+	}
+	return ""
+}
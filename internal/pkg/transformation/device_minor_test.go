@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+func TestDeviceMinorMapperProcess(t *testing.T) {
+	driverGPUs := t.TempDir()
+	require.NoError(t, sysOS.MkdirAll(filepath.Join(driverGPUs, "0000:3b:00.0"), 0o755))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(driverGPUs, "0000:3b:00.0", "information"), []byte(`Model: 		 NVIDIA A100-SXM4-80GB
+IRQ:   		 157
+GPU UUID: 	 GPU-5e3c0b1a-0000-0000-0000-000000000000
+Video BIOS: 	 92.00.36.00.10
+Bus Type: 	 PCIe
+DMA Size: 	 47 bits
+DMA Mask: 	 0x7fffffffffff
+Bus Location: 	 0000:3b:00.0
+Device Minor: 	 2
+GPU Excluded:	 No
+`), 0o644))
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(2)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(0)).Return(deviceinfo.GPUInfo{
+		DeviceInfo: dcgm.Device{PCI: dcgm.PCIInfo{BusID: "00000000:3B:00.0"}},
+	}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(1)).Return(deviceinfo.GPUInfo{
+		DeviceInfo: dcgm.Device{PCI: dcgm.PCIInfo{BusID: "00000000:86:00.0"}},
+	}).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{GPU: "0", Value: "42", Counter: counter},
+			{GPU: "0", GPUInstanceID: "7", MigProfile: "1g.10gb", Value: "21", Counter: counter},
+			{GPU: "1", Value: "451", Counter: counter},
+		},
+	}
+
+	mapper := newDeviceMinorMapper(&appconfig.Config{EnableDeviceMinor: true})
+	mapper.driverGPUs = driverGPUs
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 3)
+	assert.Equal(t, "2", metrics[counter][0].DeviceMinor, "the device minor differs from the DCGM index")
+	assert.Equal(t, "2", metrics[counter][1].DeviceMinor, "MIG instances have the device minor of their GPU")
+	assert.Empty(t, metrics[counter][2].DeviceMinor, "the device minor of the GPU is unknown")
+}
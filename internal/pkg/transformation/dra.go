@@ -24,6 +24,7 @@ import (
 
 	resourcev1beta1 "k8s.io/api/resource/v1beta1"
 	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/kubeclient"
@@ -38,7 +39,10 @@ func NewDRAResourceSliceManager() (*DRAResourceSliceManager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error getting kube client: %w", err)
 	}
+	return newDRAResourceSliceManager(client)
+}
 
+func newDRAResourceSliceManager(client kubernetes.Interface) (*DRAResourceSliceManager, error) {
 	factory := informers.NewSharedInformerFactory(client, informerResyncPeriod)
 	informer := factory.Resource().V1beta1().ResourceSlices().Informer()
 
@@ -49,7 +53,7 @@ func NewDRAResourceSliceManager() (*DRAResourceSliceManager, error) {
 		migDevices:   make(map[string]*DRAMigDeviceInfo),
 	}
 
-	_, err = informer.AddEventHandler(&cache.FilteringResourceEventHandler{
+	_, err := informer.AddEventHandler(&cache.FilteringResourceEventHandler{
 		FilterFunc: func(obj interface{}) bool {
 			s := obj.(*resourcev1beta1.ResourceSlice)
 			return s.Spec.Driver == DRAGPUDriverName
@@ -69,16 +73,20 @@ func NewDRAResourceSliceManager() (*DRAResourceSliceManager, error) {
 	factory.Start(ctx.Done())
 
 	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
-		cancel()
+		m.Stop()
 		return nil, fmt.Errorf("ResourceSlice informer cache sync failed")
 	}
 	return m, nil
 }
 
+// Stop stops the informer and waits for its goroutines to exit
 func (m *DRAResourceSliceManager) Stop() {
 	if m.cancelContext != nil {
 		m.cancelContext()
 	}
+	if m.factory != nil {
+		m.factory.Shutdown()
+	}
 }
 
 // GetDeviceInfo returns the mapping UUID and MIG device info if applicable
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"log/slog"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// energyCounter is the series of the GPU energy integrated from the power samples. It is derived
+// from the power field, so it has no field id of its own.
+var energyCounter = counters.Counter{
+	FieldName:  "dcgm_gpu_energy_joules",
+	PromType:   "counter",
+	Help:       "Energy consumed by the GPU since the exporter first saw it, integrated from the power samples (in J).",
+	Multiplier: 1,
+	Unit:       "joules",
+}
+
+// gpuEnergy is the energy accumulated on a GPU along with its last power sample
+type gpuEnergy struct {
+	sampledAt time.Time
+	watts     float64
+	joules    float64
+}
+
+// energyAccumulator integrates the power samples of the physical GPUs over the time elapsed since
+// their previous sample and adds the accumulated energy series. The energy of a GPU missing from
+// a scrape of the power field is dropped, so it restarts from 0 when the GPU comes back.
+type energyAccumulator struct {
+	Config *appconfig.Config
+
+	now func() time.Time
+
+	mu sync.Mutex
+	// gpuEnergy is the energy accumulated on each GPU
+	gpuEnergy map[string]gpuEnergy
+}
+
+func newEnergyAccumulator(c *appconfig.Config) *energyAccumulator {
+	slog.Info("GPU energy counter is enabled")
+	return &energyAccumulator{Config: c, now: time.Now}
+}
+
+func (p *energyAccumulator) Name() string {
+	return "energyAccumulator"
+}
+
+func (p *energyAccumulator) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if sysInfo != nil && sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	var power []collector.Metric
+	for counter, values := range metrics {
+		if counter.FieldID == dcgm.DCGM_FI_DEV_POWER_USAGE {
+			power = values
+			break
+		}
+	}
+	if power == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	energy := make(map[string]gpuEnergy, len(power))
+	var energyMetrics []collector.Metric
+	for _, metric := range power {
+		watts, err := strconv.ParseFloat(metric.Value, 64)
+		if metric.MigProfile != "" || err != nil {
+			continue
+		}
+		// the GPU UUID is part of the key as the index of a GPU may change, e.g. after a hot reset
+		key := metric.GPUUUID + "/" + metric.GPU
+		if _, seen := energy[key]; seen {
+			continue
+		}
+		gpu := gpuEnergy{sampledAt: now, watts: watts}
+		if last, ok := p.gpuEnergy[key]; ok {
+			// trapezoidal integration between the two samples
+			gpu.joules = last.joules + (last.watts+watts)/2*now.Sub(last.sampledAt).Seconds()
+		}
+		energy[key] = gpu
+
+		energyMetric := metric
+		energyMetric.Counter = energyCounter
+		energyMetric.Value = strconv.FormatFloat(gpu.joules, 'f', -1, 64)
+		energyMetric.AlterValue = ""
+		energyMetric.Labels = maps.Clone(metric.Labels)
+		energyMetric.Attributes = maps.Clone(metric.Attributes)
+		energyMetrics = append(energyMetrics, energyMetric)
+	}
+	p.gpuEnergy = energy
+
+	if len(energyMetrics) > 0 {
+		metrics[energyCounter] = energyMetrics
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestEnergyAccumulatorProcess(t *testing.T) {
+	powerCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_POWER_USAGE,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	mapper := newEnergyAccumulator(&appconfig.Config{HPCEnergyCounter: true})
+	mapper.now = func() time.Time { return now }
+
+	scrape := func(powers map[string]string) map[string]string {
+		t.Helper()
+		metrics := collector.MetricsByCounter{powerCounter: {}}
+		for gpu, power := range powers {
+			metrics[powerCounter] = append(metrics[powerCounter], collector.Metric{
+				GPU: gpu, GPUUUID: "GPU-" + gpu, Value: power, Counter: powerCounter,
+			})
+		}
+		require.NoError(t, mapper.Process(metrics, nil))
+
+		energy := map[string]string{}
+		for _, metric := range metrics[energyCounter] {
+			energy[metric.GPU] = metric.Value
+		}
+		return energy
+	}
+
+	assert.Equal(t, map[string]string{"0": "0", "1": "0"}, scrape(map[string]string{"0": "100", "1": "50"}))
+
+	now = start.Add(10 * time.Second)
+	assert.Equal(t, map[string]string{"0": "1500", "1": "500"}, scrape(map[string]string{"0": "200", "1": "50"}))
+
+	// the energy of GPU 1 is dropped while it is missing
+	now = start.Add(20 * time.Second)
+	assert.Equal(t, map[string]string{"0": "3500"}, scrape(map[string]string{"0": "200"}))
+
+	now = start.Add(30 * time.Second)
+	assert.Equal(t, map[string]string{"0": "5500", "1": "0"}, scrape(map[string]string{"0": "200", "1": "50"}))
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// envMapper attributes every GPU the exporter sees to the job named by environment variables, for
+// an exporter running in the container of a job, which only sees the GPUs of the job. The
+// variables are read on each scrape; while the job variable is unset the metrics are not mapped.
+type envMapper struct {
+	jobMapperBase
+}
+
+func newEnvMapper(c *appconfig.Config) *envMapper {
+	slog.Info(fmt.Sprintf("Environment job mapping is enabled and reads the job from the %q variable",
+		c.HPCJobEnvVar))
+	p := &envMapper{}
+	p.init(c)
+	return p
+}
+
+func (p *envMapper) Name() string {
+	return "envMapper"
+}
+
+func (p *envMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	mapping := p.jobMapping(mappingSourceEnv, nil)
+	job, err := p.envJob()
+	if err != nil {
+		p.mu.Lock()
+		p.warnFailure(p.now(), fmt.Sprintf("Unable to read the job from the environment: %v. Ignoring.", err))
+		p.mu.Unlock()
+	} else {
+		mapping.nodeJobs = []string{job}
+	}
+	applyJobMapping(metrics, sysInfo, mapping)
+
+	if err != nil || p.Config.HPCAccountEnvVar == "" {
+		return nil
+	}
+	account := strings.TrimSpace(os.Getenv(p.Config.HPCAccountEnvVar))
+	if account == "" {
+		return nil
+	}
+	for _, values := range metrics {
+		for _, metric := range values {
+			// the attributes of the mapped metrics are copies of their own
+			if metric.Attributes[MappingSourceAttribute] == mappingSourceEnv && metric.Attributes[HpcJobAttribute] != "" {
+				metric.Attributes[HpcAccountAttribute] = account
+			}
+		}
+	}
+	return nil
+}
+
+// envJob returns the job of the environment in the format of the mapping files: "jobid" or
+// "jobid userid".
+func (p *envMapper) envJob() (string, error) {
+	job := strings.TrimSpace(os.Getenv(p.Config.HPCJobEnvVar))
+	if job == "" {
+		return "", fmt.Errorf("the %s variable is not set", p.Config.HPCJobEnvVar)
+	}
+	if strings.ContainsFunc(job, unicode.IsSpace) {
+		return "", fmt.Errorf("the %s variable contains spaces", p.Config.HPCJobEnvVar)
+	}
+	if p.Config.HPCUserEnvVar == "" {
+		return job, nil
+	}
+	user := strings.TrimSpace(os.Getenv(p.Config.HPCUserEnvVar))
+	if user == "" {
+		return job, nil
+	}
+	if strings.ContainsFunc(user, unicode.IsSpace) {
+		return "", fmt.Errorf("the %s variable contains spaces", p.Config.HPCUserEnvVar)
+	}
+	return job + " " + user, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestEnvMapperProcess(t *testing.T) {
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, "GPU-0", "GPU-1") }
+
+	mapper := newEnvMapper(&appconfig.Config{
+		HPCJobEnvVar:     "TEST_JOB_ID",
+		HPCUserEnvVar:    "TEST_JOB_UID",
+		HPCAccountEnvVar: "TEST_JOB_ACCOUNT",
+	})
+
+	t.Setenv("TEST_JOB_ID", "1234")
+	t.Setenv("TEST_JOB_UID", "1000")
+	t.Setenv("TEST_JOB_ACCOUNT", "physics")
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	for _, metric := range metrics[counter] {
+		assert.Equal(t, map[string]string{
+			HpcJobAttribute:        "1234",
+			HpcUserAttribute:       "1000",
+			HpcAccountAttribute:    "physics",
+			MappingSourceAttribute: "env",
+		}, metric.Attributes, metric.GPU)
+	}
+
+	t.Setenv("TEST_JOB_UID", "")
+	t.Setenv("TEST_JOB_ACCOUNT", "")
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	for _, metric := range metrics[counter] {
+		assert.Equal(t, map[string]string{HpcJobAttribute: "1234", MappingSourceAttribute: "env"}, metric.Attributes, metric.GPU)
+	}
+
+	// the metrics are left unmapped while the job variable is unset
+	t.Setenv("TEST_JOB_ID", "")
+	t.Setenv("TEST_JOB_ACCOUNT", "physics")
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	for _, metric := range metrics[counter] {
+		assert.Empty(t, metric.Attributes, metric.GPU)
+	}
+}
+
+func TestEnvMapperReload(t *testing.T) {
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	config := &appconfig.Config{HPCJobEnvVar: "TEST_JOB_ID", HPCJobPlaceholder: "none"}
+	mapper := newEnvMapper(config)
+
+	t.Setenv("TEST_JOB_ID", "")
+	metrics := newGPUMetrics(counter, "GPU-0")
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, map[string]string{HpcJobAttribute: "none"}, metrics[counter][0].Attributes)
+
+	reloaded := *config
+	reloaded.HPCJobPlaceholder = "idle"
+	reloaded.HPCSharingAttribute = true
+	mapper.Reload(&reloaded)
+
+	metrics = newGPUMetrics(counter, "GPU-0")
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, "idle", metrics[counter][0].Attributes[HpcJobAttribute], "the reloaded placeholder is set")
+
+	t.Setenv("TEST_JOB_ID", "1234")
+	metrics = newGPUMetrics(counter, "GPU-0")
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, map[string]string{
+		HpcJobAttribute:        "1234",
+		MappingSourceAttribute: "env",
+		SharingAttribute:       "exclusive",
+	}, metrics[counter][0].Attributes, "the reloaded sharing attribute is set")
+}
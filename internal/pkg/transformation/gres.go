@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// gresIndexPrefix precedes the GPU indices of a job in the Slurm GRES and TRES strings,
+// e.g. gpu:a100:2(IDX:0-1)
+const gresIndexPrefix = "IDX:"
+
+// maxGRESIndex bounds the GPU indices of a GRES string, well above the GPU count of any node, so
+// that a malformed range such as 0-2000000000 is rejected rather than expanded
+const maxGRESIndex = 1023
+
+// parseGRESIndices returns the GPU indices of a Slurm GRES string, e.g. 0, 1 and 3 for
+// gpu:a100:3(IDX:0-1,3). A string may hold several GRES, e.g. of different GPU models, and
+// strings without indices such as gres/gpu=2, or with indices above maxGRESIndex, are an error.
+func parseGRESIndices(gres string) ([]int, error) {
+	var indices []int
+	rest := gres
+	for {
+		start := strings.Index(rest, gresIndexPrefix)
+		if start < 0 {
+			break
+		}
+		rest = rest[start+len(gresIndexPrefix):]
+		list := rest
+		if end := strings.IndexByte(rest, ')'); end >= 0 {
+			list, rest = rest[:end], rest[end+1:]
+		} else {
+			rest = ""
+		}
+		for _, item := range strings.Split(list, ",") {
+			first, last, isRange := strings.Cut(item, "-")
+			from, err := strconv.Atoi(strings.TrimSpace(first))
+			if err != nil || from < 0 || from > maxGRESIndex {
+				return nil, fmt.Errorf("invalid GPU index %q in GRES %q", item, gres)
+			}
+			to := from
+			if isRange {
+				to, err = strconv.Atoi(strings.TrimSpace(last))
+				if err != nil || to < from || to > maxGRESIndex {
+					return nil, fmt.Errorf("invalid GPU index range %q in GRES %q", item, gres)
+				}
+			}
+			for i := from; i <= to; i++ {
+				indices = append(indices, i)
+			}
+		}
+	}
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("no GPU index in GRES %q", gres)
+	}
+	return indices, nil
+}
+
+// gresJobMap returns the jobs of each GPU index from the lines of a GRES mapping file, in the
+// format "<jobid> [<userid>] <GRES>". The lines without GPU indices are skipped.
+func gresJobMap(lines []string) map[string][]string {
+	gpuToJobMap := map[string][]string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			slog.Debug(fmt.Sprintf("HPC mapper: skipping malformed GRES mapping line %q", line))
+			continue
+		}
+		indices, err := parseGRESIndices(fields[len(fields)-1])
+		if err != nil {
+			slog.Debug(fmt.Sprintf("HPC mapper: skipping GRES mapping line %q: %v", line, err))
+			continue
+		}
+		// the jobs are in the format of the mapping files: "jobid" or "jobid userid"
+		job := strings.Join(fields[:len(fields)-1], " ")
+		for _, index := range indices {
+			gpu := strconv.Itoa(index)
+			gpuToJobMap[gpu] = append(gpuToJobMap[gpu], job)
+		}
+	}
+	return gpuToJobMap
+}
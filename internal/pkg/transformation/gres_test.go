@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestParseGRESIndices(t *testing.T) {
+	tests := []struct {
+		name    string
+		gres    string
+		want    []int
+		wantErr bool
+	}{
+		{name: "single index", gres: "gpu:a100:1(IDX:3)", want: []int{3}},
+		{name: "range", gres: "gpu:a100:2(IDX:0-1)", want: []int{0, 1}},
+		{name: "list", gres: "gpu:a100:3(IDX:0-1,3)", want: []int{0, 1, 3}},
+		{name: "list of ranges", gres: "gpu:4(IDX:0-1,4-5)", want: []int{0, 1, 4, 5}},
+		{name: "several GRES", gres: "gpu:a100:1(IDX:0),gpu:v100:2(IDX:2-3)", want: []int{0, 2, 3}},
+		{name: "TRES without indices", gres: "gres/gpu=2", wantErr: true},
+		{name: "invalid index", gres: "gpu:1(IDX:a)", wantErr: true},
+		{name: "reversed range", gres: "gpu:2(IDX:3-1)", wantErr: true},
+		{name: "range above the maximum index", gres: "gpu:2(IDX:0-2000000000)", wantErr: true},
+		{name: "index above the maximum index", gres: "gpu:1(IDX:1024)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGRESIndices(tt.gres)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHPCProcessGRESFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "_gres"), []byte(
+		"51234567 1000 gpu:a100:2(IDX:0-1)\n"+
+			"51234568 gpu:a100:1(IDX:3)\n"+
+			"51234569 gres/gpu=1\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{counter: {}}
+	for _, gpu := range []string{"0", "1", "2", "3"} {
+		metrics[counter] = append(metrics[counter], collector.Metric{
+			GPU: gpu, GPUUUID: uuid.New().String(), Value: "42", Counter: counter, Attributes: map[string]string{},
+		})
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCJobMappingGRESFile: "_gres"})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	jobs := map[string]string{}
+	for _, metric := range metrics[counter] {
+		jobs[metric.GPU] = metric.Attributes[HpcJobAttribute] + "/" + metric.Attributes[HpcUserAttribute]
+	}
+	assert.Equal(t, map[string]string{
+		"0": "51234567/1000",
+		"1": "51234567/1000",
+		"2": "/",
+		"3": "51234568/",
+	}, jobs)
+}
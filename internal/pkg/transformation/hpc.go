@@ -18,12 +18,23 @@ package transformation
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	sysOS "os"
 	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
@@ -34,21 +45,78 @@ import (
 )
 
 type hpcMapper struct {
-	Config *appconfig.Config
+	// configMu is held by Process for reading, so that Reload waits for the calls in flight
+	configMu sync.RWMutex
+	Config   *appconfig.Config
+
+	now       func() time.Time
+	formatter collector.ValueFormatter
+	resolver  MappingKeyResolver
+
+	mu sync.Mutex
+	// lastJobMap is the job mapping read from the files on the previous scrape
+	lastJobMap map[string][]string
+	// removedJobs records when jobs disappeared from the mapping files, while they linger
+	removedJobs map[gpuJob]time.Time
+	// manifestJobMap is the job mapping read from the files of the manifestGeneration manifest
+	manifestGeneration string
+	manifestJobMap     map[string][]string
+	// jobGPUSeconds is the time accumulated by each job on each GPU as of jobSecondsAt, when enabled
+	jobGPUSeconds map[jobGPU]float64
+	jobSecondsAt  time.Time
+
+	devices deviceReadiness
+	// conflicts counts the GPUs claimed by several mapping files, once per scrape
+	conflicts atomic.Uint64
+	// oversize counts the mapping files skipped for exceeding the maximum size, once per scrape
+	oversize atomic.Uint64
+	// coverage is the mapping coverage of the active GPUs on the last scrape of the GPU group
+	coverage atomic.Pointer[MappingCoverage]
+
+	freshnessMu sync.Mutex
+	// newestFile is the modification time of the newest mapping file read on the last scrape
+	newestFile time.Time
+	fileCount  int
+	// recentFiles are the most recently modified mapping files read on the last scrape, when enabled
+	recentFiles []string
+}
+
+// errMappingFileOversize is returned when a mapping file exceeds the maximum size
+var errMappingFileOversize = errors.New("mapping file exceeds the maximum size")
+
+type gpuJob struct {
+	gpu string
+	job string
 }
 
 func newHPCMapper(c *appconfig.Config) *hpcMapper {
 	slog.Info(fmt.Sprintf("HPC job mapping is enabled and watch for the %q directory", c.HPCJobMappingDir))
 	return &hpcMapper{
-		Config: c,
+		Config:      c,
+		now:         time.Now,
+		formatter:   collector.DefaultValueFormatter{},
+		removedJobs: map[gpuJob]time.Time{},
 	}
 }
 
+// SetValueFormatter sets the formatter of the alternate values; it must be set before Process is called.
+func (p *hpcMapper) SetValueFormatter(formatter collector.ValueFormatter) {
+	p.formatter = formatter
+}
+
+// SetMappingKeyResolver sets the resolver of the mapping keys; it must be set before Process is called.
+func (p *hpcMapper) SetMappingKeyResolver(resolver MappingKeyResolver) {
+	p.resolver = resolver
+}
+
 func (p *hpcMapper) Name() string {
 	return "hpcMapper"
 }
 
 func (p *hpcMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+
 	_, err := os.Stat(p.Config.HPCJobMappingDir)
 	if err != nil {
 		slog.Error(fmt.Sprintf("Unable to access HPC job mapping file directory '%s' - directory not found. Ignoring.",
@@ -56,22 +124,200 @@ func (p *hpcMapper) Process(metrics collector.MetricsByCounter, sysInfo devicein
 		return nil
 	}
 
-	gpuFiles, err := getGPUFiles(p.Config.HPCJobMappingDir)
+	gpuFiles, newestFile, err := getGPUFiles(p.Config.HPCJobMappingDir, p.isMappingFile)
+	if err != nil {
+		return err
+	}
+
+	// the files are read for each entity group, the oversize ones are counted once per scrape, with
+	// the GPU group
+	oversize := 0
+	var gpuToJobMap map[string][]string
+	if manifest := p.Config.HPCJobMappingManifest; manifest != "" && slices.Contains(gpuFiles, manifest) {
+		gpuToJobMap, gpuFiles, err = p.readManifestJobMap(gpuFiles, &oversize)
+	} else {
+		gpuToJobMap, err = p.readJobMap(gpuFiles, &oversize)
+	}
+	if sysInfo == nil || sysInfo.InfoType() == dcgm.FE_GPU {
+		p.oversize.Add(uint64(oversize))
+	}
 	if err != nil {
 		return err
 	}
 
+	p.freshnessMu.Lock()
+	p.newestFile, p.fileCount = newestFile, len(gpuFiles)
+	p.freshnessMu.Unlock()
+
+	// every mapping file is stat'ed to list the recent ones, once per scrape, with the GPU group
+	if n := p.Config.HPCMappingFilesInfo; n > 0 && (sysInfo == nil || sysInfo.InfoType() == dcgm.FE_GPU) {
+		recentFiles := recentMappingFiles(p.Config.HPCJobMappingDir, gpuFiles, n)
+		p.freshnessMu.Lock()
+		p.recentFiles = recentFiles
+		p.freshnessMu.Unlock()
+	}
+
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	if gresFile := p.Config.HPCJobMappingGRESFile; gresFile != "" {
+		if lines, ok := gpuToJobMap[gresFile]; ok {
+			delete(gpuToJobMap, gresFile)
+			for gpu, jobs := range gresJobMap(lines) {
+				gpuToJobMap[gpu] = append(gpuToJobMap[gpu], jobs...)
+			}
+		}
+	}
+
+	// the job mapping before the lingering jobs are added and the node file is taken out of it
+	var current map[string][]string
+	if p.Config.HPCJobGPUSeconds {
+		current = maps.Clone(gpuToJobMap)
+	}
+
+	if p.Config.HPCMappingLingerDuration > 0 {
+		gpuToJobMap = p.withLingeringJobs(gpuToJobMap)
+	}
+
+	slog.Debug(fmt.Sprintf("GPU to job mapping: %+v", gpuToJobMap))
+
+	mapping := jobMapping{
+		gpuJobs:     gpuToJobMap,
+		source:      mappingSourceFile,
+		placeholder: p.Config.HPCJobPlaceholder,
+		formatter:   p.formatter,
+		resolver:    p.resolver,
+	}
+	if nodeFile := p.Config.HPCJobMappingNodeFile; nodeFile != "" {
+		mapping.nodeJobs = gpuToJobMap[nodeFile]
+		mapping.nodeKey = nodeFile
+		delete(gpuToJobMap, nodeFile)
+	}
+	mapping.fileAttribute = p.Config.HPCMappingFileAttribute
+	mapping.sharingAttribute = p.Config.HPCSharingAttribute
+	mapping.incompleteMIG = p.Config.HPCIncompleteMIGMode
+
+	conflicts := applyJobMapping(metrics, sysInfo, mapping)
+	for gpu, files := range conflicts {
+		slog.Debug(fmt.Sprintf("HPC mapper: GPU %s is claimed by several mapping files: %v", gpu, files))
+	}
+	// like the oversize files, the conflicts are counted once per scrape, with the GPU group
+	if sysInfo == nil || sysInfo.InfoType() == dcgm.FE_GPU {
+		p.conflicts.Add(uint64(len(conflicts)))
+	}
+
+	if p.Config.HPCJobGPUSeconds && (sysInfo == nil || sysInfo.InfoType() == dcgm.FE_GPU) {
+		p.accumulateJobGPUSeconds(metrics, sysInfo, current)
+	}
+
+	if sysInfo != nil && sysInfo.InfoType() == dcgm.FE_GPU {
+		coverage := mappingCoverageOf(metrics, p.Config.HPCJobPlaceholder)
+		p.coverage.Store(&coverage)
+	}
+
+	return nil
+}
+
+// Close drops the cached job mappings and job GPU seconds; the mapper is not used afterwards.
+func (p *hpcMapper) Close() error {
+	p.resetCaches()
+	return nil
+}
+
+// Reload applies the mapping settings of the configuration, e.g. a new mapping directory, and
+// drops the cached job mappings. The Process calls in flight complete with the previous settings.
+func (p *hpcMapper) Reload(c *appconfig.Config) {
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+
+	slog.Info(fmt.Sprintf("HPC job mapping is reloaded and watch for the %q directory", c.HPCJobMappingDir))
+	p.Config = c
+	p.resetCaches()
+}
+
+func (p *hpcMapper) resetCaches() {
+	p.mu.Lock()
+	p.lastJobMap = nil
+	p.removedJobs = map[gpuJob]time.Time{}
+	p.manifestGeneration, p.manifestJobMap = "", nil
+	p.jobGPUSeconds, p.jobSecondsAt = nil, time.Time{}
+	p.mu.Unlock()
+
+	p.freshnessMu.Lock()
+	p.newestFile, p.fileCount, p.recentFiles = time.Time{}, 0, nil
+	p.freshnessMu.Unlock()
+
+	p.coverage.Store(nil)
+}
+
+// MappingConflicts returns the number of times a GPU was claimed by several mapping files
+// on a scrape, since the exporter started.
+func (p *hpcMapper) MappingConflicts() uint64 {
+	return p.conflicts.Load()
+}
+
+// MappingCoverage returns the mapping coverage of the active GPUs on the last scrape.
+func (p *hpcMapper) MappingCoverage() MappingCoverage {
+	if coverage := p.coverage.Load(); coverage != nil {
+		return *coverage
+	}
+	return MappingCoverage{}
+}
+
+// mappingCoverageOf counts the physical GPUs with a non-zero utilization among the mapped
+// metrics, and those of them with a job other than the placeholder.
+func mappingCoverageOf(metrics collector.MetricsByCounter, placeholder string) MappingCoverage {
+	var coverage MappingCoverage
+	active := map[string]bool{}
+	for counter, values := range metrics {
+		if counter.FieldID != dcgm.DCGM_FI_DEV_GPU_UTIL {
+			continue
+		}
+		for _, metric := range values {
+			if metric.MigProfile != "" {
+				continue
+			}
+			if util, err := strconv.ParseFloat(metric.Value, 64); err != nil || util <= 0 {
+				continue
+			}
+			coverage.Hostname = metric.Hostname
+			job := metric.Attributes[HpcJobAttribute]
+			// a GPU of several jobs has a metric per job
+			active[metric.GPU] = active[metric.GPU] || (job != "" && job != placeholder)
+		}
+	}
+	coverage.Active = len(active)
+	for _, mapped := range active {
+		if mapped {
+			coverage.Mapped++
+		}
+	}
+	return coverage
+}
+
+// MappingOversize returns the number of times a mapping file was skipped for exceeding the
+// maximum size, since the exporter started.
+func (p *hpcMapper) MappingOversize() uint64 {
+	return p.oversize.Load()
+}
+
+// readJobMap reads the jobs of the mapping files, skipping and counting in oversize the files
+// exceeding the maximum size
+func (p *hpcMapper) readJobMap(gpuFiles []string, oversize *int) (map[string][]string, error) {
 	gpuToJobMap := make(map[string][]string)
-	// used to find GPU UUIDs from GPU and GPUInstanceID, either GPU-* or MIG-*
-	gpuUUIDs := make(map[string]string)
 
 	slog.Debug(fmt.Sprintf("HPC job mapping files: %#v", gpuFiles))
 
 	for _, gpuFileName := range gpuFiles {
-		jobs, err := readFile(path.Join(p.Config.HPCJobMappingDir, gpuFileName))
+		jobs, err := p.readMappingFile(gpuFileName, oversize)
+		if errors.Is(err, errMappingFileOversize) {
+			continue
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
+		jobs = decodeMappingLines(gpuFileName, jobs, p.Config.HPCJobMappingEncoding)
 
 		if _, exist := gpuToJobMap[gpuFileName]; !exist {
 			gpuToJobMap[gpuFileName] = []string{}
@@ -79,24 +325,183 @@ func (p *hpcMapper) Process(metrics collector.MetricsByCounter, sysInfo devicein
 		gpuToJobMap[gpuFileName] = append(gpuToJobMap[gpuFileName], jobs...)
 	}
 
-	slog.Debug(fmt.Sprintf("GPU to job mapping: %+v", gpuToJobMap))
+	return gpuToJobMap, nil
+}
+
+// readManifestJobMap reads the mapping files listed in the manifest and returns their jobs along
+// with the files. The first line of the manifest is its generation and the following lines are the
+// mapping files. The files are only read again when the generation changes, so the scheduler
+// can rewrite them and update the manifest last for the new mapping to apply all at once.
+func (p *hpcMapper) readManifestJobMap(gpuFiles []string, oversize *int) (map[string][]string, []string, error) {
+	manifest := p.Config.HPCJobMappingManifest
+	lines, err := p.readMappingFile(manifest, oversize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil, fmt.Errorf("HPC job mapping manifest %q has no generation", manifest)
+	}
+
+	generation := strings.TrimSpace(lines[0])
+	var listed []string
+	for _, line := range lines[1:] {
+		name := strings.TrimSpace(line)
+		if name == "" || name == manifest {
+			continue
+		}
+		if !slices.Contains(gpuFiles, name) {
+			slog.Warn(fmt.Sprintf("HPC job mapping file %q listed in the manifest is missing", name))
+			continue
+		}
+		listed = append(listed, name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.manifestJobMap == nil || generation != p.manifestGeneration {
+		jobMap, err := p.readJobMap(listed, oversize)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.manifestGeneration, p.manifestJobMap = generation, jobMap
+	}
+
+	// the jobs are changed by the lingering jobs and the node file, so the cached ones are copied
+	gpuToJobMap := make(map[string][]string, len(p.manifestJobMap))
+	for gpu, jobs := range p.manifestJobMap {
+		gpuToJobMap[gpu] = slices.Clone(jobs)
+	}
+
+	return gpuToJobMap, listed, nil
+}
+
+// MappingFreshness returns the modification time of the newest mapping file and the number of
+// mapping files read on the last scrape.
+func (p *hpcMapper) MappingFreshness() (time.Time, int) {
+	p.freshnessMu.Lock()
+	defer p.freshnessMu.Unlock()
+	return p.newestFile, p.fileCount
+}
+
+// MappingFiles returns the number of mapping files read on the last scrape and the names of the
+// most recently modified of them, up to the configured number.
+func (p *hpcMapper) MappingFiles() (int, []string) {
+	p.freshnessMu.Lock()
+	defer p.freshnessMu.Unlock()
+	return p.fileCount, slices.Clone(p.recentFiles)
+}
+
+// recentMappingFiles returns the names of the n most recently modified mapping files of the
+// directory, the most recent first. The files that can't be stat'ed anymore are left out.
+func recentMappingFiles(dirPath string, names []string, n int) []string {
+	modTimes := make(map[string]time.Time, len(names))
+	for _, name := range names {
+		finfo, err := os.Stat(path.Join(dirPath, name))
+		if err != nil {
+			continue
+		}
+		modTimes[name] = finfo.ModTime()
+	}
+
+	recent := slices.Collect(maps.Keys(modTimes))
+	slices.SortFunc(recent, func(a, b string) int {
+		if c := modTimes[b].Compare(modTimes[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return recent[:min(n, len(recent))]
+}
+
+// withLingeringJobs adds the jobs removed from the mapping files within the linger duration
+// to the job mapping, so that the final samples of a job are still attributed to it.
+func (p *hpcMapper) withLingeringJobs(gpuToJobMap map[string][]string) map[string][]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+
+	for gpu, jobs := range p.lastJobMap {
+		for _, job := range jobs {
+			key := gpuJob{gpu: gpu, job: job}
+			if _, removed := p.removedJobs[key]; !removed && !slices.Contains(gpuToJobMap[gpu], job) {
+				p.removedJobs[key] = now
+			}
+		}
+	}
+
+	p.lastJobMap = make(map[string][]string, len(gpuToJobMap))
+	for gpu, jobs := range gpuToJobMap {
+		p.lastJobMap[gpu] = slices.Clone(jobs)
+	}
+
+	for key, removedAt := range p.removedJobs {
+		if slices.Contains(gpuToJobMap[key.gpu], key.job) || now.Sub(removedAt) >= p.Config.HPCMappingLingerDuration {
+			delete(p.removedJobs, key)
+			continue
+		}
+		gpuToJobMap[key.gpu] = append(gpuToJobMap[key.gpu], key.job)
+	}
+
+	return gpuToJobMap
+}
+
+// jobMapping is the mapping of GPUs to jobs applied by applyJobMapping
+type jobMapping struct {
+	// gpuJobs are the jobs of each GPU, keyed as the resolver resolves them
+	gpuJobs map[string][]string
+	// resolver resolves the keys of the metrics in gpuJobs, DefaultMappingKeyResolver if nil
+	resolver MappingKeyResolver
+	// nodeJobs are the jobs of GPUs without jobs of their own, if any
+	nodeJobs []string
+	// nodeKey is the key the node jobs were read from
+	nodeKey string
+	// fileAttribute adds the base name of the key the jobs were found under as the mapping file attribute
+	fileAttribute bool
+	// sharingAttribute adds whether the GPU is exclusive to a job or shared as the sharing attribute
+	sharingAttribute bool
+	// incompleteMIG is the IncompleteMIGMode of the MIG instances whose instance doesn't resolve
+	incompleteMIG string
+	// source is the value of the mapping source attribute
+	source string
+	// placeholder is the job attribute of GPUs without any job, if not empty
+	placeholder string
+	// formatter formats the alternate values, if set
+	formatter collector.ValueFormatter
+	// skipMapped leaves the metrics already mapped by another mapper, those with a mapping source, as they are
+	skipMapped bool
+}
+
+// applyJobMapping sets the alternate value and UUID of every metric and expands the metrics of
+// GPUs with jobs into one metric per job. A GPU found under several keys is expanded for the jobs
+// of all of them, and the keys are returned by GPU as conflicts.
+func applyJobMapping(
+	metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider, mapping jobMapping,
+) map[string][]string {
+	conflicts := map[string][]string{}
+	// used to find GPU UUIDs from GPU and GPUInstanceID, either GPU-* or MIG-*
+	gpuUUIDs := make(map[string]string)
+	// switch, link and CPU metrics don't belong to the node's job and don't get the placeholder either
+	nodeJobs, placeholder := mapping.nodeJobs, mapping.placeholder
+	resolver := mapping.resolver
+	if resolver == nil {
+		resolver = DefaultMappingKeyResolver{}
+	}
+	if sysInfo != nil && sysInfo.InfoType() != dcgm.FE_GPU {
+		nodeJobs, placeholder = nil, ""
+	}
 
 	for counter := range metrics {
 		var modifiedMetrics []collector.Metric
 		for _, metric := range metrics[counter] {
-			var jobs []string
-			var exists bool
-
-			if metric.Counter.Multiplier != 1 {
-				if strings.Contains(metric.Value, ".") {
-					newval, _ := strconv.ParseFloat(metric.Value, 64)
-					metric.AlterValue = fmt.Sprintf("%f", newval*float64(metric.Counter.Multiplier))
-				} else {
-					newval, _ := strconv.Atoi(metric.Value)
-					metric.AlterValue = fmt.Sprintf("%d", newval*metric.Counter.Multiplier)
-				}
-			} else {
-				metric.AlterValue = metric.Value
+			if mapping.skipMapped && metric.Attributes[MappingSourceAttribute] != "" {
+				modifiedMetrics = append(modifiedMetrics, metric)
+				continue
+			}
+			metric.AlterValue = transformValue(metric.Value, metric.Counter)
+			if mapping.formatter != nil {
+				metric.AlterValue = mapping.formatter.FormatValue(metric.Counter, metric.AlterValue)
 			}
 			// either just gpuid (say 2) or if MIG gpuid.gpuinstanceid (say 2.11)
 			var gpuID string
@@ -105,20 +510,49 @@ func (p *hpcMapper) Process(metrics collector.MetricsByCounter, sysInfo devicein
 			} else {
 				gpuID = metric.GPU
 			}
-			// for convenience populate UUIDs
-			if _, ok := gpuUUIDs[gpuID]; !ok {
+			// for convenience populate UUIDs, the GPU UUID is part of the key as the index
+			// of a GPU may change, e.g. after a hot reset
+			uuidKey := metric.GPUUUID + "/" + gpuID
+			if _, ok := gpuUUIDs[uuidKey]; !ok {
 				if metric.MigProfile != "" {
-					gpuUUIDs[gpuID] = FindMIGUUID(sysInfo, metric.GPU, metric.GPUInstanceID)
+					gpuUUIDs[uuidKey] = migUUIDOf(sysInfo, metric)
 				} else {
-					gpuUUIDs[gpuID] = metric.GPUUUID
+					gpuUUIDs[uuidKey] = metric.GPUUUID
 				}
 			}
-			metric.AlterUUID = gpuUUIDs[gpuID]
-			if jobs, exists = gpuToJobMap[gpuUUIDs[gpuID]]; !exists {
-				jobs, exists = gpuToJobMap[gpuID]
+			metric.AlterUUID = gpuUUIDs[uuidKey]
+			// the keys of a MIG instance whose instance doesn't resolve, e.g. right after a MIG
+			// reconfiguration, would be malformed, e.g. "2.", so it is mapped as its GPU or not at all
+			keyMetric, unmapped := metric, false
+			if metric.MigProfile != "" && metric.AlterUUID == "" {
+				metric.AlterUUID = metric.GPUUUID
+				keyMetric = metric
+				keyMetric.MigProfile, keyMetric.GPUInstanceID = "", ""
+				gpuID = metric.GPU
+				unmapped = mapping.incompleteMIG == appconfig.IncompleteMIGModeMark
+			}
+			var keys []string
+			if unmapped {
+				metric.Attributes = maps.Clone(metric.Attributes)
+				if metric.Attributes == nil {
+					metric.Attributes = map[string]string{}
+				}
+				metric.Attributes[MIGIdentityAttribute] = migIdentityIncomplete
+			} else {
+				keys = findKeys(mapping.gpuJobs, resolver.MappingKeys(keyMetric, sysInfo)...)
 			}
-			if exists && len(jobs) != 0 {
-				for _, job := range jobs {
+			if len(keys) > 1 {
+				conflicts[gpuID] = keys
+			}
+			jobs := jobsOf(mapping.gpuJobs, keys)
+			if len(keys) == 0 && !unmapped {
+				for _, job := range nodeJobs {
+					jobs = append(jobs, keyedJob{job: job, key: mapping.nodeKey})
+				}
+			}
+			if len(jobs) != 0 {
+				for _, keyedJob := range jobs {
+					job := keyedJob.job
 					modifiedMetric, err := utils.DeepCopy(metric)
 					if err != nil {
 						slog.Error(fmt.Sprintf("Can not create deepCopy for the value: %v", metric),
@@ -136,16 +570,149 @@ func (p *hpcMapper) Process(metrics collector.MetricsByCounter, sysInfo devicein
 					} else {
 						modifiedMetric.Attributes[HpcJobAttribute] = job
 					}
+					modifiedMetric.Attributes[MappingSourceAttribute] = mapping.source
+					if mapping.fileAttribute {
+						modifiedMetric.Attributes[MappingFileAttribute] = path.Base(keyedJob.key)
+					}
+					if mapping.sharingAttribute {
+						modifiedMetric.Attributes[SharingAttribute] = sharingExclusive
+						if len(jobs) > 1 {
+							modifiedMetric.Attributes[SharingAttribute] = sharingShared
+						}
+					}
 					modifiedMetrics = append(modifiedMetrics, modifiedMetric)
 				}
 			} else {
+				if placeholder != "" {
+					if metric.Attributes == nil {
+						metric.Attributes = map[string]string{}
+					}
+					metric.Attributes[HpcJobAttribute] = placeholder
+				}
 				modifiedMetrics = append(modifiedMetrics, metric)
 			}
 		}
 		metrics[counter] = modifiedMetrics
 	}
 
-	return nil
+	return conflicts
+}
+
+// DefaultMappingKeyResolver keys the mappings by the names a mapping file of the metric's GPU may
+// have, in order of precedence: the GPU or MIG UUID, the PCI bus id, the GPU index (or
+// index.instance for MIG) and the serial number. The PCI bus id and the serial number identify the
+// physical GPU and are not used for MIG instances.
+type DefaultMappingKeyResolver struct{}
+
+func (DefaultMappingKeyResolver) MappingKeys(metric collector.Metric, sysInfo deviceinfo.Provider) []string {
+	if metric.MigProfile != "" {
+		return []string{metric.AlterUUID, metric.GPU + "." + metric.GPUInstanceID}
+	}
+	return []string{metric.AlterUUID, metric.GPUPCIBusID, metric.GPU, gpuSerial(sysInfo, metric.GPU)}
+}
+
+// findKeys returns the keys found in the mapping, in the order of the keys
+func findKeys(gpuToJobMap map[string][]string, keys ...string) []string {
+	var found []string
+	for _, key := range keys {
+		if key == "" || slices.Contains(found, key) {
+			continue
+		}
+		if _, exists := gpuToJobMap[key]; exists {
+			found = append(found, key)
+		}
+	}
+	return found
+}
+
+// keyedJob is a job along with the key of the mapping it was found under
+type keyedJob struct {
+	job string
+	key string
+}
+
+// jobsOf returns the jobs found under the keys, a job found under several keys only once
+func jobsOf(gpuToJobMap map[string][]string, keys []string) []keyedJob {
+	var jobs []keyedJob
+	for _, key := range keys {
+		for _, job := range gpuToJobMap[key] {
+			if !slices.ContainsFunc(jobs, func(j keyedJob) bool { return j.job == job }) {
+				jobs = append(jobs, keyedJob{job: job, key: key})
+			}
+		}
+	}
+	return jobs
+}
+
+func gpuSerial(sysInfo deviceinfo.Provider, gpu string) string {
+	if sysInfo == nil {
+		return ""
+	}
+	gpuID, err := strconv.ParseUint(gpu, 10, 32)
+	if err != nil || uint(gpuID) >= sysInfo.GPUCount() {
+		return ""
+	}
+	return sysInfo.GPU(uint(gpuID)).DeviceInfo.Identifiers.Serial
+}
+
+// deviceReadiness tracks whether the GPU device info provider was ready on the previous GPU
+// scrape, so that the mappers log once while waiting for it. The other entity groups, which
+// are always ready, leave it untouched.
+type deviceReadiness struct {
+	mu      sync.Mutex
+	waiting bool
+}
+
+// ready returns false while the GPU device info provider does not have any GPU yet, as it may
+// happen right after startup, in which case the metrics are left unmapped for the scrape.
+func (r *deviceReadiness) ready(sysInfo deviceinfo.Provider, mapper string) bool {
+	if sysInfo == nil || sysInfo.InfoType() != dcgm.FE_GPU {
+		return true
+	}
+	notReady := sysInfo.GPUCount() == 0
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if notReady && !r.waiting {
+		slog.Info(fmt.Sprintf("%s: no GPU devices are known yet, the metrics are not mapped to jobs until they are", mapper))
+	} else if !notReady && r.waiting {
+		slog.Info(fmt.Sprintf("%s: GPU devices are known, mapping the metrics to jobs", mapper))
+	}
+	r.waiting = notReady
+
+	return !notReady
+}
+
+// migUUIDOf returns the UUID of the MIG instance of the metric. The instance is looked up on the
+// GPU with the metric's GPU UUID, so it is found even when the GPU index of the metric is stale,
+// and by the GPU index only when no GPU has that UUID. The UUID is empty when the identity of the
+// instance is incomplete: its instance id is missing or it doesn't resolve to an instance.
+func migUUIDOf(sysInfo deviceinfo.Provider, metric collector.Metric) string {
+	if sysInfo == nil {
+		return ""
+	}
+	instanceID, err := strconv.ParseUint(metric.GPUInstanceID, 10, 32)
+	if err != nil {
+		return ""
+	}
+	if metric.GPUUUID != "" {
+		for _, gpu := range sysInfo.GPUs() {
+			if gpu.DeviceInfo.UUID != metric.GPUUUID {
+				continue
+			}
+			for _, instance := range gpu.GPUInstances {
+				if instance.Info.NvmlInstanceId == uint(instanceID) {
+					return instance.UUID
+				}
+			}
+		}
+	}
+	// FindMIGUUID exits on GPU indexes out of range
+	if gpu, err := strconv.ParseUint(metric.GPU, 10, 32); err != nil || uint(gpu) >= sysInfo.GPUCount() {
+		return ""
+	}
+	return FindMIGUUID(sysInfo, metric.GPU, metric.GPUInstanceID)
 }
 
 func FindMIGUUID(sysInfo deviceinfo.Provider, gpu string, instanceId string) string {
@@ -172,7 +739,23 @@ func FindMIGUUID(sysInfo deviceinfo.Provider, gpu string, instanceId string) str
 	return ""
 }
 
-func readFile(path string) ([]string, error) {
+// readMappingFile reads a file of the mapping directory, warning about and counting in oversize
+// the files exceeding the maximum size
+func (p *hpcMapper) readMappingFile(name string, oversize *int) ([]string, error) {
+	lines, err := readFile(path.Join(p.Config.HPCJobMappingDir, name), p.Config.HPCMaxMappingFileBytes)
+	if errors.Is(err, errMappingFileOversize) {
+		slog.Warn(fmt.Sprintf("HPC job mapping file %q is skipped", name), slog.String(logging.ErrorKey, err.Error()))
+		*oversize++
+	}
+	return lines, err
+}
+
+// maxLineBytes bounds the lines of the files read without a size limit
+const maxLineBytes = 1 << 20
+
+// readFile reads the lines of the file, which must not exceed maxBytes unless it is 0. Without a
+// limit the file is streamed, so only its lines are held in memory, each up to maxLineBytes.
+func readFile(path string, maxBytes int64) ([]string, error) {
 	var jobs []string
 
 	file, err := os.Open(path)
@@ -187,6 +770,16 @@ func readFile(path string) ([]string, error) {
 		}
 	}(file)
 
+	reader, lineBytes := io.Reader(file), maxLineBytes
+	if maxBytes > 0 {
+		data, err := readLimited(file, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		// a line may be as long as the file
+		reader, lineBytes = bytes.NewReader(data), len(data)+1
+	}
+
 	// Example of the expected file format:
 	// job1
 	// job2
@@ -194,7 +787,8 @@ func readFile(path string) ([]string, error) {
 	// or
 	// jobid1 uid1
 	// jobid2 uid2
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, max(lineBytes, bufio.MaxScanTokenSize))
 	for scanner.Scan() {
 		jobs = append(jobs, scanner.Text())
 	}
@@ -206,17 +800,60 @@ func readFile(path string) ([]string, error) {
 	return jobs, nil
 }
 
-func getGPUFiles(dirPath string) ([]string, error) {
-	files, err := os.ReadDir(dirPath)
+// readLimited reads the whole file, which must not exceed maxBytes
+func readLimited(file *sysOS.File, maxBytes int64) ([]byte, error) {
+	finfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if finfo.Size() > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes over %d", errMappingFileOversize, finfo.Size(), maxBytes)
+	}
+	// the file may have grown since
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
 	if err != nil {
 		return nil, err
 	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", errMappingFileOversize, maxBytes)
+	}
+	return data, nil
+}
+
+// isMappingFile tells whether the file of the mapping directory is read, i.e. whether its name
+// matches the mapping file pattern or it is one of the node, GRES and manifest files.
+func (p *hpcMapper) isMappingFile(name string) bool {
+	if p.Config.HPCMappingFilePattern == "" {
+		return true
+	}
+	for _, special := range []string{
+		p.Config.HPCJobMappingNodeFile, p.Config.HPCJobMappingGRESFile, p.Config.HPCJobMappingManifest,
+	} {
+		if special != "" && name == special {
+			return true
+		}
+	}
+	matched, err := filepath.Match(p.Config.HPCMappingFilePattern, name)
+	return err == nil && matched
+}
+
+// getGPUFiles returns the names of the mapping files in the directory and the modification time of the newest one
+func getGPUFiles(dirPath string, isMappingFile func(name string) bool) ([]string, time.Time, error) {
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 
 	slog.Debug(fmt.Sprintf("hpc mapper: %d files in the %q found", len(files), dirPath))
 
 	var mappingFiles []string
+	var newest time.Time
 
 	for _, file := range files {
+		if !isMappingFile(file.Name()) {
+			continue // Skip the bookkeeping files of the directory, e.g. locks or logs
+		}
+
 		finfo, err := file.Info()
 		if err != nil {
 			slog.Warn(fmt.Sprintf("HPC mapper: can not get file info for the %s file.", file.Name()))
@@ -228,8 +865,26 @@ func getGPUFiles(dirPath string) ([]string, error) {
 			continue // Skip directories
 		}
 
+		if finfo.Mode()&sysOS.ModeSymlink != 0 {
+			// Follow symlinks so a link to a regular mapping file is still read
+			finfo, err = os.Stat(path.Join(dirPath, file.Name()))
+			if err != nil {
+				slog.Debug(fmt.Sprintf("HPC mapper: the %q file is a dangling symlink", file.Name()))
+				continue
+			}
+		}
+
+		// Opening a FIFO or a device may block or fail, so only regular files are read
+		if !finfo.Mode().IsRegular() {
+			slog.Debug(fmt.Sprintf("HPC mapper: the %q file is not a regular file", file.Name()))
+			continue
+		}
+
 		mappingFiles = append(mappingFiles, file.Name())
+		if finfo.ModTime().After(newest) {
+			newest = finfo.ModTime()
+		}
 	}
 
-	return mappingFiles, nil
+	return mappingFiles, newest, nil
 }
@@ -16,23 +16,35 @@
 package transformation
 
 import (
+	"bufio"
+	"bytes"
 	"cmp"
 	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
+	sysOS "os"
+	"path/filepath"
 	"reflect"
 	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
 	mockos "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/os"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
 	osinterface "github.com/NVIDIA/dcgm-exporter/internal/pkg/os"
 )
 
@@ -54,6 +66,8 @@ func TestHPCProcess(t *testing.T) {
 				mOS := mockos.NewMockOS(ctrl)
 				mFileInfoGPU0 := mockos.NewMockFileInfo(ctrl)
 				mFileInfoGPU0.EXPECT().IsDir().Return(false).AnyTimes()
+				mFileInfoGPU0.EXPECT().ModTime().Return(time.Time{}).AnyTimes()
+				mFileInfoGPU0.EXPECT().Mode().Return(fs.FileMode(0o644)).AnyTimes()
 
 				mDirEntryGPU0 := mockos.NewMockDirEntry(ctrl)
 				mDirEntryGPU0.EXPECT().Info().Return(mFileInfoGPU0, nil).AnyTimes()
@@ -61,6 +75,8 @@ func TestHPCProcess(t *testing.T) {
 
 				mFileInfoGPU1 := mockos.NewMockFileInfo(ctrl)
 				mFileInfoGPU1.EXPECT().IsDir().Return(false).AnyTimes()
+				mFileInfoGPU1.EXPECT().ModTime().Return(time.Time{}).AnyTimes()
+				mFileInfoGPU1.EXPECT().Mode().Return(fs.FileMode(0o644)).AnyTimes()
 
 				mDirEntryGPU1 := mockos.NewMockDirEntry(ctrl)
 				mDirEntryGPU1.EXPECT().Info().Return(mFileInfoGPU1, nil).AnyTimes()
@@ -205,3 +221,824 @@ func TestHPCProcess(t *testing.T) {
 func TestHPCName(t *testing.T) {
 	assert.Equal(t, "hpcMapper", newHPCMapper(&appconfig.Config{}).Name())
 }
+
+func TestHPCProcessMappingSource(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job1 1000\n"), 0o644))
+
+	counter := counters.Counter{
+		FieldID:   155,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+	metrics := newGPUMetrics(counter, uuid.New().String(), uuid.New().String())
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "file", metrics[counter][0].Attributes[MappingSourceAttribute])
+	assert.NotContains(t, metrics[counter][1].Attributes, MappingSourceAttribute)
+}
+
+func TestHPCProcessLinger(t *testing.T) {
+	dir := t.TempDir()
+	jobFile := filepath.Join(dir, "0")
+	require.NoError(t, sysOS.WriteFile(jobFile, []byte("job1 1000\n"), 0o644))
+
+	counter := counters.Counter{
+		FieldID:   155,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+	gpuUUID := uuid.New().String()
+	jobsOf := func(mapper *hpcMapper) []string {
+		metrics := newGPUMetrics(counter, gpuUUID)
+		require.NoError(t, mapper.Process(metrics, nil))
+		var jobs []string
+		for _, metric := range metrics[counter] {
+			if job, ok := metric.Attributes[HpcJobAttribute]; ok {
+				jobs = append(jobs, job)
+			}
+		}
+		return jobs
+	}
+
+	now := time.Now()
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCMappingLingerDuration: 30 * time.Second})
+	mapper.now = func() time.Time { return now }
+
+	assert.Equal(t, []string{"job1"}, jobsOf(mapper))
+
+	require.NoError(t, sysOS.Remove(jobFile))
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, []string{"job1"}, jobsOf(mapper), "the removed mapping lingers")
+
+	now = now.Add(29 * time.Second)
+	assert.Equal(t, []string{"job1"}, jobsOf(mapper), "the removed mapping lingers")
+
+	now = now.Add(time.Second)
+	assert.Empty(t, jobsOf(mapper), "the removed mapping is dropped after the linger duration")
+
+	now = now.Add(time.Second)
+	assert.Empty(t, jobsOf(mapper))
+}
+
+func TestHPCProcessWithoutLinger(t *testing.T) {
+	dir := t.TempDir()
+	jobFile := filepath.Join(dir, "0")
+	require.NoError(t, sysOS.WriteFile(jobFile, []byte("job1\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, uuid.New().String()) }
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+
+	require.NoError(t, sysOS.Remove(jobFile))
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.NotContains(t, metrics[counter][0].Attributes, HpcJobAttribute)
+}
+
+func TestHPCProcessJobPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job1\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter {
+		return newGPUMetrics(counter, uuid.New().String(), uuid.New().String())
+	}
+
+	tests := []struct {
+		name        string
+		placeholder string
+		assertion   func(*testing.T, collector.Metric)
+	}{
+		{
+			name:        "When the placeholder is not configured",
+			placeholder: "",
+			assertion: func(t *testing.T, unmapped collector.Metric) {
+				assert.NotContains(t, unmapped.Attributes, HpcJobAttribute)
+			},
+		},
+		{
+			name:        "When the placeholder is configured",
+			placeholder: "none",
+			assertion: func(t *testing.T, unmapped collector.Metric) {
+				assert.Equal(t, "none", unmapped.Attributes[HpcJobAttribute])
+				assert.NotContains(t, unmapped.Attributes, MappingSourceAttribute)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newMetrics()
+			mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCJobPlaceholder: tt.placeholder})
+			require.NoError(t, mapper.Process(metrics, nil))
+
+			require.Len(t, metrics[counter], 2)
+			assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+			tt.assertion(t, metrics[counter][1])
+		})
+	}
+}
+
+func TestHPCProcessSerialMappingFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1652920012345"), []byte("job1 1000\n"), 0o644))
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(2)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(0)).Return(deviceinfo.GPUInfo{
+		DeviceInfo: dcgm.Device{Identifiers: dcgm.DeviceIdentifiers{Serial: "1652920012345"}},
+	}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(1)).Return(deviceinfo.GPUInfo{
+		DeviceInfo: dcgm.Device{Identifiers: dcgm.DeviceIdentifiers{Serial: "1652920067890"}},
+	}).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, uuid.New().String(), uuid.New().String())
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "1000", metrics[counter][0].Attributes[HpcUserAttribute])
+	assert.NotContains(t, metrics[counter][1].Attributes, HpcJobAttribute)
+}
+
+func TestMappingKeysPrecedence(t *testing.T) {
+	gpuToJobMap := map[string][]string{
+		"GPU-00000000-0000-0000-0000-000000000000": {"by-uuid"},
+		"00000000:3B:00.0":                         {"by-pci-bus-id"},
+		"0":                                        {"by-index"},
+	}
+	metric := collector.Metric{
+		GPU: "0", GPUUUID: "GPU-00000000-0000-0000-0000-000000000000", AlterUUID: "GPU-00000000-0000-0000-0000-000000000000",
+		GPUPCIBusID: "00000000:3B:00.0",
+	}
+
+	keys := findKeys(gpuToJobMap, DefaultMappingKeyResolver{}.MappingKeys(metric, nil)...)
+	assert.Equal(t, []string{metric.GPUUUID, metric.GPUPCIBusID, "0"}, keys)
+	assert.Equal(t, "by-uuid", jobsOf(gpuToJobMap, keys)[0].job, "the jobs are in the order of the keys")
+
+	metric.Attributes = map[string]string{}
+	metrics := collector.MetricsByCounter{{FieldName: "DCGM_FI_DEV_POWER_USAGE"}: {metric}}
+	conflicts := applyJobMapping(metrics, nil, jobMapping{gpuJobs: gpuToJobMap})
+	assert.Equal(t, map[string][]string{"0": keys}, conflicts, "the keys of a GPU claimed several times are reported")
+	for _, mapped := range metrics {
+		require.Len(t, mapped, 3, "the metric is expanded for the jobs of every key")
+		var jobs []string
+		for _, metric := range mapped {
+			jobs = append(jobs, metric.Attributes[HpcJobAttribute])
+		}
+		assert.Equal(t, []string{"by-uuid", "by-pci-bus-id", "by-index"}, jobs)
+	}
+
+	delete(gpuToJobMap, metric.GPUUUID)
+	keys = findKeys(gpuToJobMap, DefaultMappingKeyResolver{}.MappingKeys(metric, nil)...)
+	assert.Equal(t, []string{metric.GPUPCIBusID, "0"}, keys)
+}
+
+// busIDResolver prefers the PCI bus id over the UUID
+type busIDResolver struct{}
+
+func (busIDResolver) MappingKeys(metric collector.Metric, _ deviceinfo.Provider) []string {
+	return []string{metric.GPUPCIBusID, metric.AlterUUID}
+}
+
+func TestHPCProcessMappingKeyResolver(t *testing.T) {
+	const gpuUUID = "GPU-00000000-0000-0000-0000-000000000000"
+	const busID = "00000000:3B:00.0"
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, gpuUUID), []byte("by-uuid\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, busID), []byte("by-pci-bus-id\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("by-index\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter {
+		return collector.MetricsByCounter{
+			counter: {{GPU: "0", GPUUUID: gpuUUID, GPUPCIBusID: busID, Value: "42", Counter: counter, Attributes: map[string]string{}}},
+		}
+	}
+
+	metrics := newMetrics()
+	require.NoError(t, newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir}).Process(metrics, nil))
+	require.Len(t, metrics[counter], 3, "the jobs of every key apply")
+	assert.Equal(t, "by-uuid", metrics[counter][0].Attributes[HpcJobAttribute], "the UUID comes first by default")
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	mapper.SetMappingKeyResolver(busIDResolver{})
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	require.Len(t, metrics[counter], 2, "only the keys of the resolver apply")
+	assert.Equal(t, "by-pci-bus-id", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "by-uuid", metrics[counter][1].Attributes[HpcJobAttribute])
+}
+
+func TestHPCProcessNodeDefault(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "_node"), []byte("node-job 1000\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("gpu-job 2000\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, uuid.New().String(), uuid.New().String())
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCJobMappingNodeFile: "_node"})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "node-job", metrics[counter][0].Attributes[HpcJobAttribute], "GPUs without a file get the node default")
+	assert.Equal(t, "1000", metrics[counter][0].Attributes[HpcUserAttribute])
+	assert.Equal(t, "gpu-job", metrics[counter][1].Attributes[HpcJobAttribute], "the GPU file takes precedence")
+	assert.Equal(t, "2000", metrics[counter][1].Attributes[HpcUserAttribute])
+}
+
+func TestHPCProcessMappingFileAttribute(t *testing.T) {
+	dir := t.TempDir()
+	gpuUUID := "GPU-00000000-0000-0000-0000-000000000000"
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "_node"), []byte("node-job\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, gpuUUID), []byte("gpu-job\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, gpuUUID, uuid.New().String()) }
+
+	config := &appconfig.Config{HPCJobMappingDir: dir, HPCJobMappingNodeFile: "_node"}
+	metrics := newMetrics()
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	assert.NotContains(t, metrics[counter][0].Attributes, MappingFileAttribute, "the attribute is off by default")
+
+	config.HPCMappingFileAttribute = true
+	metrics = newMetrics()
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "gpu-job", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, gpuUUID, metrics[counter][0].Attributes[MappingFileAttribute])
+	assert.Equal(t, "node-job", metrics[counter][1].Attributes[HpcJobAttribute])
+	assert.Equal(t, "_node", metrics[counter][1].Attributes[MappingFileAttribute])
+}
+
+func TestHPCProcessSharingAttribute(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job1\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("job2 1000\njob3 1001\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter {
+		return newGPUMetrics(counter, uuid.New().String(), uuid.New().String(), uuid.New().String())
+	}
+
+	config := &appconfig.Config{HPCJobMappingDir: dir}
+	metrics := newMetrics()
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.Len(t, metrics[counter], 4)
+	assert.NotContains(t, metrics[counter][0].Attributes, SharingAttribute, "the attribute is off by default")
+
+	config.HPCSharingAttribute = true
+	metrics = newMetrics()
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.Len(t, metrics[counter], 4)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "exclusive", metrics[counter][0].Attributes[SharingAttribute])
+	assert.Equal(t, "job2", metrics[counter][1].Attributes[HpcJobAttribute])
+	assert.Equal(t, "shared", metrics[counter][1].Attributes[SharingAttribute])
+	assert.Equal(t, "job3", metrics[counter][2].Attributes[HpcJobAttribute])
+	assert.Equal(t, "shared", metrics[counter][2].Attributes[SharingAttribute])
+	assert.Equal(t, "2", metrics[counter][3].GPU)
+	assert.NotContains(t, metrics[counter][3].Attributes, SharingAttribute, "unmapped GPUs are not labeled")
+}
+
+func TestHPCProcessMappingFilePattern(t *testing.T) {
+	const gpu0UUID = "GPU-00000000-0000-0000-0000-000000000000"
+	const gpu1UUID = "GPU-11111111-1111-1111-1111-111111111111"
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, gpu0UUID), []byte("job1\n"), 0o644))
+	// bookkeeping files named like a GPU index
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("lock\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "_node"), []byte("node-job\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, gpu0UUID, gpu1UUID) }
+
+	config := &appconfig.Config{HPCJobMappingDir: dir}
+	metrics := newMetrics()
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "lock", metrics[counter][1].Attributes[HpcJobAttribute], "every file is read by default")
+
+	config.HPCMappingFilePattern = "GPU-*"
+	metrics = newMetrics()
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.NotContains(t, metrics[counter][1].Attributes, HpcJobAttribute, "the files not matching the pattern are ignored")
+
+	config.HPCJobMappingNodeFile = "_node"
+	metrics = newMetrics()
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "node-job", metrics[counter][1].Attributes[HpcJobAttribute], "the node file is read whatever the pattern")
+}
+
+func TestHPCProcessSkipsNonRegularFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, syscall.Mkfifo(filepath.Join(dir, "0"), 0o644))
+	target := filepath.Join(t.TempDir(), "job")
+	require.NoError(t, sysOS.WriteFile(target, []byte("gpu-job\n"), 0o644))
+	require.NoError(t, sysOS.Symlink(target, filepath.Join(dir, "1")))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, uuid.New().String(), uuid.New().String())
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	done := make(chan error, 1)
+	go func() { done <- mapper.Process(metrics, nil) }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Process blocked on the FIFO in the mapping directory")
+	}
+
+	require.Len(t, metrics[counter], 2)
+	assert.NotContains(t, metrics[counter][0].Attributes, HpcJobAttribute, "the FIFO must not be read")
+	assert.Equal(t, "gpu-job", metrics[counter][1].Attributes[HpcJobAttribute], "symlinks to regular files are followed")
+}
+
+func TestHPCProcessMIGUUIDWithStaleGPUIndex(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "MIG-aaaaaaaa-0000-0000-0000-000000000000"), []byte("job-a\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "MIG-bbbbbbbb-0000-0000-0000-000000000000"), []byte("job-b\n"), 0o644))
+
+	// after a hot reset the GPU the mapping file refers to is GPU 0, but its metrics still carry index 1
+	gpus := []deviceinfo.GPUInfo{
+		{
+			DeviceInfo: dcgm.Device{GPU: 0, UUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000"},
+			GPUInstances: []deviceinfo.GPUInstanceInfo{
+				{Info: dcgm.MigEntityInfo{NvmlInstanceId: 1}, UUID: "MIG-aaaaaaaa-0000-0000-0000-000000000000"},
+			},
+		},
+		{
+			DeviceInfo: dcgm.Device{GPU: 1, UUID: "GPU-bbbbbbbb-0000-0000-0000-000000000000"},
+			GPUInstances: []deviceinfo.GPUInstanceInfo{
+				{Info: dcgm.MigEntityInfo{NvmlInstanceId: 1}, UUID: "MIG-bbbbbbbb-0000-0000-0000-000000000000"},
+			},
+		},
+	}
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(len(gpus))).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUs().Return(gpus).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{
+				GPU: "1", GPUUUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000", GPUInstanceID: "1", MigProfile: "1g.10gb",
+				Value: "42", Counter: counter, Attributes: map[string]string{},
+			},
+		},
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 1)
+	assert.Equal(t, "MIG-aaaaaaaa-0000-0000-0000-000000000000", metrics[counter][0].AlterUUID)
+	assert.Equal(t, "job-a", metrics[counter][0].Attributes[HpcJobAttribute],
+		"the MIG instance is resolved through the GPU UUID, not the stale index")
+}
+
+// recordingResolver records the keys resolved by the default resolver
+type recordingResolver struct {
+	keys []string
+}
+
+func (r *recordingResolver) MappingKeys(metric collector.Metric, sysInfo deviceinfo.Provider) []string {
+	keys := DefaultMappingKeyResolver{}.MappingKeys(metric, sysInfo)
+	r.keys = append(r.keys, keys...)
+	return keys
+}
+
+func TestHPCProcessIncompleteMIGIdentity(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("gpu-job\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0."), []byte("malformed-job\n"), 0o644))
+
+	gpus := []deviceinfo.GPUInfo{{
+		DeviceInfo: dcgm.Device{GPU: 0, UUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000"},
+		GPUInstances: []deviceinfo.GPUInstanceInfo{
+			{Info: dcgm.MigEntityInfo{NvmlInstanceId: 1}, UUID: "MIG-aaaaaaaa-0000-0000-0000-000000000000"},
+		},
+	}}
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(len(gpus))).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUs().Return(gpus).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(0)).Return(gpus[0]).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter {
+		return collector.MetricsByCounter{
+			counter: {
+				// the instance id is missing, or the instance is gone after a MIG reconfiguration
+				{
+					GPU: "0", GPUUUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000", MigProfile: "1g.10gb",
+					Value: "42", Counter: counter, Attributes: map[string]string{},
+				},
+				{
+					GPU: "0", GPUUUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000", GPUInstanceID: "9", MigProfile: "1g.10gb",
+					Value: "43", Counter: counter, Attributes: map[string]string{},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		mode         string
+		wantJob      string
+		wantIdentity string
+	}{
+		{mode: "", wantJob: "gpu-job"},
+		{mode: appconfig.IncompleteMIGModeParent, wantJob: "gpu-job"},
+		{mode: appconfig.IncompleteMIGModeMark, wantJob: "none", wantIdentity: "incomplete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			resolver := &recordingResolver{}
+			mapper := newHPCMapper(&appconfig.Config{
+				HPCJobMappingDir: dir, HPCJobPlaceholder: "none", HPCIncompleteMIGMode: tt.mode,
+			})
+			mapper.SetMappingKeyResolver(resolver)
+			metrics := newMetrics()
+			require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+			require.Len(t, metrics[counter], 2)
+			for _, metric := range metrics[counter] {
+				assert.Equal(t, "GPU-aaaaaaaa-0000-0000-0000-000000000000", metric.AlterUUID, "no empty MIG UUID")
+				assert.Equal(t, tt.wantJob, metric.Attributes[HpcJobAttribute])
+				assert.Equal(t, tt.wantIdentity, metric.Attributes[MIGIdentityAttribute])
+				assert.Equal(t, "1g.10gb", metric.MigProfile, "the series stay those of MIG instances")
+			}
+			for _, key := range resolver.keys {
+				assert.NotRegexp(t, `\.$|\.9$`, key, "no malformed key")
+			}
+		})
+	}
+}
+
+func TestHPCProcessProviderNotReady(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("gpu-job\n"), 0o644))
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(0)).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{
+				GPU: "0", GPUUUID: uuid.New().String(), GPUInstanceID: "1", MigProfile: "1g.10gb",
+				Value: "42", Counter: counter, Attributes: map[string]string{},
+			},
+		},
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCJobPlaceholder: "none"})
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 1)
+	assert.Empty(t, metrics[counter][0].Attributes, "metrics pass through unmapped")
+	assert.Empty(t, metrics[counter][0].AlterUUID)
+}
+
+// oneDecimalFormatter rounds values to one decimal
+type oneDecimalFormatter struct{}
+
+func (oneDecimalFormatter) FormatValue(_ counters.Counter, value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
+
+func TestHPCProcessValueFormatter(t *testing.T) {
+	dir := t.TempDir()
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Multiplier: 1000}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{GPU: "0", GPUUUID: uuid.New().String(), Value: "41.987654", Counter: counter, Attributes: map[string]string{}},
+		},
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	mapper.SetValueFormatter(oneDecimalFormatter{})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 1)
+	assert.Equal(t, "41987.7", metrics[counter][0].AlterValue)
+	assert.Equal(t, "41.987654", metrics[counter][0].Value, "the value is formatted by the renderer")
+}
+
+func TestHPCProcessRoundedAlterValue(t *testing.T) {
+	dir := t.TempDir()
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge", Multiplier: 1000}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{GPU: "0", GPUUUID: uuid.New().String(), Value: "41.987654", Counter: counter, Attributes: map[string]string{}},
+		},
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	mapper.SetValueFormatter(collector.NewRoundingValueFormatter([]string{"DCGM_FI_DEV_POWER_USAGE"}))
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 1)
+	assert.Equal(t, "41988", metrics[counter][0].AlterValue, "the value is rounded after the multiplier")
+}
+
+func TestHPCProcessManifest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, sysOS.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("0", "job-0\n")
+	write("1", "job-1\n")
+	write("manifest", "7\n0\n")
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, "GPU-0", "GPU-1") }
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCJobMappingManifest: "manifest"})
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job-0", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.NotContains(t, metrics[counter][1].Attributes, HpcJobAttribute, "files not in the manifest are ignored")
+	_, fileCount := mapper.MappingFreshness()
+	assert.Equal(t, 1, fileCount)
+
+	// files rewritten before the manifest is updated are not read yet
+	write("0", "job-0-next\n")
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, "job-0", metrics[counter][0].Attributes[HpcJobAttribute])
+
+	write("manifest", "8\n0\n1\n")
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, "job-0-next", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "job-1", metrics[counter][1].Attributes[HpcJobAttribute])
+
+	// without a manifest all the files are read
+	require.NoError(t, sysOS.Remove(filepath.Join(dir, "manifest")))
+	write("1", "job-1-next\n")
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, "job-1-next", metrics[counter][1].Attributes[HpcJobAttribute])
+}
+
+func TestHPCProcessMappingConflicts(t *testing.T) {
+	dir := t.TempDir()
+	gpuUUID := "GPU-00000000-0000-0000-0000-000000000000"
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job-a\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, gpuUUID), []byte("job-b\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("job-c\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, gpuUUID, uuid.New().String()) }
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	var jobs []string
+	for _, metric := range metrics[counter] {
+		jobs = append(jobs, metric.GPU+":"+metric.Attributes[HpcJobAttribute])
+	}
+	assert.Equal(t, []string{"0:job-b", "0:job-a", "1:job-c"}, jobs, "GPU 0 is expanded for the jobs of both files")
+	assert.Equal(t, uint64(1), mapper.MappingConflicts())
+
+	require.NoError(t, mapper.Process(newMetrics(), nil))
+	assert.Equal(t, uint64(2), mapper.MappingConflicts(), "conflicts are counted on every scrape")
+
+	ctrl := gomock.NewController(t)
+	mockSwitchInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockSwitchInfo.EXPECT().InfoType().Return(dcgm.FE_SWITCH).AnyTimes()
+	mockSwitchInfo.EXPECT().GPUCount().Return(uint(0)).AnyTimes()
+	require.NoError(t, mapper.Process(newMetrics(), mockSwitchInfo))
+	assert.Equal(t, uint64(2), mapper.MappingConflicts(), "the conflicts are counted once per scrape, with the GPU group")
+}
+
+func TestHPCProcessMappingFiles(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour)
+	for i, name := range []string{"1", "0", "2"} {
+		require.NoError(t, sysOS.WriteFile(filepath.Join(dir, name), []byte("job-"+name+"\n"), 0o644))
+		require.NoError(t, sysOS.Chtimes(filepath.Join(dir, name), modTime, modTime.Add(time.Duration(i)*time.Minute)))
+	}
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		counter: {{GPU: "0", Value: "42", Counter: counter, Attributes: map[string]string{}}},
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCMappingFilesInfo: 2})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	count, recent := mapper.MappingFiles()
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []string{"2", "0"}, recent, "the most recently modified files, up to the configured number")
+
+	require.NoError(t, sysOS.Chtimes(filepath.Join(dir, "1"), modTime, modTime.Add(time.Hour)))
+	ctrl := gomock.NewController(t)
+	mockSwitchInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockSwitchInfo.EXPECT().InfoType().Return(dcgm.FE_SWITCH).AnyTimes()
+	mockSwitchInfo.EXPECT().GPUCount().Return(uint(0)).AnyTimes()
+	require.NoError(t, mapper.Process(metrics, mockSwitchInfo))
+	_, recent = mapper.MappingFiles()
+	assert.Equal(t, []string{"2", "0"}, recent, "the files are listed once per scrape, with the GPU group")
+	require.NoError(t, mapper.Process(metrics, nil))
+	_, recent = mapper.MappingFiles()
+	assert.Equal(t, []string{"1", "2"}, recent)
+
+	mapper = newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	require.NoError(t, mapper.Process(metrics, nil))
+	count, recent = mapper.MappingFiles()
+	assert.Equal(t, 3, count)
+	assert.Empty(t, recent, "the files are not listed by default")
+}
+
+func TestHPCProcessOversizeMappingFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte(strings.Repeat("x", 1024)), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("job-b\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, uuid.New().String(), uuid.New().String())
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCMaxMappingFileBytes: 512})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	jobs := map[string]string{}
+	for _, metric := range metrics[counter] {
+		jobs[metric.GPU] = metric.Attributes[HpcJobAttribute]
+	}
+	assert.Equal(t, map[string]string{"0": "", "1": "job-b"}, jobs, "the oversize file is skipped")
+	assert.Equal(t, uint64(1), mapper.MappingOversize())
+
+	ctrl := gomock.NewController(t)
+	mockSwitchInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockSwitchInfo.EXPECT().InfoType().Return(dcgm.FE_SWITCH).AnyTimes()
+	require.NoError(t, mapper.Process(collector.MetricsByCounter{}, mockSwitchInfo))
+	assert.Equal(t, uint64(1), mapper.MappingOversize(), "the files are counted once per scrape, with the GPU group")
+
+	mapper = newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, "x", metrics[counter][0].Attributes[HpcJobAttribute][:1], "without a limit every file is read")
+	assert.Zero(t, mapper.MappingOversize())
+}
+
+func TestHPCMappingCoverage(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job-a\n"), 0o644))
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(3)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).Return(deviceinfo.GPUInfo{}).AnyTimes()
+
+	counter := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_GPU_UTIL, FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{GPU: "0", GPUUUID: uuid.New().String(), Value: "87", Hostname: "node1", Counter: counter, Attributes: map[string]string{}},
+			{GPU: "1", GPUUUID: uuid.New().String(), Value: "35", Hostname: "node1", Counter: counter, Attributes: map[string]string{}},
+			{GPU: "2", GPUUUID: uuid.New().String(), Value: "0", Hostname: "node1", Counter: counter, Attributes: map[string]string{}},
+		},
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCJobPlaceholder: "idle"})
+	assert.Equal(t, MappingCoverage{}, mapper.MappingCoverage())
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+	assert.Equal(t, MappingCoverage{Hostname: "node1", Active: 2, Mapped: 1}, mapper.MappingCoverage(),
+		"the idle GPU 2 is not counted and the placeholder of GPU 1 is not a mapping")
+}
+
+func TestReadFileLongLine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "0")
+	line := strings.Repeat("x", 2*bufio.MaxScanTokenSize)
+	require.NoError(t, sysOS.WriteFile(file, []byte(line+"\njob-b\n"), 0o644))
+
+	jobs, err := readFile(file, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{line, "job-b"}, jobs)
+}
+
+func TestHPCMapperClose(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job-a\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, uuid.New().String())
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCMappingLingerDuration: time.Minute})
+	require.NoError(t, mapper.Process(metrics, nil))
+	require.NotEmpty(t, mapper.lastJobMap)
+
+	require.NoError(t, mapper.Close())
+	assert.Nil(t, mapper.lastJobMap)
+	assert.Empty(t, mapper.removedJobs)
+}
+
+func TestHPCMapperReload(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(oldDir, "0"), []byte("job-old\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(newDir, "0"), []byte("job-new\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	gpuUUID := uuid.New().String()
+	jobsOf := func(metrics collector.MetricsByCounter) []string {
+		var jobs []string
+		for _, metric := range metrics[counter] {
+			jobs = append(jobs, metric.Attributes[HpcJobAttribute])
+		}
+		return jobs
+	}
+
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: oldDir, HPCMappingLingerDuration: time.Hour})
+	metrics := newGPUMetrics(counter, gpuUUID)
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, []string{"job-old"}, jobsOf(metrics))
+
+	// the scrapes running along the reload complete with either directory, never a mix of both
+	done := make(chan error)
+	go func() {
+		for range 50 {
+			metrics := newGPUMetrics(counter, gpuUUID)
+			if err := mapper.Process(metrics, nil); err != nil {
+				done <- err
+				return
+			}
+			if jobs := jobsOf(metrics); !slices.Equal(jobs, []string{"job-old"}) && !slices.Equal(jobs, []string{"job-new"}) {
+				done <- fmt.Errorf("unexpected jobs %v", jobs)
+				return
+			}
+		}
+		done <- nil
+	}()
+	mapper.Reload(&appconfig.Config{HPCJobMappingDir: newDir, HPCMappingLingerDuration: time.Hour})
+	require.NoError(t, <-done)
+
+	metrics = newGPUMetrics(counter, gpuUUID)
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, []string{"job-new"}, jobsOf(metrics), "the jobs of the previous directory don't linger")
+}
+
+func TestDeviceReadiness(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	ctrl := gomock.NewController(t)
+	gpuCount := uint(0)
+	gpus := mockdeviceinfo.NewMockProvider(ctrl)
+	gpus.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	gpus.EXPECT().GPUCount().DoAndReturn(func() uint { return gpuCount }).AnyTimes()
+	switches := mockdeviceinfo.NewMockProvider(ctrl)
+	switches.EXPECT().InfoType().Return(dcgm.FE_SWITCH).AnyTimes()
+
+	var readiness deviceReadiness
+	for range 3 {
+		assert.False(t, readiness.ready(gpus, "mapper"))
+		assert.True(t, readiness.ready(switches, "mapper"), "the other groups are always ready")
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "no GPU devices are known yet"), logs.String())
+	assert.NotContains(t, logs.String(), "GPU devices are known,", "the other groups don't end the wait")
+
+	gpuCount = 1
+	for range 3 {
+		assert.True(t, readiness.ready(gpus, "mapper"))
+		assert.True(t, readiness.ready(switches, "mapper"))
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "GPU devices are known,"), logs.String())
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/hostname"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+const (
+	httpMappingTimeout = 5 * time.Second
+	// httpMappingMaxBackoff caps the delay between the requests to a failing endpoint, which
+	// doubles from the TTL on every consecutive failure
+	httpMappingMaxBackoff = 5 * time.Minute
+)
+
+// jobAssignment is a GPU assigned to a job by the cluster allocation service. The GPU is any of
+// the mapping keys of the GPU files, and is only meaningful with the node for a GPU index.
+type jobAssignment struct {
+	Node   string `json:"node"`
+	GPU    string `json:"gpu"`
+	JobID  string `json:"jobid"`
+	UserID string `json:"userid"`
+}
+
+// httpMapper reads the jobs using each GPU from a cluster-wide allocation service, which answers
+// GET requests with a JSON array of jobAssignment. The assignments of the other nodes are
+// dropped. The requests are conditional on the ETag and Last-Modified of the previous answer,
+// so that an unchanged mapping is neither downloaded nor parsed again. The last mapping answered
+// keeps applying while the endpoint fails.
+type httpMapper struct {
+	keyedJobMapper
+
+	client *http.Client
+	slots  backendSlots
+	node   string
+	parse  func(body []byte, node string) (map[string][]string, error)
+
+	// guarded by mu, along with the mapping
+	etag         string
+	lastModified string
+	updatedAt    time.Time
+	failures     int
+}
+
+func newHTTPMapper(c *appconfig.Config) (*httpMapper, error) {
+	slog.Info(fmt.Sprintf("HPC job mapping is enabled and fetches %q", c.HPCJobMappingURL))
+	// without the node, the assignments of the GPUs of this node could not be told apart
+	node, err := hostname.GetHostname(c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the hostname the HPC job mapping URL assignments are selected by: %w", err)
+	}
+	p := &httpMapper{
+		client: &http.Client{Timeout: httpMappingTimeout},
+		slots:  newBackendSlots(c.HPCJobMappingConcurrency),
+		node:   node,
+		parse:  parseJobAssignments,
+	}
+	p.init(c)
+	return p, nil
+}
+
+// Close drops the cached job mapping and the idle connections; the mapper is not used afterwards.
+func (p *httpMapper) Close() error {
+	p.client.CloseIdleConnections()
+	return p.keyedJobMapper.Close()
+}
+
+// MappingUpdatedAt returns when the mapping applied was last answered by the endpoint, the zero
+// time before its first answer.
+func (p *httpMapper) MappingUpdatedAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.updatedAt
+}
+
+func (p *httpMapper) Name() string {
+	return "httpMapper"
+}
+
+func (p *httpMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	fetching := false
+	var etag, lastModified string
+	if (p.fetchedAt.IsZero() || now.Sub(p.fetchedAt) >= p.refreshInterval()) && p.slots.tryAcquire() {
+		fetching = true
+		p.fetchedAt = now
+		if p.gpuToJobMap != nil {
+			etag, lastModified = p.etag, p.lastModified
+		}
+	}
+	p.mu.Unlock()
+
+	// the endpoint is requested without holding the lock, so that the scrapes finding no free
+	// slot are served the cached mapping meanwhile
+	if fetching {
+		answer, err := p.fetch(etag, lastModified)
+		p.slots.release()
+
+		p.mu.Lock()
+		if err != nil {
+			p.failures++
+			p.warnFailure(now, "Unable to fetch the HPC job mapping. Ignoring.",
+				slog.String("url", p.Config.HPCJobMappingURL),
+				slog.String(logging.ErrorKey, err.Error()))
+		} else {
+			p.failures = 0
+			p.updatedAt = now
+			if answer != nil {
+				p.gpuToJobMap, p.etag, p.lastModified = answer.gpuToJobMap, answer.etag, answer.lastModified
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	gpuToJobMap := p.gpuToJobMap
+	p.mu.Unlock()
+
+	applyJobMapping(metrics, sysInfo, p.jobMapping(mappingSourceHTTP, gpuToJobMap))
+
+	return nil
+}
+
+// refreshInterval is the TTL, doubled on every consecutive failure up to httpMappingMaxBackoff.
+func (p *httpMapper) refreshInterval() time.Duration {
+	interval := mappingRefreshInterval(p.Config.HPCJobMappingURLTTL, p.Config.HPCJobMappingURLRefresh)
+	for i := 0; i < p.failures && interval < httpMappingMaxBackoff; i++ {
+		interval = min(2*interval, httpMappingMaxBackoff)
+	}
+	return interval
+}
+
+// httpAnswer is a mapping answered by the endpoint along with its validators
+type httpAnswer struct {
+	gpuToJobMap  map[string][]string
+	etag         string
+	lastModified string
+}
+
+// fetch requests the mapping, conditionally on the validators of the previous answer if any, and
+// parses it. The answer is nil when the mapping is unchanged.
+func (p *httpMapper) fetch(etag, lastModified string) (*httpAnswer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpMappingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Config.HPCJobMappingURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && (etag != "" || lastModified != ""):
+		return nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+
+	reader := io.Reader(resp.Body)
+	if maxBytes := p.Config.HPCMaxMappingFileBytes; maxBytes > 0 {
+		reader = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes := p.Config.HPCMaxMappingFileBytes; maxBytes > 0 && int64(len(body)) > maxBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", errMappingFileOversize, maxBytes)
+	}
+
+	gpuToJobMap, err := p.parse(body, p.node)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpAnswer{
+		gpuToJobMap:  gpuToJobMap,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// parseJobAssignments returns the jobs of the GPUs of the node, keyed by GPU, from a JSON array of
+// jobAssignment. The assignments without a node apply to every node.
+func parseJobAssignments(body []byte, node string) (map[string][]string, error) {
+	var assignments []jobAssignment
+	if err := json.Unmarshal(body, &assignments); err != nil {
+		return nil, err
+	}
+
+	gpuToJobMap := make(map[string][]string)
+	for _, assignment := range assignments {
+		if assignment.Node != "" && assignment.Node != node {
+			continue
+		}
+		if assignment.GPU == "" || assignment.JobID == "" {
+			slog.Debug(fmt.Sprintf("HPC HTTP mapper: skipping assignment without GPU or job %+v", assignment))
+			continue
+		}
+		// the jobs are in the format of the mapping files: "jobid" or "jobid userid"
+		entry := assignment.JobID
+		if assignment.UserID != "" {
+			entry += " " + assignment.UserID
+		}
+		gpuToJobMap[assignment.GPU] = append(gpuToJobMap[assignment.GPU], entry)
+	}
+
+	slog.Debug(fmt.Sprintf("GPU to job mapping: %+v", gpuToJobMap))
+
+	return gpuToJobMap, nil
+}
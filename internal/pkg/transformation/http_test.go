@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestHTTPMapperProcess(t *testing.T) {
+	const gpu0UUID = "GPU-00000000-0000-0000-0000-000000000000"
+	const gpu1UUID = "GPU-11111111-1111-1111-1111-111111111111"
+	const etag = `"v1"`
+
+	var requests, notModified atomic.Int32
+	fail := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(`[
+			{"node": "node1", "gpu": "` + gpu0UUID + `", "jobid": "job1", "userid": "1000"},
+			{"node": "node2", "gpu": "` + gpu1UUID + `", "jobid": "job2"},
+			{"gpu": "1", "jobid": "job3"}
+		]`))
+	}))
+	t.Cleanup(server.Close)
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, gpu0UUID, gpu1UUID) }
+
+	now := time.Now()
+	mapper, err := newHTTPMapper(&appconfig.Config{
+		HPCJobMappingURL:    server.URL,
+		HPCJobMappingURLTTL: 10 * time.Second,
+	})
+	require.NoError(t, err)
+	mapper.now = func() time.Time { return now }
+	mapper.node = "node1"
+	assert.True(t, mapper.MappingUpdatedAt().IsZero())
+	var parses int
+	mapper.parse = func(body []byte, node string) (map[string][]string, error) {
+		parses++
+		return parseJobAssignments(body, node)
+	}
+
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "1000", metrics[counter][0].Attributes[HpcUserAttribute])
+	assert.Equal(t, "http", metrics[counter][0].Attributes[MappingSourceAttribute])
+	assert.Equal(t, "job3", metrics[counter][1].Attributes[HpcJobAttribute], "the assignments of other nodes are dropped")
+	assert.Equal(t, 1, parses)
+	assert.Equal(t, now, mapper.MappingUpdatedAt())
+
+	// The mapping is cached within the TTL
+	require.NoError(t, mapper.Process(newMetrics(), nil))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// An unchanged mapping is not parsed again
+	now = now.Add(10 * time.Second)
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, int32(1), notModified.Load())
+	assert.Equal(t, 1, parses, "a 304 answer is not parsed")
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	answeredAt := now
+	assert.Equal(t, answeredAt, mapper.MappingUpdatedAt(), "a 304 answer refreshes the mapping")
+
+	// Failures keep the last mapping applying and back off
+	fail.Store(true)
+	now = now.Add(10 * time.Second)
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, int32(3), requests.Load())
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, answeredAt, mapper.MappingUpdatedAt(), "the age of the mapping grows while the endpoint fails")
+
+	now = now.Add(10 * time.Second)
+	require.NoError(t, mapper.Process(newMetrics(), nil))
+	assert.Equal(t, int32(3), requests.Load(), "the failing endpoint is requested again after twice the TTL")
+
+	fail.Store(false)
+	now = now.Add(10 * time.Second)
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, int32(4), requests.Load())
+	assert.Equal(t, 1, parses, "the mapping is still unchanged")
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// idRewriter normalizes the job ids set by the job mappers, and the user ids and accounts if
+// configured, e.g. strips the cluster prefix of prod-12345, with a regular expression. It runs
+// after every mapper, so the ids are rewritten whatever their source.
+type idRewriter struct {
+	// configMu is held by Process for reading, so that Reload waits for the calls in flight
+	configMu sync.RWMutex
+	Config   *appconfig.Config
+}
+
+func newIDRewriter(c *appconfig.Config) *idRewriter {
+	slog.Info(fmt.Sprintf("HPC %v labels are rewritten with %q", c.HPCIDRewriteAttributes, c.HPCIDRewriteRegex))
+	return &idRewriter{Config: c}
+}
+
+func (p *idRewriter) Name() string {
+	return "idRewriter"
+}
+
+// Reload applies the configuration, whose job placeholder is not rewritten, e.g. after the
+// mapping settings file was re-read on SIGHUP.
+func (p *idRewriter) Reload(c *appconfig.Config) {
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+	p.Config = c
+}
+
+func (p *idRewriter) Process(metrics collector.MetricsByCounter, _ deviceinfo.Provider) error {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+
+	for counter := range metrics {
+		for i, metric := range metrics[counter] {
+			var attributes map[string]string
+			for _, attribute := range p.Config.HPCIDRewriteAttributes {
+				value, ok := metric.Attributes[attribute]
+				if !ok || (attribute == HpcJobAttribute && value == p.Config.HPCJobPlaceholder) {
+					continue
+				}
+				rewritten := p.Config.HPCIDRewriteRegex.ReplaceAllString(value, p.Config.HPCIDRewriteReplacement)
+				// an id rewritten to nothing is kept rather than rendered as an empty label
+				if rewritten == value || rewritten == "" {
+					continue
+				}
+				// the attributes may be shared with other metrics, which must not be rewritten twice
+				if attributes == nil {
+					attributes = maps.Clone(metric.Attributes)
+				}
+				attributes[attribute] = rewritten
+			}
+			if attributes != nil {
+				metrics[counter][i].Attributes = attributes
+			}
+		}
+	}
+
+	return nil
+}
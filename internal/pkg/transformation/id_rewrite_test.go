@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestIDRewriterProcess(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("prod-12345 prod-1000\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("67890\n"), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter {
+		return collector.MetricsByCounter{
+			counter: {
+				{GPU: "0", Value: "42", Counter: counter, Attributes: map[string]string{}},
+				{GPU: "1", Value: "451", Counter: counter, Attributes: map[string]string{}},
+				{GPU: "2", Value: "60", Counter: counter, Attributes: map[string]string{}},
+			},
+		}
+	}
+
+	config := &appconfig.Config{
+		HPCJobMappingDir:       dir,
+		HPCJobPlaceholder:      "prod-none",
+		HPCIDRewriteRegex:      regexp.MustCompile(`^prod-`),
+		HPCIDRewriteAttributes: []string{HpcJobAttribute},
+	}
+	transformations, err := GetTransformations(config)
+	require.NoError(t, err)
+	require.Len(t, transformations, 2)
+	metrics := newMetrics()
+	for _, transformation := range transformations {
+		require.NoError(t, transformation.Process(metrics, nil))
+	}
+
+	require.Len(t, metrics[counter], 3)
+	assert.Equal(t, "12345", metrics[counter][0].Attributes[HpcJobAttribute], "the prefix is stripped")
+	assert.Equal(t, "prod-1000", metrics[counter][0].Attributes[HpcUserAttribute], "only the configured attributes")
+	assert.Equal(t, "67890", metrics[counter][1].Attributes[HpcJobAttribute], "ids not matched are kept")
+	assert.Equal(t, "prod-none", metrics[counter][2].Attributes[HpcJobAttribute], "the placeholder is kept")
+
+	config.HPCIDRewriteAttributes = []string{HpcJobAttribute, HpcUserAttribute}
+	metrics = newMetrics()
+	for _, transformation := range transformations {
+		require.NoError(t, transformation.Process(metrics, nil))
+	}
+	assert.Equal(t, "12345", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "1000", metrics[counter][0].Attributes[HpcUserAttribute])
+}
+
+func TestIDRewriterProcessSharedAttributes(t *testing.T) {
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	attributes := map[string]string{HpcJobAttribute: "a-1"}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{GPU: "0", Value: "42", Counter: counter, Attributes: attributes},
+			{GPU: "0", Value: "43", Counter: counter, Attributes: attributes},
+		},
+	}
+
+	rewriter := newIDRewriter(&appconfig.Config{
+		HPCIDRewriteRegex:       regexp.MustCompile(`^a-`),
+		HPCIDRewriteReplacement: "a-a-",
+		HPCIDRewriteAttributes:  []string{HpcJobAttribute},
+	})
+	require.NoError(t, rewriter.Process(metrics, nil))
+
+	assert.Equal(t, "a-a-1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "a-a-1", metrics[counter][1].Attributes[HpcJobAttribute], "rewritten once")
+	assert.Equal(t, "a-1", attributes[HpcJobAttribute])
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+)
+
+// mappingErrorLogInterval limits how often a mapper logs the failures of its backend
+const mappingErrorLogInterval = time.Minute
+
+// jobMapperBase is embedded by the mappers reading the jobs of the GPUs from a backend other than
+// the mapping files, e.g. a socket or a database: it holds their configuration, the mapping last
+// read from the backend and the formatter of the alternate values.
+type jobMapperBase struct {
+	Config *appconfig.Config
+	// settings are the mapping settings of Config that Reload replaces
+	settings atomic.Pointer[jobMappingSettings]
+
+	now       func() time.Time
+	formatter collector.ValueFormatter
+	devices   deviceReadiness
+
+	mu           sync.Mutex
+	gpuToJobMap  map[string][]string
+	fetchedAt    time.Time
+	lastErrorLog time.Time
+}
+
+// jobMappingSettings are the settings of the mapping settings file applied by the backend mappers
+type jobMappingSettings struct {
+	placeholder      string
+	sharingAttribute bool
+}
+
+func (p *jobMapperBase) init(c *appconfig.Config) {
+	p.Config = c
+	p.now = time.Now
+	p.formatter = collector.DefaultValueFormatter{}
+	p.Reload(c)
+}
+
+// Reload applies the job placeholder and the sharing attribute of the configuration, e.g. after
+// the mapping settings file was re-read on SIGHUP. The Process calls in flight complete with the
+// previous settings.
+func (p *jobMapperBase) Reload(c *appconfig.Config) {
+	p.settings.Store(&jobMappingSettings{
+		placeholder:      c.HPCJobPlaceholder,
+		sharingAttribute: c.HPCSharingAttribute,
+	})
+}
+
+// jobMapping returns the mapping of the GPUs to the jobs read from the source, with the current
+// settings. The GPUs already mapped by another mapper are left to it.
+func (p *jobMapperBase) jobMapping(source string, gpuJobs map[string][]string) jobMapping {
+	settings := p.settings.Load()
+	return jobMapping{
+		gpuJobs:          gpuJobs,
+		source:           source,
+		placeholder:      settings.placeholder,
+		formatter:        p.formatter,
+		sharingAttribute: settings.sharingAttribute,
+		incompleteMIG:    p.Config.HPCIncompleteMIGMode,
+		skipMapped:       true,
+	}
+}
+
+// SetValueFormatter sets the formatter of the alternate values; it must be set before Process is called.
+func (p *jobMapperBase) SetValueFormatter(formatter collector.ValueFormatter) {
+	p.formatter = formatter
+}
+
+// Close drops the cached job mapping; the mapper is not used afterwards.
+func (p *jobMapperBase) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gpuToJobMap = nil
+	return nil
+}
+
+// warnFailure logs a failure to read the backend, at most once per mappingErrorLogInterval so that
+// a backend failing on every scrape doesn't flood the log. p.mu must be held.
+func (p *jobMapperBase) warnFailure(now time.Time, msg string, args ...any) {
+	if now.Sub(p.lastErrorLog) >= mappingErrorLogInterval {
+		slog.Warn(msg, args...)
+		p.lastErrorLog = now
+	}
+}
+
+// keyedJobMapper is the jobMapperBase of the mappers looking the jobs of a GPU up under its
+// mapping keys, which sites may resolve their own way.
+type keyedJobMapper struct {
+	jobMapperBase
+
+	resolver MappingKeyResolver
+}
+
+// SetMappingKeyResolver sets the resolver of the mapping keys; it must be set before Process is called.
+func (p *keyedJobMapper) SetMappingKeyResolver(resolver MappingKeyResolver) {
+	p.resolver = resolver
+}
+
+// jobMapping is jobMapperBase.jobMapping with the keys resolved by the resolver
+func (p *keyedJobMapper) jobMapping(source string, gpuJobs map[string][]string) jobMapping {
+	mapping := p.jobMapperBase.jobMapping(source, gpuJobs)
+	mapping.resolver = p.resolver
+	return mapping
+}
+
+// mappingRefreshInterval returns how long the mapping answered by a backend is cached, the TTL but
+// no less than the minimum refresh interval of the mapper, so that scrapes arriving faster are
+// served the cached mapping whatever the TTL. With a TTL of 0 the mapping is cached for the minimum
+// refresh interval only.
+func mappingRefreshInterval(ttl, minRefresh time.Duration) time.Duration {
+	return max(ttl, minRefresh, 0)
+}
+
+// backendSlots bounds the concurrent queries of a mapper to its backend, so that a burst of
+// scrapes doesn't overload it. The scrapes finding no free slot are served the cached mapping
+// rather than wait.
+type backendSlots chan struct{}
+
+// newBackendSlots returns the slots of n concurrent queries, at least one
+func newBackendSlots(n int) backendSlots {
+	return make(backendSlots, max(n, 1))
+}
+
+// tryAcquire takes a slot, if one is free
+func (s backendSlots) tryAcquire() bool {
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s backendSlots) release() {
+	<-s
+}
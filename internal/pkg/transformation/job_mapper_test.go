@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"bytes"
+	"log/slog"
+	sysOS "os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+// newGPUMetrics returns a metric of the counter for each of the GPU UUIDs, the metric of the i-th
+// UUID being that of GPU i, without any attribute yet
+func newGPUMetrics(counter counters.Counter, gpuUUIDs ...string) collector.MetricsByCounter {
+	metrics := make([]collector.Metric, len(gpuUUIDs))
+	for i, gpuUUID := range gpuUUIDs {
+		metrics[i] = collector.Metric{
+			GPU: strconv.Itoa(i), GPUUUID: gpuUUID, Value: "42", Counter: counter, Attributes: map[string]string{},
+		}
+	}
+	return collector.MetricsByCounter{counter: metrics}
+}
+
+func TestJobMapperBaseWarnFailure(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	var mapper jobMapperBase
+	mapper.init(&appconfig.Config{})
+	now := time.Now()
+	mapper.warnFailure(now, "backend failed")
+	mapper.warnFailure(now.Add(mappingErrorLogInterval/2), "backend failed")
+	assert.Equal(t, 1, bytes.Count(logs.Bytes(), []byte("backend failed")), "the failures are logged once per interval")
+
+	mapper.warnFailure(now.Add(mappingErrorLogInterval), "backend failed")
+	assert.Equal(t, 2, bytes.Count(logs.Bytes(), []byte("backend failed")))
+}
+
+func TestJobMappersCombined(t *testing.T) {
+	mappingDir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(mappingDir, "GPU-0"), []byte("job-a\njob-b\n"), 0o644))
+	t.Setenv("TEST_JOB_ID", "1234")
+
+	config := &appconfig.Config{
+		HPCJobMappingDir:    mappingDir,
+		HPCJobEnvVar:        "TEST_JOB_ID",
+		HPCSharingAttribute: true,
+	}
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, "GPU-0", "GPU-1")
+	require.NoError(t, newHPCMapper(config).Process(metrics, nil))
+	require.NoError(t, newEnvMapper(config).Process(metrics, nil))
+
+	var got []string
+	for _, metric := range metrics[counter] {
+		got = append(got, metric.GPU+":"+metric.Attributes[HpcJobAttribute]+":"+
+			metric.Attributes[MappingSourceAttribute]+":"+metric.Attributes[SharingAttribute])
+	}
+	assert.ElementsMatch(t, []string{"0:job-a:file:shared", "0:job-b:file:shared", "1:1234:env:exclusive"}, got,
+		"the GPUs mapped by the file mapper are left to it by the backends")
+}
+
+func TestJobMapperBaseClose(t *testing.T) {
+	var mapper jobMapperBase
+	mapper.init(&appconfig.Config{})
+	mapper.gpuToJobMap = map[string][]string{"GPU-0": {"job1"}}
+
+	assert.NoError(t, mapper.Close())
+	assert.Nil(t, mapper.gpuToJobMap)
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// jobGPUSecondsCounter is the series of the time each job had a GPU mapped to it. It isn't derived
+// from a field, so it carries no field id.
+var jobGPUSecondsCounter = counters.Counter{
+	FieldName:  "dcgm_job_gpu_seconds_total",
+	PromType:   "counter",
+	Help:       "Time the GPU was mapped to the job since the exporter first saw them together, summed over the scrape intervals (in s).",
+	Multiplier: 1,
+	Unit:       "seconds",
+}
+
+// jobGPU is a job running on a GPU, keyed by the GPU UUID and index as the index of a GPU may
+// change, e.g. after a hot reset
+type jobGPU struct {
+	gpu string
+	job string
+}
+
+// accumulateJobGPUSeconds adds the time elapsed since the previous scrape to the (job, GPU) pairs
+// of the mapped metrics and adds the accumulated seconds series. The pairs only kept by the linger
+// duration don't accumulate, so a finished job isn't billed for it, and the seconds of a pair are
+// dropped once it is no longer mapped at all. current is the job mapping before the lingering jobs
+// are added.
+func (p *hpcMapper) accumulateJobGPUSeconds(
+	metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider, current map[string][]string,
+) {
+	resolver := p.resolver
+	if resolver == nil {
+		resolver = DefaultMappingKeyResolver{}
+	}
+
+	// the counters are walked in a stable order so that each pair takes the labels of the same metric
+	metricCounters := slices.SortedFunc(maps.Keys(metrics), func(a, b counters.Counter) int {
+		return cmp.Or(cmp.Compare(a.FieldID, b.FieldID), cmp.Compare(a.FieldName, b.FieldName))
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var elapsed float64
+	if !p.jobSecondsAt.IsZero() {
+		elapsed = now.Sub(p.jobSecondsAt).Seconds()
+	}
+	p.jobSecondsAt = now
+
+	seconds := map[jobGPU]float64{}
+	var secondsMetrics []collector.Metric
+	for _, counter := range metricCounters {
+		if counter == jobGPUSecondsCounter || counter == energyCounter {
+			continue
+		}
+		for _, metric := range metrics[counter] {
+			job := metric.Attributes[HpcJobAttribute]
+			if job == "" || job == p.Config.HPCJobPlaceholder {
+				continue
+			}
+			gpuID := metric.GPU
+			if metric.MigProfile != "" {
+				gpuID = metric.GPU + "." + metric.GPUInstanceID
+			}
+			key := jobGPU{gpu: metric.GPUUUID + "/" + gpuID, job: job}
+			if _, seen := seconds[key]; seen {
+				continue
+			}
+			total := p.jobGPUSeconds[key]
+			if p.isCurrentJob(current, resolver.MappingKeys(metric, sysInfo), job) {
+				total += elapsed
+			}
+			seconds[key] = total
+
+			secondsMetric := metric
+			secondsMetric.Counter = jobGPUSecondsCounter
+			secondsMetric.Value = strconv.FormatFloat(total, 'f', -1, 64)
+			secondsMetric.AlterValue = ""
+			secondsMetric.Labels = maps.Clone(metric.Labels)
+			secondsMetric.Attributes = maps.Clone(metric.Attributes)
+			delete(secondsMetric.Attributes, CounterResetAttribute)
+			secondsMetrics = append(secondsMetrics, secondsMetric)
+		}
+	}
+	p.jobGPUSeconds = seconds
+
+	if len(secondsMetrics) > 0 {
+		metrics[jobGPUSecondsCounter] = secondsMetrics
+	}
+}
+
+// isCurrentJob tells whether the job is mapped to the metric keys in the current job mapping, or
+// is a job of the node when the keys have no job of their own.
+func (p *hpcMapper) isCurrentJob(current map[string][]string, keys []string, job string) bool {
+	found := findKeys(current, keys...)
+	if len(found) == 0 && p.Config.HPCJobMappingNodeFile != "" {
+		found = []string{p.Config.HPCJobMappingNodeFile}
+	}
+	for _, key := range found {
+		for _, line := range current[key] {
+			if id, _, _ := strings.Cut(line, " "); id == job {
+				return true
+			}
+		}
+	}
+	return false
+}
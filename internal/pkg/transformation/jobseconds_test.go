@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestHPCProcessJobGPUSeconds(t *testing.T) {
+	powerCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_POWER_USAGE,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job1\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("job2 user2\n"), 0o644))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	mapper := newHPCMapper(&appconfig.Config{
+		HPCJobMappingDir:         dir,
+		HPCJobGPUSeconds:         true,
+		HPCMappingLingerDuration: 30 * time.Second,
+	})
+	mapper.now = func() time.Time { return now }
+
+	scrape := func() map[string]string {
+		t.Helper()
+		metrics := collector.MetricsByCounter{powerCounter: {}}
+		for _, gpu := range []string{"0", "1"} {
+			metrics[powerCounter] = append(metrics[powerCounter], collector.Metric{
+				GPU: gpu, GPUUUID: "GPU-" + gpu, Value: "100", Counter: powerCounter, Attributes: map[string]string{},
+			})
+		}
+		require.NoError(t, mapper.Process(metrics, nil))
+
+		seconds := map[string]string{}
+		for _, metric := range metrics[jobGPUSecondsCounter] {
+			seconds[metric.Attributes[HpcJobAttribute]+"/"+metric.GPU] = metric.Value
+		}
+		return seconds
+	}
+
+	assert.Equal(t, map[string]string{"job1/0": "0", "job2/1": "0"}, scrape())
+
+	now = start.Add(15 * time.Second)
+	assert.Equal(t, map[string]string{"job1/0": "15", "job2/1": "15"}, scrape())
+
+	// the lingering job stops accumulating
+	require.NoError(t, sysOS.Remove(filepath.Join(dir, "1")))
+	now = start.Add(30 * time.Second)
+	assert.Equal(t, map[string]string{"job1/0": "30", "job2/1": "15"}, scrape())
+
+	now = start.Add(45 * time.Second)
+	assert.Equal(t, map[string]string{"job1/0": "45", "job2/1": "15"}, scrape())
+
+	// and is dropped after the linger duration
+	now = start.Add(60 * time.Second)
+	assert.Equal(t, map[string]string{"job1/0": "60"}, scrape())
+
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "1"), []byte("job2 user2\n"), 0o644))
+	now = start.Add(75 * time.Second)
+	assert.Equal(t, map[string]string{"job1/0": "75", "job2/1": "15"}, scrape())
+
+	require.NoError(t, mapper.Close())
+	assert.Nil(t, mapper.jobGPUSeconds)
+}
+
+func TestHPCProcessJobGPUSecondsDisabled(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "0"), []byte("job1\n"), 0o644))
+
+	counter := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_POWER_USAGE, FieldName: "DCGM_FI_DEV_POWER_USAGE"}
+	mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir})
+
+	metrics := newGPUMetrics(counter, "GPU-0")
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.NotContains(t, metrics, jobGPUSecondsCounter)
+}
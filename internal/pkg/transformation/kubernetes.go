@@ -122,6 +122,15 @@ func NewPodMapper(c *appconfig.Config) *PodMapper {
 	return podMapper
 }
 
+// Close stops the ResourceSlice informer, if it was started
+func (p *PodMapper) Close() error {
+	if p.ResourceSliceManager != nil {
+		slog.Info("Stopping ResourceSliceManager")
+		p.ResourceSliceManager.Stop()
+	}
+	return nil
+}
+
 func (p *PodMapper) Name() string {
 	return "podMapper"
 }
@@ -228,6 +237,7 @@ func (p *PodMapper) Process(metrics collector.MetricsByCounter, deviceInfo devic
 						metric.Attributes[oldContainerAttribute] = pi.Container
 					}
 					metric.Attributes[uidAttribute] = pi.UID
+					metric.Attributes[MappingSourceAttribute] = mappingSourceKubernetes
 					if pi.VGPU != "" {
 						metric.Attributes[vgpuAttribute] = pi.VGPU
 					}
@@ -267,6 +277,7 @@ func (p *PodMapper) Process(metrics collector.MetricsByCounter, deviceInfo devic
 				}
 				
 				metrics[counter][j].Attributes[uidAttribute] = podInfo.UID
+				metrics[counter][j].Attributes[MappingSourceAttribute] = mappingSourceKubernetes
 				maps.Copy(metrics[counter][j].Labels, podInfo.Labels)
 			}
 		}
@@ -305,6 +316,7 @@ func (p *PodMapper) Process(metrics collector.MetricsByCounter, deviceInfo devic
 						metric.Attributes[oldNamespaceAttribute] = pi.Namespace
 						metric.Attributes[oldContainerAttribute] = pi.Container
 					}
+					metric.Attributes[MappingSourceAttribute] = mappingSourceKubernetes
 					if dr := pi.DynamicResources; dr != nil {
 						metric.Attributes[draClaimName] = dr.ClaimName
 						metric.Attributes[draClaimNamespace] = dr.ClaimNamespace
@@ -18,7 +18,9 @@ package transformation
 
 import (
 	"fmt"
+	goruntime "runtime"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/types"
@@ -383,6 +385,7 @@ func TestProcessPodMapper_WithD_Different_Format_Of_DeviceID(t *testing.T) {
 					require.Equal(t, fmt.Sprintf("gpu-pod-%d", i), metric.Attributes[podAttribute])
 					require.Equal(t, "default", metric.Attributes[namespaceAttribute])
 					require.Equal(t, "default", metric.Attributes[containerAttribute])
+					require.Equal(t, "kubernetes", metric.Attributes[MappingSourceAttribute])
 
 					// Assert virtual GPU attributes.
 					vgpu, ok := metric.Attributes[vgpuAttribute]
@@ -560,6 +563,7 @@ func TestProcessPodMapper_WithLabels(t *testing.T) {
 		require.Equal(t, pod.name, metric.Attributes[podAttribute])
 		require.Equal(t, "default", metric.Attributes[namespaceAttribute])
 		require.Equal(t, "default", metric.Attributes[containerAttribute])
+		require.Equal(t, "kubernetes", metric.Attributes[MappingSourceAttribute])
 
 		// Verify labels were sanitized and added
 		expectedLabelCount := len(pod.labels)
@@ -910,3 +914,20 @@ func TestProcessPodMapper_WithLabelsAndUID(t *testing.T) {
 		}
 	}
 }
+
+func TestPodMapperCloseStopsResourceSliceInformer(t *testing.T) {
+	before := goruntime.NumGoroutine()
+
+	manager, err := newDRAResourceSliceManager(fake.NewClientset())
+	require.NoError(t, err)
+	assert.Greater(t, goruntime.NumGoroutine(), before, "the informer runs in the background")
+
+	podMapper := &PodMapper{Config: &appconfig.Config{KubernetesEnableDRA: true}, ResourceSliceManager: manager}
+	require.NoError(t, podMapper.Close())
+
+	// assert.Eventually runs goroutines of its own, so the goroutines are polled here
+	for deadline := time.Now().Add(5 * time.Second); goruntime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, goruntime.NumGoroutine(), before, "the informer goroutines exit")
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// legacyMapper emits legacy nvidia_gpu_* series alongside the DCGM named ones,
+// so dashboards built for older gpu-monitoring stacks keep working.
+type legacyMapper struct {
+	Config *appconfig.Config
+}
+
+func newLegacyMapper(c *appconfig.Config) *legacyMapper {
+	slog.Info(fmt.Sprintf("Legacy metric names are enabled for %d fields", len(c.LegacyMetrics)))
+	return &legacyMapper{
+		Config: c,
+	}
+}
+
+func (p *legacyMapper) Name() string {
+	return "legacyMapper"
+}
+
+func (p *legacyMapper) Process(metrics collector.MetricsByCounter, _ deviceinfo.Provider) error {
+	legacyMetrics := collector.MetricsByCounter{}
+
+	for counter, values := range metrics {
+		legacy, exists := p.Config.LegacyMetrics[counter.FieldName]
+		if !exists {
+			continue
+		}
+
+		// the legacy series is derived from the field, so it has no field id of its own
+		legacyCounter := counters.Counter{
+			FieldName:  legacy.Name,
+			PromType:   counter.PromType,
+			Help:       counter.Help,
+			Multiplier: 1,
+		}
+
+		for _, metric := range values {
+			legacyMetric := metric
+			legacyMetric.Counter = legacyCounter
+			legacyMetric.Value = scaleValue(metric.Value, legacy.Multiplier)
+			legacyMetric.AlterValue = legacyMetric.Value
+			legacyMetric.Labels = maps.Clone(metric.Labels)
+			legacyMetric.Attributes = maps.Clone(metric.Attributes)
+			legacyMetrics[legacyCounter] = append(legacyMetrics[legacyCounter], legacyMetric)
+		}
+	}
+
+	maps.Copy(metrics, legacyMetrics)
+
+	return nil
+}
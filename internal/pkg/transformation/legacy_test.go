@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestLegacyMapperProcess(t *testing.T) {
+	utilCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_UTIL,
+		FieldName: "DCGM_FI_DEV_GPU_UTIL",
+		PromType:  "gauge",
+		Help:      "GPU utilization (in %).",
+	}
+	fbFreeCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_FB_FREE,
+		FieldName: "DCGM_FI_DEV_FB_FREE",
+		PromType:  "gauge",
+		Help:      "Frame buffer memory free (in MB).",
+	}
+	tempCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_TEMP,
+		FieldName: "DCGM_FI_DEV_GPU_TEMP",
+		PromType:  "gauge",
+	}
+
+	metrics := collector.MetricsByCounter{
+		utilCounter: {
+			{GPU: "0", Value: "87", Counter: utilCounter, Attributes: map[string]string{HpcJobAttribute: "42"}},
+		},
+		fbFreeCounter: {
+			{GPU: "0", Value: "2", Counter: fbFreeCounter, Attributes: map[string]string{}},
+		},
+		tempCounter: {
+			{GPU: "0", Value: "40", Counter: tempCounter, Attributes: map[string]string{}},
+		},
+	}
+
+	mapper := newLegacyMapper(&appconfig.Config{
+		LegacyMetrics: map[string]appconfig.LegacyMetric{
+			"DCGM_FI_DEV_GPU_UTIL": {Name: "nvidia_gpu_duty_cycle", Multiplier: 1},
+			"DCGM_FI_DEV_FB_FREE":  {Name: "nvidia_gpu_memory_total_bytes", Multiplier: 1048576},
+		},
+	})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics, 5, "legacy series are emitted in addition to the DCGM ones")
+	assert.Len(t, metrics[utilCounter], 1)
+	assert.Len(t, metrics[fbFreeCounter], 1)
+
+	got := map[string]collector.Metric{}
+	for counter, values := range metrics {
+		require.Len(t, values, 1)
+		got[counter.FieldName] = values[0]
+		if strings.HasPrefix(counter.FieldName, "nvidia_gpu_") {
+			assert.Zero(t, counter.FieldID, "the legacy series have no field id of their own")
+		}
+	}
+
+	require.Contains(t, got, "nvidia_gpu_duty_cycle")
+	assert.Equal(t, "87", got["nvidia_gpu_duty_cycle"].Value)
+	assert.Equal(t, "gauge", got["nvidia_gpu_duty_cycle"].Counter.PromType)
+	assert.Equal(t, "42", got["nvidia_gpu_duty_cycle"].Attributes[HpcJobAttribute])
+
+	require.Contains(t, got, "nvidia_gpu_memory_total_bytes")
+	assert.Equal(t, "2097152", got["nvidia_gpu_memory_total_bytes"].Value)
+
+	assert.NotContains(t, got, "nvidia_gpu_temperature_celsius")
+	assert.Equal(t, "legacyMapper", mapper.Name())
+}
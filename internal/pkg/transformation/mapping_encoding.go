@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+// decodeMappingLines decodes the lines of a mapping file with the configured encoding. The lines
+// which can't be decoded are logged and skipped, the empty ones are kept as they are.
+func decodeMappingLines(name string, lines []string, encoding string) []string {
+	if encoding == "" || encoding == appconfig.HPCJobMappingEncodingNone {
+		return lines
+	}
+	decoded := make([]string, 0, len(lines))
+	for i, line := range lines {
+		line, err := decodeMappingLine(line, encoding)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Skipping line %d of HPC job mapping file %q, it is not %s encoded", i+1, name, encoding),
+				slog.String(logging.ErrorKey, err.Error()))
+			continue
+		}
+		decoded = append(decoded, line)
+	}
+	return decoded
+}
+
+// decodeMappingLine decodes the whole line, or each of its space separated fields, from base64
+func decodeMappingLine(line, encoding string) (string, error) {
+	if encoding == appconfig.HPCJobMappingEncodingBase64Line {
+		if strings.TrimSpace(line) == "" {
+			return line, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+		return string(decoded), err
+	}
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		decoded, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return "", err
+		}
+		fields[i] = string(decoded)
+	}
+	return strings.Join(fields, " "), nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"encoding/base64"
+	sysOS "os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestHPCProcessMappingEncoding(t *testing.T) {
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		encoding string
+		files    map[string]string
+	}{
+		{
+			encoding: appconfig.HPCJobMappingEncodingBase64Fields,
+			files: map[string]string{
+				"0": encode("51234567") + " " + encode("1000") + "\n",
+				"1": "not*base64 " + encode("1001") + "\n" + encode("51234568") + "\n",
+			},
+		},
+		{
+			encoding: appconfig.HPCJobMappingEncodingBase64Line,
+			files: map[string]string{
+				"0": encode("51234567 1000") + "\n",
+				"1": "not*base64\n" + encode("51234568") + "\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				require.NoError(t, sysOS.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+
+			counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+			metrics := collector.MetricsByCounter{counter: {}}
+			for _, gpu := range []string{"0", "1"} {
+				metrics[counter] = append(metrics[counter], collector.Metric{
+					GPU: gpu, GPUUUID: uuid.New().String(), Value: "42", Counter: counter, Attributes: map[string]string{},
+				})
+			}
+
+			mapper := newHPCMapper(&appconfig.Config{HPCJobMappingDir: dir, HPCJobMappingEncoding: tt.encoding})
+			require.NoError(t, mapper.Process(metrics, nil))
+
+			jobs := map[string]string{}
+			for _, metric := range metrics[counter] {
+				jobs[metric.GPU] = metric.Attributes[HpcJobAttribute] + "/" + metric.Attributes[HpcUserAttribute]
+			}
+			assert.Equal(t, map[string]string{
+				"0": "51234567/1000",
+				// the line which is not base64 encoded is skipped
+				"1": "51234568/",
+			}, jobs)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"log/slog"
+	"maps"
+	"strconv"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// migInstanceCountCounter is the series of the number of MIG instances of a GPU. It details the
+// MIG mode, but has no field id of its own.
+var migInstanceCountCounter = counters.Counter{
+	FieldName:  "dcgm_gpu_mig_instance_count",
+	PromType:   "gauge",
+	Help:       "Number of MIG instances of the GPU, 0 when MIG is disabled.",
+	Multiplier: 1,
+}
+
+// migCounter emits the number of MIG instances of each physical GPU, as known to the device
+// provider.
+type migCounter struct {
+	Config *appconfig.Config
+}
+
+func newMIGCounter(c *appconfig.Config) *migCounter {
+	slog.Info("MIG instance count is enabled")
+	return &migCounter{
+		Config: c,
+	}
+}
+
+func (p *migCounter) Name() string {
+	return "migCounter"
+}
+
+func (p *migCounter) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if sysInfo == nil || sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	gpuMetrics := physicalGPUMetrics(metrics)
+
+	var countMetrics []collector.Metric
+	for _, gpu := range sysInfo.GPUs() {
+		metric, ok := gpuMetrics[gpu.DeviceInfo.UUID]
+		if !ok {
+			continue
+		}
+		count := 0
+		if gpu.MigEnabled {
+			count = len(gpu.GPUInstances)
+		}
+		countMetrics = append(countMetrics, gpuSeries(metric, gpu, migInstanceCountCounter, strconv.Itoa(count)))
+	}
+
+	if len(countMetrics) > 0 {
+		metrics[migInstanceCountCounter] = countMetrics
+	}
+
+	return nil
+}
+
+// physicalGPUMetrics returns a metric of each GPU, by UUID, preferably one of the physical GPU
+// rather than of one of its MIG instances. The series derived for a GPU take the labels of that
+// metric, the GPUs without any are not exported and get none; the GPUs are matched by UUID as
+// their index may be stale.
+func physicalGPUMetrics(metrics collector.MetricsByCounter) map[string]collector.Metric {
+	gpuMetrics := map[string]collector.Metric{}
+	for _, values := range metrics {
+		for _, metric := range values {
+			if current, ok := gpuMetrics[metric.GPUUUID]; !ok || (current.MigProfile != "" && metric.MigProfile == "") {
+				gpuMetrics[metric.GPUUUID] = metric
+			}
+		}
+	}
+	return gpuMetrics
+}
+
+// gpuSeries returns the series of the counter of the physical GPU, labeled as the metric of the
+// GPU without its attributes
+func gpuSeries(metric collector.Metric, gpu deviceinfo.GPUInfo, counter counters.Counter, value string) collector.Metric {
+	series := metric
+	series.Counter = counter
+	series.Value = value
+	series.AlterValue = value
+	series.GPU = strconv.FormatUint(uint64(gpu.DeviceInfo.GPU), 10)
+	series.GPUDevice = "nvidia" + series.GPU
+	series.AlterUUID = gpu.DeviceInfo.UUID
+	series.MigProfile = ""
+	series.GPUInstanceID = ""
+	series.Labels = maps.Clone(metric.Labels)
+	series.Attributes = map[string]string{}
+	return series
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+func TestMIGCounterProcess(t *testing.T) {
+	gpus := []deviceinfo.GPUInfo{
+		{
+			DeviceInfo: dcgm.Device{GPU: 0, UUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000"},
+			MigEnabled: true,
+			GPUInstances: []deviceinfo.GPUInstanceInfo{
+				{Info: dcgm.MigEntityInfo{NvmlInstanceId: 1}, ProfileName: "1g.10gb"},
+				{Info: dcgm.MigEntityInfo{NvmlInstanceId: 2}, ProfileName: "1g.10gb"},
+				{Info: dcgm.MigEntityInfo{NvmlInstanceId: 3}, ProfileName: "2g.20gb"},
+			},
+		},
+		{
+			DeviceInfo: dcgm.Device{GPU: 1, UUID: "GPU-bbbbbbbb-0000-0000-0000-000000000000"},
+		},
+		{
+			// GPUs without metrics are not exported
+			DeviceInfo: dcgm.Device{GPU: 2, UUID: "GPU-cccccccc-0000-0000-0000-000000000000"},
+		},
+	}
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUs().Return(gpus).AnyTimes()
+
+	counter := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_GPU_UTIL, FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{
+				GPU: "0", GPUUUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000", GPUInstanceID: "1", MigProfile: "1g.10gb",
+				GPUModelName: "NVIDIA A100-SXM4-80GB", Hostname: "node1", Value: "42", Counter: counter,
+				Attributes: map[string]string{HpcJobAttribute: "1234"},
+			},
+			{
+				GPU: "1", GPUUUID: "GPU-bbbbbbbb-0000-0000-0000-000000000000",
+				GPUModelName: "NVIDIA A100-SXM4-80GB", Hostname: "node1", Value: "87", Counter: counter,
+			},
+		},
+	}
+
+	transformations, err := GetTransformations(&appconfig.Config{EnableMIGInstanceCount: true})
+	require.NoError(t, err)
+	require.Len(t, transformations, 1)
+	require.NoError(t, transformations[0].Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 2)
+	require.Len(t, metrics[migInstanceCountCounter], 2)
+
+	migGPU := metrics[migInstanceCountCounter][0]
+	assert.Equal(t, "3", migGPU.Value)
+	assert.Equal(t, "0", migGPU.GPU)
+	assert.Equal(t, "nvidia0", migGPU.GPUDevice)
+	assert.Equal(t, "GPU-aaaaaaaa-0000-0000-0000-000000000000", migGPU.GPUUUID)
+	assert.Equal(t, "node1", migGPU.Hostname)
+	assert.Empty(t, migGPU.MigProfile, "the count is a series of the physical GPU")
+	assert.Empty(t, migGPU.GPUInstanceID)
+	assert.Empty(t, migGPU.Attributes, "the attributes of the MIG instance are not copied")
+
+	gpu := metrics[migInstanceCountCounter][1]
+	assert.Equal(t, "0", gpu.Value)
+	assert.Equal(t, "1", gpu.GPU)
+	assert.Equal(t, "GPU-bbbbbbbb-0000-0000-0000-000000000000", gpu.GPUUUID)
+}
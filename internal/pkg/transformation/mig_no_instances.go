@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// migNoInstancesCounter is the sentinel series of a GPU in MIG mode without instances, which
+// reports no per-instance metrics
+var migNoInstancesCounter = counters.Counter{
+	FieldName:  "dcgm_gpu_mig_enabled_no_instances",
+	PromType:   "gauge",
+	Help:       "1 for a GPU in MIG mode without any compute instance, so without per-instance metrics.",
+	Multiplier: 1,
+}
+
+// migNoInstancesMarker emits the sentinel series of the physical GPUs in MIG mode whose
+// instances, as known to the device provider, have no compute instance, so that the missing
+// per-instance metrics are visible rather than a gap.
+type migNoInstancesMarker struct {
+	Config *appconfig.Config
+}
+
+func newMIGNoInstancesMarker(c *appconfig.Config) *migNoInstancesMarker {
+	slog.Info("MIG GPUs without instances are marked")
+	return &migNoInstancesMarker{
+		Config: c,
+	}
+}
+
+func (p *migNoInstancesMarker) Name() string {
+	return "migNoInstancesMarker"
+}
+
+func (p *migNoInstancesMarker) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if sysInfo == nil || sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	gpuMetrics := physicalGPUMetrics(metrics)
+
+	var markMetrics []collector.Metric
+	for _, gpu := range sysInfo.GPUs() {
+		metric, ok := gpuMetrics[gpu.DeviceInfo.UUID]
+		if !ok || !gpu.MigEnabled || hasComputeInstances(gpu) {
+			continue
+		}
+		markMetrics = append(markMetrics, gpuSeries(metric, gpu, migNoInstancesCounter, "1"))
+	}
+
+	if len(markMetrics) > 0 {
+		metrics[migNoInstancesCounter] = markMetrics
+	}
+
+	return nil
+}
+
+// hasComputeInstances tells whether any instance of the GPU has a compute instance
+func hasComputeInstances(gpu deviceinfo.GPUInfo) bool {
+	return slices.ContainsFunc(gpu.GPUInstances, func(instance deviceinfo.GPUInstanceInfo) bool {
+		return len(instance.ComputeInstances) > 0
+	})
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+func TestMIGNoInstancesMarkerProcess(t *testing.T) {
+	gpus := []deviceinfo.GPUInfo{
+		{
+			DeviceInfo: dcgm.Device{GPU: 0, UUID: "GPU-aaaaaaaa-0000-0000-0000-000000000000"},
+			MigEnabled: true,
+		},
+		{
+			// a GPU instance without a compute instance runs nothing
+			DeviceInfo: dcgm.Device{GPU: 1, UUID: "GPU-bbbbbbbb-0000-0000-0000-000000000000"},
+			MigEnabled: true,
+			GPUInstances: []deviceinfo.GPUInstanceInfo{
+				{Info: dcgm.MigEntityInfo{NvmlInstanceId: 1}, ProfileName: "1g.10gb"},
+			},
+		},
+		{
+			DeviceInfo: dcgm.Device{GPU: 2, UUID: "GPU-cccccccc-0000-0000-0000-000000000000"},
+			MigEnabled: true,
+			GPUInstances: []deviceinfo.GPUInstanceInfo{
+				{
+					Info: dcgm.MigEntityInfo{NvmlInstanceId: 1}, ProfileName: "1g.10gb",
+					ComputeInstances: []deviceinfo.ComputeInstanceInfo{{}},
+				},
+			},
+		},
+		{
+			DeviceInfo: dcgm.Device{GPU: 3, UUID: "GPU-dddddddd-0000-0000-0000-000000000000"},
+		},
+	}
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUs().Return(gpus).AnyTimes()
+
+	counter := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_GPU_TEMP, FieldName: "DCGM_FI_DEV_GPU_TEMP", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{counter: {}}
+	for _, gpu := range gpus {
+		metrics[counter] = append(metrics[counter], collector.Metric{
+			GPU: "9", GPUUUID: gpu.DeviceInfo.UUID, Hostname: "node1", Value: "40", Counter: counter,
+			Attributes: map[string]string{HpcJobAttribute: "1234"},
+		})
+	}
+
+	transformations, err := GetTransformations(&appconfig.Config{EnableMIGNoInstances: true})
+	require.NoError(t, err)
+	require.Len(t, transformations, 1)
+	require.NoError(t, transformations[0].Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 4)
+	marks := metrics[migNoInstancesCounter]
+	require.Len(t, marks, 2, "only the MIG GPUs without compute instances are marked")
+
+	assert.Equal(t, "dcgm_gpu_mig_enabled_no_instances", marks[0].Counter.FieldName)
+	assert.Equal(t, "1", marks[0].Value)
+	assert.Equal(t, "0", marks[0].GPU, "the index of the GPU is the current one")
+	assert.Equal(t, "nvidia0", marks[0].GPUDevice)
+	assert.Equal(t, "GPU-aaaaaaaa-0000-0000-0000-000000000000", marks[0].GPUUUID)
+	assert.Equal(t, "node1", marks[0].Hostname)
+	assert.Empty(t, marks[0].Attributes)
+
+	assert.Equal(t, "1", marks[1].GPU)
+}
+
+func TestMIGNoInstancesMarkerDisabled(t *testing.T) {
+	transformations, err := GetTransformations(&appconfig.Config{})
+	require.NoError(t, err)
+	for _, transformation := range transformations {
+		assert.NotEqual(t, "migNoInstancesMarker", transformation.Name())
+	}
+}
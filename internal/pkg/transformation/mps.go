@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+// mpsMapper attributes the GPUs shared by MPS (Multi-Process Service) clients to the jobs of the
+// client processes. It joins two files dumped by the prolog: the jobs of the processes, with a
+// "pid jobid [userid]" line per process, and the processes running on each GPU, as listed by
+// nvidia-smi pmon. The metrics of a GPU are labeled with each of the jobs of its processes.
+type mpsMapper struct {
+	keyedJobMapper
+}
+
+func newMPSMapper(c *appconfig.Config) *mpsMapper {
+	slog.Info(fmt.Sprintf("MPS job mapping is enabled and joins the %q process jobs with the %q GPU processes",
+		c.HPCMPSPIDFile, c.HPCMPSPmonFile))
+	p := &mpsMapper{}
+	p.init(c)
+	return p
+}
+
+func (p *mpsMapper) Name() string {
+	return "mpsMapper"
+}
+
+func (p *mpsMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	// the files are rewritten by the prolog, they are read again at most once per refresh interval
+	if p.gpuToJobMap == nil || now.Sub(p.fetchedAt) >= p.Config.HPCMPSRefresh {
+		gpuToJobMap, err := p.readMPSJobMap()
+		if err != nil {
+			p.warnFailure(now, "Unable to read the MPS job mapping files. Ignoring.",
+				slog.String(logging.ErrorKey, err.Error()))
+			gpuToJobMap = map[string][]string{}
+		}
+		p.gpuToJobMap = gpuToJobMap
+		p.fetchedAt = now
+	}
+
+	applyJobMapping(metrics, sysInfo, p.jobMapping(mappingSourceMPS, p.gpuToJobMap))
+
+	return nil
+}
+
+// readMPSJobMap returns the jobs of the processes running on each GPU, by GPU index. A job with
+// several processes on a GPU is listed once, the processes without a job are skipped.
+func (p *mpsMapper) readMPSJobMap() (map[string][]string, error) {
+	pidLines, err := readFile(p.Config.HPCMPSPIDFile, p.Config.HPCMaxMappingFileBytes)
+	if err != nil {
+		return nil, err
+	}
+	pmonLines, err := readFile(p.Config.HPCMPSPmonFile, p.Config.HPCMaxMappingFileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pidJobs := map[string]string{}
+	for _, line := range pidLines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			continue
+		}
+		// the jobs are in the format of the mapping files: "jobid" or "jobid userid"
+		pidJobs[fields[0]] = strings.Join(fields[1:], " ")
+	}
+
+	gpuToJobMap := map[string][]string{}
+	for gpu, pids := range parsePmonProcesses(pmonLines) {
+		for _, pid := range pids {
+			job, ok := pidJobs[pid]
+			if !ok || slices.Contains(gpuToJobMap[gpu], job) {
+				continue
+			}
+			gpuToJobMap[gpu] = append(gpuToJobMap[gpu], job)
+		}
+	}
+
+	slog.Debug(fmt.Sprintf("MPS GPU to job mapping: %+v", gpuToJobMap))
+
+	return gpuToJobMap, nil
+}
+
+// parsePmonProcesses returns the processes running on each GPU, by GPU index, from the output of
+// nvidia-smi pmon:
+//
+//	# gpu         pid   type     sm    mem    enc    dec    command
+//	# Idx           #    C/G      %      %      %      %    name
+//	    0       12345     C      40     10      -      -    python
+//
+// The columns are located by the header, as the drivers add some, and GPUs without processes,
+// listed with a "-" pid, are skipped.
+func parsePmonProcesses(lines []string) map[string][]string {
+	gpuColumn, pidColumn := 0, 1
+	processes := map[string][]string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "#") {
+			header := strings.Fields(strings.TrimPrefix(line, "#"))
+			if gpu, pid := slices.Index(header, "gpu"), slices.Index(header, "pid"); gpu >= 0 && pid >= 0 {
+				gpuColumn, pidColumn = gpu, pid
+			}
+			continue
+		}
+		if len(fields) <= max(gpuColumn, pidColumn) {
+			continue
+		}
+		gpu, pid := fields[gpuColumn], fields[pidColumn]
+		if _, err := strconv.Atoi(gpu); err != nil {
+			continue
+		}
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		processes[gpu] = append(processes[gpu], pid)
+	}
+	return processes
+}
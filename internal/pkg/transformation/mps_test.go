@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestMPSMapperProcess(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pids")
+	require.NoError(t, sysOS.WriteFile(pidFile, []byte("1001 job1 1000\n1002 job2\n1003 job1 1000\n"), 0o644))
+	pmonFile := filepath.Join(dir, "pmon")
+	require.NoError(t, sysOS.WriteFile(pmonFile, []byte(`# gpu         pid   type     sm    mem    enc    dec    command
+# Idx           #    C/G      %      %      %      %    name
+    0        1001     C      40     10      -      -    python
+    0        1002     C      20      5      -      -    python
+    0        1003     C      10      5      -      -    python
+    1           -     -       -      -      -      -    -
+`), 0o644))
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, "GPU-0", "GPU-1")
+
+	mapper := newMPSMapper(&appconfig.Config{HPCMPSPIDFile: pidFile, HPCMPSPmonFile: pmonFile})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 3, "both jobs of GPU 0 are attributed, once each")
+	assert.Equal(t, "0", metrics[counter][0].GPU)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "1000", metrics[counter][0].Attributes[HpcUserAttribute])
+	assert.Equal(t, "mps", metrics[counter][0].Attributes[MappingSourceAttribute])
+	assert.Equal(t, "0", metrics[counter][1].GPU)
+	assert.Equal(t, "job2", metrics[counter][1].Attributes[HpcJobAttribute])
+	assert.NotContains(t, metrics[counter][1].Attributes, HpcUserAttribute)
+	assert.Equal(t, "1", metrics[counter][2].GPU)
+	assert.NotContains(t, metrics[counter][2].Attributes, HpcJobAttribute)
+}
+
+func Test_parsePmonProcesses(t *testing.T) {
+	lines := []string{
+		"# gpu        pid  type    sm   mem   enc   dec   jpg   ofa  command",
+		"    0       2001     C    40    10     -     -     -     -  python",
+		"    1          -     -     -     -     -     -     -     -  -",
+		"# gpu  type  pid  command",
+		"    2     C 2002  python",
+	}
+	assert.Equal(t, map[string][]string{"0": {"2001"}, "2": {"2002"}}, parsePmonProcesses(lines))
+}
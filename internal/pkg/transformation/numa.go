@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+const sysfsPCIDevices = "/sys/bus/pci/devices"
+
+// numaMapper sets the NUMA node of the GPU on GPU metrics, as reported by the PCI topology.
+type numaMapper struct {
+	Config *appconfig.Config
+
+	// pciDevices is the sysfs directory of the PCI devices
+	pciDevices string
+
+	mu sync.Mutex
+	// nodes are the NUMA nodes by PCI bus id, empty when unknown
+	nodes map[string]string
+}
+
+func newNUMAMapper(c *appconfig.Config) *numaMapper {
+	slog.Info("NUMA node label is enabled")
+	return &numaMapper{
+		Config:     c,
+		pciDevices: sysfsPCIDevices,
+		nodes:      map[string]string{},
+	}
+}
+
+func (p *numaMapper) Name() string {
+	return "numaMapper"
+}
+
+func (p *numaMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if sysInfo != nil && sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for counter := range metrics {
+		for i, metric := range metrics[counter] {
+			busID := metric.GPUPCIBusID
+			if busID == "" {
+				busID = gpuPCIBusID(sysInfo, metric.GPU)
+			}
+			if busID == "" {
+				continue
+			}
+			node, ok := p.nodes[busID]
+			if !ok {
+				node = p.readNUMANode(busID)
+				p.nodes[busID] = node
+			}
+			if node == "" {
+				continue
+			}
+			if metric.Attributes == nil {
+				metrics[counter][i].Attributes = map[string]string{}
+			}
+			metrics[counter][i].Attributes[NUMANodeAttribute] = node
+		}
+	}
+
+	return nil
+}
+
+// readNUMANode returns the NUMA node of the PCI device, or an empty string when the device has
+// no NUMA affinity, e.g. on single node systems, or it can't be determined.
+func (p *numaMapper) readNUMANode(busID string) string {
+	file, err := os.Open(path.Join(p.pciDevices, sysfsBusID(busID), "numa_node"))
+	if err != nil {
+		slog.Debug(fmt.Sprintf("NUMA mapper: unable to read the NUMA node of the %q device: %v", busID, err))
+		return ""
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return ""
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || node < 0 {
+		return ""
+	}
+	return strconv.Itoa(node)
+}
+
+// sysfsBusID returns the PCI bus id as named in sysfs: DCGM reports a 32-bit domain,
+// e.g. 00000000:3B:00.0, where sysfs uses a 16-bit one in lower case, e.g. 0000:3b:00.0.
+func sysfsBusID(busID string) string {
+	busID = strings.ToLower(busID)
+	if domain, rest, found := strings.Cut(busID, ":"); found && len(domain) == 8 && strings.HasPrefix(domain, "0000") {
+		return domain[4:] + ":" + rest
+	}
+	return busID
+}
+
+func gpuPCIBusID(sysInfo deviceinfo.Provider, gpu string) string {
+	if sysInfo == nil {
+		return ""
+	}
+	gpuID, err := strconv.ParseUint(gpu, 10, 32)
+	if err != nil || uint(gpuID) >= sysInfo.GPUCount() {
+		return ""
+	}
+	return sysInfo.GPU(uint(gpuID)).DeviceInfo.PCI.BusID
+}
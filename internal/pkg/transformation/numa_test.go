@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+func TestNUMAMapperProcess(t *testing.T) {
+	pciDevices := t.TempDir()
+	for busID, node := range map[string]string{"0000:3b:00.0": "1\n", "0000:86:00.0": "-1\n"} {
+		require.NoError(t, sysOS.MkdirAll(filepath.Join(pciDevices, busID), 0o755))
+		require.NoError(t, sysOS.WriteFile(filepath.Join(pciDevices, busID, "numa_node"), []byte(node), 0o644))
+	}
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(3)).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(0)).Return(deviceinfo.GPUInfo{
+		DeviceInfo: dcgm.Device{PCI: dcgm.PCIInfo{BusID: "00000000:3B:00.0"}},
+	}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(1)).Return(deviceinfo.GPUInfo{
+		DeviceInfo: dcgm.Device{PCI: dcgm.PCIInfo{BusID: "00000000:86:00.0"}},
+	}).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(uint(2)).Return(deviceinfo.GPUInfo{
+		DeviceInfo: dcgm.Device{PCI: dcgm.PCIInfo{BusID: "00000000:AF:00.0"}},
+	}).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		counter: {
+			{GPU: "0", Value: "42", Counter: counter},
+			{GPU: "0", GPUInstanceID: "7", MigProfile: "1g.10gb", Value: "21", Counter: counter},
+			{GPU: "1", Value: "451", Counter: counter},
+			{GPU: "2", Value: "100", Counter: counter},
+		},
+	}
+
+	mapper := newNUMAMapper(&appconfig.Config{EnableNUMANodeLabel: true})
+	mapper.pciDevices = pciDevices
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 4)
+	assert.Equal(t, "1", metrics[counter][0].Attributes[NUMANodeAttribute])
+	assert.Equal(t, "1", metrics[counter][1].Attributes[NUMANodeAttribute], "MIG instances are on the NUMA node of their GPU")
+	assert.NotContains(t, metrics[counter][2].Attributes, NUMANodeAttribute, "the GPU has no NUMA affinity")
+	assert.NotContains(t, metrics[counter][3].Attributes, NUMANodeAttribute, "the NUMA node of the GPU is unknown")
+}
+
+func TestSysfsBusID(t *testing.T) {
+	assert.Equal(t, "0000:3b:00.0", sysfsBusID("00000000:3B:00.0"))
+	assert.Equal(t, "0001:3b:00.0", sysfsBusID("0001:3B:00.0"))
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	sysOS "os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+// procGPUDevicePrefix is the prefix of the /dev/nvidia<minor> devices opened by the GPU processes
+const procGPUDevicePrefix = "/dev/nvidia"
+
+// procMapper attributes the GPUs to the jobs of the processes using them, as a last resort without
+// any scheduler integration. The procfs is scanned for the processes with a /dev/nvidia<minor>
+// device open; the job of a process is extracted from its cgroup by a regular expression, e.g.
+// job_([0-9]+) for the cgroups of Slurm, and its user is the owner of the process. Scanning is
+// heavyweight, so the processes are scanned at most once per scan interval. MIG instances are not
+// mapped, as their processes open the device of their GPU. The metrics already mapped by another
+// mapper are left to it.
+type procMapper struct {
+	jobMapperBase
+}
+
+func newProcMapper(c *appconfig.Config) *procMapper {
+	slog.Info(fmt.Sprintf("Process job mapping is enabled and scans %q for GPU processes every %s",
+		c.HPCProcRoot, c.HPCProcScanInterval))
+	p := &procMapper{}
+	p.init(c)
+	return p
+}
+
+func (p *procMapper) Name() string {
+	return "procMapper"
+}
+
+func (p *procMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	scan := sysInfo != nil && sysInfo.InfoType() == dcgm.FE_GPU &&
+		(p.gpuToJobMap == nil || now.Sub(p.fetchedAt) >= p.Config.HPCProcScanInterval)
+	if scan {
+		gpuToJobMap, err := p.scanProcesses(sysInfo)
+		if err != nil {
+			p.warnFailure(now, "Unable to scan the GPU processes. Ignoring.",
+				slog.String(logging.ErrorKey, err.Error()))
+			gpuToJobMap = map[string][]string{}
+		}
+		p.gpuToJobMap = gpuToJobMap
+		p.fetchedAt = now
+	}
+
+	applyJobMapping(metrics, sysInfo, p.jobMapping(mappingSourceProc, p.gpuToJobMap))
+
+	return nil
+}
+
+// scanProcesses returns the jobs of the processes using each GPU, by GPU UUID. A job with several
+// processes on a GPU is listed once, the processes without a job are skipped. The processes whose
+// open files can't be read, e.g. with procfs mounted with hidepid, are skipped too, and an error
+// is returned when none can be read.
+func (p *procMapper) scanProcesses(sysInfo deviceinfo.Provider) (map[string][]string, error) {
+	root := p.Config.HPCProcRoot
+	minors := gpuMinors(path.Join(root, "driver", "nvidia", "gpus"), sysInfo)
+	if len(minors) == 0 {
+		return nil, fmt.Errorf("the device minor numbers of the GPUs are unknown to %s", root)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	gpuToJobMap := map[string][]string{}
+	scanned, restricted := 0, 0
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		dir := path.Join(root, entry.Name())
+		gpus, err := processGPUs(dir, minors)
+		if err != nil {
+			// the processes that exited since are skipped as well
+			if errors.Is(err, fs.ErrPermission) {
+				restricted++
+			}
+			continue
+		}
+		scanned++
+		if len(gpus) == 0 {
+			continue
+		}
+		job, ok := p.processJob(dir)
+		if !ok {
+			continue
+		}
+		for _, gpu := range gpus {
+			if !slices.Contains(gpuToJobMap[gpu], job) {
+				gpuToJobMap[gpu] = append(gpuToJobMap[gpu], job)
+			}
+		}
+	}
+	if scanned == 0 && restricted > 0 {
+		return nil, fmt.Errorf("the open files of the processes of %s can't be read: %w", root, fs.ErrPermission)
+	}
+	if restricted > 0 {
+		slog.Debug(fmt.Sprintf("Process mapper: the open files of %d processes can't be read", restricted))
+	}
+
+	slog.Debug(fmt.Sprintf("Process GPU to job mapping: %+v", gpuToJobMap))
+
+	return gpuToJobMap, nil
+}
+
+// gpuMinors returns the UUIDs of the GPUs by the minor number of their /dev/nvidia<minor> device
+func gpuMinors(driverGPUs string, sysInfo deviceinfo.Provider) map[string]string {
+	minors := map[string]string{}
+	for _, gpu := range sysInfo.GPUs() {
+		if minor := readDeviceMinor(driverGPUs, gpu.DeviceInfo.PCI.BusID); minor != "" {
+			minors[minor] = gpu.DeviceInfo.UUID
+		}
+	}
+	return minors
+}
+
+// processGPUs returns the UUIDs of the GPUs whose device the process of the procfs directory has
+// open
+func processGPUs(dir string, minors map[string]string) ([]string, error) {
+	fds, err := os.ReadDir(path.Join(dir, "fd"))
+	if err != nil {
+		return nil, err
+	}
+	var gpus []string
+	for _, fd := range fds {
+		target, err := sysOS.Readlink(path.Join(dir, "fd", fd.Name()))
+		if err != nil || !strings.HasPrefix(target, procGPUDevicePrefix) {
+			continue
+		}
+		gpu, ok := minors[strings.TrimPrefix(target, procGPUDevicePrefix)]
+		if ok && !slices.Contains(gpus, gpu) {
+			gpus = append(gpus, gpu)
+		}
+	}
+	return gpus, nil
+}
+
+// processJob returns the job of the process of the procfs directory in the format of the mapping
+// files, "jobid userid", the user being the real user id of the process. It is not found when the
+// job regular expression doesn't match the cgroup of the process.
+func (p *procMapper) processJob(dir string) (string, bool) {
+	cgroups, err := readFile(path.Join(dir, "cgroup"), 0)
+	if err != nil {
+		return "", false
+	}
+	job := ""
+	for _, cgroup := range cgroups {
+		if match := p.Config.HPCProcJobRegex.FindStringSubmatch(cgroup); len(match) > 1 && match[1] != "" {
+			job = match[1]
+			break
+		}
+	}
+	if job == "" {
+		return "", false
+	}
+
+	status, err := readFile(path.Join(dir, "status"), 0)
+	if err != nil {
+		return job, true
+	}
+	for _, line := range status {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "Uid:" {
+			return job + " " + fields[1], true
+		}
+	}
+	return job, true
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	sysOS "os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+func writeFakeProcess(t *testing.T, root, pid, cgroup, uid string, devices ...string) {
+	t.Helper()
+	dir := filepath.Join(root, pid)
+	require.NoError(t, sysOS.MkdirAll(filepath.Join(dir, "fd"), 0o755))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "cgroup"), []byte(cgroup+"\n"), 0o644))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "status"),
+		[]byte("Name:\tpython\nUid:\t"+uid+"\t"+uid+"\t"+uid+"\t"+uid+"\n"), 0o644))
+	for i, device := range devices {
+		require.NoError(t, sysOS.Symlink(device, filepath.Join(dir, "fd", strconv.Itoa(3+i))))
+	}
+}
+
+func TestProcMapperProcess(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, sysOS.MkdirAll(filepath.Join(root, "driver", "nvidia", "gpus", "0000:3b:00.0"), 0o755))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(root, "driver", "nvidia", "gpus", "0000:3b:00.0", "information"),
+		[]byte("Model: \t\t NVIDIA A100-SXM4-80GB\nDevice Minor: \t 2\n"), 0o644))
+	writeFakeProcess(t, root, "100", "0::/system.slice/slurmstepd.scope/job_1234/step_0/user/task_0", "1000",
+		"/dev/nvidiactl", "/dev/nvidia2")
+	writeFakeProcess(t, root, "101", "0::/system.slice/slurmstepd.scope/job_1234/step_0/user/task_1", "1000",
+		"/dev/nvidia2")
+	writeFakeProcess(t, root, "200", "0::/system.slice/slurmstepd.scope/job_5678/step_0/user/task_0", "1001",
+		"/dev/null")
+	writeFakeProcess(t, root, "300", "0::/user.slice/user-1002.slice/session-1.scope", "1002", "/dev/nvidia2")
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	gpus := []deviceinfo.GPUInfo{
+		{DeviceInfo: dcgm.Device{GPU: 0, UUID: "GPU-0", PCI: dcgm.PCIInfo{BusID: "00000000:3B:00.0"}}},
+		{DeviceInfo: dcgm.Device{GPU: 1, UUID: "GPU-1", PCI: dcgm.PCIInfo{BusID: "00000000:86:00.0"}}},
+	}
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(len(gpus))).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUs().Return(gpus).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).DoAndReturn(func(i uint) deviceinfo.GPUInfo {
+		return gpus[i]
+	}).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, "GPU-0", "GPU-1") }
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mapper := newProcMapper(&appconfig.Config{
+		HPCProcJobRegex:     regexp.MustCompile(`job_([0-9]+)`),
+		HPCProcRoot:         root,
+		HPCProcScanInterval: time.Minute,
+	})
+	mapper.now = func() time.Time { return now }
+
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+	require.Len(t, metrics[counter], 2)
+	assert.Equal(t, map[string]string{
+		HpcJobAttribute:        "1234",
+		HpcUserAttribute:       "1000",
+		MappingSourceAttribute: "proc",
+	}, metrics[counter][0].Attributes, "the processes of the job are attributed once, to their owner")
+	assert.Empty(t, metrics[counter][1].Attributes, "no process uses the GPU")
+
+	// the processes are not scanned again within the scan interval
+	require.NoError(t, sysOS.RemoveAll(filepath.Join(root, "100")))
+	require.NoError(t, sysOS.RemoveAll(filepath.Join(root, "101")))
+	now = now.Add(30 * time.Second)
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+	assert.Equal(t, "1234", metrics[counter][0].Attributes[HpcJobAttribute])
+
+	now = now.Add(time.Minute)
+	metrics = newMetrics()
+	require.NoError(t, mapper.Process(metrics, mockDeviceInfo))
+	assert.Empty(t, metrics[counter][0].Attributes, "the job has exited")
+}
+
+func TestProcMapperAfterFileMapper(t *testing.T) {
+	root := t.TempDir()
+	for bus, minor := range map[string]string{"0000:3b:00.0": "0", "0000:86:00.0": "1"} {
+		dir := filepath.Join(root, "driver", "nvidia", "gpus", bus)
+		require.NoError(t, sysOS.MkdirAll(dir, 0o755))
+		require.NoError(t, sysOS.WriteFile(filepath.Join(dir, "information"), []byte("Device Minor: \t "+minor+"\n"), 0o644))
+	}
+	writeFakeProcess(t, root, "100", "0::/job_1234", "1000", "/dev/nvidia0")
+	writeFakeProcess(t, root, "200", "0::/job_5678", "1001", "/dev/nvidia1")
+
+	mappingDir := t.TempDir()
+	require.NoError(t, sysOS.WriteFile(filepath.Join(mappingDir, "0"), []byte("job-a\n"), 0o644))
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	gpus := []deviceinfo.GPUInfo{
+		{DeviceInfo: dcgm.Device{GPU: 0, UUID: "GPU-0", PCI: dcgm.PCIInfo{BusID: "00000000:3B:00.0"}}},
+		{DeviceInfo: dcgm.Device{GPU: 1, UUID: "GPU-1", PCI: dcgm.PCIInfo{BusID: "00000000:86:00.0"}}},
+	}
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(len(gpus))).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUs().Return(gpus).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).DoAndReturn(func(i uint) deviceinfo.GPUInfo {
+		return gpus[i]
+	}).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := newGPUMetrics(counter, "GPU-0", "GPU-1", "GPU-2")
+
+	config := &appconfig.Config{
+		HPCJobMappingDir:  mappingDir,
+		HPCJobPlaceholder: "none",
+		HPCProcJobRegex:   regexp.MustCompile(`job_([0-9]+)`),
+		HPCProcRoot:       root,
+	}
+	require.NoError(t, newHPCMapper(config).Process(metrics, mockDeviceInfo))
+	require.NoError(t, newProcMapper(config).Process(metrics, mockDeviceInfo))
+
+	var got []string
+	for _, metric := range metrics[counter] {
+		got = append(got, metric.GPU+":"+metric.Attributes[HpcJobAttribute]+":"+metric.Attributes[MappingSourceAttribute])
+	}
+	assert.ElementsMatch(t, []string{"0:job-a:file", "1:5678:proc", "2:none:"}, got,
+		"the GPUs mapped by the file mapper are left to it, neither duplicated nor given the placeholder")
+}
+
+func TestProcMapperRestricted(t *testing.T) {
+	if sysOS.Geteuid() == 0 {
+		t.Skip("the permissions are not enforced for root")
+	}
+	root := t.TempDir()
+	require.NoError(t, sysOS.MkdirAll(filepath.Join(root, "driver", "nvidia", "gpus", "0000:3b:00.0"), 0o755))
+	require.NoError(t, sysOS.WriteFile(filepath.Join(root, "driver", "nvidia", "gpus", "0000:3b:00.0", "information"),
+		[]byte("Device Minor: \t 0\n"), 0o644))
+	writeFakeProcess(t, root, "100", "0::/job_1234", "1000", "/dev/nvidia0")
+	require.NoError(t, sysOS.Chmod(filepath.Join(root, "100", "fd"), 0o000))
+	t.Cleanup(func() { _ = sysOS.Chmod(filepath.Join(root, "100", "fd"), 0o755) })
+
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().GPUs().Return([]deviceinfo.GPUInfo{
+		{DeviceInfo: dcgm.Device{UUID: "GPU-0", PCI: dcgm.PCIInfo{BusID: "00000000:3B:00.0"}}},
+	}).AnyTimes()
+
+	mapper := newProcMapper(&appconfig.Config{HPCProcJobRegex: regexp.MustCompile(`job_([0-9]+)`), HPCProcRoot: root})
+	_, err := mapper.scanProcesses(mockDeviceInfo)
+	assert.ErrorContains(t, err, "can't be read")
+}
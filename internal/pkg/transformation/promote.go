@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// powerLimitField is the field promoted to the PowerLimitAttribute
+const powerLimitField = "DCGM_FI_DEV_ENFORCED_POWER_LIMIT"
+
+// throttleReasonFields are the names of the clock event reasons bitmask, decoded into the
+// ThrottleReasonAttribute
+var throttleReasonFields = []string{"DCGM_FI_DEV_CLOCKS_EVENT_REASONS", "DCGM_FI_DEV_CLOCK_THROTTLE_REASONS"}
+
+// healthStatuses are the HealthAttribute values of the DCGM health results
+var healthStatuses = map[dcgm.HealthResult]string{
+	dcgm.DCGM_HEALTH_RESULT_PASS: "PASS",
+	dcgm.DCGM_HEALTH_RESULT_WARN: "WARN",
+	dcgm.DCGM_HEALTH_RESULT_FAIL: "FAIL",
+}
+
+// fieldPromoter turns metadata fields, e.g. the compute mode, into attributes of the other
+// series of the same entity instead of rendering them as series of their own.
+type fieldPromoter struct {
+	Config *appconfig.Config
+
+	// attributes are the attributes of the promoted fields, by field name
+	attributes map[string]string
+}
+
+func newFieldPromoter(c *appconfig.Config) *fieldPromoter {
+	attributes := make(map[string]string, len(c.PromotedFields)+1)
+	for _, field := range c.PromotedFields {
+		attributes[field] = field
+	}
+	if c.EnablePowerLimitLabel {
+		attributes[powerLimitField] = PowerLimitAttribute
+	}
+	if c.EnableThrottleReasonLabel {
+		for _, field := range throttleReasonFields {
+			attributes[field] = ThrottleReasonAttribute
+		}
+	}
+	slog.Info(fmt.Sprintf("Fields promoted to labels: %v", attributes))
+	return &fieldPromoter{
+		Config:     c,
+		attributes: attributes,
+	}
+}
+
+func (p *fieldPromoter) Name() string {
+	return "fieldPromoter"
+}
+
+func (p *fieldPromoter) Process(metrics collector.MetricsByCounter, _ deviceinfo.Provider) error {
+	// the first pass collects the promoted values of every entity, a GPU value applies to its
+	// MIG instances too, unless they have a value of their own
+	values := map[string]map[string]string{}
+	for counter, counterMetrics := range metrics {
+		attribute, ok := p.attributes[counter.FieldName]
+		if !ok {
+			continue
+		}
+		for _, metric := range counterMetrics {
+			key := promotionKey(metric)
+			if values[key] == nil {
+				values[key] = map[string]string{}
+			}
+			values[key][attribute] = promotedValue(attribute, metric.Value)
+		}
+		delete(metrics, counter)
+	}
+	if p.Config.EnableHealthLabel {
+		p.promoteHealth(metrics, values)
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	for counter := range metrics {
+		if counter.FieldName == counters.DCGMExpGPUHealthStatus {
+			continue
+		}
+		for i, metric := range metrics[counter] {
+			promoted := values[promotionKey(metric)]
+			if metric.GPUInstanceID != "" {
+				promoted = mergedPromotions(values[metric.GPU], promoted)
+			}
+			if len(promoted) == 0 {
+				continue
+			}
+			if metric.Attributes == nil {
+				metric.Attributes = map[string]string{}
+			}
+			maps.Copy(metric.Attributes, promoted)
+			metrics[counter][i] = metric
+		}
+	}
+
+	return nil
+}
+
+// promoteHealth collects the worst health result of each GPU across the health systems of the
+// health status series, which are still rendered. Nothing is collected when the GPU health status
+// collector is not enabled.
+func (p *fieldPromoter) promoteHealth(metrics collector.MetricsByCounter, values map[string]map[string]string) {
+	worst := map[string]dcgm.HealthResult{}
+	for counter, counterMetrics := range metrics {
+		if counter.FieldName != counters.DCGMExpGPUHealthStatus {
+			continue
+		}
+		for _, metric := range counterMetrics {
+			result, err := strconv.Atoi(metric.Value)
+			if _, ok := healthStatuses[dcgm.HealthResult(result)]; err != nil || !ok {
+				continue
+			}
+			key := promotionKey(metric)
+			if current, ok := worst[key]; !ok || dcgm.HealthResult(result) > current {
+				worst[key] = dcgm.HealthResult(result)
+			}
+		}
+	}
+	for key, result := range worst {
+		if values[key] == nil {
+			values[key] = map[string]string{}
+		}
+		values[key][HealthAttribute] = healthStatuses[result]
+	}
+}
+
+// promotedValue is the attribute value of a promoted field value; the power limit, a float, is
+// rendered in its shortest form, e.g. 300 rather than 300.000000, and the throttle reasons bitmask
+// as the comma-joined names of its reasons, e.g. power_cap,sw_thermal, or none.
+func promotedValue(attribute, value string) string {
+	switch attribute {
+	case PowerLimitAttribute:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	case ThrottleReasonAttribute:
+		if bitmask, err := strconv.ParseInt(value, 10, 64); err == nil {
+			if reasons := collector.ClockEventReasons(bitmask); len(reasons) > 0 {
+				return strings.Join(reasons, ",")
+			}
+			return "none"
+		}
+	}
+	return value
+}
+
+// promotionKey is the GPU of the metric, or the GPU and the instance for MIG instances
+func promotionKey(metric collector.Metric) string {
+	if metric.GPUInstanceID != "" {
+		return metric.GPU + "." + metric.GPUInstanceID
+	}
+	return metric.GPU
+}
+
+func mergedPromotions(gpu, instance map[string]string) map[string]string {
+	if len(gpu) == 0 {
+		return instance
+	}
+	merged := maps.Clone(gpu)
+	maps.Copy(merged, instance)
+	return merged
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transformation
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestFieldPromoterProcess(t *testing.T) {
+	computeModeCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_COMPUTE_MODE,
+		FieldName: "DCGM_FI_DEV_COMPUTE_MODE",
+		PromType:  "gauge",
+	}
+	tempCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_TEMP,
+		FieldName: "DCGM_FI_DEV_GPU_TEMP",
+		PromType:  "gauge",
+	}
+	utilCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_UTIL,
+		FieldName: "DCGM_FI_DEV_GPU_UTIL",
+		PromType:  "gauge",
+	}
+
+	metrics := collector.MetricsByCounter{
+		computeModeCounter: {
+			{GPU: "0", Value: "0", Counter: computeModeCounter, Attributes: map[string]string{}},
+			{GPU: "1", Value: "3", Counter: computeModeCounter, Attributes: map[string]string{}},
+		},
+		tempCounter: {
+			{GPU: "0", Value: "40", Counter: tempCounter, Attributes: map[string]string{}},
+			{GPU: "1", Value: "45", Counter: tempCounter, Attributes: map[string]string{}},
+			{GPU: "2", Value: "50", Counter: tempCounter, Attributes: map[string]string{}},
+		},
+		utilCounter: {
+			{GPU: "1", GPUInstanceID: "7", MigProfile: "1g.10gb", Value: "87", Counter: utilCounter},
+		},
+	}
+
+	mapper := newFieldPromoter(&appconfig.Config{PromotedFields: []string{"DCGM_FI_DEV_COMPUTE_MODE"}})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	assert.NotContains(t, metrics, computeModeCounter, "promoted fields are not rendered as series")
+	require.Len(t, metrics[tempCounter], 3)
+	assert.Equal(t, "0", metrics[tempCounter][0].Attributes["DCGM_FI_DEV_COMPUTE_MODE"])
+	assert.Equal(t, "3", metrics[tempCounter][1].Attributes["DCGM_FI_DEV_COMPUTE_MODE"])
+	assert.NotContains(t, metrics[tempCounter][2].Attributes, "DCGM_FI_DEV_COMPUTE_MODE",
+		"GPUs without a value get no label")
+	require.Len(t, metrics[utilCounter], 1)
+	assert.Equal(t, "3", metrics[utilCounter][0].Attributes["DCGM_FI_DEV_COMPUTE_MODE"],
+		"MIG instances get the value of their GPU")
+}
+
+func TestFieldPromoterPowerLimit(t *testing.T) {
+	powerLimitCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_ENFORCED_POWER_LIMIT,
+		FieldName: "DCGM_FI_DEV_ENFORCED_POWER_LIMIT",
+		PromType:  "gauge",
+	}
+	tempCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_TEMP,
+		FieldName: "DCGM_FI_DEV_GPU_TEMP",
+		PromType:  "gauge",
+	}
+	utilCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_UTIL,
+		FieldName: "DCGM_FI_DEV_GPU_UTIL",
+		PromType:  "gauge",
+	}
+
+	metrics := collector.MetricsByCounter{
+		powerLimitCounter: {
+			{GPU: "0", Value: "300.000000", Counter: powerLimitCounter, Attributes: map[string]string{}},
+			{GPU: "1", Value: "262.500000", Counter: powerLimitCounter, Attributes: map[string]string{}},
+		},
+		tempCounter: {
+			{GPU: "0", Value: "40", Counter: tempCounter, Attributes: map[string]string{}},
+			{GPU: "1", Value: "45", Counter: tempCounter, Attributes: map[string]string{}},
+		},
+		utilCounter: {
+			{GPU: "0", Value: "87", Counter: utilCounter, Attributes: map[string]string{}},
+		},
+	}
+
+	transformations, err := GetTransformations(&appconfig.Config{EnablePowerLimitLabel: true})
+	require.NoError(t, err)
+	require.Len(t, transformations, 1)
+	require.NoError(t, transformations[0].Process(metrics, nil))
+
+	assert.NotContains(t, metrics, powerLimitCounter, "the power limit is not rendered as a series")
+	require.Len(t, metrics[tempCounter], 2)
+	assert.Equal(t, "300", metrics[tempCounter][0].Attributes[PowerLimitAttribute])
+	assert.Equal(t, "262.5", metrics[tempCounter][1].Attributes[PowerLimitAttribute])
+	require.Len(t, metrics[utilCounter], 1)
+	assert.Equal(t, "300", metrics[utilCounter][0].Attributes[PowerLimitAttribute])
+	assert.NotContains(t, metrics[utilCounter][0].Attributes, "DCGM_FI_DEV_ENFORCED_POWER_LIMIT")
+}
+
+func TestFieldPromoterThrottleReason(t *testing.T) {
+	throttleCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_CLOCKS_EVENT_REASONS,
+		FieldName: "DCGM_FI_DEV_CLOCKS_EVENT_REASONS",
+		PromType:  "gauge",
+	}
+	tempCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_TEMP,
+		FieldName: "DCGM_FI_DEV_GPU_TEMP",
+		PromType:  "gauge",
+	}
+
+	metrics := collector.MetricsByCounter{
+		throttleCounter: {
+			// power_cap (0x4) and sw_thermal (0x20)
+			{GPU: "0", Value: "36", Counter: throttleCounter, Attributes: map[string]string{}},
+			{GPU: "1", Value: "0", Counter: throttleCounter, Attributes: map[string]string{}},
+		},
+		tempCounter: {
+			{GPU: "0", Value: "40", Counter: tempCounter, Attributes: map[string]string{}},
+			{GPU: "1", Value: "45", Counter: tempCounter, Attributes: map[string]string{}},
+			{GPU: "2", Value: "50", Counter: tempCounter, Attributes: map[string]string{}},
+		},
+	}
+
+	transformations, err := GetTransformations(&appconfig.Config{EnableThrottleReasonLabel: true})
+	require.NoError(t, err)
+	require.Len(t, transformations, 1)
+	require.NoError(t, transformations[0].Process(metrics, nil))
+
+	assert.NotContains(t, metrics, throttleCounter, "the bitmask is not rendered as a series")
+	require.Len(t, metrics[tempCounter], 3)
+	assert.Equal(t, "power_cap,sw_thermal", metrics[tempCounter][0].Attributes[ThrottleReasonAttribute])
+	assert.Equal(t, "none", metrics[tempCounter][1].Attributes[ThrottleReasonAttribute])
+	assert.NotContains(t, metrics[tempCounter][2].Attributes, ThrottleReasonAttribute, "GPUs without the field are not labeled")
+}
+
+func TestFieldPromoterHealth(t *testing.T) {
+	healthCounter := counters.Counter{
+		FieldID:   dcgm.Short(counters.DCGMGPUHealthStatus),
+		FieldName: counters.DCGMExpGPUHealthStatus,
+		PromType:  "gauge",
+	}
+	tempCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_TEMP,
+		FieldName: "DCGM_FI_DEV_GPU_TEMP",
+		PromType:  "gauge",
+	}
+	utilCounter := counters.Counter{
+		FieldID:   dcgm.DCGM_FI_DEV_GPU_UTIL,
+		FieldName: "DCGM_FI_DEV_GPU_UTIL",
+		PromType:  "gauge",
+	}
+
+	newMetrics := func(withHealth bool) collector.MetricsByCounter {
+		metrics := collector.MetricsByCounter{
+			tempCounter: {
+				{GPU: "0", Value: "40", Counter: tempCounter, Attributes: map[string]string{}},
+				{GPU: "1", Value: "45", Counter: tempCounter, Attributes: map[string]string{}},
+			},
+			utilCounter: {
+				{GPU: "0", GPUInstanceID: "7", MigProfile: "1g.10gb", Value: "87", Counter: utilCounter},
+			},
+		}
+		if withHealth {
+			health := func(gpu, watch string, result dcgm.HealthResult) collector.Metric {
+				return collector.Metric{
+					GPU: gpu, Value: strconv.Itoa(int(result)), Counter: healthCounter,
+					Labels: map[string]string{"health_watch": watch},
+				}
+			}
+			metrics[healthCounter] = []collector.Metric{
+				health("0", "PCIE", dcgm.DCGM_HEALTH_RESULT_PASS),
+				health("0", "THERMAL", dcgm.DCGM_HEALTH_RESULT_WARN),
+				health("1", "PCIE", dcgm.DCGM_HEALTH_RESULT_PASS),
+				health("1", "THERMAL", dcgm.DCGM_HEALTH_RESULT_PASS),
+			}
+		}
+		return metrics
+	}
+
+	transformations, err := GetTransformations(&appconfig.Config{EnableHealthLabel: true})
+	require.NoError(t, err)
+	require.Len(t, transformations, 1)
+
+	metrics := newMetrics(true)
+	require.NoError(t, transformations[0].Process(metrics, nil))
+
+	require.Len(t, metrics[tempCounter], 2)
+	assert.Equal(t, "WARN", metrics[tempCounter][0].Attributes[HealthAttribute])
+	assert.Equal(t, "PASS", metrics[tempCounter][1].Attributes[HealthAttribute])
+	assert.Equal(t, "WARN", metrics[utilCounter][0].Attributes[HealthAttribute],
+		"MIG instances get the health of their GPU")
+	require.Len(t, metrics[healthCounter], 4, "the health status series are still rendered")
+	for _, metric := range metrics[healthCounter] {
+		assert.NotContains(t, metric.Attributes, HealthAttribute)
+	}
+
+	metrics = newMetrics(false)
+	require.NoError(t, transformations[0].Process(metrics, nil))
+	assert.NotContains(t, metrics[tempCounter][0].Attributes, HealthAttribute,
+		"no label when the health is not collected")
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"log/slog"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// serialMapper sets the serial number of the GPU on GPU metrics, including those of its MIG
+// instances, as reported by the device info. GPUs without a known serial are not labeled, and
+// neither are switches, whose device info has no serial.
+type serialMapper struct {
+	Config *appconfig.Config
+}
+
+func newSerialMapper(c *appconfig.Config) *serialMapper {
+	slog.Info("GPU serial number label is enabled")
+	return &serialMapper{
+		Config: c,
+	}
+}
+
+func (p *serialMapper) Name() string {
+	return "serialMapper"
+}
+
+func (p *serialMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if sysInfo == nil || sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	for counter := range metrics {
+		for i, metric := range metrics[counter] {
+			serial := gpuSerial(sysInfo, metric.GPU)
+			if serial == "" {
+				continue
+			}
+			if metric.Attributes == nil {
+				metrics[counter][i].Attributes = map[string]string{}
+			}
+			metrics[counter][i].Attributes[SerialAttribute] = serial
+		}
+	}
+
+	return nil
+}
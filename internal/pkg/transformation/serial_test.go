@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+func TestSerialMapperProcess(t *testing.T) {
+	gpus := []deviceinfo.GPUInfo{
+		{DeviceInfo: dcgm.Device{GPU: 0, Identifiers: dcgm.DeviceIdentifiers{Serial: "1652220012345"}}},
+		// virtualized GPUs may not report a serial
+		{DeviceInfo: dcgm.Device{GPU: 1}},
+	}
+	ctrl := gomock.NewController(t)
+	mockDeviceInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	mockDeviceInfo.EXPECT().InfoType().Return(dcgm.FE_GPU).AnyTimes()
+	mockDeviceInfo.EXPECT().GPUCount().Return(uint(len(gpus))).AnyTimes()
+	mockDeviceInfo.EXPECT().GPU(gomock.Any()).DoAndReturn(func(i uint) deviceinfo.GPUInfo { return gpus[i] }).AnyTimes()
+
+	counter := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_GPU_UTIL, FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter {
+		return collector.MetricsByCounter{
+			counter: {
+				{GPU: "0", Value: "42", Counter: counter},
+				{GPU: "0", GPUInstanceID: "1", MigProfile: "1g.10gb", Value: "7", Counter: counter},
+				{GPU: "1", Value: "87", Counter: counter, Attributes: map[string]string{}},
+			},
+		}
+	}
+
+	transformations, err := GetTransformations(&appconfig.Config{})
+	require.NoError(t, err)
+	assert.Empty(t, transformations, "the label is off by default")
+
+	transformations, err = GetTransformations(&appconfig.Config{EnableSerialLabel: true})
+	require.NoError(t, err)
+	require.Len(t, transformations, 1)
+	metrics := newMetrics()
+	require.NoError(t, transformations[0].Process(metrics, mockDeviceInfo))
+
+	require.Len(t, metrics[counter], 3)
+	assert.Equal(t, "1652220012345", metrics[counter][0].Attributes[SerialAttribute])
+	assert.Equal(t, "1652220012345", metrics[counter][1].Attributes[SerialAttribute], "MIG instances get the serial of their GPU")
+	assert.NotContains(t, metrics[counter][2].Attributes, SerialAttribute, "GPUs without a serial are not labeled")
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/logging"
+)
+
+const socketTimeout = 2 * time.Second
+
+// socketMapper queries a local daemon over a Unix socket for the jobs using each GPU.
+//
+// The protocol is line based: the exporter writes one GPU UUID per line and closes its side
+// of the connection, the daemon answers with "UUID jobid [uid]" lines, one per job, and closes.
+//
+// The answers are cached by the set of UUIDs queried, since each entity group asks for the
+// devices of its own metrics.
+type socketMapper struct {
+	keyedJobMapper
+
+	slots backendSlots
+	// answers are guarded by mu, they replace the single mapping of the other mappers
+	answers map[string]socketAnswer
+}
+
+// socketAnswer is the job mapping answered to a query and when it was fetched
+type socketAnswer struct {
+	gpuToJobMap map[string][]string
+	fetchedAt   time.Time
+}
+
+func newSocketMapper(c *appconfig.Config) *socketMapper {
+	slog.Info(fmt.Sprintf("HPC job mapping is enabled and queries the %q socket", c.HPCJobMappingSocket))
+	p := &socketMapper{
+		slots:   newBackendSlots(c.HPCJobMappingConcurrency),
+		answers: map[string]socketAnswer{},
+	}
+	p.init(c)
+	return p
+}
+
+// Close drops the cached answers; the mapper is not used afterwards.
+func (p *socketMapper) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.answers = map[string]socketAnswer{}
+	return nil
+}
+
+func (p *socketMapper) Name() string {
+	return "socketMapper"
+}
+
+func (p *socketMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	// only the GPUs and their MIG instances are mapped, the other groups have none to query
+	if sysInfo != nil && sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	if !p.devices.ready(sysInfo, p.Name()) {
+		return nil
+	}
+
+	uuids := gpuUUIDsOf(metrics, sysInfo)
+	if len(uuids) == 0 {
+		return nil
+	}
+	key := strings.Join(uuids, "\n")
+
+	p.mu.Lock()
+	now := p.now()
+	ttl := mappingRefreshInterval(p.Config.HPCJobMappingSocketTTL, p.Config.HPCJobMappingSocketRefresh)
+	answer, found := p.answers[key]
+	stale := !found || now.Sub(answer.fetchedAt) >= ttl
+	p.mu.Unlock()
+
+	// the socket is queried without holding the lock, so that the scrapes finding no free slot
+	// are served the cached mapping meanwhile
+	if stale && p.slots.tryAcquire() {
+		gpuToJobMap, err := queryJobMapping(p.Config.HPCJobMappingSocket, uuids)
+		p.slots.release()
+
+		p.mu.Lock()
+		if err != nil {
+			p.warnFailure(now, fmt.Sprintf("Unable to query HPC job mapping socket '%s'. Ignoring.",
+				p.Config.HPCJobMappingSocket), slog.String(logging.ErrorKey, err.Error()))
+			gpuToJobMap = map[string][]string{}
+		}
+		// the answer to an older query finishing last is dropped
+		if !now.Before(p.answers[key].fetchedAt) {
+			p.answers[key] = socketAnswer{gpuToJobMap: gpuToJobMap, fetchedAt: now}
+		}
+		// the answers to the sets of UUIDs no longer queried are dropped once expired
+		for cached, answer := range p.answers {
+			if cached != key && now.Sub(answer.fetchedAt) >= ttl {
+				delete(p.answers, cached)
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	gpuToJobMap := p.answers[key].gpuToJobMap
+	p.mu.Unlock()
+
+	applyJobMapping(metrics, sysInfo, p.jobMapping(mappingSourceSocket, gpuToJobMap))
+
+	return nil
+}
+
+// gpuUUIDsOf returns the sorted UUIDs of the GPUs and MIG instances the metrics belong to
+func gpuUUIDsOf(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) []string {
+	seen := map[string]struct{}{}
+	for _, values := range metrics {
+		for _, metric := range values {
+			uuid := metric.GPUUUID
+			if metric.MigProfile != "" {
+				uuid = migUUIDOf(sysInfo, metric)
+			}
+			if uuid != "" {
+				seen[uuid] = struct{}{}
+			}
+		}
+	}
+
+	uuids := make([]string, 0, len(seen))
+	for uuid := range seen {
+		uuids = append(uuids, uuid)
+	}
+	slices.Sort(uuids)
+
+	return uuids
+}
+
+func queryJobMapping(socketPath string, uuids []string) (map[string][]string, error) {
+	conn, err := net.DialTimeout("unix", socketPath, socketTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(socketTimeout)); err != nil {
+		return nil, err
+	}
+
+	var request strings.Builder
+	for _, uuid := range uuids {
+		request.WriteString(uuid + "\n")
+	}
+	if _, err := conn.Write([]byte(request.String())); err != nil {
+		return nil, err
+	}
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("unexpected connection type %T", conn)
+	}
+	if err := unixConn.CloseWrite(); err != nil {
+		return nil, err
+	}
+
+	// Example of the expected response:
+	// GPU-8b4054a4-c830-20d4-1111-222222222222 51234567 123456
+	// MIG-2201f4b1-a001-5ae1-87df-c6ef1d8adfab 51234568
+	gpuToJobMap := make(map[string][]string)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		uuid, job, found := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !found || job == "" {
+			slog.Debug(fmt.Sprintf("HPC socket mapper: skipping malformed line %q", scanner.Text()))
+			continue
+		}
+		gpuToJobMap[uuid] = append(gpuToJobMap[uuid], job)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug(fmt.Sprintf("GPU to job mapping: %+v", gpuToJobMap))
+
+	return gpuToJobMap, nil
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdeviceinfo "github.com/NVIDIA/dcgm-exporter/internal/mocks/pkg/deviceinfo"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+// serveJobMapping answers every query with the jobs of the requested GPUs found in jobs
+func serveJobMapping(t *testing.T, jobs map[string][]string) (string, *atomic.Int32) {
+	socketPath := filepath.Join(t.TempDir(), "mapping.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	var queries atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			queries.Add(1)
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				for _, job := range jobs[scanner.Text()] {
+					fmt.Fprintf(conn, "%s %s\n", scanner.Text(), job)
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	return socketPath, &queries
+}
+
+func TestSocketMapperProcess(t *testing.T) {
+	const gpu0UUID = "GPU-00000000-0000-0000-0000-000000000000"
+	const gpu1UUID = "GPU-11111111-1111-1111-1111-111111111111"
+
+	socketPath, queries := serveJobMapping(t, map[string][]string{
+		gpu0UUID: {"job1 1000", "job2"},
+	})
+
+	counter := counters.Counter{
+		FieldID:   155,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, gpu0UUID, gpu1UUID) }
+
+	now := time.Now()
+	mapper := newSocketMapper(&appconfig.Config{
+		HPCJobMappingSocket:    socketPath,
+		HPCJobMappingSocketTTL: 10 * time.Second,
+	})
+	mapper.now = func() time.Time { return now }
+
+	metrics := newMetrics()
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 3)
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute])
+	assert.Equal(t, "1000", metrics[counter][0].Attributes[HpcUserAttribute])
+	assert.Equal(t, "socket", metrics[counter][0].Attributes[MappingSourceAttribute])
+	assert.Equal(t, "job2", metrics[counter][1].Attributes[HpcJobAttribute])
+	assert.NotContains(t, metrics[counter][1].Attributes, HpcUserAttribute)
+	assert.NotContains(t, metrics[counter][2].Attributes, HpcJobAttribute)
+
+	// Answers are cached within the TTL
+	require.NoError(t, mapper.Process(newMetrics(), nil))
+	assert.Equal(t, int32(1), queries.Load())
+
+	now = now.Add(10 * time.Second)
+	require.NoError(t, mapper.Process(newMetrics(), nil))
+	assert.Equal(t, int32(2), queries.Load())
+}
+
+func TestSocketMapperProcessEntityGroups(t *testing.T) {
+	const gpuUUID = "GPU-00000000-0000-0000-0000-000000000000"
+	const switchUUID = "SWITCH-00000000-0000-0000-0000-000000000000"
+	socketPath, queries := serveJobMapping(t, map[string][]string{gpuUUID: {"job1"}})
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func(uuid string) collector.MetricsByCounter {
+		return newGPUMetrics(counter, uuid)
+	}
+
+	mapper := newSocketMapper(&appconfig.Config{
+		HPCJobMappingSocket:    socketPath,
+		HPCJobMappingSocketTTL: 10 * time.Second,
+	})
+
+	// each set of devices queried is cached on its own
+	for i := 0; i < 3; i++ {
+		require.NoError(t, mapper.Process(newMetrics(switchUUID), nil))
+		metrics := newMetrics(gpuUUID)
+		require.NoError(t, mapper.Process(metrics, nil))
+		assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute],
+			"the answer for other devices doesn't replace the cached GPU mapping")
+	}
+	assert.Equal(t, int32(2), queries.Load(), "the answers are cached per set of devices")
+}
+
+func TestSocketMapperProcessNonGPUGroup(t *testing.T) {
+	socketPath, queries := serveJobMapping(t, map[string][]string{})
+
+	ctrl := gomock.NewController(t)
+	switchInfo := mockdeviceinfo.NewMockProvider(ctrl)
+	switchInfo.EXPECT().InfoType().Return(dcgm.FE_SWITCH).AnyTimes()
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	switchCounter := counters.Counter{FieldID: dcgm.DCGM_FI_DEV_NVSWITCH_POWER_VDD, FieldName: "DCGM_FI_DEV_NVSWITCH_POWER_VDD", PromType: "gauge"}
+	mapper := newSocketMapper(&appconfig.Config{HPCJobMappingSocket: socketPath})
+
+	switchMetrics := collector.MetricsByCounter{switchCounter: {{GPU: "0", GPUDevice: "nvswitch0", Value: "42", Counter: switchCounter}}}
+	require.NoError(t, mapper.Process(switchMetrics, switchInfo))
+	assert.Empty(t, switchMetrics[switchCounter][0].Attributes)
+
+	// the GPU group without any GPU UUID has nothing to query either
+	require.NoError(t, mapper.Process(collector.MetricsByCounter{counter: {}}, nil))
+
+	assert.Equal(t, int32(0), queries.Load(), "no connection is made without GPUs to query")
+	assert.Empty(t, mapper.answers)
+}
+
+func TestSocketMapperProcessRapidScrapes(t *testing.T) {
+	const gpuUUID = "GPU-00000000-0000-0000-0000-000000000000"
+	socketPath, queries := serveJobMapping(t, map[string][]string{gpuUUID: {"job1"}})
+
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	newMetrics := func() collector.MetricsByCounter { return newGPUMetrics(counter, gpuUUID) }
+
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		minRefresh  time.Duration
+		wantQueries int32
+	}{
+		{name: "TTL", ttl: 2 * time.Second, minRefresh: time.Second, wantQueries: 5},
+		{name: "TTL below the minimum", ttl: 200 * time.Millisecond, minRefresh: time.Second, wantQueries: 10},
+		{name: "Without minimum", ttl: 200 * time.Millisecond, wantQueries: 50},
+		{name: "TTL 0", ttl: 0, minRefresh: time.Second, wantQueries: 10},
+		{name: "Without cache", ttl: 0, wantQueries: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries.Store(0)
+			now := time.Now()
+			mapper := newSocketMapper(&appconfig.Config{
+				HPCJobMappingSocket:        socketPath,
+				HPCJobMappingSocketTTL:     tt.ttl,
+				HPCJobMappingSocketRefresh: tt.minRefresh,
+			})
+			mapper.now = func() time.Time { return now }
+
+			// a scrape every 100ms for 10s
+			for i := 0; i < 100; i++ {
+				metrics := newMetrics()
+				require.NoError(t, mapper.Process(metrics, nil))
+				assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute], "the cached mapping is served")
+				now = now.Add(100 * time.Millisecond)
+			}
+			assert.Equal(t, tt.wantQueries, queries.Load(), "the socket is queried at most once per interval")
+		})
+	}
+}
+
+func TestSocketMapperProcessConcurrency(t *testing.T) {
+	const gpuUUID = "GPU-00000000-0000-0000-0000-000000000000"
+	counter := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+
+	// the daemon holds the queries until released, counting those in flight
+	socketPath := filepath.Join(t.TempDir(), "mapping.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	release := make(chan struct{})
+	var inFlight, maxInFlight atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for current := maxInFlight.Load(); n > current && !maxInFlight.CompareAndSwap(current, n); {
+					current = maxInFlight.Load()
+				}
+				<-release
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintf(conn, "%s job1\n", scanner.Text())
+				}
+			}()
+		}
+	}()
+
+	mapper := newSocketMapper(&appconfig.Config{
+		HPCJobMappingSocket:      socketPath,
+		HPCJobMappingConcurrency: 2,
+	})
+
+	var wg sync.WaitGroup
+	var served atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metrics := newGPUMetrics(counter, gpuUUID)
+			assert.NoError(t, mapper.Process(metrics, nil))
+			served.Add(1)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return inFlight.Load() == 2 && served.Load() == 8 },
+		5*time.Second, time.Millisecond, "the scrapes finding no free slot don't wait for the backend")
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), maxInFlight.Load(), "the backend sees at most the configured concurrency")
+
+	metrics := newGPUMetrics(counter, gpuUUID)
+	require.NoError(t, mapper.Process(metrics, nil))
+	assert.Equal(t, "job1", metrics[counter][0].Attributes[HpcJobAttribute], "the answers are cached")
+}
+
+func TestSocketMapperProcessWhenSocketIsUnavailable(t *testing.T) {
+	counter := counters.Counter{
+		FieldID:   155,
+		FieldName: "DCGM_FI_DEV_POWER_USAGE",
+		PromType:  "gauge",
+	}
+	metrics := newGPUMetrics(counter, "GPU-00000000-0000-0000-0000-000000000000")
+
+	mapper := newSocketMapper(&appconfig.Config{HPCJobMappingSocket: filepath.Join(t.TempDir(), "missing.sock")})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	require.Len(t, metrics[counter], 1)
+	assert.NotContains(t, metrics[counter][0].Attributes, HpcJobAttribute)
+	assert.Equal(t, "GPU-00000000-0000-0000-0000-000000000000", metrics[counter][0].AlterUUID)
+}
+
+func TestSocketMapperName(t *testing.T) {
+	assert.Equal(t, "socketMapper", newSocketMapper(&appconfig.Config{}).Name())
+}
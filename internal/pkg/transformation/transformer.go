@@ -17,21 +17,122 @@
 package transformation
 
 import (
+	"io"
+
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
 )
 
-// GetTransformations return list of transformation applicable for metrics
-func GetTransformations(c *appconfig.Config) []Transform {
+// GetTransformations return list of transformation applicable for metrics, or an error when one
+// of them can not be set up
+func GetTransformations(c *appconfig.Config) ([]Transform, error) {
 	var transformations []Transform
+	// promoted fields go first, so the series derived by the other transformations carry them too
+	if len(c.PromotedFields) > 0 || c.EnablePowerLimitLabel || c.EnableHealthLabel || c.EnableThrottleReasonLabel {
+		transformations = append(transformations, newFieldPromoter(c))
+	}
+
+	if len(c.FieldAliases) > 0 {
+		transformations = append(transformations, newFieldAliaser(c))
+	}
+
+	// the MIG instance counts are emitted before the GPU series are labeled, so they are too
+	if c.EnableMIGInstanceCount {
+		transformations = append(transformations, newMIGCounter(c))
+	}
+
+	if c.EnableMIGNoInstances {
+		transformations = append(transformations, newMIGNoInstancesMarker(c))
+	}
+
+	if c.EnableNUMANodeLabel {
+		transformations = append(transformations, newNUMAMapper(c))
+	}
+
+	if c.EnableSerialLabel {
+		transformations = append(transformations, newSerialMapper(c))
+	}
+
+	if c.EnableDeviceMinor {
+		transformations = append(transformations, newDeviceMinorMapper(c))
+	}
+
+	if len(c.UtilizationBands) > 0 {
+		transformations = append(transformations, newUtilBandMapper(c))
+	}
+
+	// the energy series is added before the mappers, so it is mapped to the jobs like the other
+	// series, and before the resets are marked, as it restarts from 0 for a GPU coming back
+	if c.HPCEnergyCounter {
+		transformations = append(transformations, newEnergyAccumulator(c))
+	}
+
+	if c.HPCCounterResetAttribute {
+		transformations = append(transformations, newCounterResetMarker(c))
+	}
+
 	if c.Kubernetes {
 		podMapper := NewPodMapper(c)
 		transformations = append(transformations, podMapper)
 	}
 
+	if c.KubernetesDeviceCheckpoint != "" {
+		transformations = append(transformations, newCheckpointMapper(c))
+	}
+
 	if c.HPCJobMappingDir != "" {
 		hpcMapper := newHPCMapper(c)
 		transformations = append(transformations, hpcMapper)
 	}
 
-	return transformations
+	if c.HPCJobMappingSocket != "" {
+		socketMapper := newSocketMapper(c)
+		transformations = append(transformations, socketMapper)
+	}
+
+	if c.HPCJobMappingDB != "" {
+		transformations = append(transformations, newDatabaseMapper(c))
+	}
+
+	if c.HPCJobMappingURL != "" {
+		httpMapper, err := newHTTPMapper(c)
+		if err != nil {
+			closeTransformations(transformations)
+			return nil, err
+		}
+		transformations = append(transformations, httpMapper)
+	}
+
+	if c.HPCMPSPIDFile != "" && c.HPCMPSPmonFile != "" {
+		transformations = append(transformations, newMPSMapper(c))
+	}
+
+	if c.HPCJobEnvVar != "" {
+		transformations = append(transformations, newEnvMapper(c))
+	}
+
+	// scanning the processes is a last resort, for nodes without any scheduler integration
+	if c.HPCProcJobRegex != nil {
+		transformations = append(transformations, newProcMapper(c))
+	}
+
+	// the ids are rewritten once every mapper has set them
+	if c.HPCIDRewriteRegex != nil && len(c.HPCIDRewriteAttributes) > 0 {
+		transformations = append(transformations, newIDRewriter(c))
+	}
+
+	if len(c.LegacyMetrics) > 0 {
+		legacyMapper := newLegacyMapper(c)
+		transformations = append(transformations, legacyMapper)
+	}
+
+	return transformations, nil
+}
+
+// closeTransformations releases the transformations already set up when a later one fails
+func closeTransformations(transformations []Transform) {
+	for _, t := range transformations {
+		if closer, ok := t.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
 }
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
 )
@@ -57,10 +58,74 @@ func TestGetTransformations(t *testing.T) {
 				assert.Len(t, transforms, 1)
 			},
 		},
+		{
+			name: "The HPC job mapping is queried over a socket",
+			config: &appconfig.Config{
+				HPCJobMappingSocket: "/run/gpustat.sock",
+			},
+			assert: func(t *testing.T, transforms []Transform) {
+				assert.Len(t, transforms, 1)
+			},
+		},
+		{
+			name: "Legacy metric names are configured",
+			config: &appconfig.Config{
+				LegacyMetrics: map[string]appconfig.LegacyMetric{
+					"DCGM_FI_DEV_GPU_UTIL": {Name: "nvidia_gpu_duty_cycle", Multiplier: 1},
+				},
+			},
+			assert: func(t *testing.T, transforms []Transform) {
+				assert.Len(t, transforms, 1)
+			},
+		},
+		{
+			name: "Field aliases are configured",
+			config: &appconfig.Config{
+				FieldAliases: map[string][]string{"gpu_temperature": {"DCGM_FI_DEV_MEMORY_TEMP", "DCGM_FI_DEV_GPU_TEMP"}},
+			},
+			assert: func(t *testing.T, transforms []Transform) {
+				assert.Len(t, transforms, 1)
+			},
+		},
+		{
+			name: "The energy counter and the counter resets are enabled without a job mapper",
+			config: &appconfig.Config{
+				HPCEnergyCounter:         true,
+				HPCCounterResetAttribute: true,
+			},
+			assert: func(t *testing.T, transforms []Transform) {
+				require.Len(t, transforms, 2)
+				assert.Equal(t, "energyAccumulator", transforms[0].Name())
+				assert.Equal(t, "counterResetMarker", transforms[1].Name(), "the energy series is marked too")
+			},
+		},
+		{
+			name: "The energy counter is enabled with a job mapper",
+			config: &appconfig.Config{
+				HPCEnergyCounter: true,
+				Kubernetes:       true,
+			},
+			assert: func(t *testing.T, transforms []Transform) {
+				require.Len(t, transforms, 2)
+				assert.Equal(t, "energyAccumulator", transforms[0].Name(), "the energy series is mapped to the jobs")
+			},
+		},
+		{
+			name: "Fields are promoted to labels",
+			config: &appconfig.Config{
+				PromotedFields: []string{"DCGM_FI_DEV_COMPUTE_MODE"},
+				Kubernetes:     true,
+			},
+			assert: func(t *testing.T, transforms []Transform) {
+				assert.Len(t, transforms, 2)
+				assert.Equal(t, "fieldPromoter", transforms[0].Name(), "fields are promoted first")
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			transformations := GetTransformations(tt.config)
+			transformations, err := GetTransformations(tt.config)
+			require.NoError(t, err)
 			tt.assert(t, transformations)
 		})
 	}
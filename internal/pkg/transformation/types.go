@@ -19,6 +19,7 @@ package transformation
 import (
 	"context"
 	"sync"
+	"time"
 
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -36,6 +37,75 @@ type Transform interface {
 	Name() string
 }
 
+// MappingFreshnessReporter is implemented by transformations reading job mapping files, to report
+// the modification time of the newest file and the number of files read on the last scrape.
+type MappingFreshnessReporter interface {
+	MappingFreshness() (time.Time, int)
+}
+
+// MappingFilesReporter is implemented by transformations reading job mapping files, to report the
+// number of files read on the last scrape and the names of the most recently modified of them.
+type MappingFilesReporter interface {
+	MappingFiles() (int, []string)
+}
+
+// MappingAgeReporter is implemented by transformations fetching the job mapping from a backend and
+// keeping the last one fetched while the backend fails, to report when it was fetched.
+type MappingAgeReporter interface {
+	MappingUpdatedAt() time.Time
+}
+
+// MappingConflictReporter is implemented by transformations reading job mapping files, to report
+// how many times a GPU was claimed by several mapping files on a scrape.
+type MappingConflictReporter interface {
+	MappingConflicts() uint64
+}
+
+// MappingOversizeReporter is implemented by transformations reading job mapping files, to report
+// how many times a mapping file was skipped for exceeding the maximum size.
+type MappingOversizeReporter interface {
+	MappingOversize() uint64
+}
+
+// MappingCoverage is the number of active GPUs on a scrape, i.e. with a non-zero utilization,
+// and how many of them are mapped to a job.
+type MappingCoverage struct {
+	Hostname string
+	Active   int
+	Mapped   int
+}
+
+// MappingCoverageReporter is implemented by transformations mapping GPUs to jobs, to report the
+// mapping coverage of the active GPUs on the last scrape.
+type MappingCoverageReporter interface {
+	MappingCoverage() MappingCoverage
+}
+
+// MappingSettingsReloader is implemented by transformations applying the job mapping settings,
+// e.g. the mapping directory or the job placeholder, to apply new ones without being created again.
+type MappingSettingsReloader interface {
+	Reload(c *appconfig.Config)
+}
+
+// ValueFormatterSetter is implemented by transformations computing metric values, so they are
+// formatted like the rendered ones.
+type ValueFormatterSetter interface {
+	SetValueFormatter(formatter collector.ValueFormatter)
+}
+
+// MappingKeyResolver returns the keys the jobs of a metric's GPU are looked up under in a job
+// mapping, in order of precedence; the jobs found under every key are applied. The alternate UUID
+// of the metric is set, to the MIG UUID for MIG instances, and the device info may be nil.
+type MappingKeyResolver interface {
+	MappingKeys(metric collector.Metric, sysInfo deviceinfo.Provider) []string
+}
+
+// MappingKeyResolverSetter is implemented by the transformations mapping GPUs to jobs, so that
+// sites may key their mappings their own way.
+type MappingKeyResolverSetter interface {
+	SetMappingKeyResolver(resolver MappingKeyResolver)
+}
+
 type PodMapper struct {
 	Config               *appconfig.Config
 	Client               kubernetes.Interface
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/deviceinfo"
+)
+
+// utilBandField is the field the utilization bands are taken from
+const utilBandField = "DCGM_FI_DEV_GPU_UTIL"
+
+// utilBandMapper labels the metrics of each GPU with the configured band of its utilization, so
+// that dashboards can filter the busy or idle GPUs without range queries. The GPUs without a
+// utilization sample, or above the last band, get no band.
+type utilBandMapper struct {
+	Config *appconfig.Config
+}
+
+func newUtilBandMapper(c *appconfig.Config) *utilBandMapper {
+	slog.Info(fmt.Sprintf("Utilization band label is enabled with bands %v", c.UtilizationBands))
+	return &utilBandMapper{Config: c}
+}
+
+func (p *utilBandMapper) Name() string {
+	return "utilBandMapper"
+}
+
+func (p *utilBandMapper) Process(metrics collector.MetricsByCounter, sysInfo deviceinfo.Provider) error {
+	if sysInfo != nil && sysInfo.InfoType() != dcgm.FE_GPU {
+		return nil
+	}
+
+	// the band of each GPU, which applies to its MIG instances too
+	bands := map[string]string{}
+	for counter, counterMetrics := range metrics {
+		if counter.FieldName != utilBandField {
+			continue
+		}
+		for _, metric := range counterMetrics {
+			if metric.GPUInstanceID != "" {
+				continue
+			}
+			utilization, err := strconv.ParseFloat(metric.Value, 64)
+			if err != nil {
+				continue
+			}
+			if band, ok := p.band(utilization); ok {
+				bands[metric.GPU] = band
+			}
+		}
+	}
+	if len(bands) == 0 {
+		return nil
+	}
+
+	for counter := range metrics {
+		for i, metric := range metrics[counter] {
+			band, ok := bands[metric.GPU]
+			if !ok {
+				continue
+			}
+			if metric.Attributes == nil {
+				metrics[counter][i].Attributes = map[string]string{}
+			}
+			metrics[counter][i].Attributes[UtilBandAttribute] = band
+		}
+	}
+
+	return nil
+}
+
+// band returns the label of the first band whose upper bound the utilization doesn't exceed
+func (p *utilBandMapper) band(utilization float64) (string, bool) {
+	for _, band := range p.Config.UtilizationBands {
+		if utilization <= band.UpperBound {
+			return band.Label, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/appconfig"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/collector"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestUtilBandMapperProcess(t *testing.T) {
+	util := counters.Counter{FieldID: 203, FieldName: "DCGM_FI_DEV_GPU_UTIL", PromType: "gauge"}
+	power := counters.Counter{FieldID: 155, FieldName: "DCGM_FI_DEV_POWER_USAGE", PromType: "gauge"}
+	metrics := collector.MetricsByCounter{
+		util: {
+			{GPU: "0", Value: "0", Counter: util},
+			{GPU: "1", Value: "30", Counter: util},
+			{GPU: "2", Value: "95", Counter: util},
+		},
+		power: {
+			{GPU: "0", Value: "60", Counter: power},
+			{GPU: "1", Value: "150", Counter: power},
+			{GPU: "1", GPUInstanceID: "7", MigProfile: "1g.10gb", Value: "21", Counter: power},
+			{GPU: "2", Value: "400", Counter: power},
+			{GPU: "3", Value: "60", Counter: power},
+		},
+	}
+
+	mapper := newUtilBandMapper(&appconfig.Config{UtilizationBands: []appconfig.UtilizationBand{
+		{Label: "idle", UpperBound: 5},
+		{Label: "low", UpperBound: 25},
+		{Label: "med", UpperBound: 75},
+		{Label: "high", UpperBound: 100},
+	}})
+	require.NoError(t, mapper.Process(metrics, nil))
+
+	for i, want := range []string{"idle", "med", "high"} {
+		assert.Equal(t, want, metrics[util][i].Attributes[UtilBandAttribute])
+	}
+	assert.Equal(t, "idle", metrics[power][0].Attributes[UtilBandAttribute])
+	assert.Equal(t, "med", metrics[power][1].Attributes[UtilBandAttribute])
+	assert.Equal(t, "med", metrics[power][2].Attributes[UtilBandAttribute], "MIG instances get the band of their GPU")
+	assert.Equal(t, "high", metrics[power][3].Attributes[UtilBandAttribute])
+	assert.NotContains(t, metrics[power][4].Attributes, UtilBandAttribute, "no band without a utilization sample")
+}
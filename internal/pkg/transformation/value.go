@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+// transformValue computes the alternate value of a metric, applying the counter's transform
+// if it has one and its multiplier otherwise.
+// Integer values stay integral as long as the scale and the offset are whole numbers.
+func transformValue(value string, counter counters.Counter) string {
+	t := counter.Transform
+	if !t.IsSet() {
+		return scaleValue(value, counter.Multiplier)
+	}
+	if !strings.Contains(value, ".") && t.Scale == math.Trunc(t.Scale) && t.Offset == math.Trunc(t.Offset) {
+		newval, _ := strconv.ParseInt(value, 10, 64)
+		return fmt.Sprintf("%d", newval*int64(t.Scale)+int64(t.Offset))
+	}
+	newval, _ := strconv.ParseFloat(value, 64)
+	return fmt.Sprintf("%f", newval*t.Scale+t.Offset)
+}
+
+// scaleValue multiplies a metric value by the multiplier, keeping integer values integral.
+func scaleValue(value string, multiplier int) string {
+	if multiplier == 1 {
+		return value
+	}
+	if strings.Contains(value, ".") {
+		newval, _ := strconv.ParseFloat(value, 64)
+		return fmt.Sprintf("%f", newval*float64(multiplier))
+	}
+	newval, _ := strconv.Atoi(value)
+	return fmt.Sprintf("%d", newval*multiplier)
+}
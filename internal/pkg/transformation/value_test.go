@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2024, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transformation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/counters"
+)
+
+func TestTransformValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		counter counters.Counter
+		want    string
+	}{
+		{
+			name:    "legacy multiplier, integer value",
+			value:   "16",
+			counter: counters.Counter{Multiplier: 1048576},
+			want:    "16777216",
+		},
+		{
+			name:    "legacy multiplier, float value",
+			value:   "1.500000",
+			counter: counters.Counter{Multiplier: 1000},
+			want:    "1500.000000",
+		},
+		{
+			name:    "whole scale keeps integer values integral",
+			value:   "16",
+			counter: counters.Counter{Multiplier: 1, Transform: counters.ValueTransform{Scale: 1024}},
+			want:    "16384",
+		},
+		{
+			name:    "fractional scale converts millidegrees to degrees",
+			value:   "45500",
+			counter: counters.Counter{Multiplier: 1, Transform: counters.ValueTransform{Scale: 0.001}},
+			want:    "45.500000",
+		},
+		{
+			name:    "whole scale and offset, integer value",
+			value:   "20",
+			counter: counters.Counter{Multiplier: 1, Transform: counters.ValueTransform{Scale: 2, Offset: -5}},
+			want:    "35",
+		},
+		{
+			name:    "scale and offset, float value",
+			value:   "25.000000",
+			counter: counters.Counter{Multiplier: 1, Transform: counters.ValueTransform{Scale: 1.8, Offset: 32}},
+			want:    "77.000000",
+		},
+		{
+			name:    "transform replaces the multiplier",
+			value:   "10",
+			counter: counters.Counter{Multiplier: 1000, Transform: counters.ValueTransform{Scale: 1, Offset: 273}},
+			want:    "283",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, transformValue(tt.value, tt.counter))
+		})
+	}
+}
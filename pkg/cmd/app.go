@@ -5,9 +5,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"runtime/debug"
 	"slices"
@@ -33,8 +35,10 @@ import (
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/nvmlprovider"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/prerequisites"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/registry"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/rendermetrics"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/server"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/stdout"
+	"github.com/NVIDIA/dcgm-exporter/internal/pkg/transformation"
 	"github.com/NVIDIA/dcgm-exporter/internal/pkg/utils"
 )
 
@@ -91,13 +95,106 @@ const (
 	CLILogFormat                  = "log-format"
 	CLIPodResourcesKubeletSocket  = "pod-resources-kubelet-socket"
 	CLIHPCJobMappingDir           = "hpc-job-mapping-dir"
+	CLIHPCJobMappingSocket        = "hpc-job-mapping-socket"
+	CLIHPCJobMappingSocketTTL     = "hpc-job-mapping-socket-ttl"
+	CLIHPCJobMappingSocketRefresh = "hpc-job-mapping-socket-min-refresh"
+	CLIHPCJobMappingDB            = "hpc-job-mapping-db"
+	CLIHPCJobMappingDBQuery       = "hpc-job-mapping-db-query"
+	CLIHPCJobMappingDBTTL         = "hpc-job-mapping-db-ttl"
+	CLIHPCJobMappingDBRefresh     = "hpc-job-mapping-db-min-refresh"
+	CLIHPCJobMappingURL           = "hpc-job-mapping-url"
+	CLIHPCJobMappingURLTTL        = "hpc-job-mapping-url-ttl"
+	CLIHPCJobMappingURLRefresh    = "hpc-job-mapping-url-min-refresh"
+	CLIHPCJobMappingConcurrency   = "hpc-job-mapping-concurrency"
+	CLIHPCMappingLinger           = "hpc-mapping-linger"
+	CLIHPCMappingStaleAfter       = "hpc-mapping-stale-after"
+	CLIHPCJobPlaceholder          = "hpc-job-placeholder"
+	CLIHPCJobMappingNodeFile      = "hpc-job-mapping-node-file"
+	CLIHPCJobMappingManifest      = "hpc-job-mapping-manifest"
+	CLIHPCJobMappingGRESFile      = "hpc-job-mapping-gres-file"
+	CLIHPCMappingFilePattern      = "hpc-mapping-file-pattern"
+	CLIHPCJobMappingEncoding      = "hpc-job-mapping-encoding"
+	CLIHPCIncompleteMIGMode       = "hpc-incomplete-mig-mode"
+	CLIHPCMPSPIDFile              = "hpc-mps-pid-file"
+	CLIHPCMPSPmonFile             = "hpc-mps-pmon-file"
+	CLIHPCMPSRefresh              = "hpc-mps-min-refresh"
+	CLIHPCJobEnvVar               = "hpc-job-env-var"
+	CLIHPCUserEnvVar              = "hpc-user-env-var"
+	CLIHPCAccountEnvVar           = "hpc-account-env-var"
+	CLIHPCProcJobRegex            = "hpc-proc-job-regex"
+	CLIHPCProcRoot                = "hpc-proc-root"
+	CLIHPCProcScanInterval        = "hpc-proc-scan-interval"
+	CLIHPCIDRewriteRegex          = "hpc-id-rewrite-regex"
+	CLIHPCIDRewriteReplacement    = "hpc-id-rewrite-replacement"
+	CLIHPCIDRewriteAttributes     = "hpc-id-rewrite-attributes"
+	CLIHPCMappingFileAttribute    = "hpc-mapping-file-attribute"
+	CLIHPCMappingFilesInfo        = "hpc-mapping-files-info"
+	CLIHPCSharingAttribute        = "hpc-sharing-attribute"
+	CLIHPCCounterResetAttribute   = "hpc-counter-reset-attribute"
+	CLIHPCEnergyCounter           = "hpc-energy-counter"
+	CLIHPCJobGPUSeconds           = "hpc-job-gpu-seconds"
+	CLIHPCMaxMappingFileBytes     = "hpc-max-mapping-file-bytes"
+	CLIHPCMappingSettingsFile     = "hpc-mapping-settings-file"
 	CLINvidiaResourceNames        = "nvidia-resource-names"
 	CLIKubernetesVirtualGPUs      = "kubernetes-virtual-gpus"
+	CLIKubernetesDeviceCheckpoint = "kubernetes-device-checkpoint"
 	CLIDumpEnabled                = "dump-enabled"
 	CLIDumpDirectory              = "dump-directory"
 	CLIDumpRetention              = "dump-retention"
 	CLIDumpCompression            = "dump-compression"
 	CLIKubernetesEnableDRA        = "kubernetes-enable-dra"
+	CLILegacyMetrics              = "legacy-metrics"
+	CLIDuplicateLabelMode         = "duplicate-label-mode"
+	CLILabelEscaping              = "label-escaping"
+	CLILineEnding                 = "line-ending"
+	CLIRenderMode                 = "render-mode"
+	CLIEnableOpenMetrics          = "enable-openmetrics"
+	CLIStaticLabels               = "static-labels"
+	CLIHostnameOverride           = "hostname-override"
+	CLIEnableSelfMetrics          = "enable-self-metrics"
+	CLIScrapeHistoryCount         = "scrape-history-count"
+	CLIScrapeHistoryMaxBytes      = "scrape-history-max-bytes"
+	CLIScrapeFile                 = "scrape-file"
+	CLIScrapeFileMaxBytes         = "scrape-file-max-bytes"
+	CLIEnableDeltaEndpoint        = "enable-delta-endpoint"
+	CLIEnableChecksumTrailer      = "enable-checksum-trailer"
+	CLICollapseDuplicateSeries    = "collapse-duplicate-series"
+	CLIEnableFieldTimestamps      = "enable-field-timestamps"
+	CLIEnableEntityKindLabel      = "enable-entity-kind-label"
+	CLIPromoteFields              = "promote-fields"
+	CLIFieldIDLabel               = "field-id-label"
+	CLIFieldIDLabelTypes          = "field-id-label-types"
+	CLIFieldAlias                 = "field-alias"
+	CLILinkDirection              = "link-direction"
+	CLIEnableNUMANodeLabel        = "enable-numa-node-label"
+	CLIEnableSerialLabel          = "enable-serial-label"
+	CLIEnableDeviceMinor          = "enable-device-minor"
+	CLIMinorNumberLabel           = "minor-number-label"
+	CLIEnablePowerLimitLabel      = "enable-power-limit-label"
+	CLIEnableHealthLabel          = "enable-health-label"
+	CLIEnableThrottleReasonLabel  = "enable-throttle-reason-label"
+	CLINodeGPUUtilBuckets         = "node-gpu-util-buckets"
+	CLIPowerZone                  = "power-zone"
+	CLIUtilBands                  = "util-bands"
+	CLIEnableMIGInstanceCount     = "enable-mig-instance-count"
+	CLIEnableMIGNoInstances       = "enable-mig-no-instances-series"
+	CLIGPULabelOrder              = "gpu-label-order"
+	CLIOmitEmptyGPULabels         = "omit-empty-gpu-labels"
+	CLITenant                     = "tenant"
+	CLITenantAttribute            = "tenant-attribute"
+	CLITenantLabelMode            = "tenant-label-mode"
+	CLICohort                     = "cohort"
+	CLIDefaultCohort              = "default-cohort"
+	CLIModelNameMap               = "model-name-map"
+	CLIEnabledEntityGroups        = "enabled-entity-groups"
+	CLIIntegerFields              = "integer-fields"
+	CLIRoundedFields              = "rounded-fields"
+	CLISummaryFields              = "summary-fields"
+	CLISummaryWindow              = "summary-window"
+	CLIEnableGenerationLabel      = "enable-generation-label"
+	CLIRenderDeadline             = "render-deadline"
+	CLISampleRate                 = "sample-rate"
+	CLISampleFields               = "sample-fields"
 )
 
 func NewApp(buildVersion ...string) *cli.App {
@@ -279,6 +376,248 @@ func NewApp(buildVersion ...string) *cli.App {
 			Usage:   "Path to HPC job mapping file directory used for mapping GPUs to jobs.",
 			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_DIR"},
 		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobMappingSocket,
+			Value:   "",
+			Usage:   "Path to a Unix socket answering GPU UUID to HPC job queries.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_SOCKET"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCJobMappingSocketTTL,
+			Value:   10 * time.Second,
+			Usage:   "How long the answers of the HPC job mapping socket are cached, at least --hpc-job-mapping-socket-min-refresh; with 0 the answers are cached for --hpc-job-mapping-socket-min-refresh only.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_SOCKET_TTL"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCJobMappingSocketRefresh,
+			Value:   time.Second,
+			Usage:   "Minimum interval between two queries of the HPC job mapping socket, so that frequent scrapes do not hammer the socket, whatever the TTL; 0 queries the socket on every scrape when the TTL is 0 too.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_SOCKET_MIN_REFRESH"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobMappingDB,
+			Value:   "",
+			Usage:   "Path to a SQLite database with the GPU to HPC job mapping, opened read-only.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_DB"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobMappingDBQuery,
+			Value:   "SELECT gpu_uuid, jobid, userid FROM allocations",
+			Usage:   "Query of the HPC job mapping database returning (gpu_uuid, jobid, userid) rows; userid may be NULL.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_DB_QUERY"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCJobMappingDBTTL,
+			Value:   10 * time.Second,
+			Usage:   "How long the results of the HPC job mapping database query are cached, at least --hpc-job-mapping-db-min-refresh; with 0 the results are cached for --hpc-job-mapping-db-min-refresh only.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_DB_TTL"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCJobMappingDBRefresh,
+			Value:   time.Second,
+			Usage:   "Minimum interval between two queries of the HPC job mapping database, so that frequent scrapes do not hammer the database, whatever the TTL; 0 queries the database on every scrape when the TTL is 0 too.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_DB_MIN_REFRESH"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobMappingURL,
+			Value:   "",
+			Usage:   "URL of a cluster-wide allocation service answering with a JSON array of {node, gpu, jobid, userid} GPU to HPC job assignments; the assignments of other nodes are dropped.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_URL"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCJobMappingURLTTL,
+			Value:   30 * time.Second,
+			Usage:   "Interval between the conditional requests to the HPC job mapping URL, at least --hpc-job-mapping-url-min-refresh; doubled on every consecutive failure up to 5 minutes. With 0 the mapping is requested at most once per --hpc-job-mapping-url-min-refresh.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_URL_TTL"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCJobMappingURLRefresh,
+			Value:   time.Second,
+			Usage:   "Minimum interval between two requests to the HPC job mapping URL, so that frequent scrapes do not hammer the service, whatever the TTL; 0 requests the mapping on every scrape when the TTL is 0 too.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_URL_MIN_REFRESH"},
+		},
+		&cli.IntFlag{
+			Name:    CLIHPCJobMappingConcurrency,
+			Value:   1,
+			Usage:   "Maximum number of concurrent queries of the HPC job mapping socket, database or URL, at least 1; the scrapes finding none available are served the cached mapping.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_CONCURRENCY"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCMappingLinger,
+			Value:   0,
+			Usage:   "How long a job mapping removed from the HPC job mapping directory keeps applying, e.g. 30s.",
+			EnvVars: []string{"DCGM_HPC_MAPPING_LINGER"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCMappingStaleAfter,
+			Value:   0,
+			Usage:   "Leave out the nvidia_gpu_jobId and nvidia_gpu_jobUid series, so that Prometheus marks them stale, once the newest HPC job mapping file is older than this, e.g. 1h (0 = never).",
+			EnvVars: []string{"DCGM_HPC_MAPPING_STALE_AFTER"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobPlaceholder,
+			Value:   "",
+			Usage:   "Job attribute given to GPUs without a job, e.g. none. GPUs without a job have no job attribute when empty.",
+			EnvVars: []string{"DCGM_HPC_JOB_PLACEHOLDER"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobMappingNodeFile,
+			Value:   "_node",
+			Usage:   "Name of the file in the HPC job mapping directory with the jobs of GPUs without a file of their own.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_NODE_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobMappingManifest,
+			Value:   "manifest",
+			Usage:   "Name of the file in the HPC job mapping directory listing the mapping files to read; all files are read when it is missing.",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_MANIFEST"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobMappingGRESFile,
+			Value:   "_gres",
+			Usage:   "Name of the file in the HPC job mapping directory with a '<jobid> [<userid>] <GRES>' line per job, the GPUs of a job being the IDX indices of its Slurm GRES, e.g. gpu:a100:2(IDX:0-1).",
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_GRES_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCMappingFilePattern,
+			Value:   "*",
+			Usage:   "Glob the names of the files in the HPC job mapping directory must match to be read, e.g. 'GPU-*', so that other files such as locks or logs are ignored; the node, GRES and manifest files are always read.",
+			EnvVars: []string{"DCGM_HPC_MAPPING_FILE_PATTERN"},
+		},
+		&cli.StringFlag{
+			Name:  CLIHPCJobMappingEncoding,
+			Value: appconfig.HPCJobMappingEncodingNone,
+			Usage: fmt.Sprintf("Encoding of the lines of the HPC job mapping files. Possible values: '%s', '%s' (each field is base64 encoded), '%s' (the whole line is base64 encoded)",
+				appconfig.HPCJobMappingEncodingNone, appconfig.HPCJobMappingEncodingBase64Fields, appconfig.HPCJobMappingEncodingBase64Line),
+			EnvVars: []string{"DCGM_HPC_JOB_MAPPING_ENCODING"},
+		},
+		&cli.StringFlag{
+			Name:  CLIHPCIncompleteMIGMode,
+			Value: appconfig.IncompleteMIGModeParent,
+			Usage: fmt.Sprintf("How the metrics of MIG instances whose instance id is missing or doesn't resolve, e.g. right after a MIG reconfiguration, are mapped to jobs. Possible values: '%s' (as their GPU), '%s' (not mapped, with a mig_identity=\"incomplete\" label)",
+				appconfig.IncompleteMIGModeParent, appconfig.IncompleteMIGModeMark),
+			EnvVars: []string{"DCGM_HPC_INCOMPLETE_MIG_MODE"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCMPSPIDFile,
+			Value:   "",
+			Usage:   "File with a '<pid> <jobid> [<userid>]' line per MPS client process; along with --hpc-mps-pmon-file, labels the GPUs with the jobs of their processes.",
+			EnvVars: []string{"DCGM_HPC_MPS_PID_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCMPSPmonFile,
+			Value:   "",
+			Usage:   "File with the output of 'nvidia-smi pmon -c 1', listing the processes running on each GPU, joined with --hpc-mps-pid-file.",
+			EnvVars: []string{"DCGM_HPC_MPS_PMON_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCMPSRefresh,
+			Value:   time.Second,
+			Usage:   "Minimum interval between two reads of the MPS job mapping files, the jobs of the previous read applying in between; 0 reads them on every scrape.",
+			EnvVars: []string{"DCGM_HPC_MPS_MIN_REFRESH"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCJobEnvVar,
+			Value:   "",
+			Usage:   "Environment variable with the job of all the GPUs the exporter sees, when it runs in the container of a job, e.g. SLURM_JOB_ID.",
+			EnvVars: []string{"DCGM_HPC_JOB_ENV_VAR"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCUserEnvVar,
+			Value:   "",
+			Usage:   "Environment variable with the user of the job named by --hpc-job-env-var, e.g. SLURM_JOB_UID.",
+			EnvVars: []string{"DCGM_HPC_USER_ENV_VAR"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCAccountEnvVar,
+			Value:   "",
+			Usage:   "Environment variable with the account of the job named by --hpc-job-env-var, e.g. SLURM_JOB_ACCOUNT, recorded as the account label.",
+			EnvVars: []string{"DCGM_HPC_ACCOUNT_ENV_VAR"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCProcJobRegex,
+			Value:   "",
+			Usage:   "Last resort job mapping: scan the processes for those with a GPU device open and attribute their GPU to the job extracted from their cgroup by the first group of this regular expression, e.g. job_([0-9]+), and to their owner.",
+			EnvVars: []string{"DCGM_HPC_PROC_JOB_REGEX"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCProcRoot,
+			Value:   "/proc",
+			Usage:   "Mount point of the procfs scanned for GPU processes, e.g. the procfs of the host mounted in the container.",
+			EnvVars: []string{"DCGM_HPC_PROC_ROOT"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIHPCProcScanInterval,
+			Value:   10 * time.Second,
+			Usage:   "Minimum interval between two scans of the GPU processes, the jobs of the previous scan applying in between.",
+			EnvVars: []string{"DCGM_HPC_PROC_SCAN_INTERVAL"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCIDRewriteRegex,
+			Value:   "",
+			Usage:   "Regular expression rewriting the mapped job ids with --hpc-id-rewrite-replacement, e.g. ^prod-; the ids it doesn't match are kept as they are.",
+			EnvVars: []string{"DCGM_HPC_ID_REWRITE_REGEX"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCIDRewriteReplacement,
+			Value:   "",
+			Usage:   "Replacement of the matches of --hpc-id-rewrite-regex, where $1 or ${name} is a submatch.",
+			EnvVars: []string{"DCGM_HPC_ID_REWRITE_REPLACEMENT"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIHPCIDRewriteAttributes,
+			Value:   cli.NewStringSlice(transformation.HpcJobAttribute),
+			Usage:   "Labels rewritten by --hpc-id-rewrite-regex, among jobid, userid and account.",
+			EnvVars: []string{"DCGM_HPC_ID_REWRITE_ATTRIBUTES"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIHPCMappingFileAttribute,
+			Value:   false,
+			Usage:   "Add a mapping_file label with the name of the HPC job mapping file to the metrics mapped to a job.",
+			EnvVars: []string{"DCGM_HPC_MAPPING_FILE_ATTRIBUTE"},
+		},
+		&cli.IntFlag{
+			Name:    CLIHPCMappingFilesInfo,
+			Value:   0,
+			Usage:   "Number of the most recently modified HPC job mapping files listed, one series per file, by dcgm_hpc_mapping_file_info (0 = none); the number of files is rendered as dcgm_hpc_mapping_files regardless.",
+			EnvVars: []string{"DCGM_HPC_MAPPING_FILES_INFO"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIHPCSharingAttribute,
+			Value:   false,
+			Usage:   "Add a sharing label to the metrics mapped to a job, 'exclusive' when the GPU has a single job and 'shared' when it has several.",
+			EnvVars: []string{"DCGM_HPC_SHARING_ATTRIBUTE"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIHPCCounterResetAttribute,
+			Value:   false,
+			Usage:   "Add a counter_reset=\"true\" label to counter samples lower than on the previous scrape, e.g. after a GPU reset.",
+			EnvVars: []string{"DCGM_HPC_COUNTER_RESET_ATTRIBUTE"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIHPCEnergyCounter,
+			Value:   false,
+			Usage:   "Emit a dcgm_gpu_energy_joules counter per GPU, integrated from the power samples over the scrape interval.",
+			EnvVars: []string{"DCGM_HPC_ENERGY_COUNTER"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIHPCJobGPUSeconds,
+			Value:   false,
+			Usage:   "Emit a dcgm_job_gpu_seconds_total counter per HPC job and GPU, adding the scrape interval while the GPU is mapped to the job. Requires --hpc-job-mapping-dir.",
+			EnvVars: []string{"DCGM_HPC_JOB_GPU_SECONDS"},
+		},
+		&cli.Int64Flag{
+			Name:    CLIHPCMaxMappingFileBytes,
+			Value:   16 << 20,
+			Usage:   "Skip the HPC job mapping files, and the kubelet device checkpoint, larger than this number of bytes, no limit when 0.",
+			EnvVars: []string{"DCGM_HPC_MAX_MAPPING_FILE_BYTES"},
+		},
+		&cli.StringFlag{
+			Name:    CLIHPCMappingSettingsFile,
+			Value:   "",
+			Usage:   "Path to a file of <flag>=<value> lines overriding the HPC job mapping directory, file pattern, node file, manifest, GRES file, placeholder, mapping_file and sharing attribute flags; it is re-read on SIGHUP and applied without a restart.",
+			EnvVars: []string{"DCGM_HPC_MAPPING_SETTINGS_FILE"},
+		},
 		&cli.StringSliceFlag{
 			Name:    CLINvidiaResourceNames,
 			Value:   cli.NewStringSlice(),
@@ -291,6 +630,12 @@ func NewApp(buildVersion ...string) *cli.App {
 			Usage:   "Capture metrics associated with virtual GPUs exposed by Kubernetes device plugins when using GPU sharing strategies, e.g. time-sharing or MPS.",
 			EnvVars: []string{"KUBERNETES_VIRTUAL_GPUS"},
 		},
+		&cli.StringFlag{
+			Name:    CLIKubernetesDeviceCheckpoint,
+			Value:   "",
+			Usage:   "Kubelet device plugin checkpoint, e.g. /var/lib/kubelet/device-plugins/kubelet_internal_checkpoint, read to add the pod_uid and container of the GPUs to the metrics.",
+			EnvVars: []string{"DCGM_EXPORTER_KUBERNETES_DEVICE_CHECKPOINT"},
+		},
 		&cli.BoolFlag{
 			Name:    CLIDumpEnabled,
 			Value:   false,
@@ -321,6 +666,310 @@ func NewApp(buildVersion ...string) *cli.App {
 			Usage:   "Capture metrics associated with GPUs managed by Kubernetes Dynamic Resource Allocation (DRA) API.",
 			EnvVars: []string{"KUBERNETES_ENABLE_DRA"},
 		},
+		&cli.StringSliceFlag{
+			Name:    CLILegacyMetrics,
+			Value:   cli.NewStringSlice(),
+			Usage:   "Emit legacy-named series in addition to DCGM fields, as <DCGM_FIELD>=<legacy_name>[:<multiplier>], e.g. DCGM_FI_DEV_GPU_UTIL=nvidia_gpu_duty_cycle.",
+			EnvVars: []string{"DCGM_EXPORTER_LEGACY_METRICS"},
+		},
+		&cli.StringFlag{
+			Name:  CLIDuplicateLabelMode,
+			Value: appconfig.DuplicateLabelDrop,
+			Usage: fmt.Sprintf("How to render attributes colliding with fixed labels such as gpu or Hostname. Possible values: '%s' (drop and warn), '%s' (rename to exported_<name>), '%s' (fail the scrape)",
+				appconfig.DuplicateLabelDrop, appconfig.DuplicateLabelPrefix, appconfig.DuplicateLabelError),
+			EnvVars: []string{"DCGM_EXPORTER_DUPLICATE_LABEL_MODE"},
+		},
+		&cli.StringFlag{
+			Name:  CLILabelEscaping,
+			Value: appconfig.LabelEscapingStrict,
+			Usage: fmt.Sprintf("How to render quotes, backslashes and newlines in label values. Possible values: '%s' (escape them), '%s' (remove them, for consumers not supporting escapes)",
+				appconfig.LabelEscapingStrict, appconfig.LabelEscapingStrip),
+			EnvVars: []string{"DCGM_EXPORTER_LABEL_ESCAPING"},
+		},
+		&cli.StringFlag{
+			Name:  CLILineEnding,
+			Value: appconfig.LineEndingLF,
+			Usage: fmt.Sprintf("Line terminator of the rendered metrics. Possible values: '%s' (as expected by Prometheus), '%s'",
+				appconfig.LineEndingLF, appconfig.LineEndingCRLF),
+			EnvVars: []string{"DCGM_EXPORTER_LINE_ENDING"},
+		},
+		&cli.StringFlag{
+			Name:  CLIRenderMode,
+			Value: appconfig.RenderModeTemplate,
+			Usage: fmt.Sprintf("How to render the metrics served on /metrics. Possible values: '%s' (text templates), '%s' (a Prometheus registry of constant metrics, validating the series)",
+				appconfig.RenderModeTemplate, appconfig.RenderModeRegistry),
+			EnvVars: []string{"DCGM_EXPORTER_RENDER_MODE"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableOpenMetrics,
+			Value:   false,
+			Usage:   fmt.Sprintf("Serve /metrics in the OpenMetrics format to the scrapers asking for it, with a '# UNIT' line and the unit suffix on the series of the fields of known unit, e.g. DCGM_FI_DEV_GPU_TEMP_celsius. Requires --%s=%s.", CLIRenderMode, appconfig.RenderModeRegistry),
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_OPENMETRICS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIStaticLabels,
+			Value:   cli.NewStringSlice(),
+			Usage:   "Labels added to every series, as <name>=<value>, e.g. datacenter=dc1.",
+			EnvVars: []string{"DCGM_EXPORTER_STATIC_LABELS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIHostnameOverride,
+			Value:   cli.NewStringSlice(),
+			Usage:   "Hostname label of an entity group, as <group>=<hostname>, where group is one of gpu, switch, link, cpu or cpu_core.",
+			EnvVars: []string{"DCGM_EXPORTER_HOSTNAME_OVERRIDE"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableSelfMetrics,
+			Value:   false,
+			Usage:   "Expose dcgm_exporter_* metrics about the exporter itself, such as render durations.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_SELF_METRICS"},
+		},
+		&cli.IntFlag{
+			Name:    CLIScrapeHistoryCount,
+			Value:   0,
+			Usage:   "Number of recent rendered scrapes kept in memory and served on /metrics/last (0 = disabled).",
+			EnvVars: []string{"DCGM_EXPORTER_SCRAPE_HISTORY_COUNT"},
+		},
+		&cli.IntFlag{
+			Name:    CLIScrapeHistoryMaxBytes,
+			Value:   16 * 1024 * 1024,
+			Usage:   "Maximum total size in bytes of the scrapes kept for /metrics/last (0 = unbounded).",
+			EnvVars: []string{"DCGM_EXPORTER_SCRAPE_HISTORY_MAX_BYTES"},
+		},
+		&cli.StringFlag{
+			Name:    CLIScrapeFile,
+			Value:   "",
+			Usage:   "Debugging: file the output of each scrape of /metrics is written to, replacing the previous one (empty = disabled).",
+			EnvVars: []string{"DCGM_EXPORTER_SCRAPE_FILE"},
+		},
+		&cli.IntFlag{
+			Name:    CLIScrapeFileMaxBytes,
+			Value:   16 * 1024 * 1024,
+			Usage:   "Maximum size in bytes of the output written to the scrape file, cut after its last line within the bound (0 = unbounded).",
+			EnvVars: []string{"DCGM_EXPORTER_SCRAPE_FILE_MAX_BYTES"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableDeltaEndpoint,
+			Value:   false,
+			Usage:   "Serve on /metrics/delta only the series whose value changed since the previous scrape of the endpoint; not standard Prometheus, for a single consumer.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_DELTA_ENDPOINT"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableChecksumTrailer,
+			Value:   false,
+			Usage:   "End the scrapes of /metrics with a '# CHECKSUM sha256 <hex>' comment line, the checksum of the body preceding it.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_CHECKSUM_TRAILER"},
+		},
+		&cli.BoolFlag{
+			Name:    CLICollapseDuplicateSeries,
+			Value:   false,
+			Usage:   "Drop the series duplicating another series of the scrape, e.g. a GPU attributed to the same job by several job mappers, keeping the first and counting the dropped series in dcgm_exporter_duplicate_series_dropped.",
+			EnvVars: []string{"DCGM_EXPORTER_COLLAPSE_DUPLICATE_SERIES"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableFieldTimestamps,
+			Value:   false,
+			Usage:   "Timestamp each sample with the time DCGM last updated its field, so that stale values are told apart; samples of fields without an update time get the scrape time.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_FIELD_TIMESTAMPS"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableEntityKindLabel,
+			Value:   false,
+			Usage:   "Add an entity_kind label to GPU series, \"gpu\" for physical GPUs and \"mig\" for MIG instances.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_ENTITY_KIND_LABEL"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIPromoteFields,
+			Usage:   "DCGM fields, e.g. DCGM_FI_DEV_COMPUTE_MODE, rendered as labels of the other series of the same GPU instead of as series.",
+			EnvVars: []string{"DCGM_EXPORTER_PROMOTE_FIELDS"},
+		},
+		&cli.StringFlag{
+			Name:    CLIFieldIDLabel,
+			Value:   "",
+			Usage:   "Name of a label carrying the DCGM field id of each series, e.g. field_id; disabled when empty.",
+			EnvVars: []string{"DCGM_EXPORTER_FIELD_ID_LABEL"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIFieldIDLabelTypes,
+			Usage:   "Prometheus types, e.g. counter, of the series getting the field id label; all when not set.",
+			EnvVars: []string{"DCGM_EXPORTER_FIELD_ID_LABEL_TYPES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIFieldAlias,
+			Usage:   "Render DCGM fields under a single series name, as <name>=<DCGM_FIELD>[:<DCGM_FIELD>...] in precedence order.",
+			EnvVars: []string{"DCGM_EXPORTER_FIELD_ALIASES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLILinkDirection,
+			Usage:   "Render the TX and RX fields of link and switch series under a single name with a direction=\"tx|rx\" label, as <name>=<TX_DCGM_FIELD>:<RX_DCGM_FIELD>.",
+			EnvVars: []string{"DCGM_EXPORTER_LINK_DIRECTIONS"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableNUMANodeLabel,
+			Value:   false,
+			Usage:   "Label GPU metrics with the NUMA node of the GPU, read from the PCI topology.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_NUMA_NODE_LABEL"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableSerialLabel,
+			Value:   false,
+			Usage:   "Label GPU metrics with serial, the serial number of the GPU, when known; adds a label per GPU to every series.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_SERIAL_LABEL"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableDeviceMinor,
+			Value:   false,
+			Usage:   "Render the minor number of the /dev/nvidia<minor> device of each GPU, read from /proc/driver/nvidia, as the minor_number label instead of the DCGM index of the GPU.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_DEVICE_MINOR"},
+		},
+		&cli.StringFlag{
+			Name:    CLIMinorNumberLabel,
+			Value:   "minor_number",
+			Usage:   "Name of the minor number label of the alternate GPU series and of the Slurm job series, e.g. gpu_index when it is not resolved with --enable-device-minor.",
+			EnvVars: []string{"DCGM_EXPORTER_MINOR_NUMBER_LABEL"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnablePowerLimitLabel,
+			Value:   false,
+			Usage:   "Label GPU metrics with power_limit_watts, the enforced power limit of the GPU, instead of rendering the DCGM_FI_DEV_ENFORCED_POWER_LIMIT series; the field must be collected.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_POWER_LIMIT_LABEL"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableHealthLabel,
+			Value:   false,
+			Usage:   "Label GPU metrics with health, the worst status (PASS, WARN or FAIL) of the GPU health checks; the DCGM_EXP_GPU_HEALTH_STATUS field must be collected.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_HEALTH_LABEL"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableThrottleReasonLabel,
+			Value:   false,
+			Usage:   "Label GPU metrics with throttle_reason, the comma-joined clock throttle reasons of the GPU, e.g. power_cap,sw_thermal, instead of rendering the DCGM_FI_DEV_CLOCKS_EVENT_REASONS bitmask series; the field must be collected.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_THROTTLE_REASON_LABEL"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLINodeGPUUtilBuckets,
+			Usage:   "Upper bounds of the buckets of dcgm_node_gpu_util, a histogram of the DCGM_FI_DEV_GPU_UTIL of the GPUs of the node, e.g. 10,25,50,75,90; the histogram is not rendered without buckets.",
+			EnvVars: []string{"DCGM_EXPORTER_NODE_GPU_UTIL_BUCKETS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIPowerZone,
+			Value:   cli.NewStringSlice(),
+			Usage:   "Power zone of the GPUs with the given UUID, or of the given host, as <uuid|hostname>=<zone>, rendering the power drawn by the GPUs of each zone as dcgm_zone_gpu_power_watts; GPUs of no zone are in the unknown zone.",
+			EnvVars: []string{"DCGM_EXPORTER_POWER_ZONE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIUtilBands,
+			Usage:   "Bands of the util_band label of GPU metrics, as increasing <label>=<upper bound> pairs of DCGM_FI_DEV_GPU_UTIL, e.g. idle=5,low=25,med=75,high=100; the field must be collected.",
+			EnvVars: []string{"DCGM_EXPORTER_UTIL_BANDS"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableMIGInstanceCount,
+			Value:   false,
+			Usage:   "Render dcgm_gpu_mig_instance_count, the number of MIG instances of each GPU, 0 when MIG is disabled.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_MIG_INSTANCE_COUNT"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableMIGNoInstances,
+			Value:   false,
+			Usage:   "Render dcgm_gpu_mig_enabled_no_instances, 1 for each GPU in MIG mode without any compute instance, so without per-instance metrics.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_MIG_NO_INSTANCES_SERIES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIGPULabelOrder,
+			Usage:   "Order of the fixed labels of GPU metrics, e.g. UUID,gpu,Hostname; the labels not named follow in their default order.",
+			EnvVars: []string{"DCGM_EXPORTER_GPU_LABEL_ORDER"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIOmitEmptyGPULabels,
+			Value:   false,
+			Usage:   "Leave out the pci_bus_id, device and modelName labels of GPU metrics when they are empty, e.g. on some virtualized GPUs, instead of rendering them with an empty value.",
+			EnvVars: []string{"DCGM_EXPORTER_OMIT_EMPTY_GPU_LABELS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLITenant,
+			Usage:   "GPUs owned by a tenant and served on /metrics/tenants/<tenant>, as <tenant>=<GPU UUID> or <tenant>=<owner>, where owner is a value of the tenant attribute.",
+			EnvVars: []string{"DCGM_EXPORTER_TENANTS"},
+		},
+		&cli.StringFlag{
+			Name:    CLITenantAttribute,
+			Value:   "userid",
+			Usage:   "Attribute or label of GPU metrics naming their owner, e.g. an account label, matched against the tenant owners.",
+			EnvVars: []string{"DCGM_EXPORTER_TENANT_ATTRIBUTE"},
+		},
+		&cli.StringFlag{
+			Name:  CLITenantLabelMode,
+			Value: appconfig.TenantLabelModeNone,
+			Usage: fmt.Sprintf("How GPU series are marked with the tenant owning them. Possible values: '%s', '%s' (a tenant label), '%s' (the tenant name and an underscore prefix the series name)",
+				appconfig.TenantLabelModeNone, appconfig.TenantLabelModeLabel, appconfig.TenantLabelModePrefix),
+			EnvVars: []string{"DCGM_EXPORTER_TENANT_LABEL_MODE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLICohort,
+			Value:   cli.NewStringSlice(),
+			Usage:   "Rule labeling GPU metrics with cohort=<cohort> when the GPU UUID or model name matches a glob pattern, as <uuid|model>:<pattern>=<cohort>, e.g. model:*A100*=a100 or uuid:GPU-12*=canary. The first matching rule wins.",
+			EnvVars: []string{"DCGM_EXPORTER_COHORT"},
+		},
+		&cli.StringFlag{
+			Name:    CLIDefaultCohort,
+			Value:   "",
+			Usage:   "Cohort of the GPUs matching no cohort rule; they get no cohort label when empty.",
+			EnvVars: []string{"DCGM_EXPORTER_DEFAULT_COHORT"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIModelNameMap,
+			Value:   cli.NewStringSlice(),
+			Usage:   "Rule rendering the GPU model names matching a regular expression as a canonical name, as <regex>=<name>, e.g. 'NVIDIA A100.*80GB=A100-80GB'. The first matching rule wins, other names are unchanged.",
+			EnvVars: []string{"DCGM_EXPORTER_MODEL_NAME_MAP"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIEnabledEntityGroups,
+			Usage:   "The only entity groups rendered, among gpu, switch, link, cpu and cpu_core (default all).",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLED_ENTITY_GROUPS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIIntegerFields,
+			Usage:   "DCGM fields whose integral values are rendered without decimals, besides the error and page counts.",
+			EnvVars: []string{"DCGM_EXPORTER_INTEGER_FIELDS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLIRoundedFields,
+			Usage:   "DCGM fields, or legacy series, whose values are rounded to the nearest integer along with their alternate series, e.g. DCGM_FI_DEV_POWER_USAGE.",
+			EnvVars: []string{"DCGM_EXPORTER_ROUNDED_FIELDS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLISummaryFields,
+			Usage:   "GPU fields rendered as Prometheus summaries of the values DCGM sampled, e.g. DCGM_FI_DEV_GPU_UTIL.",
+			EnvVars: []string{"DCGM_EXPORTER_SUMMARY_FIELDS"},
+		},
+		&cli.DurationFlag{
+			Name:    CLISummaryWindow,
+			Value:   time.Minute,
+			Usage:   "Window of the sampled values the quantiles of the summary fields are computed over (0 = the values sampled since the previous collection).",
+			EnvVars: []string{"DCGM_EXPORTER_SUMMARY_WINDOW"},
+		},
+		&cli.BoolFlag{
+			Name:    CLIEnableGenerationLabel,
+			Value:   false,
+			Usage:   "Debugging: add a collection_generation label, incremented on every collection, to every series of a scrape. Beware of the cardinality.",
+			EnvVars: []string{"DCGM_EXPORTER_ENABLE_GENERATION_LABEL"},
+		},
+		&cli.DurationFlag{
+			Name:    CLIRenderDeadline,
+			Value:   0,
+			Usage:   "Time allowed to render the groups of a scrape, e.g. 5s, past which the groups rendered so far are served and dcgm_exporter_render_deadline_exceeded is incremented (0 = no limit).",
+			EnvVars: []string{"DCGM_EXPORTER_RENDER_DEADLINE"},
+		},
+		&cli.Float64Flag{
+			Name:    CLISampleRate,
+			Value:   0,
+			Usage:   "Sampling, for testing only: fraction of the GPUs and other entities rendered, e.g. 0.5 for every other one (0 = all).",
+			EnvVars: []string{"DCGM_EXPORTER_SAMPLE_RATE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    CLISampleFields,
+			Usage:   "Sampling, for testing only: the only fields rendered for an entity group, as <group>=<DCGM_FIELD>, e.g. gpu=DCGM_FI_DEV_GPU_TEMP.",
+			EnvVars: []string{"DCGM_EXPORTER_SAMPLE_FIELDS"},
+		},
 	}
 
 	if runtime.GOOS == "linux" {
@@ -408,7 +1057,7 @@ func startDCGMExporter(c *cli.Context) error {
 
 		slog.Info("Starting dcgm-exporter", slog.String("Version", version))
 
-		config, err := contextToConfig(c)
+		config, err := loadConfig(c)
 		if err != nil {
 			return err
 		}
@@ -468,10 +1117,25 @@ func startDCGMExporter(c *cli.Context) error {
 
 		sigs := newOSWatcher(syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
 
-		go watchCollectorsFile(config.CollectorsFile, reloadMetricsServer(sigs))
+		restart := make(chan struct{}, 1)
+		go watchCollectorsFile(config.CollectorsFile, reloadMetricsServer(restart))
 
-		sig := <-sigs
-		slog.Info("Received signal", slog.String("signal", sig.String()))
+		var sig os.Signal
+		for sig == nil {
+			select {
+			case <-restart:
+				sig = syscall.SIGHUP
+			case received := <-sigs:
+				slog.Info("Received signal", slog.String("signal", received.String()))
+				if received == syscall.SIGHUP {
+					if reloaded, ok := reloadMappingSettings(c, config, server); ok {
+						config = reloaded
+						continue
+					}
+				}
+				sig = received
+			}
+		}
 		close(stop)
 		cancel() // Cancel the context for this iteration
 		err = utils.WaitWithTimeout(&wg, time.Second*2)
@@ -501,6 +1165,46 @@ func startDCGMExporter(c *cli.Context) error {
 	return nil
 }
 
+// loadConfig reads the configuration of the flags, with the job mapping settings of the mapping
+// settings file applied over them.
+func loadConfig(c *cli.Context) (*appconfig.Config, error) {
+	config, err := contextToConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.HPCMappingSettingsFile != "" {
+		if err := applyMappingSettingsFile(config, config.HPCMappingSettingsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// reloadMappingSettings re-reads the configuration on SIGHUP and applies it to the running job mapping,
+// when only the mapping settings can have changed. It returns false when the exporter must restart
+// instead, i.e. without a mapping settings file or when the job mapping is turned on or off.
+func reloadMappingSettings(
+	c *cli.Context, config *appconfig.Config, metricsServer *server.MetricsServer,
+) (*appconfig.Config, bool) {
+	if config.HPCMappingSettingsFile == "" || config.HPCJobMappingDir == "" {
+		return nil, false
+	}
+
+	reloaded, err := loadConfig(c)
+	if err != nil {
+		slog.Error("Keeping the previous HPC job mapping settings", slog.String(logging.ErrorKey, err.Error()))
+		return config, true
+	}
+	if reloaded.HPCJobMappingDir == "" {
+		return nil, false
+	}
+
+	metricsServer.ReloadTransformations(reloaded)
+	return reloaded, true
+}
+
 func startDeviceWatchListManager(
 	cs *counters.CounterSet, config *appconfig.Config,
 ) devicewatchlistmanager.Manager {
@@ -681,6 +1385,225 @@ func contextToConfig(c *cli.Context) (*appconfig.Config, error) {
 		return nil, fmt.Errorf("invalid %s parameter value: %s", CLIDCGMLogLevel, dcgmLogLevel)
 	}
 
+	legacyMetrics, err := parseLegacyMetrics(c.StringSlice(CLILegacyMetrics))
+	if err != nil {
+		return nil, err
+	}
+
+	duplicateLabelMode := c.String(CLIDuplicateLabelMode)
+	if duplicateLabelMode == "" {
+		duplicateLabelMode = appconfig.DuplicateLabelDrop
+	}
+	if !slices.Contains([]string{
+		appconfig.DuplicateLabelDrop, appconfig.DuplicateLabelPrefix, appconfig.DuplicateLabelError,
+	}, duplicateLabelMode) {
+		return nil, fmt.Errorf("invalid %s parameter value: %s", CLIDuplicateLabelMode, duplicateLabelMode)
+	}
+
+	labelEscaping := c.String(CLILabelEscaping)
+	if labelEscaping == "" {
+		labelEscaping = appconfig.LabelEscapingStrict
+	}
+	if !slices.Contains([]string{appconfig.LabelEscapingStrict, appconfig.LabelEscapingStrip}, labelEscaping) {
+		return nil, fmt.Errorf("invalid %s parameter value: %s", CLILabelEscaping, labelEscaping)
+	}
+
+	mappingFilePattern := c.String(CLIHPCMappingFilePattern)
+	if _, err := filepath.Match(mappingFilePattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid %s parameter value: %s; err: %w", CLIHPCMappingFilePattern, mappingFilePattern, err)
+	}
+
+	mappingEncoding := c.String(CLIHPCJobMappingEncoding)
+	if mappingEncoding == "" {
+		mappingEncoding = appconfig.HPCJobMappingEncodingNone
+	}
+	if !slices.Contains([]string{
+		appconfig.HPCJobMappingEncodingNone, appconfig.HPCJobMappingEncodingBase64Fields, appconfig.HPCJobMappingEncodingBase64Line,
+	}, mappingEncoding) {
+		return nil, fmt.Errorf("invalid %s parameter value: %s", CLIHPCJobMappingEncoding, mappingEncoding)
+	}
+
+	incompleteMIGMode := c.String(CLIHPCIncompleteMIGMode)
+	if incompleteMIGMode == "" {
+		incompleteMIGMode = appconfig.IncompleteMIGModeParent
+	}
+	if !slices.Contains([]string{appconfig.IncompleteMIGModeParent, appconfig.IncompleteMIGModeMark}, incompleteMIGMode) {
+		return nil, fmt.Errorf("invalid %s parameter value: %s", CLIHPCIncompleteMIGMode, incompleteMIGMode)
+	}
+
+	lineEnding := c.String(CLILineEnding)
+	if lineEnding == "" {
+		lineEnding = appconfig.LineEndingLF
+	}
+	if !slices.Contains([]string{appconfig.LineEndingLF, appconfig.LineEndingCRLF}, lineEnding) {
+		return nil, fmt.Errorf("invalid %s parameter value: %s", CLILineEnding, lineEnding)
+	}
+
+	renderMode := c.String(CLIRenderMode)
+	if renderMode == "" {
+		renderMode = appconfig.RenderModeTemplate
+	}
+	if !slices.Contains([]string{appconfig.RenderModeTemplate, appconfig.RenderModeRegistry}, renderMode) {
+		return nil, fmt.Errorf("invalid %s parameter value: %s", CLIRenderMode, renderMode)
+	}
+	if c.Bool(CLIEnableOpenMetrics) && renderMode != appconfig.RenderModeRegistry {
+		return nil, fmt.Errorf("%s requires the %s render mode", CLIEnableOpenMetrics, appconfig.RenderModeRegistry)
+	}
+
+	if c.Bool(CLIHPCJobGPUSeconds) && c.String(CLIHPCJobMappingDir) == "" {
+		return nil, fmt.Errorf("%s requires %s", CLIHPCJobGPUSeconds, CLIHPCJobMappingDir)
+	}
+
+	staticLabels, err := parseStaticLabels(c.StringSlice(CLIStaticLabels))
+	if err != nil {
+		return nil, err
+	}
+
+	fieldIDLabel := c.String(CLIFieldIDLabel)
+	if fieldIDLabel != "" {
+		if err := rendermetrics.ValidateLabelName(fieldIDLabel); err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value; err: %w", CLIFieldIDLabel, err)
+		}
+	}
+
+	gpuLabelOrder := c.StringSlice(CLIGPULabelOrder)
+	if err := rendermetrics.ValidateGPULabelOrder(gpuLabelOrder); err != nil {
+		return nil, fmt.Errorf("invalid %s parameter value; err: %w", CLIGPULabelOrder, err)
+	}
+
+	// the default name is left empty, as it is a fixed label
+	minorNumberLabel := c.String(CLIMinorNumberLabel)
+	if minorNumberLabel == "minor_number" {
+		minorNumberLabel = ""
+	}
+	if minorNumberLabel != "" {
+		if err := rendermetrics.ValidateLabelName(minorNumberLabel); err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value; err: %w", CLIMinorNumberLabel, err)
+		}
+	}
+
+	fieldAliases, err := parseFieldAliases(c.StringSlice(CLIFieldAlias))
+	if err != nil {
+		return nil, err
+	}
+
+	linkDirections, err := parseLinkDirections(c.StringSlice(CLILinkDirection))
+	if err != nil {
+		return nil, err
+	}
+
+	var idRewriteRegex *regexp.Regexp
+	if pattern := c.String(CLIHPCIDRewriteRegex); pattern != "" {
+		idRewriteRegex, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; err: %w", CLIHPCIDRewriteRegex, pattern, err)
+		}
+	}
+	var procJobRegex *regexp.Regexp
+	if pattern := c.String(CLIHPCProcJobRegex); pattern != "" {
+		procJobRegex, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; err: %w", CLIHPCProcJobRegex, pattern, err)
+		}
+		if procJobRegex.NumSubexp() == 0 {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; expected a group capturing the job id",
+				CLIHPCProcJobRegex, pattern)
+		}
+	}
+	idRewriteAttributes := c.StringSlice(CLIHPCIDRewriteAttributes)
+	for _, attribute := range idRewriteAttributes {
+		switch attribute {
+		case transformation.HpcJobAttribute, transformation.HpcUserAttribute, transformation.HpcAccountAttribute:
+		default:
+			return nil, fmt.Errorf("invalid %s parameter value: %s; expected jobid, userid or account",
+				CLIHPCIDRewriteAttributes, attribute)
+		}
+	}
+
+	if summaryWindow := c.Duration(CLISummaryWindow); summaryWindow < 0 {
+		return nil, fmt.Errorf("invalid %s parameter value: %s, must not be negative", CLISummaryWindow, summaryWindow)
+	}
+	if filesInfo := c.Int(CLIHPCMappingFilesInfo); filesInfo < 0 {
+		return nil, fmt.Errorf("invalid %s parameter value: %d, must not be negative", CLIHPCMappingFilesInfo, filesInfo)
+	}
+
+	for _, name := range []string{
+		CLIHPCJobMappingSocketRefresh, CLIHPCJobMappingDBRefresh, CLIHPCJobMappingURLRefresh, CLIHPCMPSRefresh,
+	} {
+		if refresh := c.Duration(name); refresh < 0 {
+			return nil, fmt.Errorf("invalid %s parameter value: %s, must not be negative", name, refresh)
+		}
+	}
+
+	if concurrency := c.Int(CLIHPCJobMappingConcurrency); concurrency < 1 {
+		return nil, fmt.Errorf("invalid %s parameter value: %d, must be at least 1", CLIHPCJobMappingConcurrency, concurrency)
+	}
+
+	sampleRate := c.Float64(CLISampleRate)
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("invalid %s parameter value: %v, must be between 0 and 1", CLISampleRate, sampleRate)
+	}
+
+	sampleFields, err := parseSampleFields(c.StringSlice(CLISampleFields))
+	if err != nil {
+		return nil, err
+	}
+
+	hostnameOverrides, err := parseHostnameOverrides(c.StringSlice(CLIHostnameOverride))
+	if err != nil {
+		return nil, err
+	}
+
+	tenants, err := parseTenants(c.StringSlice(CLITenant))
+	if err != nil {
+		return nil, err
+	}
+
+	tenantLabelMode := c.String(CLITenantLabelMode)
+	if tenantLabelMode == "" {
+		tenantLabelMode = appconfig.TenantLabelModeNone
+	}
+	if !slices.Contains([]string{
+		appconfig.TenantLabelModeNone, appconfig.TenantLabelModeLabel, appconfig.TenantLabelModePrefix,
+	}, tenantLabelMode) {
+		return nil, fmt.Errorf("invalid %s parameter value: %s", CLITenantLabelMode, tenantLabelMode)
+	}
+	if tenantLabelMode == appconfig.TenantLabelModePrefix {
+		if err := rendermetrics.ValidateTenantPrefixes(tenants); err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value; err: %w", CLITenant, err)
+		}
+	}
+
+	nodeGPUUtilBuckets, err := parseNodeGPUUtilBuckets(c.StringSlice(CLINodeGPUUtilBuckets))
+	if err != nil {
+		return nil, err
+	}
+
+	powerZones, err := parsePowerZones(c.StringSlice(CLIPowerZone))
+	if err != nil {
+		return nil, err
+	}
+
+	utilBands, err := parseUtilBands(c.StringSlice(CLIUtilBands))
+	if err != nil {
+		return nil, err
+	}
+
+	cohortRules, err := parseCohortRules(c.StringSlice(CLICohort))
+	if err != nil {
+		return nil, err
+	}
+
+	modelNameRules, err := parseModelNameRules(c.StringSlice(CLIModelNameMap))
+	if err != nil {
+		return nil, err
+	}
+
+	enabledEntityGroups, err := parseEntityGroups(c.StringSlice(CLIEnabledEntityGroups))
+	if err != nil {
+		return nil, err
+	}
+
 	return &appconfig.Config{
 		CollectorsFile:             c.String(CLIFieldsFile),
 		Address:                    c.String(CLIAddress),
@@ -709,18 +1632,441 @@ func contextToConfig(c *cli.Context) (*appconfig.Config, error) {
 		DCGMLogLevel:               dcgmLogLevel,
 		PodResourcesKubeletSocket:  c.String(CLIPodResourcesKubeletSocket),
 		HPCJobMappingDir:           c.String(CLIHPCJobMappingDir),
+		HPCJobMappingSocket:        c.String(CLIHPCJobMappingSocket),
+		HPCJobMappingSocketTTL:     c.Duration(CLIHPCJobMappingSocketTTL),
+		HPCJobMappingSocketRefresh: c.Duration(CLIHPCJobMappingSocketRefresh),
+		HPCJobMappingDB:            c.String(CLIHPCJobMappingDB),
+		HPCJobMappingDBQuery:       c.String(CLIHPCJobMappingDBQuery),
+		HPCJobMappingDBTTL:         c.Duration(CLIHPCJobMappingDBTTL),
+		HPCJobMappingDBRefresh:     c.Duration(CLIHPCJobMappingDBRefresh),
+		HPCJobMappingURL:           c.String(CLIHPCJobMappingURL),
+		HPCJobMappingURLTTL:        c.Duration(CLIHPCJobMappingURLTTL),
+		HPCJobMappingURLRefresh:    c.Duration(CLIHPCJobMappingURLRefresh),
+		HPCJobMappingConcurrency:   c.Int(CLIHPCJobMappingConcurrency),
+		HPCMappingLingerDuration:   c.Duration(CLIHPCMappingLinger),
+		HPCMappingStaleAfter:       c.Duration(CLIHPCMappingStaleAfter),
+		HPCJobPlaceholder:          c.String(CLIHPCJobPlaceholder),
+		HPCJobMappingNodeFile:      c.String(CLIHPCJobMappingNodeFile),
+		HPCJobMappingManifest:      c.String(CLIHPCJobMappingManifest),
+		HPCJobMappingGRESFile:      c.String(CLIHPCJobMappingGRESFile),
+		HPCMappingFilePattern:      mappingFilePattern,
+		HPCJobMappingEncoding:      mappingEncoding,
+		HPCIncompleteMIGMode:       incompleteMIGMode,
+		HPCMPSPIDFile:              c.String(CLIHPCMPSPIDFile),
+		HPCMPSRefresh:              c.Duration(CLIHPCMPSRefresh),
+		HPCMPSPmonFile:             c.String(CLIHPCMPSPmonFile),
+		HPCJobEnvVar:               c.String(CLIHPCJobEnvVar),
+		HPCUserEnvVar:              c.String(CLIHPCUserEnvVar),
+		HPCAccountEnvVar:           c.String(CLIHPCAccountEnvVar),
+		HPCProcJobRegex:            procJobRegex,
+		HPCProcRoot:                c.String(CLIHPCProcRoot),
+		HPCProcScanInterval:        c.Duration(CLIHPCProcScanInterval),
+		HPCIDRewriteRegex:          idRewriteRegex,
+		HPCIDRewriteReplacement:    c.String(CLIHPCIDRewriteReplacement),
+		HPCIDRewriteAttributes:     idRewriteAttributes,
+		HPCMappingFileAttribute:    c.Bool(CLIHPCMappingFileAttribute),
+		HPCMappingFilesInfo:        c.Int(CLIHPCMappingFilesInfo),
+		HPCSharingAttribute:        c.Bool(CLIHPCSharingAttribute),
+		HPCCounterResetAttribute:   c.Bool(CLIHPCCounterResetAttribute),
+		HPCEnergyCounter:           c.Bool(CLIHPCEnergyCounter),
+		HPCJobGPUSeconds:           c.Bool(CLIHPCJobGPUSeconds),
+		HPCMaxMappingFileBytes:     c.Int64(CLIHPCMaxMappingFileBytes),
+		HPCMappingSettingsFile:     c.String(CLIHPCMappingSettingsFile),
 		NvidiaResourceNames:        c.StringSlice(CLINvidiaResourceNames),
 		KubernetesVirtualGPUs:      c.Bool(CLIKubernetesVirtualGPUs),
+		KubernetesDeviceCheckpoint: c.String(CLIKubernetesDeviceCheckpoint),
 		DumpConfig: appconfig.DumpConfig{
 			Enabled:     c.Bool(CLIDumpEnabled),
 			Directory:   c.String(CLIDumpDirectory),
 			Retention:   c.Int(CLIDumpRetention),
 			Compression: c.Bool(CLIDumpCompression),
 		},
-		KubernetesEnableDRA: c.Bool(CLIKubernetesEnableDRA),
+		KubernetesEnableDRA:       c.Bool(CLIKubernetesEnableDRA),
+		LegacyMetrics:             legacyMetrics,
+		DuplicateLabelMode:        duplicateLabelMode,
+		LabelEscaping:             labelEscaping,
+		LineEnding:                lineEnding,
+		RenderMode:                renderMode,
+		EnableOpenMetrics:         c.Bool(CLIEnableOpenMetrics),
+		StaticLabels:              staticLabels,
+		HostnameOverrides:         hostnameOverrides,
+		EnableSelfMetrics:         c.Bool(CLIEnableSelfMetrics),
+		ScrapeHistoryCount:        c.Int(CLIScrapeHistoryCount),
+		ScrapeHistoryMaxBytes:     c.Int(CLIScrapeHistoryMaxBytes),
+		ScrapeFile:                c.String(CLIScrapeFile),
+		ScrapeFileMaxBytes:        c.Int(CLIScrapeFileMaxBytes),
+		EnableDeltaEndpoint:       c.Bool(CLIEnableDeltaEndpoint),
+		EnableChecksumTrailer:     c.Bool(CLIEnableChecksumTrailer),
+		CollapseDuplicateSeries:   c.Bool(CLICollapseDuplicateSeries),
+		EnableFieldTimestamps:     c.Bool(CLIEnableFieldTimestamps),
+		EnableEntityKindLabel:     c.Bool(CLIEnableEntityKindLabel),
+		PromotedFields:            c.StringSlice(CLIPromoteFields),
+		FieldIDLabel:              fieldIDLabel,
+		FieldIDLabelTypes:         c.StringSlice(CLIFieldIDLabelTypes),
+		FieldAliases:              fieldAliases,
+		LinkDirections:            linkDirections,
+		EnableNUMANodeLabel:       c.Bool(CLIEnableNUMANodeLabel),
+		EnableSerialLabel:         c.Bool(CLIEnableSerialLabel),
+		EnableDeviceMinor:         c.Bool(CLIEnableDeviceMinor),
+		MinorNumberLabel:          minorNumberLabel,
+		EnablePowerLimitLabel:     c.Bool(CLIEnablePowerLimitLabel),
+		EnableHealthLabel:         c.Bool(CLIEnableHealthLabel),
+		EnableThrottleReasonLabel: c.Bool(CLIEnableThrottleReasonLabel),
+		NodeGPUUtilBuckets:        nodeGPUUtilBuckets,
+		PowerZones:                powerZones,
+		UtilizationBands:          utilBands,
+		EnableMIGInstanceCount:    c.Bool(CLIEnableMIGInstanceCount),
+		EnableMIGNoInstances:      c.Bool(CLIEnableMIGNoInstances),
+		GPULabelOrder:             gpuLabelOrder,
+		OmitEmptyGPULabels:        c.Bool(CLIOmitEmptyGPULabels),
+		Tenants:                   tenants,
+		TenantAttribute:           c.String(CLITenantAttribute),
+		TenantLabelMode:           tenantLabelMode,
+		CohortRules:               cohortRules,
+		DefaultCohort:             c.String(CLIDefaultCohort),
+		ModelNameRules:            modelNameRules,
+		EnabledEntityGroups:       enabledEntityGroups,
+		IntegerFields:             c.StringSlice(CLIIntegerFields),
+		RoundedFields:             c.StringSlice(CLIRoundedFields),
+		SummaryFields:             c.StringSlice(CLISummaryFields),
+		SummaryWindow:             c.Duration(CLISummaryWindow),
+		EnableGenerationLabel:     c.Bool(CLIEnableGenerationLabel),
+		RenderDeadline:            c.Duration(CLIRenderDeadline),
+		SampleRate:                sampleRate,
+		SampleFields:              sampleFields,
 	}, nil
 }
 
+// entityGroups are the entity group names accepted by the per group parameters
+var entityGroups = map[string]dcgm.Field_Entity_Group{
+	"gpu":      dcgm.FE_GPU,
+	"switch":   dcgm.FE_SWITCH,
+	"link":     dcgm.FE_LINK,
+	"cpu":      dcgm.FE_CPU,
+	"cpu_core": dcgm.FE_CPU_CORE,
+}
+
+// parseEntityGroups parses entity group names.
+func parseEntityGroups(values []string) ([]dcgm.Field_Entity_Group, error) {
+	var groups []dcgm.Field_Entity_Group
+
+	for _, value := range values {
+		group, ok := entityGroups[strings.ToLower(value)]
+		if !ok {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLIEnabledEntityGroups, value)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// parseHostnameOverrides parses <group>=<hostname> entries.
+func parseHostnameOverrides(values []string) (map[dcgm.Field_Entity_Group]string, error) {
+	hostnameOverrides := map[dcgm.Field_Entity_Group]string{}
+
+	for _, value := range values {
+		groupName, hostname, found := strings.Cut(value, "=")
+		group, ok := entityGroups[strings.ToLower(groupName)]
+		if !found || !ok || hostname == "" {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLIHostnameOverride, value)
+		}
+		hostnameOverrides[group] = hostname
+	}
+
+	return hostnameOverrides, nil
+}
+
+// parseTenants parses <tenant>=<GPU UUID> and <tenant>=<owner> entries.
+func parseTenants(values []string) (map[string]appconfig.Tenant, error) {
+	tenants := map[string]appconfig.Tenant{}
+
+	for _, value := range values {
+		name, owner, found := strings.Cut(value, "=")
+		if !found || name == "" || owner == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLITenant, value)
+		}
+		tenant := tenants[name]
+		if strings.HasPrefix(owner, "GPU-") {
+			tenant.GPUUUIDs = append(tenant.GPUUUIDs, owner)
+		} else {
+			tenant.Owners = append(tenant.Owners, owner)
+		}
+		tenants[name] = tenant
+	}
+
+	return tenants, nil
+}
+
+// parseCohortRules parses <uuid|model>:<pattern>=<cohort> entries, in order.
+func parseCohortRules(values []string) ([]appconfig.CohortRule, error) {
+	var rules []appconfig.CohortRule
+
+	for _, value := range values {
+		field, rest, found := strings.Cut(value, ":")
+		// the cohort follows the last "=", the pattern may contain some
+		sep := strings.LastIndex(rest, "=")
+		if !found || sep <= 0 || sep == len(rest)-1 ||
+			(field != appconfig.CohortMatchUUID && field != appconfig.CohortMatchModel) {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLICohort, value)
+		}
+		pattern := rest[:sep]
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; err: %w", CLICohort, value, err)
+		}
+		rules = append(rules, appconfig.CohortRule{Field: field, Pattern: pattern, Cohort: rest[sep+1:]})
+	}
+
+	return rules, nil
+}
+
+// parseModelNameRules parses <regex>=<name> model name rules.
+func parseModelNameRules(values []string) ([]appconfig.ModelNameRule, error) {
+	var rules []appconfig.ModelNameRule
+
+	for _, value := range values {
+		// the name follows the last "=", the pattern may contain some
+		sep := strings.LastIndex(value, "=")
+		if sep <= 0 || sep == len(value)-1 {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; expected <regex>=<name>", CLIModelNameMap, value)
+		}
+		pattern, err := regexp.Compile(value[:sep])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; err: %w", CLIModelNameMap, value, err)
+		}
+		rules = append(rules, appconfig.ModelNameRule{Pattern: pattern, Name: value[sep+1:]})
+	}
+
+	return rules, nil
+}
+
+// parseNodeGPUUtilBuckets parses the upper bounds of the node GPU utilization histogram buckets,
+// which must be increasing finite numbers; the +Inf bucket is implied.
+func parseNodeGPUUtilBuckets(values []string) ([]float64, error) {
+	var bounds []float64
+
+	for _, value := range values {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || math.IsInf(bound, 0) || math.IsNaN(bound) {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLINodeGPUUtilBuckets, value)
+		}
+		if len(bounds) > 0 && bound <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; buckets must be increasing", CLINodeGPUUtilBuckets, value)
+		}
+		bounds = append(bounds, bound)
+	}
+
+	return bounds, nil
+}
+
+// parsePowerZones parses <uuid|hostname>=<zone> entries.
+func parsePowerZones(values []string) (map[string]string, error) {
+	zones := map[string]string{}
+
+	for _, value := range values {
+		key, zone, found := strings.Cut(value, "=")
+		if !found || key == "" || zone == "" {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; expected <uuid|hostname>=<zone>", CLIPowerZone, value)
+		}
+		zones[key] = zone
+	}
+
+	return zones, nil
+}
+
+// parseUtilBands parses <label>=<upper bound> utilization bands, whose labels must be distinct and
+// upper bounds increasing finite numbers.
+func parseUtilBands(values []string) ([]appconfig.UtilizationBand, error) {
+	var bands []appconfig.UtilizationBand
+
+	for _, value := range values {
+		label, boundValue, ok := strings.Cut(strings.TrimSpace(value), "=")
+		label = strings.TrimSpace(label)
+		if !ok || label == "" {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; expected <label>=<upper bound>", CLIUtilBands, value)
+		}
+		bound, err := strconv.ParseFloat(strings.TrimSpace(boundValue), 64)
+		if err != nil || math.IsInf(bound, 0) || math.IsNaN(bound) {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLIUtilBands, value)
+		}
+		for _, band := range bands {
+			if band.Label == label {
+				return nil, fmt.Errorf("invalid %s parameter value: %s; duplicate label", CLIUtilBands, value)
+			}
+		}
+		if len(bands) > 0 && bound <= bands[len(bands)-1].UpperBound {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; bands must be increasing", CLIUtilBands, value)
+		}
+		bands = append(bands, appconfig.UtilizationBand{Label: label, UpperBound: bound})
+	}
+
+	return bands, nil
+}
+
+// parseSampleFields parses <group>=<DCGM_FIELD> entries.
+func parseSampleFields(values []string) (map[dcgm.Field_Entity_Group][]string, error) {
+	sampleFields := map[dcgm.Field_Entity_Group][]string{}
+
+	for _, value := range values {
+		groupName, field, found := strings.Cut(value, "=")
+		group, ok := entityGroups[strings.ToLower(groupName)]
+		if !found || !ok || field == "" {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLISampleFields, value)
+		}
+		sampleFields[group] = append(sampleFields[group], field)
+	}
+
+	return sampleFields, nil
+}
+
+// parseStaticLabels parses <name>=<value> entries.
+func parseStaticLabels(values []string) (map[string]string, error) {
+	staticLabels := map[string]string{}
+
+	for _, value := range values {
+		name, labelValue, found := strings.Cut(value, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLIStaticLabels, value)
+		}
+		staticLabels[name] = labelValue
+	}
+
+	if err := rendermetrics.ValidateStaticLabels(staticLabels); err != nil {
+		return nil, fmt.Errorf("invalid %s parameter value; err: %w", CLIStaticLabels, err)
+	}
+
+	return staticLabels, nil
+}
+
+// applyMappingSettingsFile overrides the job mapping settings of the configuration with the
+// <flag>=<value> lines of the file; empty lines and lines starting with # are skipped.
+func applyMappingSettingsFile(config *appconfig.Config, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid %s parameter value: %s; err: %w", CLIHPCMappingSettingsFile, path, err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !found {
+			return fmt.Errorf("invalid %s line: %s", path, line)
+		}
+
+		switch name {
+		case CLIHPCJobMappingDir:
+			config.HPCJobMappingDir = value
+		case CLIHPCMappingFilePattern:
+			if _, err := filepath.Match(value, ""); err != nil {
+				return fmt.Errorf("invalid %s value in %s: %s; err: %w", name, path, value, err)
+			}
+			config.HPCMappingFilePattern = value
+		case CLIHPCJobMappingNodeFile:
+			config.HPCJobMappingNodeFile = value
+		case CLIHPCJobMappingManifest:
+			config.HPCJobMappingManifest = value
+		case CLIHPCJobMappingGRESFile:
+			config.HPCJobMappingGRESFile = value
+		case CLIHPCJobPlaceholder:
+			config.HPCJobPlaceholder = value
+		case CLIHPCMappingFileAttribute, CLIHPCSharingAttribute:
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s value in %s: %s; err: %w", name, path, value, err)
+			}
+			if name == CLIHPCMappingFileAttribute {
+				config.HPCMappingFileAttribute = enabled
+			} else {
+				config.HPCSharingAttribute = enabled
+			}
+		default:
+			return fmt.Errorf("unsupported setting in %s: %s", path, name)
+		}
+	}
+
+	return nil
+}
+
+// parseFieldAliases parses <name>=<DCGM_FIELD>[:<DCGM_FIELD>...] entries.
+func parseFieldAliases(values []string) (map[string][]string, error) {
+	fieldAliases := map[string][]string{}
+
+	for _, value := range values {
+		name, fields, found := strings.Cut(value, "=")
+		if !found || name == "" || fields == "" {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLIFieldAlias, value)
+		}
+		if _, exists := fieldAliases[name]; exists {
+			return nil, fmt.Errorf("invalid %s parameter value: %s is aliased twice", CLIFieldAlias, name)
+		}
+		fieldAliases[name] = strings.Split(fields, ":")
+		if slices.Contains(fieldAliases[name], "") {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLIFieldAlias, value)
+		}
+	}
+
+	return fieldAliases, nil
+}
+
+// parseLinkDirections parses <name>=<TX_DCGM_FIELD>:<RX_DCGM_FIELD> entries.
+func parseLinkDirections(values []string) (map[string]appconfig.LinkDirection, error) {
+	linkDirections := map[string]appconfig.LinkDirection{}
+
+	for _, value := range values {
+		name, fields, found := strings.Cut(value, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLILinkDirection, value)
+		}
+		if _, exists := linkDirections[name]; exists {
+			return nil, fmt.Errorf("invalid %s parameter value: %s is directed twice", CLILinkDirection, name)
+		}
+		tx, rx, found := strings.Cut(fields, ":")
+		if !found || tx == "" || rx == "" || tx == rx || strings.Contains(rx, ":") {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLILinkDirection, value)
+		}
+		linkDirections[name] = appconfig.LinkDirection{TXField: tx, RXField: rx}
+	}
+
+	return linkDirections, nil
+}
+
+// parseLegacyMetrics parses <DCGM_FIELD>=<legacy_name>[:<multiplier>] entries.
+func parseLegacyMetrics(values []string) (map[string]appconfig.LegacyMetric, error) {
+	legacyMetrics := map[string]appconfig.LegacyMetric{}
+
+	for _, value := range values {
+		field, legacy, found := strings.Cut(value, "=")
+		if !found || field == "" || legacy == "" {
+			return nil, fmt.Errorf("invalid %s parameter value: %s", CLILegacyMetrics, value)
+		}
+
+		name, multiplierStr, found := strings.Cut(legacy, ":")
+		if err := rendermetrics.ValidateMetricName(name); err != nil {
+			return nil, fmt.Errorf("invalid %s parameter value: %s; err: %w", CLILegacyMetrics, value, err)
+		}
+		multiplier := 1
+		if found {
+			var err error
+			multiplier, err = strconv.Atoi(multiplierStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s multiplier in %q; err: %w", CLILegacyMetrics, value, err)
+			}
+			if multiplier == 0 {
+				return nil, fmt.Errorf("invalid %s multiplier in %q; expected a non-zero multiplier", CLILegacyMetrics, value)
+			}
+		}
+
+		legacyMetrics[field] = appconfig.LegacyMetric{Name: name, Multiplier: multiplier}
+	}
+
+	return legacyMetrics, nil
+}
+
 func watchCollectorsFile(filePath string, onChange func()) {
 	slog.Info("Watching for changes in file", slog.String("file", filePath))
 	watcher, err := fsnotify.NewWatcher()
@@ -771,10 +2117,13 @@ func watchCollectorsFile(filePath string, onChange func()) {
 	select {}
 }
 
-func reloadMetricsServer(s chan os.Signal) func() {
-	// all we have to do is send a sighup
+func reloadMetricsServer(restart chan struct{}) func() {
+	// all we have to do is ask for a restart, a pending one covers the later changes
 	return func() {
 		slog.Info("Reloading metrics server")
-		s <- syscall.SIGHUP
+		select {
+		case restart <- struct{}{}:
+		default:
+		}
 	}
 }
@@ -18,6 +18,8 @@ package cmd
 
 import (
 	"flag"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -284,6 +286,9 @@ func Test_contextToConfig_DumpConfig(t *testing.T) {
 			if _, ok := tt.flags[CLIDCGMLogLevel]; !ok {
 				set.String(CLIDCGMLogLevel, "NONE", "")
 			}
+			if _, ok := tt.flags[CLIHPCJobMappingConcurrency]; !ok {
+				set.Int(CLIHPCJobMappingConcurrency, 1, "")
+			}
 			// Set defaults for dump config flags if not present
 			if _, ok := tt.flags[CLIDumpEnabled]; !ok {
 				set.Bool(CLIDumpEnabled, false, "")
@@ -329,3 +334,212 @@ func Test_contextToConfig_DumpConfig(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseLegacyMetrics(t *testing.T) {
+	got, err := parseLegacyMetrics([]string{
+		"DCGM_FI_DEV_GPU_UTIL=nvidia_gpu_duty_cycle",
+		"DCGM_FI_DEV_FB_FREE=nvidia_gpu_memory_total_bytes:1048576",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]appconfig.LegacyMetric{
+		"DCGM_FI_DEV_GPU_UTIL": {Name: "nvidia_gpu_duty_cycle", Multiplier: 1},
+		"DCGM_FI_DEV_FB_FREE":  {Name: "nvidia_gpu_memory_total_bytes", Multiplier: 1048576},
+	}, got)
+
+	_, err = parseLegacyMetrics([]string{"DCGM_FI_DEV_GPU_UTIL"})
+	assert.Error(t, err)
+
+	for _, value := range []string{
+		"DCGM_FI_DEV_FB_FREE=nvidia_gpu_memory_total_bytes:MB",
+		"DCGM_FI_DEV_FB_FREE=nvidia_gpu_memory_total_bytes:0",
+		"DCGM_FI_DEV_FB_FREE=:1048576",
+		"DCGM_FI_DEV_FB_FREE=nvidia-gpu-memory",
+		"DCGM_FI_DEV_FB_FREE=0nvidia_gpu_memory",
+	} {
+		_, err = parseLegacyMetrics([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseFieldAliases(t *testing.T) {
+	got, err := parseFieldAliases([]string{"gpu_memory_temp=DCGM_FI_DEV_MEMORY_TEMP:DCGM_FI_DEV_GPU_TEMP"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"gpu_memory_temp": {"DCGM_FI_DEV_MEMORY_TEMP", "DCGM_FI_DEV_GPU_TEMP"},
+	}, got)
+
+	for _, value := range []string{"gpu_memory_temp", "=DCGM_FI_DEV_GPU_TEMP", "gpu_memory_temp=DCGM_FI_DEV_GPU_TEMP:"} {
+		_, err = parseFieldAliases([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseLinkDirections(t *testing.T) {
+	got, err := parseLinkDirections([]string{"nvlink_bytes=DCGM_FI_DEV_NVLINK_TX_BYTES:DCGM_FI_DEV_NVLINK_RX_BYTES"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]appconfig.LinkDirection{
+		"nvlink_bytes": {TXField: "DCGM_FI_DEV_NVLINK_TX_BYTES", RXField: "DCGM_FI_DEV_NVLINK_RX_BYTES"},
+	}, got)
+
+	for _, value := range []string{
+		"nvlink_bytes", "=DCGM_FI_DEV_NVLINK_TX_BYTES:DCGM_FI_DEV_NVLINK_RX_BYTES", "nvlink_bytes=DCGM_FI_DEV_NVLINK_TX_BYTES",
+		"nvlink_bytes=DCGM_FI_DEV_NVLINK_TX_BYTES:", "nvlink_bytes=A:B:C", "nvlink_bytes=A:A",
+	} {
+		_, err = parseLinkDirections([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseUtilBands(t *testing.T) {
+	got, err := parseUtilBands([]string{"idle=5", " low = 25", "high=100"})
+	require.NoError(t, err)
+	assert.Equal(t, []appconfig.UtilizationBand{
+		{Label: "idle", UpperBound: 5}, {Label: "low", UpperBound: 25}, {Label: "high", UpperBound: 100},
+	}, got)
+
+	for _, values := range [][]string{{"idle"}, {"=5"}, {"idle=x"}, {"idle=+Inf"}, {"idle=5", "low=5"}, {"idle=5", "idle=25"}} {
+		_, err = parseUtilBands(values)
+		assert.Error(t, err, values)
+	}
+}
+
+func Test_parseSampleFields(t *testing.T) {
+	got, err := parseSampleFields([]string{"gpu=DCGM_FI_DEV_GPU_TEMP", "GPU=DCGM_FI_DEV_POWER_USAGE", "switch=DCGM_FI_DEV_NVSWITCH_TEMPERATURE_CURRENT"})
+	require.NoError(t, err)
+	assert.Equal(t, map[dcgm.Field_Entity_Group][]string{
+		dcgm.FE_GPU:    {"DCGM_FI_DEV_GPU_TEMP", "DCGM_FI_DEV_POWER_USAGE"},
+		dcgm.FE_SWITCH: {"DCGM_FI_DEV_NVSWITCH_TEMPERATURE_CURRENT"},
+	}, got)
+
+	for _, value := range []string{"gpu", "gpu=", "vgpu=DCGM_FI_DEV_GPU_TEMP"} {
+		_, err = parseSampleFields([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseStaticLabels(t *testing.T) {
+	got, err := parseStaticLabels([]string{"datacenter=dc1", "rack=r12"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"datacenter": "dc1", "rack": "r12"}, got)
+
+	for _, value := range []string{"datacenter", "data-center=dc1", "__name__=dc1", "gpu=0", "Hostname=node1", "jobid=1"} {
+		_, err = parseStaticLabels([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseHostnameOverrides(t *testing.T) {
+	got, err := parseHostnameOverrides([]string{"cpu=aggregator", "SWITCH=aggregator"})
+	require.NoError(t, err)
+	assert.Equal(t, map[dcgm.Field_Entity_Group]string{
+		dcgm.FE_CPU:    "aggregator",
+		dcgm.FE_SWITCH: "aggregator",
+	}, got)
+
+	for _, value := range []string{"cpu", "cpu=", "vgpu=aggregator"} {
+		_, err = parseHostnameOverrides([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseEntityGroups(t *testing.T) {
+	got, err := parseEntityGroups([]string{"gpu", "CPU_CORE"})
+	require.NoError(t, err)
+	assert.Equal(t, []dcgm.Field_Entity_Group{dcgm.FE_GPU, dcgm.FE_CPU_CORE}, got)
+
+	_, err = parseEntityGroups([]string{"vgpu"})
+	assert.Error(t, err)
+}
+
+func Test_parseTenants(t *testing.T) {
+	got, err := parseTenants([]string{"physics=GPU-0", "physics=phys-acct", "chemistry=chem-acct"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]appconfig.Tenant{
+		"physics":   {GPUUUIDs: []string{"GPU-0"}, Owners: []string{"phys-acct"}},
+		"chemistry": {Owners: []string{"chem-acct"}},
+	}, got)
+
+	for _, value := range []string{"physics", "physics=", "=GPU-0", "a/b=GPU-0"} {
+		_, err = parseTenants([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseCohortRules(t *testing.T) {
+	got, err := parseCohortRules([]string{"uuid:GPU-12*=canary", "model:*A100*=a100", "model:a=b=c"})
+	require.NoError(t, err)
+	assert.Equal(t, []appconfig.CohortRule{
+		{Field: appconfig.CohortMatchUUID, Pattern: "GPU-12*", Cohort: "canary"},
+		{Field: appconfig.CohortMatchModel, Pattern: "*A100*", Cohort: "a100"},
+		{Field: appconfig.CohortMatchModel, Pattern: "a=b", Cohort: "c"},
+	}, got)
+
+	for _, value := range []string{"model", "model:*A100*", "model:*A100*=", "model:=a100", "serial:1*=a", "uuid:GPU-[=a"} {
+		_, err = parseCohortRules([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseModelNameRules(t *testing.T) {
+	got, err := parseModelNameRules([]string{"NVIDIA A100.*80GB=A100-80GB", "H100 (?P<x>=)?=H100"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "NVIDIA A100.*80GB", got[0].Pattern.String())
+	assert.Equal(t, "A100-80GB", got[0].Name)
+	assert.Equal(t, "H100 (?P<x>=)?", got[1].Pattern.String(), "the name follows the last =")
+	assert.Equal(t, "H100", got[1].Name)
+
+	for _, value := range []string{"A100", "A100=", "=A100", "A100[=A100"} {
+		_, err = parseModelNameRules([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parsePowerZones(t *testing.T) {
+	got, err := parsePowerZones([]string{"GPU-1234=rack-a", "node2=rack-b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"GPU-1234": "rack-a", "node2": "rack-b"}, got)
+
+	for _, value := range []string{"GPU-1234", "GPU-1234=", "=rack-a"} {
+		_, err = parsePowerZones([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func Test_parseNodeGPUUtilBuckets(t *testing.T) {
+	got, err := parseNodeGPUUtilBuckets([]string{"10", " 50", "90.5"})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{10, 50, 90.5}, got)
+
+	for _, values := range [][]string{{"ten"}, {"+Inf"}, {"NaN"}, {"50", "10"}, {"10", "10"}} {
+		_, err = parseNodeGPUUtilBuckets(values)
+		assert.Error(t, err, values)
+	}
+}
+
+func Test_applyMappingSettingsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapping.conf")
+	require.NoError(t, os.WriteFile(path, []byte(`# reloaded on SIGHUP
+hpc-job-mapping-dir = /run/slurm-gpus
+
+hpc-mapping-file-pattern=job-*
+hpc-job-placeholder=idle
+hpc-sharing-attribute=true
+`), 0o644))
+
+	config := &appconfig.Config{HPCJobMappingDir: "/var/lib/gpus", HPCJobMappingNodeFile: "/etc/node-jobs"}
+	require.NoError(t, applyMappingSettingsFile(config, path))
+	assert.Equal(t, &appconfig.Config{
+		HPCJobMappingDir:      "/run/slurm-gpus",
+		HPCJobMappingNodeFile: "/etc/node-jobs",
+		HPCMappingFilePattern: "job-*",
+		HPCJobPlaceholder:     "idle",
+		HPCSharingAttribute:   true,
+	}, config)
+
+	for _, content := range []string{"hpc-job-mapping-dir", "hpc-mapping-file-pattern=[", "hpc-sharing-attribute=maybe", "hpc-job-mapping-socket=/run/jobs.sock"} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		assert.Error(t, applyMappingSettingsFile(&appconfig.Config{}, path), content)
+	}
+	assert.Error(t, applyMappingSettingsFile(&appconfig.Config{}, filepath.Join(t.TempDir(), "missing.conf")))
+}